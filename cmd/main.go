@@ -2,16 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"nuclei-service-demo/internal/config"
+	applog "nuclei-service-demo/internal/logger"
 	"nuclei-service-demo/internal/repository/postgres"
 	"nuclei-service-demo/internal/server"
 	"nuclei-service-demo/internal/service"
+	"nuclei-service-demo/internal/telemetry"
 
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
@@ -32,25 +36,72 @@ func main() {
 	if err != nil {
 		logger.Fatal("Failed to load configuration", zap.Error(err))
 	}
+	if err := cfg.Validate(); err != nil {
+		logger.Fatal("Invalid configuration", zap.Error(err))
+	}
+
+	// Switch to the configured log level
+	leveled, err := applog.NewLogger(cfg.Server.LogLevel)
+	if err != nil {
+		logger.Fatal("Failed to create logger", zap.Error(err))
+	}
+	logger.Sync()
+	logger = leveled
+	defer logger.Sync()
+	if cfg.Profile != "" {
+		logger.Info("Applied configuration profile", zap.String("profile", cfg.Profile))
+	}
+
+	// Export traces when an OTLP endpoint is configured
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg)
+	if err != nil {
+		logger.Fatal("Failed to set up tracing", zap.Error(err))
+	}
 
 	// Initialize database connection
-	db, err := postgres.NewConnection(cfg.DB)
+	db, err := postgres.ConnectWithRetry(cfg.DB, cfg.DB.ConnectAttempts,
+		time.Duration(cfg.DB.ConnectBackoffSeconds)*time.Second, logger)
 	if err != nil {
 		logger.Fatal("Failed to connect to database", zap.Error(err))
 	}
 	defer db.Close()
 
+	// Revert migrations when invoked as "migrate-down"
+	if len(os.Args) > 1 && os.Args[1] == "migrate-down" {
+		if err := postgres.RollbackMigrations(db, cfg.DB.MigrationsPath); err != nil {
+			logger.Fatal("Failed to revert migrations", zap.Error(err))
+		}
+		logger.Info("Migrations reverted")
+		return
+	}
+
+	// Apply migrations
+	if cfg.DB.MigrateUp {
+		if err := postgres.RunMigrations(db, cfg.DB.MigrationsPath); err != nil {
+			logger.Fatal("Failed to run migrations", zap.Error(err))
+		}
+		logger.Info("Migrations applied", zap.String("path", cfg.DB.MigrationsPath))
+	}
+
 	// Initialize repositories
 	scanRepo := postgres.NewScanRepository(db, cfg, logger)
 
 	// Initialize services
 	nucleiService := service.NewNucleiService(cfg, logger)
 
+	// Fail scans left running by a worker that stopped sending heartbeats,
+	// at startup before the worker picks up scans and then until shutdown
+	reaper := service.NewStaleScansReaper(scanRepo, cfg, logger)
+	if err := reaper.Run(context.Background()); err != nil {
+		logger.Error("Failed to reap stalled scans", zap.Error(err))
+	}
+
 	// Initialize and start scan worker
-	scanWorker := service.NewScanWorker(scanRepo, nucleiService, logger)
+	scanWorker := service.NewScanWorker(scanRepo, nucleiService, cfg, logger)
 	workerCtx, workerCancel := context.WithCancel(context.Background())
 	defer workerCancel()
 	go scanWorker.Start(workerCtx)
+	go reaper.Start(workerCtx)
 
 	// Load .env file if it exists
 	if err := godotenv.Load(); err != nil {
@@ -59,7 +110,7 @@ func main() {
 
 	// Create server
 	log.Printf("[%s] Initializing server...", time.Now().Format(time.RFC3339))
-	srv, err := server.New(cfg)
+	srv, err := server.New(cfg, db, nucleiService, scanWorker)
 	if err != nil {
 		log.Fatalf("[%s] Failed to create server: %v", time.Now().Format(time.RFC3339), err)
 	}
@@ -68,33 +119,60 @@ func main() {
 	go func() {
 		// Start server
 		log.Printf("[%s] Starting server on %s:%d...", time.Now().Format(time.RFC3339), cfg.Server.Host, cfg.Server.Port)
-		if err := srv.Start(); err != nil {
+		if err := srv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("[%s] Failed to start server: %v", time.Now().Format(time.RFC3339), err)
 		}
 	}()
 
-	go func() {
-		// Start demo server
+	// Create demo server when enabled; the API keeps running without it
+	var demoSrv *server.DemoServer
+	if cfg.Server.DemoEnabled {
 		log.Printf("[%s] Initializing Demo server...", time.Now().Format(time.RFC3339))
-		demoSrv, err := server.NewDemoServer(cfg)
+		demoSrv, err = server.NewDemoServer(cfg)
 		if err != nil {
-			log.Fatalf("[%s] Failed to create demo server: %v", time.Now().Format(time.RFC3339), err)
+			logger.Error("Failed to create demo server", zap.Error(err))
+		} else {
+			go func() {
+				// Start demo server
+				if err := demoSrv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					logger.Error("Demo server stopped", zap.Error(err))
+				}
+			}()
 		}
-		if err := demoSrv.Start(); err != nil {
-			log.Fatalf("[%s] Failed to start demo server: %v", time.Now().Format(time.RFC3339), err)
-		}
-	}()
+	} else {
+		logger.Info("Demo server disabled")
+	}
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// Drain scan worker, aborting any scans still running at the deadline
+	logger.Info("Stopping scan worker")
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer drainCancel()
+	if err := scanWorker.Stop(drainCtx); err != nil {
+		logger.Error("Scan worker did not drain in time", zap.Error(err))
+	}
+	workerCancel()
+
 	// Shutdown server
 	logger.Info("Shutting down server")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		logger.Fatal("Server forced to shutdown", zap.Error(err))
+	if demoSrv != nil {
+		if err := demoSrv.Shutdown(ctx); err != nil {
+			logger.Error("Demo server forced to shutdown", zap.Error(err))
+		}
+	}
+	shutdownErr := srv.Shutdown(ctx)
+
+	// Flush spans recorded before shutdown
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Failed to flush traces", zap.Error(err))
+	}
+	if shutdownErr != nil {
+		logger.Fatal("Server forced to shutdown", zap.Error(shutdownErr))
 	}
 }
@@ -1,72 +1,362 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
 )
 
 // DB represents the database configuration
 type DB struct {
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	User     string `json:"user"`
-	Password string `json:"password"`
-	Name     string `json:"name"`
+	Host           string `json:"host" yaml:"host"`
+	Port           int    `json:"port" yaml:"port"`
+	User           string `json:"user" yaml:"user"`
+	Password       string `json:"password" yaml:"password"`
+	Name           string `json:"name" yaml:"name"`
+	MigrationsPath string `json:"migrations_path" yaml:"migrations_path"`
+	MigrateUp      bool   `json:"migrate_up" yaml:"migrate_up"`
+
+	MaxOpenConns           int `json:"max_open_conns" yaml:"max_open_conns"`
+	MaxIdleConns           int `json:"max_idle_conns" yaml:"max_idle_conns"`
+	ConnMaxLifetimeSeconds int `json:"conn_max_lifetime_seconds" yaml:"conn_max_lifetime_seconds"`
+	ConnMaxIdleTimeSeconds int `json:"conn_max_idle_time_seconds" yaml:"conn_max_idle_time_seconds"`
+	QueryTimeoutSeconds    int `json:"query_timeout_seconds" yaml:"query_timeout_seconds"`
+	ConnectAttempts        int `json:"connect_attempts" yaml:"connect_attempts"`
+	ConnectBackoffSeconds  int `json:"connect_backoff_seconds" yaml:"connect_backoff_seconds"`
 }
 
 // Config represents the application configuration
 type Config struct {
 	Server struct {
-		Port        int    `json:"port"`
-		Host        string `json:"host"`
-		DemoPort    int    `json:"demo_port"`
-		DemoHost    string `json:"demo_host"`
-		DemoEnabled bool   `json:"demo_enabled"`
-	} `json:"server"`
-	DB     DB `json:"db"`
+		Port                int      `json:"port" yaml:"port"`
+		Host                string   `json:"host" yaml:"host"`
+		DemoPort            int      `json:"demo_port" yaml:"demo_port"`
+		DemoHost            string   `json:"demo_host" yaml:"demo_host"`
+		DemoEnabled         bool     `json:"demo_enabled" yaml:"demo_enabled"`
+		MaxRequestBodyBytes int64    `json:"max_request_body_bytes" yaml:"max_request_body_bytes"`
+		CORSAllowedOrigins  []string `json:"cors_allowed_origins" yaml:"cors_allowed_origins"`
+		SSRFAllowedHosts    []string `json:"ssrf_allowed_hosts" yaml:"ssrf_allowed_hosts"`
+		TLSCertFile         string   `json:"tls_cert_file" yaml:"tls_cert_file"`
+		TLSKeyFile          string   `json:"tls_key_file" yaml:"tls_key_file"`
+		TLSSelfSigned       bool     `json:"tls_self_signed" yaml:"tls_self_signed"`
+		APIKey              string   `json:"api_key" yaml:"api_key"`
+		ReadTimeoutSeconds  int      `json:"read_timeout_seconds" yaml:"read_timeout_seconds"`
+		WriteTimeoutSeconds int      `json:"write_timeout_seconds" yaml:"write_timeout_seconds"`
+		IdleTimeoutSeconds  int      `json:"idle_timeout_seconds" yaml:"idle_timeout_seconds"`
+		SilentPaths         []string `json:"silent_paths" yaml:"silent_paths"`
+		LogRequestBodies    bool     `json:"log_request_bodies" yaml:"log_request_bodies"`
+		LogBodyMaxBytes     int      `json:"log_body_max_bytes" yaml:"log_body_max_bytes"`
+		Debug               bool     `json:"debug" yaml:"debug"`
+		LogLevel            string   `json:"log_level" yaml:"log_level"`
+	} `json:"server" yaml:"server"`
+	DB     DB `json:"db" yaml:"db"`
 	Nuclei struct {
-		TemplatesDir    string `json:"templates_dir"`
-		Concurrency     int    `json:"concurrency"`
-		RateLimit       int    `json:"rate_limit"`
-		Timeout         int    `json:"timeout"`
-		Retries         int    `json:"retries"`
-		Headless        bool   `json:"headless"`
-		FollowRedirects bool   `json:"follow_redirects"`
-	} `json:"nuclei"`
+		TemplatesDir    string   `json:"templates_dir" yaml:"templates_dir"`
+		Concurrency     int      `json:"concurrency" yaml:"concurrency"`
+		RateLimit       int      `json:"rate_limit" yaml:"rate_limit"`
+		Timeout         int      `json:"timeout" yaml:"timeout"`
+		Retries         int      `json:"retries" yaml:"retries"`
+		Headless        bool     `json:"headless" yaml:"headless"`
+		FollowRedirects bool     `json:"follow_redirects" yaml:"follow_redirects"`
+		Proxy           string   `json:"proxy" yaml:"proxy"`
+		DNSResolvers    []string `json:"dns_resolvers" yaml:"dns_resolvers"`
+		UploadDir       string   `json:"upload_dir" yaml:"upload_dir"`
+		MaxTemplateSize int64    `json:"max_template_size" yaml:"max_template_size"`
+		MaxImportBytes  int64    `json:"max_import_bytes" yaml:"max_import_bytes"`
+
+		// MaxHTTPStorageBytes caps the stored request and response of each result
+		MaxHTTPStorageBytes int `json:"max_http_storage_bytes" yaml:"max_http_storage_bytes"`
+
+		Passive         bool   `json:"passive" yaml:"passive"`
+		PassiveInputDir string `json:"passive_input_dir" yaml:"passive_input_dir"`
+
+		GitTemplatesURL    string `json:"git_templates_url" yaml:"git_templates_url"`
+		GitTemplatesBranch string `json:"git_templates_branch" yaml:"git_templates_branch"`
+		GitTemplatesToken  string `json:"-" yaml:"git_templates_token"`
+
+		TemplateRefreshInterval time.Duration `json:"template_refresh_interval" yaml:"template_refresh_interval"`
+	} `json:"nuclei" yaml:"nuclei"`
+	Cache struct {
+		TemplateSize int `json:"template_size" yaml:"template_size"`
+		TemplateTTL  int `json:"template_ttl" yaml:"template_ttl"`
+	} `json:"cache" yaml:"cache"`
+	Worker struct {
+		CheckInterval     time.Duration `json:"check_interval" yaml:"check_interval"`
+		MaxConcurrency    int           `json:"max_concurrency" yaml:"max_concurrency"`
+		MaxQueueDepth     int           `json:"max_queue_depth" yaml:"max_queue_depth"`
+		HeartbeatInterval time.Duration `json:"heartbeat_interval" yaml:"heartbeat_interval"`
+	} `json:"worker" yaml:"worker"`
+	Telemetry struct {
+		OTLPEndpoint string `json:"otlp_endpoint" yaml:"otlp_endpoint"`
+	} `json:"telemetry" yaml:"telemetry"`
+
+	// Profile is the configuration profile applied from the profiles file, if any
+	Profile string `json:"profile,omitempty" yaml:"-"`
 }
 
-// Load loads the configuration from environment variables
+// Load loads the configuration from environment variables, falling back to
+// the profile named by NUCLEI_PROFILE for variables that are not set, then to
+// the values set in the configuration file, then to built-in defaults
 func Load() (*Config, error) {
-	cfg := &Config{}
+	cfg := defaultConfig()
+
+	// Read configuration file; CONFIG_FILE must exist when set
+	if path := getEnv("CONFIG_FILE", ""); path != "" {
+		if err := loadFromFile(path, cfg); err != nil {
+			return nil, err
+		}
+	} else if _, err := os.Stat(defaultConfigFile); err == nil {
+		if err := loadFromFile(defaultConfigFile, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Apply configuration profile
+	profile := getEnv("NUCLEI_PROFILE", "")
+	if profile != "" {
+		path := getEnv("NUCLEI_PROFILES_FILE", "")
+		if path == "" {
+			return nil, errors.New("NUCLEI_PROFILE requires NUCLEI_PROFILES_FILE")
+		}
+		if err := loadProfile(path, profile, cfg); err != nil {
+			return nil, err
+		}
+		cfg.Profile = profile
+	}
 
 	// Server configuration
-	cfg.Server.Port = getEnvAsInt("SERVER_PORT", 3742)
-	cfg.Server.Host = getEnv("SERVER_HOST", "localhost")
+	cfg.Server.Port = getEnvAsInt("SERVER_PORT", cfg.Server.Port)
+	cfg.Server.Host = getEnv("SERVER_HOST", cfg.Server.Host)
+	cfg.Server.MaxRequestBodyBytes = getEnvAsInt64("MAX_REQUEST_BODY_BYTES", cfg.Server.MaxRequestBodyBytes)
+	cfg.Server.CORSAllowedOrigins = getEnvAsSlice("CORS_ALLOWED_ORIGINS", cfg.Server.CORSAllowedOrigins)
+	cfg.Server.TLSCertFile = getEnv("TLS_CERT_FILE", cfg.Server.TLSCertFile)
+	cfg.Server.TLSKeyFile = getEnv("TLS_KEY_FILE", cfg.Server.TLSKeyFile)
+	cfg.Server.TLSSelfSigned = getEnvAsBool("TLS_SELF_SIGNED", cfg.Server.TLSSelfSigned)
+	cfg.Server.APIKey = getEnv("API_KEY", cfg.Server.APIKey)
+	cfg.Server.ReadTimeoutSeconds = getEnvAsInt("SERVER_READ_TIMEOUT", cfg.Server.ReadTimeoutSeconds)
+	cfg.Server.WriteTimeoutSeconds = getEnvAsInt("SERVER_WRITE_TIMEOUT", cfg.Server.WriteTimeoutSeconds)
+	cfg.Server.IdleTimeoutSeconds = getEnvAsInt("SERVER_IDLE_TIMEOUT", cfg.Server.IdleTimeoutSeconds)
+	cfg.Server.SilentPaths = getEnvAsSlice("SILENT_PATHS", cfg.Server.SilentPaths)
+	cfg.Server.LogRequestBodies = getEnvAsBool("LOG_REQUEST_BODIES", cfg.Server.LogRequestBodies)
+	cfg.Server.Debug = getEnvAsBool("DEBUG", cfg.Server.Debug)
+	cfg.Server.LogLevel = getEnv("LOG_LEVEL", cfg.Server.LogLevel)
+	if cfg.Server.LogLevel == "" {
+		// DEBUG implies debug logging unless LOG_LEVEL says otherwise
+		cfg.Server.LogLevel = "info"
+		if cfg.Server.Debug {
+			cfg.Server.LogLevel = "debug"
+		}
+	}
+	cfg.Server.LogBodyMaxBytes = getEnvAsInt("LOG_BODY_MAX_BYTES", cfg.Server.LogBodyMaxBytes)
+	if cfg.Server.LogBodyMaxBytes <= 0 {
+		// Debugging logs more of each body unless LOG_BODY_MAX_BYTES says otherwise
+		cfg.Server.LogBodyMaxBytes = 1 << 10
+		if cfg.Server.Debug {
+			cfg.Server.LogBodyMaxBytes = 64 << 10
+		}
+	}
 
 	// Database configuration
-	cfg.DB.Host = getEnv("DB_HOST", "nuclei-postgres")
-	cfg.DB.Port = getEnvAsInt("DB_PORT", 15432)
-	cfg.DB.User = getEnv("DB_USER", "postgres")
-	cfg.DB.Password = getEnv("DB_PASSWORD", "postgres")
-	cfg.DB.Name = getEnv("DB_NAME", "nuclei")
+	cfg.DB.Host = getEnv("DB_HOST", cfg.DB.Host)
+	cfg.DB.Port = getEnvAsInt("DB_PORT", cfg.DB.Port)
+	cfg.DB.User = getEnv("DB_USER", cfg.DB.User)
+	cfg.DB.Password = getEnv("DB_PASSWORD", cfg.DB.Password)
+	cfg.DB.Name = getEnv("DB_NAME", cfg.DB.Name)
+	cfg.DB.MigrationsPath = getEnv("MIGRATIONS_PATH", cfg.DB.MigrationsPath)
+	cfg.DB.MigrateUp = getEnvAsBool("MIGRATE_UP", cfg.DB.MigrateUp)
+	cfg.DB.MaxOpenConns = getEnvAsInt("DB_MAX_OPEN_CONNS", cfg.DB.MaxOpenConns)
+	cfg.DB.MaxIdleConns = getEnvAsInt("DB_MAX_IDLE_CONNS", cfg.DB.MaxIdleConns)
+	cfg.DB.ConnMaxLifetimeSeconds = getEnvAsInt("DB_CONN_MAX_LIFETIME", cfg.DB.ConnMaxLifetimeSeconds)
+	cfg.DB.ConnMaxIdleTimeSeconds = getEnvAsInt("DB_CONN_MAX_IDLE_TIME", cfg.DB.ConnMaxIdleTimeSeconds)
+	cfg.DB.QueryTimeoutSeconds = getEnvAsInt("DB_QUERY_TIMEOUT", cfg.DB.QueryTimeoutSeconds)
+	cfg.DB.ConnectAttempts = getEnvAsInt("DB_CONNECT_ATTEMPTS", cfg.DB.ConnectAttempts)
+	cfg.DB.ConnectBackoffSeconds = getEnvAsInt("DB_CONNECT_BACKOFF", cfg.DB.ConnectBackoffSeconds)
 
 	// Demo configuration
-	cfg.Server.DemoPort = getEnvAsInt("DEMO_PORT", 3743)
-	cfg.Server.DemoHost = getEnv("DEMO_HOST", "localhost")
-	cfg.Server.DemoEnabled = getEnvAsBool("DEMO_ENABLED", true)
+	cfg.Server.DemoPort = getEnvAsInt("DEMO_PORT", cfg.Server.DemoPort)
+	cfg.Server.DemoHost = getEnv("DEMO_HOST", cfg.Server.DemoHost)
+	cfg.Server.DemoEnabled = getEnvAsBool("DEMO_ENABLED", cfg.Server.DemoEnabled)
+	cfg.Server.SSRFAllowedHosts = getEnvAsSlice("DEMO_SSRF_ALLOWED_HOSTS", cfg.Server.SSRFAllowedHosts)
 
 	// Nuclei configuration
-	cfg.Nuclei.TemplatesDir = getEnv("NUCLEI_TEMPLATES_DIR", "./templates")
-	cfg.Nuclei.Concurrency = getEnvAsInt("NUCLEI_CONCURRENCY", 10)
-	cfg.Nuclei.RateLimit = getEnvAsInt("NUCLEI_RATE_LIMIT", 100)
-	cfg.Nuclei.Timeout = getEnvAsInt("NUCLEI_TIMEOUT", 30)
-	cfg.Nuclei.Retries = getEnvAsInt("NUCLEI_RETRIES", 3)
-	cfg.Nuclei.Headless = getEnvAsBool("NUCLEI_HEADLESS", false)
-	cfg.Nuclei.FollowRedirects = getEnvAsBool("NUCLEI_FOLLOW_REDIRECTS", true)
+	cfg.Nuclei.TemplatesDir = getEnv("NUCLEI_TEMPLATES_DIR", cfg.Nuclei.TemplatesDir)
+	cfg.Nuclei.Concurrency = getEnvAsInt("NUCLEI_CONCURRENCY", cfg.Nuclei.Concurrency)
+	cfg.Nuclei.RateLimit = getEnvAsInt("NUCLEI_RATE_LIMIT", cfg.Nuclei.RateLimit)
+	cfg.Nuclei.Timeout = getEnvAsInt("NUCLEI_TIMEOUT", cfg.Nuclei.Timeout)
+	cfg.Nuclei.Retries = getEnvAsInt("NUCLEI_RETRIES", cfg.Nuclei.Retries)
+	cfg.Nuclei.Headless = getEnvAsBool("NUCLEI_HEADLESS", cfg.Nuclei.Headless)
+	cfg.Nuclei.FollowRedirects = getEnvAsBool("NUCLEI_FOLLOW_REDIRECTS", cfg.Nuclei.FollowRedirects)
+	cfg.Nuclei.Proxy = getEnv("NUCLEI_PROXY", cfg.Nuclei.Proxy)
+	cfg.Nuclei.DNSResolvers = getEnvAsSlice("NUCLEI_DNS_RESOLVERS", cfg.Nuclei.DNSResolvers)
+	cfg.Nuclei.UploadDir = getEnv("NUCLEI_UPLOAD_DIR", cfg.Nuclei.UploadDir)
+	cfg.Nuclei.MaxTemplateSize = getEnvAsInt64("NUCLEI_MAX_TEMPLATE_SIZE", cfg.Nuclei.MaxTemplateSize)
+	cfg.Nuclei.MaxImportBytes = getEnvAsInt64("NUCLEI_MAX_IMPORT_BYTES", cfg.Nuclei.MaxImportBytes)
+	cfg.Nuclei.MaxHTTPStorageBytes = getEnvAsInt("NUCLEI_MAX_HTTP_STORAGE_BYTES", cfg.Nuclei.MaxHTTPStorageBytes)
+	cfg.Nuclei.Passive = getEnvAsBool("NUCLEI_PASSIVE", cfg.Nuclei.Passive)
+	cfg.Nuclei.PassiveInputDir = getEnv("NUCLEI_PASSIVE_INPUT_DIR", cfg.Nuclei.PassiveInputDir)
+	cfg.Nuclei.GitTemplatesURL = getEnv("NUCLEI_GIT_TEMPLATES_URL", cfg.Nuclei.GitTemplatesURL)
+	cfg.Nuclei.GitTemplatesBranch = getEnv("NUCLEI_GIT_TEMPLATES_BRANCH", cfg.Nuclei.GitTemplatesBranch)
+	cfg.Nuclei.GitTemplatesToken = getEnv("NUCLEI_GIT_TEMPLATES_TOKEN", cfg.Nuclei.GitTemplatesToken)
+	cfg.Nuclei.TemplateRefreshInterval = getEnvAsDuration("TEMPLATE_REFRESH_INTERVAL", cfg.Nuclei.TemplateRefreshInterval)
+
+	// Cache configuration
+	cfg.Cache.TemplateSize = getEnvAsInt("TEMPLATE_CACHE_SIZE", cfg.Cache.TemplateSize)
+	cfg.Cache.TemplateTTL = getEnvAsInt("TEMPLATE_CACHE_TTL", cfg.Cache.TemplateTTL)
+
+	// Worker configuration
+	cfg.Worker.CheckInterval = getEnvAsDuration("WORKER_CHECK_INTERVAL", cfg.Worker.CheckInterval)
+	cfg.Worker.MaxConcurrency = getEnvAsInt("WORKER_MAX_CONCURRENCY", cfg.Worker.MaxConcurrency)
+	cfg.Worker.MaxQueueDepth = getEnvAsInt("WORKER_MAX_QUEUE_DEPTH", cfg.Worker.MaxQueueDepth)
+	cfg.Worker.HeartbeatInterval = getEnvAsDuration("WORKER_HEARTBEAT_INTERVAL", cfg.Worker.HeartbeatInterval)
+
+	// Telemetry configuration
+	cfg.Telemetry.OTLPEndpoint = getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", cfg.Telemetry.OTLPEndpoint)
 
 	return cfg, nil
 }
 
+// defaultConfig returns the built-in defaults, which the configuration file,
+// profile and environment variables are layered over
+func defaultConfig() *Config {
+	cfg := &Config{}
+
+	// Server configuration
+	cfg.Server.Port = 3742
+	cfg.Server.Host = "localhost"
+	cfg.Server.MaxRequestBodyBytes = 10 << 20
+	cfg.Server.ReadTimeoutSeconds = 15
+	cfg.Server.WriteTimeoutSeconds = 15
+	cfg.Server.IdleTimeoutSeconds = 60
+
+	// Database configuration
+	cfg.DB.Host = "nuclei-postgres"
+	cfg.DB.Port = 15432
+	cfg.DB.User = "postgres"
+	cfg.DB.Password = "postgres"
+	cfg.DB.Name = "nuclei"
+	cfg.DB.MigrationsPath = "./migrations"
+	cfg.DB.MigrateUp = true
+	cfg.DB.MaxOpenConns = 25
+	cfg.DB.MaxIdleConns = 25
+	cfg.DB.ConnMaxLifetimeSeconds = 300
+	cfg.DB.ConnMaxIdleTimeSeconds = 300
+	cfg.DB.QueryTimeoutSeconds = 30
+	cfg.DB.ConnectAttempts = 10
+	cfg.DB.ConnectBackoffSeconds = 1
+
+	// Demo configuration
+	cfg.Server.DemoPort = 3743
+	cfg.Server.DemoHost = "localhost"
+	cfg.Server.DemoEnabled = true
+
+	// Nuclei configuration
+	cfg.Nuclei.TemplatesDir = "./templates"
+	cfg.Nuclei.Concurrency = 10
+	cfg.Nuclei.RateLimit = 100
+	cfg.Nuclei.Timeout = 30
+	cfg.Nuclei.Retries = 3
+	cfg.Nuclei.FollowRedirects = true
+	cfg.Nuclei.UploadDir = "./templates/custom"
+	cfg.Nuclei.MaxTemplateSize = 1 << 20
+	cfg.Nuclei.MaxImportBytes = 10 << 20
+	cfg.Nuclei.MaxHTTPStorageBytes = 64 << 10
+	cfg.Nuclei.PassiveInputDir = "./passive"
+	cfg.Nuclei.TemplateRefreshInterval = 24 * time.Hour
+
+	// Cache configuration
+	cfg.Cache.TemplateSize = 1000
+	cfg.Cache.TemplateTTL = 300
+
+	// Worker configuration
+	cfg.Worker.CheckInterval = 20 * time.Second
+	cfg.Worker.MaxConcurrency = 1
+	cfg.Worker.MaxQueueDepth = 100
+	cfg.Worker.HeartbeatInterval = 30 * time.Second
+
+	return cfg
+}
+
+// loadProfile overlays the named profile from the profiles file at path onto
+// cfg. The file maps profile names to configurations in the same format as
+// the configuration file; only the keys a profile sets are applied:
+//
+//	production:
+//	  server:
+//	    demo_enabled: false
+//	  nuclei:
+//	    rate_limit: 50
+func loadProfile(path, name string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read profiles file: %w", err)
+	}
+
+	// Keep each profile as YAML until it is chosen, so it can be decoded onto
+	// cfg key by key rather than replacing it with a zero-valued Config
+	var profiles map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("failed to parse profiles file %s: %w", path, err)
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %q not found in %s", name, path)
+	}
+
+	raw, err := yaml.Marshal(&profile)
+	if err != nil {
+		return fmt.Errorf("failed to read profile %q: %w", name, err)
+	}
+	if err := decodeConfig(raw, cfg); err != nil {
+		return fmt.Errorf("failed to parse profile %q in %s: %w", name, path, err)
+	}
+	return nil
+}
+
+// Validate checks the configuration and returns every problem found, joined
+// into a single error
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("SERVER_PORT must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if c.Server.ReadTimeoutSeconds <= 0 || c.Server.WriteTimeoutSeconds <= 0 || c.Server.IdleTimeoutSeconds <= 0 {
+		errs = append(errs, errors.New("server timeouts must be positive"))
+	} else if c.Server.WriteTimeoutSeconds < c.Server.ReadTimeoutSeconds {
+		errs = append(errs, fmt.Errorf("SERVER_WRITE_TIMEOUT (%ds) must be at least SERVER_READ_TIMEOUT (%ds)",
+			c.Server.WriteTimeoutSeconds, c.Server.ReadTimeoutSeconds))
+	}
+	if _, err := zapcore.ParseLevel(c.Server.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn, error, dpanic, panic or fatal, got %q", c.Server.LogLevel))
+	}
+	if c.DB.Password == "" {
+		errs = append(errs, errors.New("DB_PASSWORD must not be empty"))
+	}
+	if c.Nuclei.TemplatesDir == "" {
+		errs = append(errs, errors.New("NUCLEI_TEMPLATES_DIR must not be empty"))
+	}
+	if c.Nuclei.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("NUCLEI_CONCURRENCY must be at least 1, got %d", c.Nuclei.Concurrency))
+	}
+	if c.Nuclei.Timeout < 1 {
+		errs = append(errs, fmt.Errorf("NUCLEI_TIMEOUT must be at least 1, got %d", c.Nuclei.Timeout))
+	}
+	for _, resolver := range c.Nuclei.DNSResolvers {
+		if _, _, err := net.SplitHostPort(resolver); err != nil {
+			errs = append(errs, fmt.Errorf("NUCLEI_DNS_RESOLVERS entry %q must be host:port", resolver))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // getEnv gets an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -85,6 +375,30 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsInt64 gets an environment variable as a 64-bit integer or returns a default value
+func getEnvAsInt64(key string, defaultValue int64) int64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsSlice gets a comma-separated environment variable as a slice or returns a default value
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	if value, exists := os.LookupEnv(key); exists {
+		var values []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		return values
+	}
+	return defaultValue
+}
+
 // getEnvAsBool gets an environment variable as a boolean or returns a default value
 func getEnvAsBool(key string, defaultValue bool) bool {
 	if value, exists := os.LookupEnv(key); exists {
@@ -94,3 +408,13 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// getEnvAsDuration gets an environment variable as a duration (e.g. "20s") or returns a default value
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if durationValue, err := time.ParseDuration(value); err == nil {
+			return durationValue
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns a configuration that passes Validate
+func validConfig() *Config {
+	cfg := &Config{}
+	cfg.Server.Port = 3742
+	cfg.Server.ReadTimeoutSeconds = 15
+	cfg.Server.WriteTimeoutSeconds = 15
+	cfg.Server.IdleTimeoutSeconds = 60
+	cfg.Server.LogLevel = "info"
+	cfg.DB.Password = "postgres"
+	cfg.Nuclei.TemplatesDir = "./templates"
+	cfg.Nuclei.Concurrency = 10
+	cfg.Nuclei.Timeout = 30
+	return cfg
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"port at minimum", func(c *Config) { c.Server.Port = 1 }, ""},
+		{"port at maximum", func(c *Config) { c.Server.Port = 65535 }, ""},
+		{"port zero", func(c *Config) { c.Server.Port = 0 }, "SERVER_PORT"},
+		{"port above maximum", func(c *Config) { c.Server.Port = 65536 }, "SERVER_PORT"},
+		{"read timeout zero", func(c *Config) { c.Server.ReadTimeoutSeconds = 0 }, "timeouts must be positive"},
+		{"write timeout negative", func(c *Config) { c.Server.WriteTimeoutSeconds = -1 }, "timeouts must be positive"},
+		{"idle timeout zero", func(c *Config) { c.Server.IdleTimeoutSeconds = 0 }, "timeouts must be positive"},
+		{"write timeout equal to read timeout", func(c *Config) { c.Server.WriteTimeoutSeconds = 15 }, ""},
+		{"write timeout below read timeout", func(c *Config) { c.Server.WriteTimeoutSeconds = 14 }, "SERVER_WRITE_TIMEOUT"},
+		{"log level debug", func(c *Config) { c.Server.LogLevel = "debug" }, ""},
+		{"log level unknown", func(c *Config) { c.Server.LogLevel = "verbose" }, "LOG_LEVEL"},
+		{"log level empty means info", func(c *Config) { c.Server.LogLevel = "" }, ""},
+		{"empty DB password", func(c *Config) { c.DB.Password = "" }, "DB_PASSWORD"},
+		{"empty templates dir", func(c *Config) { c.Nuclei.TemplatesDir = "" }, "NUCLEI_TEMPLATES_DIR"},
+		{"concurrency at minimum", func(c *Config) { c.Nuclei.Concurrency = 1 }, ""},
+		{"concurrency zero", func(c *Config) { c.Nuclei.Concurrency = 0 }, "NUCLEI_CONCURRENCY"},
+		{"timeout at minimum", func(c *Config) { c.Nuclei.Timeout = 1 }, ""},
+		{"timeout zero", func(c *Config) { c.Nuclei.Timeout = 0 }, "NUCLEI_TIMEOUT"},
+		{"resolvers", func(c *Config) { c.Nuclei.DNSResolvers = []string{"1.1.1.1:53", "[::1]:5353"} }, ""},
+		{"resolver without port", func(c *Config) { c.Nuclei.DNSResolvers = []string{"1.1.1.1"} }, "NUCLEI_DNS_RESOLVERS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigValidateReportsEveryProblem(t *testing.T) {
+	cfg := validConfig()
+	cfg.Server.Port = 0
+	cfg.DB.Password = ""
+	cfg.Nuclei.Concurrency = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want an error")
+	}
+	for _, want := range []string{"SERVER_PORT", "DB_PASSWORD", "NUCLEI_CONCURRENCY"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want it to mention %q", err, want)
+		}
+	}
+}
+
+// loadWithEnv sets env for the duration of the test and loads the configuration
+func loadWithEnv(t *testing.T, env map[string]string) *Config {
+	t.Helper()
+
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return cfg
+}
+
+func TestLoadLogLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		logLevel string
+		debug    string
+		want     string
+		wantErr  bool
+	}{
+		{"default", "", "", "info", false},
+		{"debug", "debug", "", "debug", false},
+		{"warn", "warn", "", "warn", false},
+		{"upper case", "ERROR", "", "ERROR", false},
+		{"debug mode", "", "true", "debug", false},
+		{"LOG_LEVEL wins over debug mode", "warn", "true", "warn", false},
+		{"invalid", "verbose", "", "verbose", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadWithEnv(t, map[string]string{"LOG_LEVEL": tt.logLevel, "DEBUG": tt.debug})
+
+			if cfg.Server.LogLevel != tt.want {
+				t.Errorf("LogLevel = %q, want %q", cfg.Server.LogLevel, tt.want)
+			}
+			err := cfg.Validate()
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "LOG_LEVEL") {
+					t.Errorf("Validate() = %v, want a LOG_LEVEL error", err)
+				}
+			} else if err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestLoadWorkerSettings(t *testing.T) {
+	tests := []struct {
+		name            string
+		env             map[string]string
+		wantInterval    time.Duration
+		wantConcurrency int
+	}{
+		{"defaults", nil, 20 * time.Second, 1},
+		{"from environment", map[string]string{"WORKER_CHECK_INTERVAL": "1ms", "WORKER_MAX_CONCURRENCY": "4"}, time.Millisecond, 4},
+		{"invalid values keep defaults", map[string]string{"WORKER_CHECK_INTERVAL": "20", "WORKER_MAX_CONCURRENCY": "many"}, 20 * time.Second, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadWithEnv(t, tt.env)
+
+			if cfg.Worker.CheckInterval != tt.wantInterval {
+				t.Errorf("CheckInterval = %v, want %v", cfg.Worker.CheckInterval, tt.wantInterval)
+			}
+			if cfg.Worker.MaxConcurrency != tt.wantConcurrency {
+				t.Errorf("MaxConcurrency = %d, want %d", cfg.Worker.MaxConcurrency, tt.wantConcurrency)
+			}
+		})
+	}
+}
+
+func TestLoadServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name                          string
+		env                           map[string]string
+		wantRead, wantWrite, wantIdle int
+	}{
+		{"defaults", nil, 15, 15, 60},
+		{"from environment", map[string]string{"SERVER_READ_TIMEOUT": "30", "SERVER_WRITE_TIMEOUT": "300", "SERVER_IDLE_TIMEOUT": "120"}, 30, 300, 120},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadWithEnv(t, tt.env)
+
+			got := [3]int{cfg.Server.ReadTimeoutSeconds, cfg.Server.WriteTimeoutSeconds, cfg.Server.IdleTimeoutSeconds}
+			if want := [3]int{tt.wantRead, tt.wantWrite, tt.wantIdle}; got != want {
+				t.Errorf("read, write and idle timeouts = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadRequestLogging(t *testing.T) {
+	cfg := loadWithEnv(t, nil)
+	if cfg.Server.LogRequestBodies || cfg.Server.LogBodyMaxBytes != 1<<10 {
+		t.Errorf("default LogRequestBodies = %v with cap %d, want disabled with a 1 KB cap", cfg.Server.LogRequestBodies, cfg.Server.LogBodyMaxBytes)
+	}
+
+	cfg = loadWithEnv(t, map[string]string{"SILENT_PATHS": "/health,/metrics", "LOG_REQUEST_BODIES": "true"})
+	if want := []string{"/health", "/metrics"}; !slices.Equal(cfg.Server.SilentPaths, want) {
+		t.Errorf("SilentPaths = %v, want %v", cfg.Server.SilentPaths, want)
+	}
+	if !cfg.Server.LogRequestBodies {
+		t.Error("LogRequestBodies = false, want true from LOG_REQUEST_BODIES")
+	}
+}
+
+func TestLoadPassiveMode(t *testing.T) {
+	cfg := loadWithEnv(t, nil)
+	if cfg.Nuclei.Passive || cfg.Nuclei.PassiveInputDir != "./passive" {
+		t.Errorf("default passive %v with input dir %q, want off with ./passive", cfg.Nuclei.Passive, cfg.Nuclei.PassiveInputDir)
+	}
+
+	cfg = loadWithEnv(t, map[string]string{"NUCLEI_PASSIVE": "true", "NUCLEI_PASSIVE_INPUT_DIR": "/data/passive"})
+	if !cfg.Nuclei.Passive || cfg.Nuclei.PassiveInputDir != "/data/passive" {
+		t.Errorf("passive %v with input dir %q, want on with /data/passive", cfg.Nuclei.Passive, cfg.Nuclei.PassiveInputDir)
+	}
+}
+
+func TestLoadDebug(t *testing.T) {
+	cfg := loadWithEnv(t, map[string]string{"DEBUG": "true"})
+	if !cfg.Server.Debug || cfg.Server.LogLevel != "debug" || cfg.Server.LogBodyMaxBytes != 64<<10 {
+		t.Errorf("debug %v, log level %q and body cap %d, want debug logging of 64 KB bodies", cfg.Server.Debug, cfg.Server.LogLevel, cfg.Server.LogBodyMaxBytes)
+	}
+
+	cfg = loadWithEnv(t, map[string]string{"DEBUG": "true", "LOG_LEVEL": "warn", "LOG_BODY_MAX_BYTES": "512"})
+	if cfg.Server.LogLevel != "warn" || cfg.Server.LogBodyMaxBytes != 512 {
+		t.Errorf("log level %q and body cap %d, want the explicit warn and 512", cfg.Server.LogLevel, cfg.Server.LogBodyMaxBytes)
+	}
+}
+
+func TestLoadDNSResolvers(t *testing.T) {
+	cfg := loadWithEnv(t, map[string]string{"NUCLEI_DNS_RESOLVERS": "1.1.1.1:53, 8.8.8.8:53"})
+	if want := []string{"1.1.1.1:53", "8.8.8.8:53"}; !slices.Equal(cfg.Nuclei.DNSResolvers, want) {
+		t.Errorf("DNSResolvers = %v, want %v", cfg.Nuclei.DNSResolvers, want)
+	}
+}
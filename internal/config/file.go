@@ -0,0 +1,42 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is read when CONFIG_FILE is not set, if it exists
+const defaultConfigFile = "./config.yaml"
+
+// loadFromFile reads the YAML configuration file at path into cfg. Keys are
+// the configuration's JSON field names (e.g. server.port, worker.check_interval)
+// and unknown keys are rejected so typos do not go unnoticed. Only the keys
+// present in the file are set, so explicit zero values such as false or 0s
+// replace the defaults already in cfg
+func loadFromFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := decodeConfig(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// decodeConfig decodes YAML onto cfg, setting only the keys present in data
+// and rejecting unknown keys. Empty input leaves cfg unchanged
+func decodeConfig(data []byte, cfg *Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes content to a config file in a temporary directory
+// and returns its path
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFileProvidesDefaults(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  port: 8080
+  cors_allowed_origins: [https://app.example.com]
+db:
+  host: db.internal
+nuclei:
+  rate_limit: 50
+worker:
+  check_interval: 10s
+`)
+	cfg := loadWithEnv(t, map[string]string{"CONFIG_FILE": path})
+
+	if cfg.Server.Port != 8080 {
+		t.Errorf("Server.Port = %d, want 8080", cfg.Server.Port)
+	}
+	if len(cfg.Server.CORSAllowedOrigins) != 1 || cfg.Server.CORSAllowedOrigins[0] != "https://app.example.com" {
+		t.Errorf("Server.CORSAllowedOrigins = %v, want [https://app.example.com]", cfg.Server.CORSAllowedOrigins)
+	}
+	if cfg.DB.Host != "db.internal" {
+		t.Errorf("DB.Host = %q, want db.internal", cfg.DB.Host)
+	}
+	if cfg.Nuclei.RateLimit != 50 {
+		t.Errorf("Nuclei.RateLimit = %d, want 50", cfg.Nuclei.RateLimit)
+	}
+	if cfg.Worker.CheckInterval != 10*time.Second {
+		t.Errorf("Worker.CheckInterval = %v, want 10s", cfg.Worker.CheckInterval)
+	}
+
+	// Keys missing from the file keep the built-in defaults
+	if cfg.Nuclei.Concurrency != 10 {
+		t.Errorf("Nuclei.Concurrency = %d, want the default 10", cfg.Nuclei.Concurrency)
+	}
+	if !cfg.DB.MigrateUp {
+		t.Error("DB.MigrateUp = false, want the default true")
+	}
+}
+
+func TestLoadConfigFileSetsZeroValues(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  demo_enabled: false
+db:
+  migrate_up: false
+nuclei:
+  follow_redirects: false
+  retries: 0
+  template_refresh_interval: 0s
+`)
+	cfg := loadWithEnv(t, map[string]string{"CONFIG_FILE": path})
+
+	if cfg.Server.DemoEnabled {
+		t.Error("Server.DemoEnabled = true, want false from the file")
+	}
+	if cfg.DB.MigrateUp {
+		t.Error("DB.MigrateUp = true, want false from the file")
+	}
+	if cfg.Nuclei.FollowRedirects {
+		t.Error("Nuclei.FollowRedirects = true, want false from the file")
+	}
+	if cfg.Nuclei.Retries != 0 {
+		t.Errorf("Nuclei.Retries = %d, want 0 from the file", cfg.Nuclei.Retries)
+	}
+	if cfg.Nuclei.TemplateRefreshInterval != 0 {
+		t.Errorf("Nuclei.TemplateRefreshInterval = %v, want 0 from the file", cfg.Nuclei.TemplateRefreshInterval)
+	}
+}
+
+func TestLoadEnvOverridesConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  port: 8080
+  demo_enabled: false
+nuclei:
+  rate_limit: 50
+worker:
+  check_interval: 10s
+`)
+	cfg := loadWithEnv(t, map[string]string{
+		"CONFIG_FILE":           path,
+		"SERVER_PORT":           "9090",
+		"DEMO_ENABLED":          "true",
+		"NUCLEI_RATE_LIMIT":     "75",
+		"WORKER_CHECK_INTERVAL": "1m",
+	})
+
+	if cfg.Server.Port != 9090 {
+		t.Errorf("Server.Port = %d, want 9090 from SERVER_PORT", cfg.Server.Port)
+	}
+	if !cfg.Server.DemoEnabled {
+		t.Error("Server.DemoEnabled = false, want true from DEMO_ENABLED")
+	}
+	if cfg.Nuclei.RateLimit != 75 {
+		t.Errorf("Nuclei.RateLimit = %d, want 75 from NUCLEI_RATE_LIMIT", cfg.Nuclei.RateLimit)
+	}
+	if cfg.Worker.CheckInterval != time.Minute {
+		t.Errorf("Worker.CheckInterval = %v, want 1m from WORKER_CHECK_INTERVAL", cfg.Worker.CheckInterval)
+	}
+}
+
+func TestLoadConfigFileRejectsUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, "server:\n  prot: 8080\n")
+	t.Setenv("CONFIG_FILE", path)
+
+	if _, err := Load(); err == nil {
+		t.Error("Load() error = nil, want an error for the unknown key")
+	}
+}
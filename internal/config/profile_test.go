@@ -0,0 +1,134 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// profilesFile is the profiles file shipped with the service
+const profilesFile = "../../profiles.yaml"
+
+func TestLoadProfiles(t *testing.T) {
+	tests := []struct {
+		profile string
+		check   func(t *testing.T, cfg *Config)
+	}{
+		{"development", func(t *testing.T, cfg *Config) {
+			if !cfg.Server.Debug || !cfg.Server.LogRequestBodies || !cfg.Server.DemoEnabled {
+				t.Errorf("Debug, LogRequestBodies, DemoEnabled = %v, %v, %v, want all true",
+					cfg.Server.Debug, cfg.Server.LogRequestBodies, cfg.Server.DemoEnabled)
+			}
+			if cfg.Server.LogLevel != "debug" {
+				t.Errorf("LogLevel = %q, want debug", cfg.Server.LogLevel)
+			}
+			if cfg.Nuclei.RateLimit != 20 || cfg.Nuclei.Concurrency != 5 {
+				t.Errorf("RateLimit, Concurrency = %d, %d, want 20, 5", cfg.Nuclei.RateLimit, cfg.Nuclei.Concurrency)
+			}
+			if cfg.Worker.CheckInterval != 5*time.Second {
+				t.Errorf("CheckInterval = %v, want 5s", cfg.Worker.CheckInterval)
+			}
+		}},
+		{"staging", func(t *testing.T, cfg *Config) {
+			if cfg.Server.Debug || cfg.Server.DemoEnabled {
+				t.Errorf("Debug, DemoEnabled = %v, %v, want false, false", cfg.Server.Debug, cfg.Server.DemoEnabled)
+			}
+			if cfg.Nuclei.RateLimit != 100 || cfg.Nuclei.Concurrency != 10 {
+				t.Errorf("RateLimit, Concurrency = %d, %d, want 100, 10", cfg.Nuclei.RateLimit, cfg.Nuclei.Concurrency)
+			}
+			if cfg.Worker.MaxConcurrency != 2 || cfg.Worker.MaxQueueDepth != 100 {
+				t.Errorf("MaxConcurrency, MaxQueueDepth = %d, %d, want 2, 100", cfg.Worker.MaxConcurrency, cfg.Worker.MaxQueueDepth)
+			}
+		}},
+		{"production", func(t *testing.T, cfg *Config) {
+			if cfg.Server.Debug || cfg.Server.LogRequestBodies || cfg.Server.DemoEnabled || cfg.Server.TLSSelfSigned {
+				t.Errorf("Debug, LogRequestBodies, DemoEnabled, TLSSelfSigned = %v, %v, %v, %v, want all false",
+					cfg.Server.Debug, cfg.Server.LogRequestBodies, cfg.Server.DemoEnabled, cfg.Server.TLSSelfSigned)
+			}
+			if cfg.Server.MaxRequestBodyBytes != 5<<20 {
+				t.Errorf("MaxRequestBodyBytes = %d, want %d", cfg.Server.MaxRequestBodyBytes, 5<<20)
+			}
+			if cfg.Nuclei.RateLimit != 50 {
+				t.Errorf("RateLimit = %d, want 50", cfg.Nuclei.RateLimit)
+			}
+			if cfg.Worker.MaxConcurrency != 4 || cfg.Worker.MaxQueueDepth != 50 {
+				t.Errorf("MaxConcurrency, MaxQueueDepth = %d, %d, want 4, 50", cfg.Worker.MaxConcurrency, cfg.Worker.MaxQueueDepth)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			cfg := loadWithEnv(t, map[string]string{"NUCLEI_PROFILE": tt.profile, "NUCLEI_PROFILES_FILE": profilesFile})
+
+			if cfg.Profile != tt.profile {
+				t.Errorf("Profile = %q, want %q", cfg.Profile, tt.profile)
+			}
+			tt.check(t, cfg)
+
+			// Settings the profile leaves out keep their defaults
+			if cfg.Nuclei.Timeout != 30 || cfg.DB.Host != "nuclei-postgres" {
+				t.Errorf("Timeout, DB.Host = %d, %q, want the defaults 30, nuclei-postgres", cfg.Nuclei.Timeout, cfg.DB.Host)
+			}
+		})
+	}
+}
+
+func TestLoadProfileEnvWins(t *testing.T) {
+	cfg := loadWithEnv(t, map[string]string{
+		"NUCLEI_PROFILE":       "production",
+		"NUCLEI_PROFILES_FILE": profilesFile,
+		"NUCLEI_RATE_LIMIT":    "200",
+		"DEMO_ENABLED":         "true",
+	})
+
+	if cfg.Nuclei.RateLimit != 200 {
+		t.Errorf("RateLimit = %d, want 200 from NUCLEI_RATE_LIMIT", cfg.Nuclei.RateLimit)
+	}
+	if !cfg.Server.DemoEnabled {
+		t.Error("DemoEnabled = false, want true from DEMO_ENABLED")
+	}
+	if cfg.Worker.MaxQueueDepth != 50 {
+		t.Errorf("MaxQueueDepth = %d, want 50 from the profile", cfg.Worker.MaxQueueDepth)
+	}
+}
+
+func TestLoadProfileOverridesConfigFile(t *testing.T) {
+	path := writeConfigFile(t, "server:\n  demo_enabled: true\n  port: 8080\nnuclei:\n  rate_limit: 500\n")
+	cfg := loadWithEnv(t, map[string]string{
+		"CONFIG_FILE":          path,
+		"NUCLEI_PROFILE":       "production",
+		"NUCLEI_PROFILES_FILE": profilesFile,
+	})
+
+	if cfg.Server.DemoEnabled {
+		t.Error("DemoEnabled = true, want false from the profile")
+	}
+	if cfg.Nuclei.RateLimit != 50 {
+		t.Errorf("RateLimit = %d, want 50 from the profile", cfg.Nuclei.RateLimit)
+	}
+	if cfg.Server.Port != 8080 {
+		t.Errorf("Port = %d, want 8080 from the config file", cfg.Server.Port)
+	}
+}
+
+func TestLoadProfileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"unknown profile", map[string]string{"NUCLEI_PROFILE": "qa", "NUCLEI_PROFILES_FILE": profilesFile}},
+		{"missing profiles file", map[string]string{"NUCLEI_PROFILE": "production"}},
+		{"unknown key", map[string]string{"NUCLEI_PROFILE": "qa", "NUCLEI_PROFILES_FILE": writeConfigFile(t, "qa:\n  nuclei:\n    rate_limt: 5\n")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if _, err := Load(); err == nil {
+				t.Error("Load() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package errors
+
+import (
+	"database/sql"
+	stderrors "errors"
+
+	"github.com/lib/pq"
+)
+
+// Error codes identifying each kind of Error
+const (
+	CodeNotFound      = "not_found"
+	CodeConflict      = "conflict"
+	CodeInvalidInput  = "invalid_input"
+	CodeQueueFull     = "queue_full"
+	CodeScanRunning   = "scan_running"
+	CodeStatusChanged = "status_changed"
+	CodeExportFailed  = "export_failed"
+)
+
+// Error is an error of a known kind, identified by Code. Message is safe to
+// show to API clients; Err, if set, is the underlying cause
+type Error struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+// Typed sentinel errors. errors.Is matches any *Error with the same code, so
+// an error carrying its own message still matches its sentinel
+var (
+	// ErrNotFound is returned when a requested record does not exist
+	ErrNotFound = &Error{Code: CodeNotFound, Message: "not found"}
+	// ErrConflict is returned when a write violates a uniqueness constraint
+	ErrConflict = &Error{Code: CodeConflict, Message: "already exists"}
+	// ErrInvalidInput is returned when input is rejected by validation or by
+	// a database constraint
+	ErrInvalidInput = &Error{Code: CodeInvalidInput, Message: "invalid input"}
+	// ErrQueueFull is returned when too many scans are already pending
+	ErrQueueFull = &Error{Code: CodeQueueFull, Message: "scan queue is full"}
+	// ErrScanRunning is returned when an operation requires a scan that is not running
+	ErrScanRunning = &Error{Code: CodeScanRunning, Message: "scan is running"}
+	// ErrStatusChanged is returned when a status update finds the record no
+	// longer in the status it was expected to move from
+	ErrStatusChanged = &Error{Code: CodeStatusChanged, Message: "status changed"}
+)
+
+// Error returns the message, followed by the cause if there is one
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error with the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Postgres error codes mapped by WrapPostgresError
+const (
+	pqUniqueViolation           = "23505"
+	pqForeignKeyViolation       = "23503"
+	pqNotNullViolation          = "23502"
+	pqCheckViolation            = "23514"
+	pqInvalidTextRepresentation = "22P02"
+	pqStringDataRightTruncation = "22001"
+)
+
+// WrapPostgresError maps sql.ErrNoRows and constraint and input errors from
+// Postgres to typed errors wrapping the original. Any other error, including
+// one that is already typed, is returned unchanged
+func WrapPostgresError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pqErr *pq.Error
+	if !stderrors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case pqUniqueViolation:
+		return &Error{Code: CodeConflict, Message: "a record with the same values already exists", Err: err}
+	case pqForeignKeyViolation:
+		return &Error{Code: CodeInvalidInput, Message: "references a record that does not exist", Err: err}
+	case pqNotNullViolation, pqCheckViolation, pqInvalidTextRepresentation, pqStringDataRightTruncation:
+		return &Error{Code: CodeInvalidInput, Message: "invalid value: " + pqErr.Message, Err: err}
+	}
+	return err
+}
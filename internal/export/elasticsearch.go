@@ -0,0 +1,163 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	apperrors "nuclei-service-demo/internal/errors"
+	"nuclei-service-demo/internal/model"
+)
+
+var (
+	// ErrInvalidOptions is returned when export options fail validation
+	ErrInvalidOptions = &apperrors.Error{Code: apperrors.CodeInvalidInput, Message: "invalid export options"}
+	// ErrExportFailed is returned when the export destination rejects the export
+	ErrExportFailed = &apperrors.Error{Code: apperrors.CodeExportFailed, Message: "export failed"}
+)
+
+// defaultTimeout bounds an export request when no client is given
+const defaultTimeout = 30 * time.Second
+
+// maxBulkErrorBytes caps how much of a failed bulk response is reported
+const maxBulkErrorBytes = 1 << 10
+
+// ESExportOptions configures an export to Elasticsearch
+type ESExportOptions struct {
+	// URL is the cluster's base URL, e.g. http://es:9200
+	URL string `json:"url"`
+	// Index receives the documents
+	Index string `json:"index"`
+	// APIKey is sent as "Authorization: ApiKey <key>" when set
+	APIKey string `json:"api_key,omitempty"`
+
+	// Client sends the request; nil uses a client with defaultTimeout
+	Client *http.Client `json:"-"`
+}
+
+// Validate checks that the options name a usable cluster and index
+func (o ESExportOptions) Validate() error {
+	parsed, err := url.Parse(o.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidOptions)
+	}
+	if o.Index == "" {
+		return fmt.Errorf("%w: index is required", ErrInvalidOptions)
+	}
+	if strings.ContainsAny(o.Index, `/\*?"<>| ,#`) || o.Index != strings.ToLower(o.Index) {
+		return fmt.Errorf("%w: index must be a lowercase Elasticsearch index name", ErrInvalidOptions)
+	}
+	return nil
+}
+
+// esDocument is a scan result as indexed in Elasticsearch
+type esDocument struct {
+	*model.ScanResult
+	Timestamp time.Time `json:"@timestamp"`
+}
+
+// esBulkResponse is the part of a _bulk response needed to count indexed documents
+type esBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// ExportToElasticsearch indexes results with a single gzip-compressed _bulk
+// request, using each result's ID as the document ID so repeated exports
+// overwrite rather than duplicate. It returns the number of documents indexed
+func ExportToElasticsearch(ctx context.Context, results []*model.ScanResult, opts ESExportOptions) (int, error) {
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	body, err := bulkBody(results, opts.Index)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(opts.URL, "/")+"/_bulk", body)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	if opts.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+opts.APIKey)
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrExportFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxBulkErrorBytes))
+		return 0, fmt.Errorf("%w: elasticsearch returned %s: %s", ErrExportFailed, resp.Status, strings.TrimSpace(string(detail)))
+	}
+
+	var bulk esBulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&bulk); err != nil {
+		return 0, fmt.Errorf("%w: failed to decode bulk response: %v", ErrExportFailed, err)
+	}
+
+	// Count documents per item, keeping the first failure for the error
+	indexed := 0
+	var firstErr string
+	for _, item := range bulk.Items {
+		for _, action := range item {
+			if action.Status >= 200 && action.Status < 300 {
+				indexed++
+			} else if firstErr == "" && action.Error != nil {
+				firstErr = action.Error.Type + ": " + action.Error.Reason
+			}
+		}
+	}
+	if bulk.Errors || indexed < len(results) {
+		return indexed, fmt.Errorf("%w: %d of %d documents were not indexed: %s", ErrExportFailed, len(results)-indexed, len(results), firstErr)
+	}
+
+	return indexed, nil
+}
+
+// bulkBody encodes results as a gzip-compressed _bulk request body
+func bulkBody(results []*model.ScanResult, index string) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+
+	for _, result := range results {
+		action := map[string]map[string]string{"index": {"_index": index, "_id": result.ID}}
+		if err := enc.Encode(action); err != nil {
+			return nil, fmt.Errorf("failed to encode bulk action: %w", err)
+		}
+		if err := enc.Encode(esDocument{ScanResult: result, Timestamp: result.MatchedAt}); err != nil {
+			return nil, fmt.Errorf("failed to encode scan result: %w", err)
+		}
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress bulk request: %w", err)
+	}
+	return &buf, nil
+}
@@ -0,0 +1,182 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nuclei-service-demo/internal/model"
+)
+
+// bulkRequest is a _bulk request as received by the fake cluster
+type bulkRequest struct {
+	auth    string
+	actions []map[string]map[string]string
+	docs    []map[string]interface{}
+}
+
+// newBulkServer returns a fake Elasticsearch cluster that records _bulk
+// requests and answers each document with status
+func newBulkServer(t *testing.T, status int, requests *[]bulkRequest) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/_bulk" {
+			t.Errorf("request %s %s, want POST /_bulk", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Content-Encoding"); got != "gzip" {
+			t.Errorf("Content-Encoding = %q, want gzip", got)
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("request body is not gzip: %v", err)
+			return
+		}
+
+		req := bulkRequest{auth: r.Header.Get("Authorization")}
+		lines := bufio.NewScanner(gz)
+		lines.Buffer(nil, 1<<20)
+		for i := 0; lines.Scan(); i++ {
+			if i%2 == 0 {
+				var action map[string]map[string]string
+				if err := json.Unmarshal(lines.Bytes(), &action); err != nil {
+					t.Errorf("bulk action line %d: %v", i, err)
+				}
+				req.actions = append(req.actions, action)
+				continue
+			}
+			var doc map[string]interface{}
+			if err := json.Unmarshal(lines.Bytes(), &doc); err != nil {
+				t.Errorf("bulk document line %d: %v", i, err)
+			}
+			req.docs = append(req.docs, doc)
+		}
+		*requests = append(*requests, req)
+
+		items := make([]string, len(req.actions))
+		for i := range items {
+			if status == http.StatusCreated {
+				items[i] = fmt.Sprintf(`{"index": {"status": %d}}`, status)
+			} else {
+				items[i] = fmt.Sprintf(`{"index": {"status": %d, "error": {"type": "mapper_parsing_exception", "reason": "bad field"}}}`, status)
+			}
+		}
+		fmt.Fprintf(w, `{"errors": %v, "items": [%s]}`, status != http.StatusCreated, strings.Join(items, ","))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// testResults returns two scan results to export
+func testResults() []*model.ScanResult {
+	matchedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return []*model.ScanResult{
+		{ID: "result-1", ScanID: "scan-1", TemplateID: "cve-2021-1234", Severity: "critical", Host: "http://example.com", MatchedAt: matchedAt},
+		{ID: "result-2", ScanID: "scan-1", TemplateID: "tech-detect", Severity: "info", Host: "http://example.com", MatchedAt: matchedAt},
+	}
+}
+
+func TestExportToElasticsearch(t *testing.T) {
+	var requests []bulkRequest
+	server := newBulkServer(t, http.StatusCreated, &requests)
+
+	indexed, err := ExportToElasticsearch(context.Background(), testResults(), ESExportOptions{
+		URL:    server.URL,
+		Index:  "nuclei-results",
+		APIKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("ExportToElasticsearch() error = %v", err)
+	}
+	if indexed != 2 {
+		t.Errorf("indexed = %d, want 2", indexed)
+	}
+
+	if len(requests) != 1 {
+		t.Fatalf("received %d bulk requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.auth != "ApiKey secret" {
+		t.Errorf("Authorization = %q, want %q", req.auth, "ApiKey secret")
+	}
+	if len(req.actions) != 2 || len(req.docs) != 2 {
+		t.Fatalf("received %d actions and %d documents, want 2 of each", len(req.actions), len(req.docs))
+	}
+	for i, result := range testResults() {
+		action := req.actions[i]["index"]
+		if action["_index"] != "nuclei-results" || action["_id"] != result.ID {
+			t.Errorf("action %d = %v, want index nuclei-results and id %s", i, action, result.ID)
+		}
+		doc := req.docs[i]
+		if doc["template_id"] != result.TemplateID || doc["severity"] != result.Severity || doc["scan_id"] != result.ScanID {
+			t.Errorf("document %d = %v, want the fields of %s", i, doc, result.ID)
+		}
+		if doc["@timestamp"] != "2024-05-01T12:00:00Z" {
+			t.Errorf("document %d @timestamp = %v, want the match time", i, doc["@timestamp"])
+		}
+	}
+}
+
+func TestExportToElasticsearchReportsRejectedDocuments(t *testing.T) {
+	var requests []bulkRequest
+	server := newBulkServer(t, http.StatusBadRequest, &requests)
+
+	indexed, err := ExportToElasticsearch(context.Background(), testResults(), ESExportOptions{URL: server.URL, Index: "nuclei-results"})
+	if !errors.Is(err, ErrExportFailed) {
+		t.Fatalf("ExportToElasticsearch() error = %v, want ErrExportFailed", err)
+	}
+	if !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Errorf("error = %v, want it to include the bulk item error", err)
+	}
+	if indexed != 0 {
+		t.Errorf("indexed = %d, want 0", indexed)
+	}
+}
+
+func TestExportToElasticsearchClusterError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "security_exception"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := ExportToElasticsearch(context.Background(), testResults(), ESExportOptions{URL: server.URL, Index: "nuclei-results"})
+	if !errors.Is(err, ErrExportFailed) || !strings.Contains(err.Error(), "401") {
+		t.Errorf("ExportToElasticsearch() error = %v, want ErrExportFailed with the 401 status", err)
+	}
+}
+
+func TestESExportOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    ESExportOptions
+		wantErr bool
+	}{
+		{"valid", ESExportOptions{URL: "http://es:9200", Index: "nuclei-results"}, false},
+		{"https", ESExportOptions{URL: "https://es.example.com", Index: "nuclei"}, false},
+		{"missing url", ESExportOptions{Index: "nuclei-results"}, true},
+		{"non-http url", ESExportOptions{URL: "ftp://es:9200", Index: "nuclei-results"}, true},
+		{"missing index", ESExportOptions{URL: "http://es:9200"}, true},
+		{"upper case index", ESExportOptions{URL: "http://es:9200", Index: "Nuclei"}, true},
+		{"index with path", ESExportOptions{URL: "http://es:9200", Index: "nuclei/_doc"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("Validate() = %v, want it to wrap ErrInvalidOptions", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package logger
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewLogger creates a production logger writing at level (debug, info, warn,
+// error, dpanic, panic or fatal). An empty level means info
+func NewLogger(level string) (*zap.Logger, error) {
+	logConfig := zap.NewProductionConfig()
+	if level != "" {
+		parsed, err := zapcore.ParseLevel(level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+		}
+		logConfig.Level = zap.NewAtomicLevelAt(parsed)
+	}
+
+	logger, err := logConfig.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+	return logger, nil
+}
@@ -0,0 +1,42 @@
+package logger
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestNewLoggerLevel(t *testing.T) {
+	tests := []struct {
+		level     string
+		wantDebug bool
+		wantInfo  bool
+	}{
+		{"", false, true},
+		{"info", false, true},
+		{"debug", true, true},
+		{"warn", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			logger, err := NewLogger(tt.level)
+			if err != nil {
+				t.Fatalf("NewLogger(%q) error = %v", tt.level, err)
+			}
+
+			if got := logger.Check(zap.DebugLevel, "debug message") != nil; got != tt.wantDebug {
+				t.Errorf("debug messages written = %v, want %v", got, tt.wantDebug)
+			}
+			if got := logger.Check(zap.InfoLevel, "info message") != nil; got != tt.wantInfo {
+				t.Errorf("info messages written = %v, want %v", got, tt.wantInfo)
+			}
+		})
+	}
+}
+
+func TestNewLoggerRejectsInvalidLevel(t *testing.T) {
+	if _, err := NewLogger("verbose"); err == nil {
+		t.Error("NewLogger(\"verbose\") error = nil, want an error")
+	}
+}
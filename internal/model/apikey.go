@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+)
+
+// API key permissions
+const (
+	// PermissionRead allows GET requests
+	PermissionRead = "read"
+	// PermissionWrite allows requests that create, change or delete
+	// resources, and implies PermissionRead
+	PermissionWrite = "write"
+	// PermissionAdmin allows managing API keys
+	PermissionAdmin = "admin"
+)
+
+// APIKey represents a credential for the API. Only the SHA-256 hash of the
+// key is stored; the plaintext is returned once, when the key is created
+type APIKey struct {
+	ID          string     `json:"id"`
+	Key         string     `json:"-"`
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// HasPermission reports whether the key grants the permission. Admin keys
+// grant every permission and write keys also grant read
+func (k *APIKey) HasPermission(permission string) bool {
+	for _, p := range k.Permissions {
+		switch {
+		case p == permission, p == PermissionAdmin:
+			return true
+		case p == PermissionWrite && permission == PermissionRead:
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the key has expired at the given time
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// CreateAPIKeyInput represents the input for creating an API key
+type CreateAPIKeyInput struct {
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey is a newly created API key together with its plaintext key
+type CreatedAPIKey struct {
+	*APIKey
+	PlaintextKey string `json:"key"`
+}
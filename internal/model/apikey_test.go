@@ -0,0 +1,35 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestAPIKeyHasPermission(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []string
+		want        map[string]bool
+	}{
+		{"read", []string{PermissionRead},
+			map[string]bool{PermissionRead: true, PermissionWrite: false, PermissionAdmin: false}},
+		{"write implies read", []string{PermissionWrite},
+			map[string]bool{PermissionRead: true, PermissionWrite: true, PermissionAdmin: false}},
+		{"read and write", []string{PermissionRead, PermissionWrite},
+			map[string]bool{PermissionRead: true, PermissionWrite: true, PermissionAdmin: false}},
+		{"admin", []string{PermissionAdmin},
+			map[string]bool{PermissionRead: true, PermissionWrite: true, PermissionAdmin: true}},
+		{"none", nil,
+			map[string]bool{PermissionRead: false, PermissionWrite: false, PermissionAdmin: false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &APIKey{Permissions: tt.permissions}
+			for permission, want := range tt.want {
+				if got := key.HasPermission(permission); got != want {
+					t.Errorf("HasPermission(%q) = %v, want %v", permission, got, want)
+				}
+			}
+		})
+	}
+}
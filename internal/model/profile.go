@@ -0,0 +1,87 @@
+package model
+
+import (
+	"time"
+)
+
+// ScanProfile represents a named, reusable set of scan options
+type ScanProfile struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Options     ScanOptions `json:"options"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// ScanProfileInput represents the input for creating or replacing a scan profile
+type ScanProfileInput struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Options     ScanOptions `json:"options"`
+}
+
+// MergeScanOptions returns the profile options overridden by every non-zero
+// field of explicit. Boolean options can be enabled but not disabled by
+// explicit, except follow_redirects, which explicit sets whenever it is
+// given. Explicit headers and cookies replace those of the same name
+func MergeScanOptions(profile ScanOptions, explicit *ScanOptions) *ScanOptions {
+	merged := profile
+	merged.CustomHeaders = mergeStringMaps(profile.CustomHeaders, nil)
+	merged.CustomCookies = mergeStringMaps(profile.CustomCookies, nil)
+	if explicit == nil {
+		return &merged
+	}
+
+	if explicit.Concurrency != 0 {
+		merged.Concurrency = explicit.Concurrency
+	}
+	if explicit.RateLimit != 0 {
+		merged.RateLimit = explicit.RateLimit
+	}
+	if explicit.Timeout != 0 {
+		merged.Timeout = explicit.Timeout
+	}
+	if explicit.Retries != 0 {
+		merged.Retries = explicit.Retries
+	}
+	if explicit.Proxy != "" {
+		merged.Proxy = explicit.Proxy
+	}
+	if explicit.PassiveInput != "" {
+		merged.PassiveInput = explicit.PassiveInput
+	}
+	if explicit.FollowRedirects != nil {
+		merged.FollowRedirects = explicit.FollowRedirects
+	}
+	if explicit.TLSSkipVerify != nil {
+		merged.TLSSkipVerify = explicit.TLSSkipVerify
+	}
+	if len(explicit.DNSResolvers) > 0 {
+		merged.DNSResolvers = explicit.DNSResolvers
+	}
+	merged.Headless = merged.Headless || explicit.Headless
+	merged.DryRun = merged.DryRun || explicit.DryRun
+	merged.Passive = merged.Passive || explicit.Passive
+	merged.Verbose = merged.Verbose || explicit.Verbose
+	merged.CustomHeaders = mergeStringMaps(merged.CustomHeaders, explicit.CustomHeaders)
+	merged.CustomCookies = mergeStringMaps(merged.CustomCookies, explicit.CustomCookies)
+
+	return &merged
+}
+
+// mergeStringMaps returns a new map with the entries of base and override,
+// override winning on conflicts; nil if both are empty
+func mergeStringMaps(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeScanOptions(t *testing.T) {
+	follow, noFollow := true, false
+	profile := ScanOptions{
+		Concurrency:     10,
+		RateLimit:       50,
+		Timeout:         600,
+		Retries:         2,
+		Proxy:           "http://proxy:3128",
+		FollowRedirects: &follow,
+		Headless:        true,
+		CustomHeaders:   map[string]string{"X-Tenant": "acme", "X-Scan": "profile"},
+		CustomCookies:   map[string]string{"session": "profile"},
+	}
+
+	tests := []struct {
+		name     string
+		explicit *ScanOptions
+		want     ScanOptions
+	}{
+		{name: "no explicit options", explicit: nil, want: profile},
+		{name: "zero explicit options", explicit: &ScanOptions{}, want: profile},
+		{
+			name:     "explicit fields win",
+			explicit: &ScanOptions{Concurrency: 100, Proxy: "socks5://127.0.0.1:1080", Verbose: true},
+			want: func() ScanOptions {
+				o := profile
+				o.Concurrency, o.Proxy, o.Verbose = 100, "socks5://127.0.0.1:1080", true
+				return o
+			}(),
+		},
+		{
+			name:     "follow redirects disabled explicitly",
+			explicit: &ScanOptions{FollowRedirects: &noFollow},
+			want: func() ScanOptions {
+				o := profile
+				o.FollowRedirects = &noFollow
+				return o
+			}(),
+		},
+		{
+			name: "headers and cookies merged by name",
+			explicit: &ScanOptions{
+				CustomHeaders: map[string]string{"X-Scan": "explicit"},
+				CustomCookies: map[string]string{"theme": "dark"},
+			},
+			want: func() ScanOptions {
+				o := profile
+				o.CustomHeaders = map[string]string{"X-Tenant": "acme", "X-Scan": "explicit"}
+				o.CustomCookies = map[string]string{"session": "profile", "theme": "dark"}
+				return o
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MergeScanOptions(profile, tt.explicit)
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("MergeScanOptions() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	// Merging must not change the stored profile
+	MergeScanOptions(profile, &ScanOptions{CustomHeaders: map[string]string{"X-Scan": "explicit"}})
+	if profile.CustomHeaders["X-Scan"] != "profile" {
+		t.Errorf("profile header X-Scan = %q after a merge, want it unchanged", profile.CustomHeaders["X-Scan"])
+	}
+}
@@ -1,9 +1,19 @@
 package model
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+
+	apperrors "nuclei-service-demo/internal/errors"
 )
 
 // ScanStatus represents the status of a scan
@@ -20,31 +30,252 @@ const (
 	ScanStatusFailed = "failed"
 	// ScanStatusCancelled indicates a scan has been cancelled
 	ScanStatusCancelled = "cancelled"
+	// ScanStatusDryRun marks a dry-run scan, which is never stored or executed
+	ScanStatusDryRun = "dry_run"
+)
+
+const (
+	// ScanPriorityLow is for background scans that can wait
+	ScanPriorityLow = 0
+	// ScanPriorityNormal is the priority of scans that do not set one
+	ScanPriorityNormal = 1
+	// ScanPriorityHigh is for scans that should start before all others
+	ScanPriorityHigh = 2
+)
+
+// ScanOrder selects the order scans are listed in
+type ScanOrder int
+
+const (
+	// ScanOrderNewest lists the most recently created scans first
+	ScanOrderNewest ScanOrder = iota
+	// ScanOrderPriority lists the highest-priority scans first, oldest first
+	// within a priority, which is the order pending scans are run in
+	ScanOrderPriority
 )
 
+// validTransitions lists the statuses each status may move to
+var validTransitions = map[ScanStatus][]ScanStatus{
+	ScanStatusPending: {ScanStatusRunning, ScanStatusCancelled},
+	ScanStatusRunning: {ScanStatusCompleted, ScanStatusFailed, ScanStatusCancelled},
+}
+
+// InvalidTransitionError is returned when a scan status change is not allowed
+type InvalidTransitionError struct {
+	From ScanStatus
+	To   ScanStatus
+}
+
+// Error implements the error interface
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("invalid scan status transition from %q to %q", e.From, e.To)
+}
+
+// ValidateTransition returns an *InvalidTransitionError if a scan may not move from one status to another
+func ValidateTransition(from, to ScanStatus) error {
+	for _, allowed := range validTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return &InvalidTransitionError{From: from, To: to}
+}
+
 // Scan represents a nuclei scan
 type Scan struct {
-	ID          string       `json:"id" db:"id"`
-	Target      string       `json:"target" db:"target"`
-	Status      string       `json:"status" db:"status"`
-	TemplateIDs []string     `json:"template_ids" db:"template_ids"`
-	Tags        []string     `json:"tags" db:"tags"`
-	Options     *ScanOptions `json:"options" db:"options"`
-	Error       string       `json:"error,omitempty" db:"error"`
-	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
-	StartedAt   *time.Time   `json:"started_at,omitempty" db:"started_at"`
-	CompletedAt *time.Time   `json:"completed_at,omitempty" db:"completed_at"`
-	Results     []ScanResult `json:"results,omitempty" db:"-"`
-}
-
-// ScanOptions represents the options for a scan
+	ID            string            `json:"id" db:"id"`
+	Target        string            `json:"target" db:"target"`
+	Targets       []string          `json:"targets" db:"targets"`
+	Status        string            `json:"status" db:"status"`
+	TemplateIDs   []string          `json:"template_ids" db:"template_ids"`
+	WorkflowIDs   []string          `json:"workflow_ids" db:"workflow_ids"`
+	Tags          []string          `json:"tags" db:"tags"`
+	Labels        map[string]string `json:"labels,omitempty" db:"labels"`
+	Priority      int               `json:"priority" db:"priority"`
+	Options       *ScanOptions      `json:"options" db:"options"`
+	Error         string            `json:"error,omitempty" db:"error"`
+	ResultSummary *ResultSummary    `json:"result_summary,omitempty" db:"result_summary"`
+	CreatedAt     time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at" db:"updated_at"`
+	StartedAt     *time.Time        `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt   *time.Time        `json:"completed_at,omitempty" db:"completed_at"`
+	Results       []ScanResult      `json:"results,omitempty" db:"-"`
+
+	// VerboseLog is the nuclei output captured by a verbose scan; it is
+	// served separately from the scan
+	VerboseLog string `json:"-" db:"verbose_log"`
+}
+
+// ScanOptions represents the options for a scan. TLSSkipVerify acknowledges
+// that a target's certificate is untrusted; the nuclei engine never verifies
+// certificates, so only true is accepted
 type ScanOptions struct {
-	Concurrency int  `json:"concurrency"`
-	RateLimit   int  `json:"rate_limit"`
-	Timeout     int  `json:"timeout"`
-	Retries     int  `json:"retries"`
-	Headless    bool `json:"headless"`
+	Concurrency   int    `json:"concurrency"`
+	RateLimit     int    `json:"rate_limit"`
+	Timeout       int    `json:"timeout"`
+	Retries       int    `json:"retries"`
+	Headless      bool   `json:"headless"`
+	Proxy         string `json:"proxy,omitempty"`
+	TLSSkipVerify *bool  `json:"tls_skip_verify,omitempty"`
+	DryRun        bool   `json:"dry_run"`
+	Passive       bool   `json:"passive"`
+	PassiveInput  string `json:"passive_input,omitempty"`
+
+	// FollowRedirects overrides NUCLEI_FOLLOW_REDIRECTS for the scan when set
+	FollowRedirects *bool `json:"follow_redirects,omitempty"`
+
+	// Verbose captures nuclei's verbose output, stored as the scan's log
+	Verbose bool `json:"verbose"`
+
+	// DNSResolvers are host:port DNS servers used instead of the defaults
+	DNSResolvers []string `json:"dns_resolvers,omitempty"`
+
+	CustomHeaders map[string]string `json:"custom_headers,omitempty"`
+	CustomCookies map[string]string `json:"custom_cookies,omitempty"`
+}
+
+// Common errors. They are typed apperrors values, so handlers report them
+// through their error code
+var (
+	// ErrInvalidScanOptions is returned when scan options are out of range
+	ErrInvalidScanOptions = &apperrors.Error{Code: apperrors.CodeInvalidInput, Message: "invalid scan options"}
+	// ErrQueueFull is returned when too many scans are already pending; it is
+	// the typed apperrors.ErrQueueFull
+	ErrQueueFull = apperrors.ErrQueueFull
+	// ErrInvalidPriority is returned when a scan priority is out of range
+	ErrInvalidPriority = &apperrors.Error{Code: apperrors.CodeInvalidInput, Message: "invalid scan priority"}
+	// ErrInvalidLabels is returned when scan labels are empty or malformed
+	ErrInvalidLabels = &apperrors.Error{Code: apperrors.CodeInvalidInput, Message: "invalid scan labels"}
+)
+
+// maxLabelLength is the maximum length of a scan label key or value
+const maxLabelLength = 256
+
+// ValidatePriority checks that priority is low, normal or high
+func ValidatePriority(priority int) error {
+	if priority < ScanPriorityLow || priority > ScanPriorityHigh {
+		return fmt.Errorf("%w: priority must be 0 (low), 1 (normal) or 2 (high)", ErrInvalidPriority)
+	}
+	return nil
+}
+
+// ValidateLabels checks that labels sets at least one label, that every key
+// is non-empty and that no key or value is longer than maxLabelLength
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) == 0 {
+		return fmt.Errorf("%w: at least one label is required", ErrInvalidLabels)
+	}
+	for key, value := range labels {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("%w: label keys must not be empty", ErrInvalidLabels)
+		}
+		if len(key) > maxLabelLength || len(value) > maxLabelLength {
+			return fmt.Errorf("%w: label %q is longer than %d characters", ErrInvalidLabels, key, maxLabelLength)
+		}
+	}
+	return nil
+}
+
+// ApplyDefaults sets concurrency, rate limit and timeout left at zero, which
+// is what omitting them gives, to the given defaults
+func (o *ScanOptions) ApplyDefaults(concurrency, rateLimit, timeout int) {
+	if o.Concurrency == 0 {
+		o.Concurrency = concurrency
+	}
+	if o.RateLimit == 0 {
+		o.RateLimit = rateLimit
+	}
+	if o.Timeout == 0 {
+		o.Timeout = timeout
+	}
+}
+
+// Validate checks that the scan options are within supported ranges. Apply
+// the configured defaults first so omitted options are not rejected
+func (o *ScanOptions) Validate() error {
+	if o.Concurrency < 1 || o.Concurrency > 500 {
+		return fmt.Errorf("%w: concurrency must be between 1 and 500", ErrInvalidScanOptions)
+	}
+	if o.RateLimit < 1 || o.RateLimit > 10000 {
+		return fmt.Errorf("%w: rate_limit must be between 1 and 10000", ErrInvalidScanOptions)
+	}
+	if o.Timeout < 1 || o.Timeout > 3600 {
+		return fmt.Errorf("%w: timeout must be between 1 and 3600", ErrInvalidScanOptions)
+	}
+	if o.Retries < 0 || o.Retries > 10 {
+		return fmt.Errorf("%w: retries must be between 0 and 10", ErrInvalidScanOptions)
+	}
+	if o.TLSSkipVerify != nil && !*o.TLSSkipVerify {
+		return fmt.Errorf("%w: tls_skip_verify cannot be false, the nuclei engine never verifies certificates", ErrInvalidScanOptions)
+	}
+	if o.Passive && o.PassiveInput == "" {
+		return fmt.Errorf("%w: passive_input is required in passive mode", ErrInvalidScanOptions)
+	}
+	if o.PassiveInput != "" && !filepath.IsLocal(o.PassiveInput) {
+		return fmt.Errorf("%w: passive_input must be a relative path inside the passive input directory", ErrInvalidScanOptions)
+	}
+	for _, resolver := range o.DNSResolvers {
+		if !validResolver(resolver) {
+			return fmt.Errorf("%w: dns resolver %q must be host:port", ErrInvalidScanOptions, resolver)
+		}
+	}
+	for name, value := range o.CustomHeaders {
+		if name == "" || strings.ContainsAny(name, "\r\n:") || strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%w: invalid custom header %q", ErrInvalidScanOptions, name)
+		}
+	}
+	for name, value := range o.CustomCookies {
+		if name == "" || strings.ContainsAny(name, "\r\n;= ") || strings.ContainsAny(value, "\r\n;") {
+			return fmt.Errorf("%w: invalid custom cookie %q", ErrInvalidScanOptions, name)
+		}
+	}
+	return nil
+}
+
+// validResolver reports whether resolver is a host:port address with a port in range
+func validResolver(resolver string) bool {
+	host, port, err := net.SplitHostPort(resolver)
+	if err != nil || host == "" || strings.ContainsAny(host, " /") {
+		return false
+	}
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// truncatedMarker ends a request or response cut short before storage
+const truncatedMarker = "[truncated]"
+
+// TruncateStoredHTTP cuts Request and Response down to at most maxBytes each,
+// appending truncatedMarker to any that were cut. The cut never splits a
+// UTF-8 character. A non-positive maxBytes leaves them unchanged
+func (r *ScanResult) TruncateStoredHTTP(maxBytes int) {
+	if maxBytes <= 0 {
+		return
+	}
+	r.Request = truncateUTF8(r.Request, maxBytes)
+	r.Response = truncateUTF8(r.Response, maxBytes)
+}
+
+// truncateUTF8 returns s cut to at most maxBytes on a character boundary,
+// followed by truncatedMarker, or s unchanged if it fits
+func truncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + truncatedMarker
+}
+
+// ResultSummary counts a scan's results by severity
+type ResultSummary struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+	Info     int `json:"info"`
 }
 
 // ScanResult represents a result from a nuclei scan
@@ -62,14 +293,50 @@ type ScanResult struct {
 	Request          string                 `json:"request,omitempty"`
 	Response         string                 `json:"response,omitempty"`
 	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+
+	IsFalsePositive   bool   `json:"is_false_positive"`
+	FalsePositiveNote string `json:"false_positive_note,omitempty"`
 }
 
 // StartScanInput represents the input for starting a scan
 type StartScanInput struct {
-	Target      string       `json:"target"`
-	TemplateIDs []string     `json:"template_ids"`
-	Tags        []string     `json:"tags"`
-	Options     *ScanOptions `json:"options"`
+	Target        string       `json:"target"`
+	Targets       []string     `json:"targets,omitempty"`
+	TargetGroupID *string      `json:"target_group_id,omitempty"`
+	ProfileID     *string      `json:"profile_id,omitempty"`
+	TemplateIDs   []string     `json:"template_ids"`
+	WorkflowIDs   []string     `json:"workflow_ids,omitempty"`
+	Tags          []string     `json:"tags"`
+	Priority      *int         `json:"priority,omitempty"`
+	Options       *ScanOptions `json:"options"`
+}
+
+// ScanTargets returns the targets to scan: Targets if set, otherwise Target
+func (in StartScanInput) ScanTargets() []string {
+	if len(in.Targets) > 0 {
+		return in.Targets
+	}
+	return []string{in.Target}
+}
+
+// ValidateTarget checks that a scan target is an absolute HTTP or HTTPS URL with a host
+func ValidateTarget(target string) error {
+	if strings.TrimSpace(target) == "" {
+		return errors.New("target is required")
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("target is not a valid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("target must use the http or https scheme, got %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return errors.New("target must include a host")
+	}
+
+	return nil
 }
 
 // ParseScanStatus parses a string into a ScanStatus
@@ -98,6 +365,54 @@ type ScanFilter struct {
 	To     string   `json:"to,omitempty"`
 }
 
+// ResultFilter represents scan result filtering options
+type ResultFilter struct {
+	Severity   *string `json:"severity,omitempty"`
+	TemplateID *string `json:"template_id,omitempty"`
+	Matched    *bool   `json:"matched,omitempty"`
+	// IncludeFalsePositives also returns results marked as false positives
+	IncludeFalsePositives bool `json:"include_false_positives,omitempty"`
+}
+
+// ScanComparison represents the difference between the results of two scans
+type ScanComparison struct {
+	New      []*ScanResult `json:"new"`
+	Resolved []*ScanResult `json:"resolved"`
+	Common   []*ScanResult `json:"common"`
+}
+
+// Scan result page size limits
+const (
+	DefaultResultLimit = 50
+	MaxResultLimit     = 500
+)
+
+// Page represents pagination options
+type Page struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// PageMeta describes the page returned alongside a list of items
+type PageMeta struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// ListResponse wraps a single page of items returned by a list endpoint
+type ListResponse[T any] struct {
+	Data []T      `json:"data"`
+	Meta PageMeta `json:"meta"`
+}
+
+// WorkerStatus reports the state of the background scan worker
+type WorkerStatus struct {
+	Paused      bool       `json:"paused"`
+	ActiveScans int        `json:"active_scans"`
+	LastRun     *time.Time `json:"last_run"`
+}
+
 // NewUUID generates a new UUID string
 func NewUUID() string {
 	return uuid.New().String()
@@ -0,0 +1,188 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateTransition(t *testing.T) {
+	statuses := []ScanStatus{
+		ScanStatusPending,
+		ScanStatusRunning,
+		ScanStatusCompleted,
+		ScanStatusFailed,
+		ScanStatusCancelled,
+		ScanStatusDryRun,
+	}
+	legal := map[[2]ScanStatus]bool{
+		{ScanStatusPending, ScanStatusRunning}:   true,
+		{ScanStatusPending, ScanStatusCancelled}: true,
+		{ScanStatusRunning, ScanStatusCompleted}: true,
+		{ScanStatusRunning, ScanStatusFailed}:    true,
+		{ScanStatusRunning, ScanStatusCancelled}: true,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			t.Run(from+"->"+to, func(t *testing.T) {
+				err := ValidateTransition(from, to)
+				if legal[[2]ScanStatus{from, to}] {
+					if err != nil {
+						t.Fatalf("ValidateTransition(%q, %q) = %v, want nil", from, to, err)
+					}
+					return
+				}
+
+				var transitionErr *InvalidTransitionError
+				if !errors.As(err, &transitionErr) {
+					t.Fatalf("ValidateTransition(%q, %q) = %v, want *InvalidTransitionError", from, to, err)
+				}
+				if transitionErr.From != from || transitionErr.To != to {
+					t.Errorf("error reports %q -> %q, want %q -> %q", transitionErr.From, transitionErr.To, from, to)
+				}
+			})
+		}
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		wantErr bool
+	}{
+		{"http URL", "http://example.com", false},
+		{"https URL with path and port", "https://example.com:8443/login?next=/", false},
+		{"http IPv4", "http://192.168.1.10", false},
+		{"https IPv6", "https://[::1]:8443", false},
+		{"empty", "", true},
+		{"blank", "   ", true},
+		{"bare host", "example.com", true},
+		{"bare IPv4", "192.168.1.10", true},
+		{"bare IPv4 with port", "192.168.1.10:8080", true},
+		{"IP range", "10.0.0.0/24", true},
+		{"non-http scheme", "ftp://example.com", true},
+		{"file scheme", "file:///etc/passwd", true},
+		{"missing host", "http://", true},
+		{"missing host with path", "https:///path", true},
+		{"unparseable", "http://exa mple.com:port", true},
+		{"garbage", "not a url", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTarget(tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTarget(%q) = %v, wantErr %v", tt.target, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScanOptionsValidate(t *testing.T) {
+	skipVerify, verify := true, false
+	tests := []struct {
+		name    string
+		modify  func(o *ScanOptions)
+		wantErr bool
+	}{
+		{"defaults", func(o *ScanOptions) {}, false},
+		{"concurrency at minimum", func(o *ScanOptions) { o.Concurrency = 1 }, false},
+		{"concurrency at maximum", func(o *ScanOptions) { o.Concurrency = 500 }, false},
+		{"concurrency below minimum", func(o *ScanOptions) { o.Concurrency = 0 }, true},
+		{"concurrency negative", func(o *ScanOptions) { o.Concurrency = -1 }, true},
+		{"concurrency above maximum", func(o *ScanOptions) { o.Concurrency = 501 }, true},
+		{"rate limit at minimum", func(o *ScanOptions) { o.RateLimit = 1 }, false},
+		{"rate limit at maximum", func(o *ScanOptions) { o.RateLimit = 10000 }, false},
+		{"rate limit below minimum", func(o *ScanOptions) { o.RateLimit = 0 }, true},
+		{"rate limit above maximum", func(o *ScanOptions) { o.RateLimit = 10001 }, true},
+		{"timeout at minimum", func(o *ScanOptions) { o.Timeout = 1 }, false},
+		{"timeout at maximum", func(o *ScanOptions) { o.Timeout = 3600 }, false},
+		{"timeout below minimum", func(o *ScanOptions) { o.Timeout = 0 }, true},
+		{"timeout above maximum", func(o *ScanOptions) { o.Timeout = 3601 }, true},
+		{"retries at minimum", func(o *ScanOptions) { o.Retries = 0 }, false},
+		{"retries at maximum", func(o *ScanOptions) { o.Retries = 10 }, false},
+		{"retries negative", func(o *ScanOptions) { o.Retries = -1 }, true},
+		{"retries above maximum", func(o *ScanOptions) { o.Retries = 11 }, true},
+		{"tls skip verify", func(o *ScanOptions) { o.TLSSkipVerify = &skipVerify }, false},
+		{"tls verify requested", func(o *ScanOptions) { o.TLSSkipVerify = &verify }, true},
+		{"passive with input", func(o *ScanOptions) { o.Passive = true; o.PassiveInput = "traffic/site.har" }, false},
+		{"passive without input", func(o *ScanOptions) { o.Passive = true }, true},
+		{"passive input absolute", func(o *ScanOptions) { o.Passive = true; o.PassiveInput = "/etc/passwd" }, true},
+		{"passive input escapes directory", func(o *ScanOptions) { o.Passive = true; o.PassiveInput = "../secrets.har" }, true},
+		{"resolver host and port", func(o *ScanOptions) { o.DNSResolvers = []string{"1.1.1.1:53", "dns.internal:5353"} }, false},
+		{"resolver IPv6", func(o *ScanOptions) { o.DNSResolvers = []string{"[2606:4700::1111]:53"} }, false},
+		{"resolver without port", func(o *ScanOptions) { o.DNSResolvers = []string{"1.1.1.1"} }, true},
+		{"resolver without host", func(o *ScanOptions) { o.DNSResolvers = []string{":53"} }, true},
+		{"resolver port out of range", func(o *ScanOptions) { o.DNSResolvers = []string{"1.1.1.1:65536"} }, true},
+		{"resolver port zero", func(o *ScanOptions) { o.DNSResolvers = []string{"1.1.1.1:0"} }, true},
+		{"resolver URL", func(o *ScanOptions) { o.DNSResolvers = []string{"udp://1.1.1.1:53"} }, true},
+		{"header", func(o *ScanOptions) { o.CustomHeaders = map[string]string{"X-Scan": "nuclei"} }, false},
+		{"header empty name", func(o *ScanOptions) { o.CustomHeaders = map[string]string{"": "value"} }, true},
+		{"header CRLF in name", func(o *ScanOptions) { o.CustomHeaders = map[string]string{"X-Scan\r\nHost": "value"} }, true},
+		{"header CRLF in value", func(o *ScanOptions) { o.CustomHeaders = map[string]string{"X-Scan": "a\r\nHost: evil"} }, true},
+		{"header colon in name", func(o *ScanOptions) { o.CustomHeaders = map[string]string{"X-Scan:": "value"} }, true},
+		{"cookie", func(o *ScanOptions) { o.CustomCookies = map[string]string{"session": "abc123"} }, false},
+		{"cookie empty name", func(o *ScanOptions) { o.CustomCookies = map[string]string{"": "abc123"} }, true},
+		{"cookie CRLF in name", func(o *ScanOptions) { o.CustomCookies = map[string]string{"session\r\n": "abc123"} }, true},
+		{"cookie CRLF in value", func(o *ScanOptions) { o.CustomCookies = map[string]string{"session": "abc\r\nSet-Cookie: x=y"} }, true},
+		{"cookie separator in value", func(o *ScanOptions) { o.CustomCookies = map[string]string{"session": "abc; admin=1"} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := ScanOptions{Concurrency: 25, RateLimit: 150, Timeout: 300, Retries: 1}
+			tt.modify(&options)
+
+			err := options.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidScanOptions) {
+				t.Errorf("Validate() = %v, want it to wrap ErrInvalidScanOptions", err)
+			}
+		})
+	}
+}
+
+func TestScanOptionsApplyDefaultsBeforeValidate(t *testing.T) {
+	var options ScanOptions
+	if err := options.Validate(); err == nil {
+		t.Fatal("Validate() of zero options = nil, want an error")
+	}
+
+	options.ApplyDefaults(25, 150, 300)
+	if err := options.Validate(); err != nil {
+		t.Errorf("Validate() after ApplyDefaults = %v, want nil", err)
+	}
+}
+
+func TestTruncateStoredHTTP(t *testing.T) {
+	const limit = 64 << 10
+	large := strings.Repeat("a", 1<<20)
+	tests := []struct {
+		name     string
+		value    string
+		maxBytes int
+		want     string
+	}{
+		{name: "1 MB cut to the limit", value: large, maxBytes: limit, want: large[:limit] + truncatedMarker},
+		{name: "at the limit", value: large[:limit], maxBytes: limit, want: large[:limit]},
+		{name: "no limit", value: large, maxBytes: 0, want: large},
+		{name: "cut before a split character", value: "abécd", maxBytes: 3, want: "ab" + truncatedMarker},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &ScanResult{Request: tt.value, Response: tt.value}
+			result.TruncateStoredHTTP(tt.maxBytes)
+
+			if result.Request != tt.want {
+				t.Errorf("Request has %d bytes, want %d", len(result.Request), len(tt.want))
+			}
+			if result.Response != tt.want {
+				t.Errorf("Response has %d bytes, want %d", len(result.Response), len(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Stats represents aggregate statistics across scans, templates, results and
+// the scan worker
+type Stats struct {
+	Scans     *ScanStats     `json:"scans"`
+	Templates *TemplateStats `json:"templates"`
+	Results   *ResultStats   `json:"results"`
+	Worker    *WorkerStats   `json:"worker"`
+}
+
+// ScanStats represents aggregate scan statistics
+type ScanStats struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+	Last24h  int            `json:"last_24h"`
+	Results  *ResultStats   `json:"-"`
+}
+
+// TemplateStats represents aggregate template statistics
+type TemplateStats struct {
+	Total      int            `json:"total"`
+	BySeverity map[string]int `json:"by_severity"`
+}
+
+// ResultStats represents aggregate scan result statistics
+type ResultStats struct {
+	Total      int            `json:"total"`
+	BySeverity map[string]int `json:"by_severity"`
+}
+
+// WorkerStats represents scan worker counters since the service started
+type WorkerStats struct {
+	ActiveScans    int        `json:"active_scans"`
+	TotalProcessed int64      `json:"total_processed"`
+	TotalFailed    int64      `json:"total_failed"`
+	LastRunAt      *time.Time `json:"last_run_at"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+)
+
+// TargetGroup represents a named set of targets that are scanned together
+type TargetGroup struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Targets   []string  `json:"targets"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateTargetGroupInput represents the input for creating a target group
+type CreateTargetGroupInput struct {
+	Name    string   `json:"name"`
+	Targets []string `json:"targets"`
+}
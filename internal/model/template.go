@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 )
 
@@ -16,4 +17,130 @@ type Template struct {
 	Path        string    `json:"path"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// LastModifiedAt is the template file's modification time when it was loaded
+	LastModifiedAt time.Time `json:"-"`
+}
+
+// templateProtocolTypes maps top-level template protocol keys to template types,
+// in the order they are checked
+var templateProtocolTypes = []struct {
+	key  string
+	kind string
+}{
+	{"http", "http"},
+	{"dns", "dns"},
+	{"network", "tcp"},
+	{"headless", "headless"},
+	{"file", "file"},
+}
+
+// templateRequestSections are the top-level keys holding a template's
+// requests; a template needs at least one of them to send anything
+var templateRequestSections = []string{
+	"http", "requests", "dns", "network", "tcp", "headless", "file",
+	"ssl", "websocket", "whois", "code", "javascript", "workflows",
+}
+
+// HasTemplateRequests reports whether the parsed template YAML declares at
+// least one request section
+func HasTemplateRequests(raw map[string]interface{}) bool {
+	for _, key := range templateRequestSections {
+		if _, ok := raw[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectTemplateType returns the template type declared in info.type, falling
+// back to the first top-level protocol key found in the parsed template YAML.
+// It returns an empty string when no type can be determined.
+func DetectTemplateType(raw map[string]interface{}) string {
+	if info, ok := raw["info"].(map[string]interface{}); ok {
+		if kind, ok := info["type"].(string); ok && kind != "" {
+			return kind
+		}
+	}
+	for _, protocol := range templateProtocolTypes {
+		if _, ok := raw[protocol.key]; ok {
+			return protocol.kind
+		}
+	}
+	return ""
+}
+
+// TemplateTags returns the tags declared in info.tags of the parsed template
+// YAML, which nuclei accepts either as a comma-separated string or as a list
+func TemplateTags(raw map[string]interface{}) []string {
+	tags := []string{}
+	info, ok := raw["info"].(map[string]interface{})
+	if !ok {
+		return tags
+	}
+
+	var values []string
+	switch v := info["tags"].(type) {
+	case string:
+		values = strings.Split(v, ",")
+	case []interface{}:
+		for _, item := range v {
+			if tag, ok := item.(string); ok {
+				values = append(values, tag)
+			}
+		}
+	}
+	for _, tag := range values {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// TemplateFilter selects templates by metadata; nil fields match any value
+type TemplateFilter struct {
+	Tags     *string `json:"tags,omitempty"`
+	Author   *string `json:"author,omitempty"`
+	Severity *string `json:"severity,omitempty"`
+	Type     *string `json:"type,omitempty"`
+}
+
+// TemplateContent pairs a template ID with the raw YAML of its file
+type TemplateContent struct {
+	ID      string
+	Content []byte
+}
+
+// ImportResult summarizes a template archive import
+type ImportResult struct {
+	Imported int      `json:"imported"`
+	Failed   int      `json:"failed"`
+	Errors   []string `json:"errors"`
+}
+
+// TemplateValidation is the outcome of validating template YAML without
+// storing it
+type TemplateValidation struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// TemplateRefreshStatus describes the current or most recent template refresh
+type TemplateRefreshStatus struct {
+	Running        bool           `json:"running"`
+	LastRefreshAt  *time.Time     `json:"last_refresh_at"`
+	LastDurationMs int64          `json:"last_duration_ms"`
+	TemplateCount  int            `json:"template_count"`
+	LastResult     *RefreshResult `json:"last_result,omitempty"`
+	LastError      string         `json:"last_error,omitempty"`
+}
+
+// RefreshResult summarizes a template refresh
+type RefreshResult struct {
+	Loaded    int      `json:"loaded"`
+	Unchanged int      `json:"unchanged"`
+	Removed   int      `json:"removed"`
+	Failed    int      `json:"failed"`
+	Errors    []string `json:"errors"`
 }
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDetectTemplateType(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"http", "id: t\nhttp:\n  - method: GET\n", "http"},
+		{"dns", "id: t\ndns:\n  - name: \"{{FQDN}}\"\n", "dns"},
+		{"network is tcp", "id: t\nnetwork:\n  - host:\n      - \"{{Hostname}}\"\n", "tcp"},
+		{"headless", "id: t\nheadless:\n  - steps: []\n", "headless"},
+		{"file", "id: t\nfile:\n  - extensions:\n      - all\n", "file"},
+		{"info.type wins", "id: t\ninfo:\n  type: workflow\nhttp:\n  - method: GET\n", "workflow"},
+		{"empty info.type falls back", "id: t\ninfo:\n  type: \"\"\ndns:\n  - name: x\n", "dns"},
+		{"unknown protocol", "id: t\nwhois:\n  - query: x\n", ""},
+		{"no protocol", "id: t\ninfo:\n  name: Empty\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw map[string]interface{}
+			if err := yaml.Unmarshal([]byte(tt.yaml), &raw); err != nil {
+				t.Fatalf("yaml.Unmarshal() error = %v", err)
+			}
+			if got := DetectTemplateType(raw); got != tt.want {
+				t.Errorf("DetectTemplateType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+)
+
+// CachingTemplateRepository implements repository.TemplateRepository with an
+// in-memory LRU cache in front of another repository
+type CachingTemplateRepository struct {
+	repo   repository.TemplateRepository
+	cache  *expirable.LRU[string, *model.Template]
+	cfg    *config.Config
+	logger *zap.Logger
+}
+
+// NewCachingTemplateRepository creates a new caching template repository
+func NewCachingTemplateRepository(repo repository.TemplateRepository, cfg *config.Config, logger *zap.Logger) *CachingTemplateRepository {
+	return &CachingTemplateRepository{
+		repo:   repo,
+		cache:  expirable.NewLRU[string, *model.Template](cfg.Cache.TemplateSize, nil, time.Duration(cfg.Cache.TemplateTTL)*time.Second),
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// List returns a list of templates
+func (r *CachingTemplateRepository) List(ctx context.Context, tags, author, severity, templateType *string, page model.Page) ([]*model.Template, error) {
+	return r.repo.List(ctx, tags, author, severity, templateType, page)
+}
+
+// Count returns the number of templates matching the filters
+func (r *CachingTemplateRepository) Count(ctx context.Context, tags, author, severity, templateType *string) (int, error) {
+	return r.repo.Count(ctx, tags, author, severity, templateType)
+}
+
+// Get returns a template by ID, serving it from the cache when possible
+func (r *CachingTemplateRepository) Get(ctx context.Context, id string) (*model.Template, error) {
+	if template, ok := r.cache.Get(id); ok {
+		r.logger.Debug("Template cache hit", zap.String("id", id))
+		return copyTemplate(template), nil
+	}
+
+	r.logger.Debug("Template cache miss", zap.String("id", id))
+	template, err := r.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache.Add(id, copyTemplate(template))
+	return template, nil
+}
+
+// Search returns templates matching a full-text query
+func (r *CachingTemplateRepository) Search(ctx context.Context, query string) ([]*model.Template, error) {
+	return r.repo.Search(ctx, query)
+}
+
+// ListByTags returns templates sharing tags with a template
+func (r *CachingTemplateRepository) ListByTags(ctx context.Context, tags []string, excludeID string, limit int) ([]*model.Template, error) {
+	return r.repo.ListByTags(ctx, tags, excludeID, limit)
+}
+
+// Create creates a new template
+func (r *CachingTemplateRepository) Create(ctx context.Context, template *model.Template) error {
+	r.cache.Remove(template.ID)
+	return r.repo.Create(ctx, template)
+}
+
+// Update updates a template and invalidates its cache entry
+func (r *CachingTemplateRepository) Update(ctx context.Context, template *model.Template) error {
+	r.cache.Remove(template.ID)
+	return r.repo.Update(ctx, template)
+}
+
+// Delete deletes a template by ID and invalidates its cache entry
+func (r *CachingTemplateRepository) Delete(ctx context.Context, id string) error {
+	r.cache.Remove(id)
+	return r.repo.Delete(ctx, id)
+}
+
+// Refresh refreshes the template cache and purges all cached entries
+func (r *CachingTemplateRepository) Refresh(ctx context.Context) error {
+	r.cache.Purge()
+	return r.repo.Refresh(ctx)
+}
+
+// UpsertTemplate creates or updates a template and invalidates its cache entry
+func (r *CachingTemplateRepository) UpsertTemplate(ctx context.Context, template *model.Template) error {
+	r.cache.Remove(template.ID)
+	return r.repo.UpsertTemplate(ctx, template)
+}
+
+// CheckIDCollision returns the path of a different file already stored under id
+func (r *CachingTemplateRepository) CheckIDCollision(ctx context.Context, id, path string) (string, error) {
+	return r.repo.CheckIDCollision(ctx, id, path)
+}
+
+// ListModTimes returns the stored file modification time of each template
+func (r *CachingTemplateRepository) ListModTimes(ctx context.Context) (map[string]time.Time, error) {
+	return r.repo.ListModTimes(ctx)
+}
+
+// DeleteMissing deletes templates whose path is not in paths and purges all cached entries
+func (r *CachingTemplateRepository) DeleteMissing(ctx context.Context, paths []string) (int, error) {
+	r.cache.Purge()
+	return r.repo.DeleteMissing(ctx, paths)
+}
+
+// GetStats returns aggregate template statistics
+func (r *CachingTemplateRepository) GetStats(ctx context.Context) (*model.TemplateStats, error) {
+	return r.repo.GetStats(ctx)
+}
+
+// copyTemplate returns a copy so callers cannot modify cached entries
+func copyTemplate(template *model.Template) *model.Template {
+	c := *template
+	c.Tags = append([]string(nil), template.Tags...)
+	return &c
+}
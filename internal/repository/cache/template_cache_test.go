@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+)
+
+// fakeTemplateRepository serves templates from a map and counts Get calls.
+// Methods a test does not use panic through the nil embedded interface
+type fakeTemplateRepository struct {
+	repository.TemplateRepository
+
+	templates map[string]*model.Template
+	gets      int
+}
+
+// Get returns a copy of the stored template
+func (f *fakeTemplateRepository) Get(ctx context.Context, id string) (*model.Template, error) {
+	f.gets++
+	template, ok := f.templates[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	c := *template
+	return &c, nil
+}
+
+// Update stores template
+func (f *fakeTemplateRepository) Update(ctx context.Context, template *model.Template) error {
+	f.templates[template.ID] = template
+	return nil
+}
+
+// Delete removes the template stored under id
+func (f *fakeTemplateRepository) Delete(ctx context.Context, id string) error {
+	delete(f.templates, id)
+	return nil
+}
+
+// Refresh does nothing
+func (f *fakeTemplateRepository) Refresh(ctx context.Context) error {
+	return nil
+}
+
+// newTestCache returns a cache in front of a fake repository holding one template
+func newTestCache() (*CachingTemplateRepository, *fakeTemplateRepository) {
+	repo := &fakeTemplateRepository{templates: map[string]*model.Template{
+		"cve-2021-1234": {ID: "cve-2021-1234", Name: "Original", Tags: []string{"cve"}},
+	}}
+	cfg := &config.Config{}
+	cfg.Cache.TemplateSize = 10
+	cfg.Cache.TemplateTTL = 300
+	return NewCachingTemplateRepository(repo, cfg, zap.NewNop()), repo
+}
+
+func TestCachingTemplateRepositoryHitsAndMisses(t *testing.T) {
+	cache, repo := newTestCache()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		template, err := cache.Get(ctx, "cve-2021-1234")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if template.Name != "Original" {
+			t.Fatalf("Get() name = %q, want Original", template.Name)
+		}
+	}
+	if repo.gets != 1 {
+		t.Errorf("repository Get called %d times, want 1 (a miss, then hits)", repo.gets)
+	}
+
+	if _, err := cache.Get(ctx, "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := cache.Get(ctx, "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+	if repo.gets != 3 {
+		t.Errorf("repository Get called %d times, want 3 (missing templates are not cached)", repo.gets)
+	}
+}
+
+func TestCachingTemplateRepositoryReturnsCopies(t *testing.T) {
+	cache, _ := newTestCache()
+	ctx := context.Background()
+
+	template, err := cache.Get(ctx, "cve-2021-1234")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	template.Name = "Modified"
+	template.Tags[0] = "modified"
+
+	template, err = cache.Get(ctx, "cve-2021-1234")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if template.Name != "Original" || template.Tags[0] != "cve" {
+		t.Errorf("cached template = %q %v, want it unaffected by changes to a returned copy", template.Name, template.Tags)
+	}
+}
+
+func TestCachingTemplateRepositoryInvalidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate func(ctx context.Context, cache *CachingTemplateRepository) error
+		wantName   string
+		wantErr    error
+	}{
+		{
+			name: "update",
+			invalidate: func(ctx context.Context, cache *CachingTemplateRepository) error {
+				return cache.Update(ctx, &model.Template{ID: "cve-2021-1234", Name: "Updated"})
+			},
+			wantName: "Updated",
+		},
+		{
+			name: "delete",
+			invalidate: func(ctx context.Context, cache *CachingTemplateRepository) error {
+				return cache.Delete(ctx, "cve-2021-1234")
+			},
+			wantErr: repository.ErrNotFound,
+		},
+		{
+			name: "refresh",
+			invalidate: func(ctx context.Context, cache *CachingTemplateRepository) error {
+				return cache.Refresh(ctx)
+			},
+			wantName: "Original",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, repo := newTestCache()
+			ctx := context.Background()
+
+			if _, err := cache.Get(ctx, "cve-2021-1234"); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if err := tt.invalidate(ctx, cache); err != nil {
+				t.Fatalf("invalidate error = %v", err)
+			}
+
+			template, err := cache.Get(ctx, "cve-2021-1234")
+			if repo.gets != 2 {
+				t.Errorf("repository Get called %d times, want 2 (the cache entry was invalidated)", repo.gets)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Get() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if template.Name != tt.wantName {
+				t.Errorf("Get() name = %q, want %q", template.Name, tt.wantName)
+			}
+		})
+	}
+}
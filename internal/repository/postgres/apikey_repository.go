@@ -0,0 +1,199 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/config"
+	apperrors "nuclei-service-demo/internal/errors"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+)
+
+// APIKeyRepository implements repository.APIKeyRepository
+type APIKeyRepository struct {
+	db     *sql.DB
+	cfg    *config.Config
+	logger *zap.Logger
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *sql.DB, cfg *config.Config, logger *zap.Logger) *APIKeyRepository {
+	return &APIKeyRepository{
+		db:     db,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// List returns all API keys
+func (r *APIKeyRepository) List(ctx context.Context) ([]*model.APIKey, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Listing API keys from database")
+
+	// Build query
+	query := `
+		SELECT k.id, k.name, k.key_hash, k.permissions, k.created_at, k.expires_at
+		FROM api_keys k
+		ORDER BY k.created_at
+	`
+
+	// Execute query
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.logger.Error("Failed to execute API key list query", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+	defer rows.Close()
+
+	// Scan results
+	keys := []*model.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan API key row", zap.Error(err))
+			return nil, apperrors.WrapPostgresError(err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate API key rows", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Retrieved API keys from database", zap.Int("count", len(keys)))
+	return keys, nil
+}
+
+// GetByHash returns the API key with the given key hash
+func (r *APIKeyRepository) GetByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	// Build query
+	query := `
+		SELECT k.id, k.name, k.key_hash, k.permissions, k.created_at, k.expires_at
+		FROM api_keys k
+		WHERE k.key_hash = $1
+	`
+
+	// Execute query
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrNotFound
+		}
+		r.logger.Error("Failed to get API key", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	return key, nil
+}
+
+// Create creates a new API key
+func (r *APIKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Creating API key in database",
+		zap.String("id", key.ID),
+		zap.String("name", key.Name))
+
+	// Build query
+	query := `
+		INSERT INTO api_keys (id, name, key_hash, permissions, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	// Execute query
+	_, err := r.db.ExecContext(ctx, query,
+		key.ID,
+		key.Name,
+		key.Key,
+		pq.Array(key.Permissions),
+		key.CreatedAt,
+		key.ExpiresAt,
+	)
+	if err != nil {
+		r.logger.Error("Failed to create API key", zap.Error(err), zap.String("id", key.ID))
+		return apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Successfully created API key", zap.String("id", key.ID))
+	return nil
+}
+
+// Delete deletes an API key by ID
+func (r *APIKeyRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Deleting API key from database", zap.String("id", id))
+
+	// Build query
+	query := `
+		DELETE FROM api_keys
+		WHERE id = $1
+	`
+
+	// Execute query
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to delete API key", zap.Error(err), zap.String("id", id))
+		return apperrors.WrapPostgresError(err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get deleted API key count", zap.Error(err), zap.String("id", id))
+		return apperrors.WrapPostgresError(err)
+	}
+	if deleted == 0 {
+		r.logger.Warn("API key not found", zap.String("id", id))
+		return repository.ErrNotFound
+	}
+
+	r.logger.Info("Successfully deleted API key", zap.String("id", id))
+	return nil
+}
+
+// Count returns the number of stored API keys
+func (r *APIKeyRepository) Count(ctx context.Context) (int, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	// Build query
+	query := `
+		SELECT COUNT(*)
+		FROM api_keys
+	`
+
+	// Execute query
+	var count int
+	if err := r.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		r.logger.Error("Failed to count API keys", zap.Error(err))
+		return 0, apperrors.WrapPostgresError(err)
+	}
+
+	return count, nil
+}
+
+// scanAPIKey reads an API key from a row
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+	var key model.APIKey
+	if err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Key,
+		pq.Array(&key.Permissions),
+		&key.CreatedAt,
+		&key.ExpiresAt,
+	); err != nil {
+		return nil, apperrors.WrapPostgresError(err)
+	}
+	return &key, nil
+}
@@ -1,16 +1,87 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"nuclei-service-demo/internal/config"
 	"time"
 
 	_ "github.com/lib/pq"
+	"go.uber.org/zap"
 )
 
+// maxConnectBackoff caps the delay between connection attempts
+const maxConnectBackoff = 30 * time.Second
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
 // NewConnection creates a new database connection
 func NewConnection(dbConfig config.DB) (*sql.DB, error) {
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Test connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return db, nil
+}
+
+// ConnectWithRetry creates a new database connection, pinging the database up
+// to maxAttempts times. The delay between attempts starts at backoff and
+// doubles after each failure, capped at 30 seconds.
+func ConnectWithRetry(dbConfig config.DB, maxAttempts int, backoff time.Duration, logger *zap.Logger) (*sql.DB, error) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	delay := backoff
+	for attempt := 1; ; attempt++ {
+		err = db.Ping()
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= maxAttempts {
+			break
+		}
+
+		logger.Warn("Database not ready, retrying",
+			zap.Error(err),
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", maxAttempts),
+			zap.Duration("backoff", delay))
+		time.Sleep(delay)
+
+		delay *= 2
+		if delay > maxConnectBackoff {
+			delay = maxConnectBackoff
+		}
+	}
+
+	db.Close()
+	return nil, fmt.Errorf("failed to ping database after %d attempts: %w", maxAttempts, err)
+}
+
+// openDB opens a connection pool without checking that the database is reachable
+func openDB(dbConfig config.DB) (*sql.DB, error) {
 	// Create connection string
 	connStr := fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
@@ -24,14 +95,37 @@ func NewConnection(dbConfig config.DB) (*sql.DB, error) {
 	}
 
 	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	db.SetMaxIdleConns(dbConfig.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(dbConfig.ConnMaxLifetimeSeconds) * time.Second)
+	db.SetConnMaxIdleTime(time.Duration(dbConfig.ConnMaxIdleTimeSeconds) * time.Second)
 
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	return db, nil
+}
+
+// queryCounts executes a two-column "key, count" query and returns the counts
+// keyed by the first column along with their sum
+func queryCounts(ctx context.Context, db queryer, query string, args ...interface{}) (map[string]int, int, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
 	}
+	defer rows.Close()
 
-	return db, nil
+	counts := make(map[string]int)
+	total := 0
+	for rows.Next() {
+		var key string
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, 0, err
+		}
+		counts[key] = count
+		total += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return counts, total, nil
 }
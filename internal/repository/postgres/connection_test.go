@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"nuclei-service-demo/internal/config"
+)
+
+// unreachableDB is a database configuration whose connections are refused
+var unreachableDB = config.DB{Host: "127.0.0.1", Port: 1, User: "postgres", Password: "postgres", Name: "nuclei"}
+
+func TestOpenDBAppliesPoolLimits(t *testing.T) {
+	dbConfig := unreachableDB
+	dbConfig.MaxOpenConns = 3
+	dbConfig.MaxIdleConns = 2
+	dbConfig.ConnMaxLifetimeSeconds = 60
+	dbConfig.ConnMaxIdleTimeSeconds = 30
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		t.Fatalf("openDB() error = %v", err)
+	}
+	defer db.Close()
+
+	if got := db.Stats().MaxOpenConnections; got != 3 {
+		t.Errorf("MaxOpenConnections = %d, want 3", got)
+	}
+}
+
+func TestConnectWithRetryGivesUp(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+
+	start := time.Now()
+	db, err := ConnectWithRetry(unreachableDB, 3, time.Millisecond, zap.New(core))
+	if err == nil {
+		db.Close()
+		t.Fatal("ConnectWithRetry() error = nil, want an error for an unreachable database")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("ConnectWithRetry() took %v, want it to give up quickly", elapsed)
+	}
+	if !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("error = %v, want it to report 3 attempts", err)
+	}
+	if retries := logs.FilterMessage("Database not ready, retrying").Len(); retries != 2 {
+		t.Errorf("logged %d retries, want 2", retries)
+	}
+}
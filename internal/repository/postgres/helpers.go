@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+	"nuclei-service-demo/internal/telemetry"
+)
+
+// tracer starts the spans wrapping repository queries
+var tracer = telemetry.Tracer("nuclei-service-demo/internal/repository/postgres")
+
+// queryCtx bounds a repository call by the configured query timeout, so a
+// caller without a deadline cannot hang on a slow query. A non-positive
+// timeout leaves the parent context unchanged
+func queryCtx(parent context.Context, cfg *config.Config) (context.Context, context.CancelFunc) {
+	if cfg == nil || cfg.DB.QueryTimeoutSeconds <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, time.Duration(cfg.DB.QueryTimeoutSeconds)*time.Second)
+}
+
+// appendPage adds LIMIT and OFFSET clauses for page to query. A non-positive
+// limit leaves the query unbounded
+func appendPage(query string, args []interface{}, page model.Page) (string, []interface{}) {
+	if page.Limit <= 0 {
+		return query, args
+	}
+	args = append(args, page.Limit, page.Offset)
+	query += fmt.Sprintf(` LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+	return query, args
+}
+
+// startQuerySpan starts a span for a repository call named name
+func startQuerySpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attribute.String("db.system", "postgresql")))
+}
+
+// endQuerySpan ends a query span, recording err unless it only means that no
+// row matched
+func endQuerySpan(span trace.Span, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		err = nil
+	}
+	telemetry.EndSpan(span, err)
+}
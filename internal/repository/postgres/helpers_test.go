@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/config"
+)
+
+// testDatabaseURLEnv names a disposable Postgres database for the
+// integration tests, which migrate and truncate it
+const testDatabaseURLEnv = "TEST_DATABASE_URL"
+
+// testMigrationsPath is the migrations directory relative to this package
+const testMigrationsPath = "../../../migrations"
+
+// newTestDB connects to the database named by TEST_DATABASE_URL, applies
+// the migrations and empties every table. The test is skipped when the
+// variable is not set
+func newTestDB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	url := os.Getenv(testDatabaseURLEnv)
+	if url == "" {
+		t.Skipf("%s is not set", testDatabaseURLEnv)
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := RunMigrations(db, testMigrationsPath); err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	if _, err := db.Exec(`TRUNCATE scans, scan_results, templates, target_groups, scan_profiles, api_keys CASCADE`); err != nil {
+		t.Fatalf("failed to empty tables: %v", err)
+	}
+	return db
+}
+
+// testConfig returns a configuration with a query timeout for the integration tests
+func testConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.DB.QueryTimeoutSeconds = 30
+	return cfg
+}
+
+func TestQueryCtx(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.DB.QueryTimeoutSeconds = 30
+
+	ctx, cancel := queryCtx(context.Background(), cfg)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("queryCtx() context has no deadline")
+	}
+	if remaining := time.Until(deadline); remaining <= 29*time.Second || remaining > 30*time.Second {
+		t.Errorf("deadline in %v, want about 30s", remaining)
+	}
+
+	cfg.DB.QueryTimeoutSeconds = 0
+	ctx, cancel = queryCtx(context.Background(), cfg)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("queryCtx() with no timeout set a deadline")
+	}
+}
+
+func TestRepositoryExpiredContext(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.DB.QueryTimeoutSeconds = 30
+	db := newUnreachableDB(t)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"ScanRepository.Get", func() error {
+			_, err := NewScanRepository(db, cfg, zap.NewNop()).Get(ctx, "scan-1")
+			return err
+		}},
+		{"ScanRepository.Count", func() error {
+			_, err := NewScanRepository(db, cfg, zap.NewNop()).Count(ctx, nil, nil, nil, nil)
+			return err
+		}},
+		{"TemplateRepository.Get", func() error {
+			_, err := NewTemplateRepository(db, cfg, zap.NewNop()).Get(ctx, "cve-2021-1234")
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("error = %v, want context.DeadlineExceeded", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratepg "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// RunMigrations applies all pending up migrations from migrationsPath
+func RunMigrations(db *sql.DB, migrationsPath string) error {
+	m, err := newMigrate(db, migrationsPath)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
+	return nil
+}
+
+// RollbackMigrations reverts all applied migrations from migrationsPath
+func RollbackMigrations(db *sql.DB, migrationsPath string) error {
+	m, err := newMigrate(db, migrationsPath)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Down(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to revert migrations: %w", err)
+	}
+
+	return nil
+}
+
+// newMigrate creates a migrate instance backed by the given database connection
+func newMigrate(db *sql.DB, migrationsPath string) (*migrate.Migrate, error) {
+	driver, err := migratepg.WithInstance(db, &migratepg.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	m, err := migrate.NewWithDatabaseInstance("file://"+migrationsPath, "postgres", driver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,37 @@
+package postgres
+
+import "testing"
+
+func TestMigrationsUpDownUp(t *testing.T) {
+	db := newTestDB(t)
+
+	tables := []string{"scans", "scan_results", "templates", "target_groups", "scan_profiles", "api_keys"}
+	assertTables := func(wantExist bool) {
+		t.Helper()
+		for _, table := range tables {
+			var exists bool
+			if err := db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, "public."+table).Scan(&exists); err != nil {
+				t.Fatalf("failed to look up table %s: %v", table, err)
+			}
+			if exists != wantExist {
+				t.Errorf("table %s exists = %v, want %v", table, exists, wantExist)
+			}
+		}
+	}
+
+	// newTestDB has applied every migration
+	assertTables(true)
+	if err := RunMigrations(db, testMigrationsPath); err != nil {
+		t.Fatalf("RunMigrations() with nothing to apply error = %v", err)
+	}
+
+	if err := RollbackMigrations(db, testMigrationsPath); err != nil {
+		t.Fatalf("RollbackMigrations() error = %v", err)
+	}
+	assertTables(false)
+
+	if err := RunMigrations(db, testMigrationsPath); err != nil {
+		t.Fatalf("RunMigrations() after rollback error = %v", err)
+	}
+	assertTables(true)
+}
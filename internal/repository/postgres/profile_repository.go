@@ -0,0 +1,242 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/config"
+	apperrors "nuclei-service-demo/internal/errors"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+)
+
+// ProfileRepository implements repository.ProfileRepository
+type ProfileRepository struct {
+	db     *sql.DB
+	cfg    *config.Config
+	logger *zap.Logger
+}
+
+// NewProfileRepository creates a new scan profile repository
+func NewProfileRepository(db *sql.DB, cfg *config.Config, logger *zap.Logger) *ProfileRepository {
+	return &ProfileRepository{
+		db:     db,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// List returns all scan profiles
+func (r *ProfileRepository) List(ctx context.Context) ([]*model.ScanProfile, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Listing scan profiles from database")
+
+	// Build query
+	query := `
+		SELECT p.id, p.name, p.description, p.options, p.created_at, p.updated_at
+		FROM scan_profiles p
+		ORDER BY p.name
+	`
+
+	// Execute query
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.logger.Error("Failed to execute scan profile list query", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+	defer rows.Close()
+
+	// Scan results
+	profiles := []*model.ScanProfile{}
+	for rows.Next() {
+		profile, err := r.scanProfile(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan scan profile row", zap.Error(err))
+			return nil, apperrors.WrapPostgresError(err)
+		}
+		profiles = append(profiles, profile)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate scan profile rows", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Retrieved scan profiles from database", zap.Int("count", len(profiles)))
+	return profiles, nil
+}
+
+// Get returns a scan profile by ID
+func (r *ProfileRepository) Get(ctx context.Context, id string) (*model.ScanProfile, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Getting scan profile from database", zap.String("id", id))
+
+	// Build query
+	query := `
+		SELECT p.id, p.name, p.description, p.options, p.created_at, p.updated_at
+		FROM scan_profiles p
+		WHERE p.id = $1
+	`
+
+	// Execute query
+	profile, err := r.scanProfile(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			r.logger.Warn("Scan profile not found", zap.String("id", id))
+			return nil, repository.ErrNotFound
+		}
+		r.logger.Error("Failed to get scan profile", zap.Error(err), zap.String("id", id))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Retrieved scan profile from database", zap.String("id", id))
+	return profile, nil
+}
+
+// Create creates a new scan profile
+func (r *ProfileRepository) Create(ctx context.Context, profile *model.ScanProfile) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Creating scan profile in database",
+		zap.String("id", profile.ID),
+		zap.String("name", profile.Name))
+
+	// Encode options
+	options, err := json.Marshal(profile.Options)
+	if err != nil {
+		r.logger.Error("Failed to encode scan profile options", zap.Error(err), zap.String("id", profile.ID))
+		return apperrors.WrapPostgresError(err)
+	}
+
+	// Build query
+	query := `
+		INSERT INTO scan_profiles (id, name, description, options, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	// Execute query
+	_, err = r.db.ExecContext(ctx, query,
+		profile.ID,
+		profile.Name,
+		profile.Description,
+		options,
+		profile.CreatedAt,
+		profile.UpdatedAt,
+	)
+	if err != nil {
+		r.logger.Error("Failed to create scan profile", zap.Error(err), zap.String("id", profile.ID))
+		return apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Successfully created scan profile", zap.String("id", profile.ID))
+	return nil
+}
+
+// Update updates a scan profile
+func (r *ProfileRepository) Update(ctx context.Context, profile *model.ScanProfile) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Updating scan profile in database", zap.String("id", profile.ID))
+
+	// Encode options
+	options, err := json.Marshal(profile.Options)
+	if err != nil {
+		r.logger.Error("Failed to encode scan profile options", zap.Error(err), zap.String("id", profile.ID))
+		return apperrors.WrapPostgresError(err)
+	}
+
+	// Build query
+	query := `
+		UPDATE scan_profiles
+		SET name = $1, description = $2, options = $3, updated_at = $4
+		WHERE id = $5
+		RETURNING created_at
+	`
+
+	// Execute query
+	if err := r.db.QueryRowContext(ctx, query,
+		profile.Name,
+		profile.Description,
+		options,
+		profile.UpdatedAt,
+		profile.ID,
+	).Scan(&profile.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			r.logger.Warn("Scan profile not found", zap.String("id", profile.ID))
+			return repository.ErrNotFound
+		}
+		r.logger.Error("Failed to update scan profile", zap.Error(err), zap.String("id", profile.ID))
+		return apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Successfully updated scan profile", zap.String("id", profile.ID))
+	return nil
+}
+
+// Delete deletes a scan profile by ID
+func (r *ProfileRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Deleting scan profile from database", zap.String("id", id))
+
+	// Build query
+	query := `
+		DELETE FROM scan_profiles
+		WHERE id = $1
+	`
+
+	// Execute query
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to delete scan profile", zap.Error(err), zap.String("id", id))
+		return apperrors.WrapPostgresError(err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get deleted scan profile count", zap.Error(err), zap.String("id", id))
+		return apperrors.WrapPostgresError(err)
+	}
+	if deleted == 0 {
+		r.logger.Warn("Scan profile not found", zap.String("id", id))
+		return repository.ErrNotFound
+	}
+
+	r.logger.Info("Successfully deleted scan profile", zap.String("id", id))
+	return nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProfile reads a scan profile from a row
+func (r *ProfileRepository) scanProfile(row rowScanner) (*model.ScanProfile, error) {
+	var profile model.ScanProfile
+	var options []byte
+	if err := row.Scan(
+		&profile.ID,
+		&profile.Name,
+		&profile.Description,
+		&options,
+		&profile.CreatedAt,
+		&profile.UpdatedAt,
+	); err != nil {
+		return nil, apperrors.WrapPostgresError(err)
+	}
+	if len(options) > 0 {
+		if err := json.Unmarshal(options, &profile.Options); err != nil {
+			r.logger.Warn("Failed to decode scan profile options", zap.Error(err), zap.String("id", profile.ID))
+		}
+	}
+	return &profile, nil
+}
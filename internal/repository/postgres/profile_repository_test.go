@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+)
+
+func TestProfileRepositoryCRUD(t *testing.T) {
+	repo := NewProfileRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	follow := true
+	profile := &model.ScanProfile{
+		ID:          "fast",
+		Name:        "Fast",
+		Description: "Quick scan with high concurrency",
+		Options: model.ScanOptions{
+			Concurrency:     100,
+			RateLimit:       500,
+			Timeout:         120,
+			FollowRedirects: &follow,
+			CustomHeaders:   map[string]string{"X-Scan": "fast"},
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := repo.Create(ctx, profile); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := repo.Create(ctx, &model.ScanProfile{ID: "thorough", Name: "Thorough", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("Create(thorough) error = %v", err)
+	}
+
+	got, err := repo.Get(ctx, "fast")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Name != profile.Name || got.Description != profile.Description || !reflect.DeepEqual(got.Options, profile.Options) {
+		t.Errorf("Get() = %+v, want %+v", got, profile)
+	}
+
+	profiles, err := repo.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(profiles) != 2 || profiles[0].ID != "fast" || profiles[1].ID != "thorough" {
+		t.Errorf("List() returned %d profiles, want fast and thorough ordered by name", len(profiles))
+	}
+
+	profile.Name = "Faster"
+	profile.Options.Concurrency = 200
+	profile.UpdatedAt = now.Add(time.Minute)
+	if err := repo.Update(ctx, profile); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got, err := repo.Get(ctx, "fast"); err != nil || got.Name != "Faster" || got.Options.Concurrency != 200 {
+		t.Errorf("Get() after Update = %+v, %v; want the new name and concurrency", got, err)
+	}
+	if !profile.CreatedAt.Equal(now) {
+		t.Errorf("Update() CreatedAt = %v, want the stored %v", profile.CreatedAt, now)
+	}
+
+	if err := repo.Delete(ctx, "fast"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := repo.Get(ctx, "fast"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(ctx, "fast"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Delete() of a missing profile error = %v, want ErrNotFound", err)
+	}
+	if err := repo.Update(ctx, &model.ScanProfile{ID: "missing", Name: "Missing"}); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Update() of a missing profile error = %v, want ErrNotFound", err)
+	}
+}
@@ -3,12 +3,16 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
 	"go.uber.org/zap"
 
 	"nuclei-service-demo/internal/config"
+	apperrors "nuclei-service-demo/internal/errors"
 	"nuclei-service-demo/internal/model"
 	"nuclei-service-demo/internal/repository"
 )
@@ -29,34 +33,36 @@ func NewScanRepository(db *sql.DB, cfg *config.Config, logger *zap.Logger) *Scan
 	}
 }
 
-// List returns a list of scans
-func (r *ScanRepository) List(ctx context.Context, status, target, templateID *string) ([]*model.Scan, error) {
+// List returns a page of scans, newest first. Scans must carry every tag in
+// tags to match. A zero page limit returns every matching scan.
+func (r *ScanRepository) List(ctx context.Context, status, target, templateID *string, tags []string, order model.ScanOrder, page model.Page) (_ []*model.Scan, err error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+	ctx, span := startQuerySpan(ctx, "ScanRepository.List")
+	defer func() { endQuerySpan(span, err) }()
+
 	r.logger.Info("Listing scans from database",
 		zap.String("status", safePtr(status)),
 		zap.String("target", safePtr(target)),
-		zap.String("templateID", safePtr(templateID)))
+		zap.String("templateID", safePtr(templateID)),
+		zap.Strings("tags", tags),
+		zap.Int("limit", page.Limit),
+		zap.Int("offset", page.Offset))
 
 	// Build query
 	query := `
-		SELECT s.id, s.target, s.status, s.created_at, s.updated_at
+		SELECT s.id, s.target, s.targets, s.status, s.created_at, s.updated_at, s.options, s.template_ids, s.workflow_ids, s.tags, s.labels, s.priority, COALESCE(s.error, ''), s.result_summary
 		FROM scans s
 		WHERE 1=1
 	`
-	args := []interface{}{}
-
-	if status != nil {
-		query += ` AND s.status = $1`
-		args = append(args, *status)
+	query, args := appendScanFilter(query, nil, status, target, templateID, tags)
+	switch order {
+	case model.ScanOrderPriority:
+		query += ` ORDER BY s.priority DESC, s.created_at ASC, s.id`
+	default:
+		query += ` ORDER BY s.created_at DESC, s.id`
 	}
-	if target != nil {
-		query += ` AND s.target = $2`
-		args = append(args, *target)
-	}
-	// Skip templateID check since the column doesn't exist
-	// if templateID != nil {
-	// 	query += ` AND $3 = ANY(s.template_ids)`
-	// 	args = append(args, *templateID)
-	// }
+	query, args = appendPage(query, args, page)
 
 	r.logger.Info("Executing scan list query",
 		zap.String("query", query),
@@ -66,7 +72,7 @@ func (r *ScanRepository) List(ctx context.Context, status, target, templateID *s
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		r.logger.Error("Failed to execute scan list query", zap.Error(err))
-		return nil, err
+		return nil, apperrors.WrapPostgresError(err)
 	}
 	defer rows.Close()
 
@@ -76,30 +82,35 @@ func (r *ScanRepository) List(ctx context.Context, status, target, templateID *s
 		var scan model.Scan
 		var createdAt, updatedAt time.Time
 		var statusStr string
+		var options, labels, summary []byte
 		if err := rows.Scan(
 			&scan.ID,
 			&scan.Target,
+			pq.Array(&scan.Targets),
 			&statusStr,
 			&createdAt,
 			&updatedAt,
+			&options,
+			pq.Array(&scan.TemplateIDs),
+			pq.Array(&scan.WorkflowIDs),
+			pq.Array(&scan.Tags),
+			&labels,
+			&scan.Priority,
+			&scan.Error,
+			&summary,
 		); err != nil {
 			r.logger.Error("Failed to scan row", zap.Error(err))
-			return nil, err
+			return nil, apperrors.WrapPostgresError(err)
 		}
 
 		scan.Status = model.ParseScanStatus(statusStr)
 		scan.CreatedAt = createdAt
 		scan.UpdatedAt = updatedAt
-
-		// Set default values
-		scan.TemplateIDs = []string{}
-		scan.Tags = []string{}
-		scan.Options = &model.ScanOptions{
-			Concurrency: 10,
-			RateLimit:   100,
-			Timeout:     30,
-			Retries:     3,
-			Headless:    false,
+		scan.Options = decodeScanOptions(options)
+		scan.Labels = decodeLabels(labels)
+		scan.ResultSummary = decodeResultSummary(summary)
+		if len(scan.Targets) == 0 {
+			scan.Targets = []string{scan.Target}
 		}
 
 		scans = append(scans, &scan)
@@ -109,13 +120,63 @@ func (r *ScanRepository) List(ctx context.Context, status, target, templateID *s
 	return scans, nil
 }
 
+// Count returns the number of scans matching the filters
+func (r *ScanRepository) Count(ctx context.Context, status, target, templateID *string, tags []string) (int, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	// Build query
+	query := `
+		SELECT COUNT(*)
+		FROM scans s
+		WHERE 1=1
+	`
+	query, args := appendScanFilter(query, nil, status, target, templateID, tags)
+
+	// Execute query
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		r.logger.Error("Failed to count scans", zap.Error(err))
+		return 0, apperrors.WrapPostgresError(err)
+	}
+
+	return count, nil
+}
+
+// appendScanFilter adds the scan list filters to query
+func appendScanFilter(query string, args []interface{}, status, target, templateID *string, tags []string) (string, []interface{}) {
+	if status != nil {
+		args = append(args, *status)
+		query += fmt.Sprintf(` AND s.status = $%d`, len(args))
+	}
+	if target != nil {
+		args = append(args, *target)
+		query += fmt.Sprintf(` AND $%d = ANY(s.targets)`, len(args))
+	}
+	for _, tag := range tags {
+		args = append(args, tag)
+		query += fmt.Sprintf(` AND $%d = ANY(s.tags)`, len(args))
+	}
+	// Skip templateID check since the column doesn't exist
+	// if templateID != nil {
+	// 	query += ` AND $3 = ANY(s.template_ids)`
+	// 	args = append(args, *templateID)
+	// }
+	return query, args
+}
+
 // Get returns a scan by ID
-func (r *ScanRepository) Get(ctx context.Context, id string) (*model.Scan, error) {
+func (r *ScanRepository) Get(ctx context.Context, id string) (_ *model.Scan, err error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+	ctx, span := startQuerySpan(ctx, "ScanRepository.Get")
+	defer func() { endQuerySpan(span, err) }()
+
 	r.logger.Info("Getting scan from database", zap.String("id", id))
 
 	// Build query
 	query := `
-		SELECT s.id, s.target, s.status, s.created_at, s.updated_at
+		SELECT s.id, s.target, s.targets, s.status, s.created_at, s.updated_at, s.options, s.template_ids, s.workflow_ids, s.tags, s.labels, s.priority, COALESCE(s.error, ''), s.result_summary
 		FROM scans s
 		WHERE s.id = $1
 	`
@@ -126,34 +187,39 @@ func (r *ScanRepository) Get(ctx context.Context, id string) (*model.Scan, error
 	var scan model.Scan
 	var createdAt, updatedAt time.Time
 	var statusStr string
+	var options, labels, summary []byte
 	if err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&scan.ID,
 		&scan.Target,
+		pq.Array(&scan.Targets),
 		&statusStr,
 		&createdAt,
 		&updatedAt,
+		&options,
+		pq.Array(&scan.TemplateIDs),
+		pq.Array(&scan.WorkflowIDs),
+		pq.Array(&scan.Tags),
+		&labels,
+		&scan.Priority,
+		&scan.Error,
+		&summary,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			r.logger.Warn("Scan not found", zap.String("id", id))
 			return nil, repository.ErrNotFound
 		}
 		r.logger.Error("Failed to get scan", zap.Error(err), zap.String("id", id))
-		return nil, err
+		return nil, apperrors.WrapPostgresError(err)
 	}
 
 	scan.Status = model.ParseScanStatus(statusStr)
 	scan.CreatedAt = createdAt
 	scan.UpdatedAt = updatedAt
-
-	// Set default values
-	scan.TemplateIDs = []string{}
-	scan.Tags = []string{}
-	scan.Options = &model.ScanOptions{
-		Concurrency: 10,
-		RateLimit:   100,
-		Timeout:     30,
-		Retries:     3,
-		Headless:    false,
+	scan.Options = decodeScanOptions(options)
+	scan.Labels = decodeLabels(labels)
+	scan.ResultSummary = decodeResultSummary(summary)
+	if len(scan.Targets) == 0 {
+		scan.Targets = []string{scan.Target}
 	}
 
 	r.logger.Info("Retrieved scan from database", zap.String("id", id))
@@ -161,7 +227,12 @@ func (r *ScanRepository) Get(ctx context.Context, id string) (*model.Scan, error
 }
 
 // Create creates a new scan
-func (r *ScanRepository) Create(ctx context.Context, scan *model.Scan) error {
+func (r *ScanRepository) Create(ctx context.Context, scan *model.Scan) (err error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+	ctx, span := startQuerySpan(ctx, "ScanRepository.Create")
+	defer func() { endQuerySpan(span, err) }()
+
 	r.logger.Info("Creating scan in database",
 		zap.String("id", scan.ID),
 		zap.String("target", scan.Target),
@@ -169,28 +240,42 @@ func (r *ScanRepository) Create(ctx context.Context, scan *model.Scan) error {
 
 	// Build query
 	query := `
-		INSERT INTO scans (id, target, status, created_at, updated_at, template_ids, tags)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO scans (id, target, targets, status, created_at, updated_at, template_ids, workflow_ids, tags, options, priority)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id
 	`
 
+	// Encode options
+	var options []byte
+	if scan.Options != nil {
+		options, err = json.Marshal(scan.Options)
+		if err != nil {
+			r.logger.Error("Failed to encode scan options", zap.Error(err), zap.String("id", scan.ID))
+			return apperrors.WrapPostgresError(err)
+		}
+	}
+
 	r.logger.Info("Executing scan create query", zap.String("query", query))
 
 	// Execute query
 	now := time.Now()
 	var id string
-	err := r.db.QueryRowContext(ctx, query,
+	err = r.db.QueryRowContext(ctx, query,
 		scan.ID,
 		scan.Target,
+		pq.Array(scan.Targets),
 		scan.Status,
 		now,
 		now,
 		pq.Array(scan.TemplateIDs),
+		pq.Array(scan.WorkflowIDs),
 		pq.Array(scan.Tags),
+		options,
+		scan.Priority,
 	).Scan(&id)
 	if err != nil {
 		r.logger.Error("Failed to create scan", zap.Error(err), zap.String("id", scan.ID))
-		return err
+		return apperrors.WrapPostgresError(err)
 	}
 
 	// Update scan ID with the returned value
@@ -201,39 +286,180 @@ func (r *ScanRepository) Create(ctx context.Context, scan *model.Scan) error {
 }
 
 // Update updates a scan
-func (r *ScanRepository) Update(ctx context.Context, scan *model.Scan) error {
+func (r *ScanRepository) Update(ctx context.Context, scan *model.Scan) (err error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+	ctx, span := startQuerySpan(ctx, "ScanRepository.Update")
+	defer func() { endQuerySpan(span, err) }()
+
 	r.logger.Info("Updating scan in database",
 		zap.String("id", scan.ID),
 		zap.String("status", string(scan.Status)))
 
-	// Build query
+	// Build query; a scan without a result summary keeps the stored one
 	query := `
 		UPDATE scans
-		SET target = $1, status = $2, updated_at = $3
-		WHERE id = $4
+		SET target = $1, status = $2, updated_at = $3, error = $4, result_summary = COALESCE($5, result_summary)
+		WHERE id = $6
 	`
 
+	// Encode result summary
+	var summary []byte
+	if scan.ResultSummary != nil {
+		summary, err = json.Marshal(scan.ResultSummary)
+		if err != nil {
+			r.logger.Error("Failed to encode scan result summary", zap.Error(err), zap.String("id", scan.ID))
+			return apperrors.WrapPostgresError(err)
+		}
+	}
+
 	r.logger.Info("Executing scan update query", zap.String("query", query))
 
 	// Execute query
 	now := time.Now()
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = r.db.ExecContext(ctx, query,
 		scan.Target,
 		scan.Status,
 		now,
+		sql.NullString{String: scan.Error, Valid: scan.Error != ""},
+		summary,
 		scan.ID,
 	)
 	if err != nil {
 		r.logger.Error("Failed to update scan", zap.Error(err), zap.String("id", scan.ID))
-		return err
+		return apperrors.WrapPostgresError(err)
 	}
 
 	r.logger.Info("Successfully updated scan", zap.String("id", scan.ID))
 	return nil
 }
 
+// UpdateStatus stores a scan's status, error and result summary only if its
+// stored status is still from, so a scan cancelled, reaped or picked up by
+// another worker in the meantime is not overwritten
+func (r *ScanRepository) UpdateStatus(ctx context.Context, scan *model.Scan, from model.ScanStatus) (err error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+	ctx, span := startQuerySpan(ctx, "ScanRepository.UpdateStatus")
+	defer func() { endQuerySpan(span, err) }()
+	return r.updateStatus(ctx, r.db, scan, from)
+}
+
+// UpdateStatusWithTx stores a scan's status like UpdateStatus within a
+// transaction
+func (r *ScanRepository) UpdateStatusWithTx(ctx context.Context, tx *sql.Tx, scan *model.Scan, from model.ScanStatus) (err error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+	ctx, span := startQuerySpan(ctx, "ScanRepository.UpdateStatusWithTx")
+	defer func() { endQuerySpan(span, err) }()
+	return r.updateStatus(ctx, tx, scan, from)
+}
+
+// updateStatus updates a scan's status using the given executor
+func (r *ScanRepository) updateStatus(ctx context.Context, db execer, scan *model.Scan, from model.ScanStatus) error {
+	r.logger.Info("Updating scan status in database",
+		zap.String("id", scan.ID),
+		zap.String("from", from),
+		zap.String("status", scan.Status))
+
+	// Build query; a scan without a result summary keeps the stored one
+	query := `
+		UPDATE scans
+		SET status = $1, updated_at = $2, error = $3, result_summary = COALESCE($4, result_summary)
+		WHERE id = $5 AND status = $6
+	`
+
+	// Encode result summary
+	var summary []byte
+	if scan.ResultSummary != nil {
+		var err error
+		summary, err = json.Marshal(scan.ResultSummary)
+		if err != nil {
+			r.logger.Error("Failed to encode scan result summary", zap.Error(err), zap.String("id", scan.ID))
+			return apperrors.WrapPostgresError(err)
+		}
+	}
+
+	// Execute query
+	res, err := db.ExecContext(ctx, query,
+		scan.Status,
+		time.Now(),
+		sql.NullString{String: scan.Error, Valid: scan.Error != ""},
+		summary,
+		scan.ID,
+		from,
+	)
+	if err != nil {
+		r.logger.Error("Failed to update scan status", zap.Error(err), zap.String("id", scan.ID))
+		return apperrors.WrapPostgresError(err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get affected rows", zap.Error(err), zap.String("id", scan.ID))
+		return apperrors.WrapPostgresError(err)
+	}
+	if rows == 0 {
+		r.logger.Warn("Scan status changed before update",
+			zap.String("id", scan.ID),
+			zap.String("from", from),
+			zap.String("status", scan.Status))
+		return repository.ErrStatusChanged
+	}
+
+	r.logger.Info("Successfully updated scan status", zap.String("id", scan.ID))
+	return nil
+}
+
+// UpdateLabels merges labels into a scan's labels, overwriting keys that are
+// already set and keeping the others
+func (r *ScanRepository) UpdateLabels(ctx context.Context, id string, labels map[string]string) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Updating scan labels in database",
+		zap.String("id", id),
+		zap.Int("labels", len(labels)))
+
+	// Build query
+	query := `
+		UPDATE scans
+		SET labels = labels || $1::jsonb, updated_at = $2
+		WHERE id = $3
+	`
+
+	// Encode labels
+	encoded, err := json.Marshal(labels)
+	if err != nil {
+		r.logger.Error("Failed to encode scan labels", zap.Error(err), zap.String("id", id))
+		return apperrors.WrapPostgresError(err)
+	}
+
+	// Execute query
+	res, err := r.db.ExecContext(ctx, query, encoded, time.Now(), id)
+	if err != nil {
+		r.logger.Error("Failed to update scan labels", zap.Error(err), zap.String("id", id))
+		return apperrors.WrapPostgresError(err)
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get updated scan count", zap.Error(err), zap.String("id", id))
+		return apperrors.WrapPostgresError(err)
+	}
+	if updated == 0 {
+		r.logger.Warn("Scan not found", zap.String("id", id))
+		return repository.ErrNotFound
+	}
+
+	r.logger.Info("Successfully updated scan labels", zap.String("id", id))
+	return nil
+}
+
 // Delete deletes a scan by ID
 func (r *ScanRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
 	r.logger.Info("Deleting scan from database", zap.String("id", id))
 
 	// Build query
@@ -248,15 +474,18 @@ func (r *ScanRepository) Delete(ctx context.Context, id string) error {
 	_, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		r.logger.Error("Failed to delete scan", zap.Error(err), zap.String("id", id))
-		return err
+		return apperrors.WrapPostgresError(err)
 	}
 
 	r.logger.Info("Successfully deleted scan", zap.String("id", id))
 	return nil
 }
 
-// AddResult adds a scan result
-func (r *ScanRepository) AddResult(ctx context.Context, result *model.ScanResult) error {
+// AddResult adds a scan result, reporting whether it was inserted or skipped as a duplicate
+func (r *ScanRepository) AddResult(ctx context.Context, result *model.ScanResult) (bool, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
 	r.logger.Info("Adding scan result to database",
 		zap.String("scan_id", result.ScanID),
 		zap.String("template_id", result.TemplateID),
@@ -264,14 +493,26 @@ func (r *ScanRepository) AddResult(ctx context.Context, result *model.ScanResult
 
 	// Build query
 	query := `
-		INSERT INTO scan_results (scan_id, template_id, template_name, severity, matched, host, matched_at, matcher_name, extracted_results, request, response)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		INSERT INTO scan_results (scan_id, template_id, template_name, severity, matched, host, matched_at, matcher_name, extracted_results, request, response, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (scan_id, template_id, host, matcher_name) DO NOTHING
 	`
 
 	r.logger.Info("Executing scan result create query", zap.String("query", query))
 
+	extractedResults, err := json.Marshal(result.ExtractedResults)
+	if err != nil {
+		r.logger.Error("Failed to encode extracted results", zap.Error(err))
+		return false, apperrors.WrapPostgresError(err)
+	}
+	metadata, err := encodeMetadata(result.Metadata)
+	if err != nil {
+		r.logger.Error("Failed to encode result metadata", zap.Error(err))
+		return false, apperrors.WrapPostgresError(err)
+	}
+
 	// Execute query
-	_, err := r.db.ExecContext(ctx, query,
+	res, err := r.db.ExecContext(ctx, query,
 		result.ScanID,
 		result.TemplateID,
 		result.TemplateName,
@@ -280,51 +521,203 @@ func (r *ScanRepository) AddResult(ctx context.Context, result *model.ScanResult
 		result.Host,
 		result.MatchedAt,
 		result.MatcherName,
-		pq.Array(result.ExtractedResults),
+		extractedResults,
 		result.Request,
 		result.Response,
-		// result.Metadata,
+		metadata,
 	)
 	if err != nil {
 		r.logger.Error("Failed to add scan result",
 			zap.Error(err),
 			zap.String("scan_id", result.ScanID),
 			zap.String("template_id", result.TemplateID))
-		return err
+		return false, apperrors.WrapPostgresError(err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get affected rows", zap.Error(err))
+		return false, apperrors.WrapPostgresError(err)
+	}
+	if rows == 0 {
+		r.logger.Info("Skipped duplicate scan result",
+			zap.String("scan_id", result.ScanID),
+			zap.String("template_id", result.TemplateID))
+		return false, nil
 	}
 
 	r.logger.Info("Successfully added scan result",
 		zap.String("scan_id", result.ScanID),
 		zap.String("template_id", result.TemplateID))
-	return nil
+	return true, nil
 }
 
-// GetResults returns scan results for a scan
-func (r *ScanRepository) GetResults(ctx context.Context, scanID string) ([]*model.ScanResult, error) {
-	r.logger.Info("Getting scan results from database", zap.String("scan_id", scanID))
+// resultInsertColumns is the number of parameters bound per scan result row
+const resultInsertColumns = 12
+
+// resultBatchSize keeps multi-row inserts well below Postgres' 65535 parameter limit
+const resultBatchSize = 500
+
+// AddResultsBatch adds scan results using multi-row inserts, within tx or
+// directly when tx is nil, and returns how many were inserted; duplicates
+// are skipped
+func (r *ScanRepository) AddResultsBatch(ctx context.Context, tx *sql.Tx, results []*model.ScanResult) (int, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Adding scan results batch to database", zap.Int("count", len(results)))
+
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	var db execer = r.db
+	if tx != nil {
+		db = tx
+	}
+
+	inserted := 0
+	for start := 0; start < len(results); start += resultBatchSize {
+		end := min(start+resultBatchSize, len(results))
+
+		n, err := r.insertResults(ctx, db, results[start:end])
+		if err != nil {
+			return 0, err
+		}
+		inserted += n
+	}
+
+	r.logger.Info("Successfully added scan results batch",
+		zap.Int("count", len(results)),
+		zap.Int("inserted", inserted))
+	return inserted, nil
+}
+
+// insertResults inserts scan results with a single multi-row statement
+func (r *ScanRepository) insertResults(ctx context.Context, db execer, results []*model.ScanResult) (int, error) {
+	// Build query
+	var query strings.Builder
+	query.WriteString(`
+		INSERT INTO scan_results (scan_id, template_id, template_name, severity, matched, host, matched_at, matcher_name, extracted_results, request, response, metadata)
+		VALUES `)
+
+	args := make([]interface{}, 0, len(results)*resultInsertColumns)
+	for i, result := range results {
+		extractedResults, err := json.Marshal(result.ExtractedResults)
+		if err != nil {
+			r.logger.Error("Failed to encode extracted results", zap.Error(err))
+			return 0, apperrors.WrapPostgresError(err)
+		}
+		metadata, err := encodeMetadata(result.Metadata)
+		if err != nil {
+			r.logger.Error("Failed to encode result metadata", zap.Error(err))
+			return 0, apperrors.WrapPostgresError(err)
+		}
+
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString("(")
+		for j := 1; j <= resultInsertColumns; j++ {
+			if j > 1 {
+				query.WriteString(", ")
+			}
+			fmt.Fprintf(&query, "$%d", i*resultInsertColumns+j)
+		}
+		query.WriteString(")")
+
+		args = append(args,
+			result.ScanID,
+			result.TemplateID,
+			result.TemplateName,
+			result.Severity,
+			result.Matched,
+			result.Host,
+			result.MatchedAt,
+			result.MatcherName,
+			extractedResults,
+			result.Request,
+			result.Response,
+			metadata,
+		)
+	}
+	query.WriteString(`
+		ON CONFLICT (scan_id, template_id, host, matcher_name) DO NOTHING`)
+
+	// Execute query
+	res, err := db.ExecContext(ctx, query.String(), args...)
+	if err != nil {
+		r.logger.Error("Failed to add scan results batch", zap.Error(err), zap.Int("count", len(results)))
+		return 0, apperrors.WrapPostgresError(err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get affected rows", zap.Error(err))
+		return 0, apperrors.WrapPostgresError(err)
+	}
+
+	return int(rows), nil
+}
+
+// BeginTx starts a new transaction
+func (r *ScanRepository) BeginTx(ctx context.Context) (*sql.Tx, error) {
+	// No query timeout here: the context bounds the whole transaction
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.Error("Failed to begin transaction", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+	return tx, nil
+}
+
+// GetResults returns a page of scan results for a scan matching the filter
+func (r *ScanRepository) GetResults(ctx context.Context, scanID string, filter model.ResultFilter, page model.Page) ([]*model.ScanResult, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Getting scan results from database",
+		zap.String("scan_id", scanID),
+		zap.String("severity", safePtr(filter.Severity)),
+		zap.String("template_id", safePtr(filter.TemplateID)),
+		zap.Int("limit", page.Limit),
+		zap.Int("offset", page.Offset))
 
 	// Build query
 	query := `
-		SELECT r.scan_id, r.template_id, r.template_name, r.severity, r.matched, r.host, r.matched_at, r.matcher_name, r.extracted_results, r.request, r.response, r.metadata
+		SELECT r.id, r.scan_id, r.template_id, COALESCE(r.template_name, ''), COALESCE(r.severity, ''), r.matched,
+			COALESCE(r.host, ''), r.matched_at, COALESCE(r.matcher_name, ''), r.extracted_results,
+			COALESCE(r.request, ''), COALESCE(r.response, ''), r.metadata,
+			r.is_false_positive, r.false_positive_note
 		FROM scan_results r
 		WHERE r.scan_id = $1
 	`
+	args := []interface{}{scanID}
+	query, args = appendResultFilter(query, args, filter)
 
-	r.logger.Info("Executing scan results get query", zap.String("query", query))
+	// Order by id as well so pages are stable when matched_at ties
+	query += ` ORDER BY r.matched_at, r.id`
+	query, args = appendPage(query, args, page)
+
+	r.logger.Info("Executing scan results get query",
+		zap.String("query", query),
+		zap.Int("args_count", len(args)))
 
 	// Execute query
-	rows, err := r.db.QueryContext(ctx, query, scanID)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		r.logger.Error("Failed to get scan results", zap.Error(err), zap.String("scan_id", scanID))
-		return nil, err
+		return nil, apperrors.WrapPostgresError(err)
 	}
 	defer rows.Close()
 
 	// Scan results
-	var results []*model.ScanResult
+	results := []*model.ScanResult{}
 	for rows.Next() {
 		var result model.ScanResult
+		var extractedResults, metadata []byte
 		if err := rows.Scan(
+			&result.ID,
 			&result.ScanID,
 			&result.TemplateID,
 			&result.TemplateName,
@@ -333,16 +726,32 @@ func (r *ScanRepository) GetResults(ctx context.Context, scanID string) ([]*mode
 			&result.Host,
 			&result.MatchedAt,
 			&result.MatcherName,
-			&result.ExtractedResults,
+			&extractedResults,
 			&result.Request,
 			&result.Response,
-			&result.Metadata,
+			&metadata,
+			&result.IsFalsePositive,
+			&result.FalsePositiveNote,
 		); err != nil {
 			r.logger.Error("Failed to scan result row", zap.Error(err))
-			return nil, err
+			return nil, apperrors.WrapPostgresError(err)
+		}
+		if len(extractedResults) > 0 {
+			if err := json.Unmarshal(extractedResults, &result.ExtractedResults); err != nil {
+				r.logger.Warn("Failed to decode extracted results", zap.Error(err))
+			}
+		}
+		if len(metadata) > 0 {
+			if err := json.Unmarshal(metadata, &result.Metadata); err != nil {
+				r.logger.Warn("Failed to decode result metadata", zap.Error(err))
+			}
 		}
 		results = append(results, &result)
 	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate scan results", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
 
 	r.logger.Info("Retrieved scan results from database",
 		zap.String("scan_id", scanID),
@@ -350,6 +759,378 @@ func (r *ScanRepository) GetResults(ctx context.Context, scanID string) ([]*mode
 	return results, nil
 }
 
+// CountResults returns the number of scan results for a scan matching the filter
+func (r *ScanRepository) CountResults(ctx context.Context, scanID string, filter model.ResultFilter) (int, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	// Build query
+	query := `
+		SELECT COUNT(*)
+		FROM scan_results r
+		WHERE r.scan_id = $1
+	`
+	args := []interface{}{scanID}
+	query, args = appendResultFilter(query, args, filter)
+
+	// Execute query
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		r.logger.Error("Failed to count scan results", zap.Error(err), zap.String("scan_id", scanID))
+		return 0, apperrors.WrapPostgresError(err)
+	}
+
+	return count, nil
+}
+
+// appendResultFilter adds the filter's conditions on scan_results r to query
+func appendResultFilter(query string, args []interface{}, filter model.ResultFilter) (string, []interface{}) {
+	if filter.Severity != nil {
+		args = append(args, *filter.Severity)
+		query += fmt.Sprintf(` AND r.severity = $%d`, len(args))
+	}
+	if filter.TemplateID != nil {
+		args = append(args, *filter.TemplateID)
+		query += fmt.Sprintf(` AND r.template_id = $%d`, len(args))
+	}
+	if filter.Matched != nil {
+		args = append(args, *filter.Matched)
+		query += fmt.Sprintf(` AND r.matched = $%d`, len(args))
+	}
+	if !filter.IncludeFalsePositives {
+		query += ` AND NOT r.is_false_positive`
+	}
+	return query, args
+}
+
+// UpdateResultFalsePositive marks or unmarks a scan result as a false positive
+func (r *ScanRepository) UpdateResultFalsePositive(ctx context.Context, scanID, resultID string, fp bool, note string) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Updating scan result false positive flag",
+		zap.String("scan_id", scanID),
+		zap.String("result_id", resultID),
+		zap.Bool("false_positive", fp))
+
+	// Build query
+	query := `
+		UPDATE scan_results
+		SET is_false_positive = $1, false_positive_note = $2, updated_at = $3
+		WHERE scan_id = $4 AND id = $5
+	`
+
+	// Execute query
+	res, err := r.db.ExecContext(ctx, query, fp, note, time.Now(), scanID, resultID)
+	if err != nil {
+		r.logger.Error("Failed to update scan result", zap.Error(err), zap.String("result_id", resultID))
+		return apperrors.WrapPostgresError(err)
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get updated scan result count", zap.Error(err), zap.String("result_id", resultID))
+		return apperrors.WrapPostgresError(err)
+	}
+	if updated == 0 {
+		r.logger.Warn("Scan result not found", zap.String("scan_id", scanID), zap.String("result_id", resultID))
+		return repository.ErrNotFound
+	}
+
+	r.logger.Info("Updated scan result false positive flag", zap.String("result_id", resultID))
+	return nil
+}
+
+// SaveVerboseLog stores the nuclei output captured by a verbose scan
+func (r *ScanRepository) SaveVerboseLog(ctx context.Context, id, log string) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Saving scan verbose log", zap.String("id", id), zap.Int("bytes", len(log)))
+
+	// Build query
+	query := `
+		UPDATE scans
+		SET verbose_log = $1
+		WHERE id = $2
+	`
+
+	// Execute query
+	res, err := r.db.ExecContext(ctx, query, log, id)
+	if err != nil {
+		r.logger.Error("Failed to save scan verbose log", zap.Error(err), zap.String("id", id))
+		return apperrors.WrapPostgresError(err)
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get updated scan count", zap.Error(err), zap.String("id", id))
+		return apperrors.WrapPostgresError(err)
+	}
+	if updated == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+// GetVerboseLog returns a scan's stored verbose log, or an empty string if
+// the scan was not verbose
+func (r *ScanRepository) GetVerboseLog(ctx context.Context, id string) (string, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	// Build query
+	query := `
+		SELECT COALESCE(verbose_log, '')
+		FROM scans
+		WHERE id = $1
+	`
+
+	// Execute query
+	var log string
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&log); err != nil {
+		if err == sql.ErrNoRows {
+			return "", repository.ErrNotFound
+		}
+		r.logger.Error("Failed to get scan verbose log", zap.Error(err), zap.String("id", id))
+		return "", apperrors.WrapPostgresError(err)
+	}
+
+	return log, nil
+}
+
+// GetResultSummary counts a scan's results by severity. Results of any other
+// severity are not counted
+func (r *ScanRepository) GetResultSummary(ctx context.Context, scanID string) (*model.ResultSummary, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+	return r.resultSummary(ctx, r.db, scanID)
+}
+
+// GetResultSummaryWithTx counts a scan's results by severity within a
+// transaction, including results added in it
+func (r *ScanRepository) GetResultSummaryWithTx(ctx context.Context, tx *sql.Tx, scanID string) (*model.ResultSummary, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+	return r.resultSummary(ctx, tx, scanID)
+}
+
+// resultSummary counts a scan's results by severity using the given queryer
+func (r *ScanRepository) resultSummary(ctx context.Context, db queryer, scanID string) (*model.ResultSummary, error) {
+	// Build query
+	query := `
+		SELECT LOWER(severity), COUNT(*)
+		FROM scan_results
+		WHERE scan_id = $1
+		GROUP BY 1
+	`
+
+	// Execute query
+	bySeverity, _, err := queryCounts(ctx, db, query, scanID)
+	if err != nil {
+		r.logger.Error("Failed to count scan results by severity", zap.Error(err), zap.String("scan_id", scanID))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	return &model.ResultSummary{
+		Critical: bySeverity["critical"],
+		High:     bySeverity["high"],
+		Medium:   bySeverity["medium"],
+		Low:      bySeverity["low"],
+		Info:     bySeverity["info"],
+	}, nil
+}
+
+// scanCountsByStatusQuery counts scans per status
+const scanCountsByStatusQuery = `
+	SELECT status, COUNT(*)
+	FROM scans
+	GROUP BY status
+`
+
+// CountByStatus returns the number of scans with the given status
+func (r *ScanRepository) CountByStatus(ctx context.Context, status string) (int, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	// Build query
+	query := `
+		SELECT COUNT(*)
+		FROM scans
+		WHERE status = $1
+	`
+
+	// Execute query
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, status).Scan(&count); err != nil {
+		r.logger.Error("Failed to count scans", zap.Error(err), zap.String("status", status))
+		return 0, apperrors.WrapPostgresError(err)
+	}
+
+	return count, nil
+}
+
+// CountAllByStatus returns the number of scans per status in a single query
+func (r *ScanRepository) CountAllByStatus(ctx context.Context) (map[string]int, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	byStatus, _, err := queryCounts(ctx, r.db, scanCountsByStatusQuery)
+	if err != nil {
+		r.logger.Error("Failed to count scans by status", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	return byStatus, nil
+}
+
+// Heartbeat records that a running scan is still being processed
+func (r *ScanRepository) Heartbeat(ctx context.Context, id string) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	// Build query
+	query := `
+		UPDATE scans
+		SET last_heartbeat_at = NOW()
+		WHERE id = $1 AND status = 'running'
+	`
+
+	// Execute query
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		r.logger.Error("Failed to record scan heartbeat", zap.Error(err), zap.String("id", id))
+		return apperrors.WrapPostgresError(err)
+	}
+
+	return nil
+}
+
+// FailStaleScans marks running scans whose last heartbeat is older than
+// before as failed with message. Scans that never recorded a heartbeat are
+// judged by when they were last updated
+func (r *ScanRepository) FailStaleScans(ctx context.Context, before time.Time, message string) (int, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	// Build query
+	query := `
+		UPDATE scans
+		SET status = 'failed', error = $1, updated_at = NOW()
+		WHERE status = 'running' AND COALESCE(last_heartbeat_at, updated_at) < $2
+	`
+
+	// Execute query
+	res, err := r.db.ExecContext(ctx, query, message, before)
+	if err != nil {
+		r.logger.Error("Failed to fail stale scans", zap.Error(err))
+		return 0, apperrors.WrapPostgresError(err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, apperrors.WrapPostgresError(err)
+	}
+
+	return int(count), nil
+}
+
+// GetStats returns aggregate scan and result statistics
+func (r *ScanRepository) GetStats(ctx context.Context) (*model.ScanStats, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Getting scan statistics from database")
+
+	stats := &model.ScanStats{}
+
+	// Count scans by status
+	byStatus, total, err := queryCounts(ctx, r.db, scanCountsByStatusQuery)
+	if err != nil {
+		r.logger.Error("Failed to count scans by status", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+	stats.Total = total
+	stats.ByStatus = byStatus
+
+	// Count scans created in the last 24 hours
+	query := `
+		SELECT COUNT(*)
+		FROM scans
+		WHERE created_at >= NOW() - INTERVAL '24 hours'
+	`
+	if err := r.db.QueryRowContext(ctx, query).Scan(&stats.Last24h); err != nil {
+		r.logger.Error("Failed to count recent scans", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	// Count results by severity
+	bySeverity, total, err := queryCounts(ctx, r.db, `
+		SELECT COALESCE(NULLIF(severity, ''), 'unknown'), COUNT(*)
+		FROM scan_results
+		GROUP BY 1
+	`)
+	if err != nil {
+		r.logger.Error("Failed to count scan results by severity", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+	stats.Results = &model.ResultStats{
+		Total:      total,
+		BySeverity: bySeverity,
+	}
+
+	r.logger.Info("Retrieved scan statistics from database",
+		zap.Int("scans", stats.Total),
+		zap.Int("results", stats.Results.Total))
+	return stats, nil
+}
+
+// decodeScanOptions decodes stored scan options, falling back to defaults when none were stored
+func decodeScanOptions(data []byte) *model.ScanOptions {
+	options := &model.ScanOptions{
+		Concurrency: 10,
+		RateLimit:   100,
+		Timeout:     30,
+		Retries:     3,
+		Headless:    false,
+	}
+	if len(data) > 0 {
+		_ = json.Unmarshal(data, options)
+	}
+	return options
+}
+
+// decodeLabels decodes stored scan labels, returning nil when none are set
+func decodeLabels(data []byte) map[string]string {
+	var labels map[string]string
+	if len(data) > 0 {
+		_ = json.Unmarshal(data, &labels)
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// decodeResultSummary decodes a stored result summary, returning nil when
+// none was stored
+func decodeResultSummary(data []byte) *model.ResultSummary {
+	if len(data) == 0 {
+		return nil
+	}
+	var summary model.ResultSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil
+	}
+	return &summary
+}
+
+// encodeMetadata encodes result metadata for the JSONB column, storing NULL when there is none
+func encodeMetadata(metadata map[string]interface{}) (interface{}, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(metadata)
+}
+
 // Helper function to safely dereference string pointers for logging
 // func safePtr(s *string) string {
 // 	if s == nil {
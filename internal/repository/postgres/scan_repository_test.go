@@ -0,0 +1,954 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+)
+
+var (
+	spanExporterOnce sync.Once
+	spanExporter     *tracetest.InMemoryExporter
+)
+
+// recordSpans installs a global tracer provider recording to an in-memory
+// exporter, once per test binary since the package tracer delegates to the
+// first provider installed, and clears the spans recorded so far
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	spanExporterOnce.Do(func() {
+		spanExporter = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+	})
+	spanExporter.Reset()
+	return spanExporter
+}
+
+// newUnreachableDB returns a database handle whose connections are refused,
+// so every query fails without a running Postgres
+func newUnreachableDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 user=postgres dbname=nuclei sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestScanRepositoryQuerySpans(t *testing.T) {
+	repo := NewScanRepository(newUnreachableDB(t), &config.Config{}, zap.NewNop())
+	scan := &model.Scan{ID: "scan-1", Target: "http://example.com", Status: model.ScanStatusRunning}
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{"ScanRepository.List", func(ctx context.Context) error {
+			_, err := repo.List(ctx, nil, nil, nil, nil, model.ScanOrderPriority, model.Page{Limit: 10})
+			return err
+		}},
+		{"ScanRepository.Get", func(ctx context.Context) error {
+			_, err := repo.Get(ctx, scan.ID)
+			return err
+		}},
+		{"ScanRepository.Create", func(ctx context.Context) error {
+			return repo.Create(ctx, &model.Scan{Target: "http://example.com", Status: model.ScanStatusPending})
+		}},
+		{"ScanRepository.Update", func(ctx context.Context) error {
+			return repo.Update(ctx, scan)
+		}},
+		{"ScanRepository.UpdateStatus", func(ctx context.Context) error {
+			return repo.UpdateStatus(ctx, scan, model.ScanStatusPending)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := recordSpans(t)
+
+			if err := tt.call(context.Background()); err == nil {
+				t.Fatal("query against an unreachable database succeeded")
+			}
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("recorded %d spans, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name != tt.name {
+				t.Errorf("span name = %q, want %q", span.Name, tt.name)
+			}
+			if !hasAttribute(span.Attributes, attribute.String("db.system", "postgresql")) {
+				t.Errorf("span attributes = %v, want db.system=postgresql", span.Attributes)
+			}
+			if span.Status.Code != codes.Error {
+				t.Errorf("span status = %v, want %v for the failed query", span.Status.Code, codes.Error)
+			}
+		})
+	}
+}
+
+// hasAttribute reports whether attrs contains want
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
+
+// createTestScan stores a pending scan of target and returns it
+func createTestScan(t testing.TB, repo *ScanRepository, target string) *model.Scan {
+	t.Helper()
+
+	scan := &model.Scan{
+		ID:       model.NewUUID(),
+		Target:   target,
+		Targets:  []string{target},
+		Status:   model.ScanStatusPending,
+		Priority: model.ScanPriorityNormal,
+	}
+	if err := repo.Create(context.Background(), scan); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return scan
+}
+
+func TestScanRepositoryAddResultSkipsDuplicates(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	scan := createTestScan(t, repo, "http://example.com")
+
+	result := &model.ScanResult{
+		ScanID:      scan.ID,
+		TemplateID:  "cve-2021-1234",
+		Severity:    "high",
+		Matched:     true,
+		Host:        "http://example.com",
+		MatchedAt:   time.Now(),
+		MatcherName: "status",
+	}
+	inserted, err := repo.AddResult(ctx, result)
+	if err != nil || !inserted {
+		t.Fatalf("first AddResult() = %v, %v, want true, nil", inserted, err)
+	}
+	inserted, err = repo.AddResult(ctx, result)
+	if err != nil || inserted {
+		t.Fatalf("second AddResult() = %v, %v, want false, nil", inserted, err)
+	}
+
+	count, err := repo.CountResults(ctx, scan.ID, model.ResultFilter{})
+	if err != nil {
+		t.Fatalf("CountResults() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("stored %d results, want 1", count)
+	}
+}
+
+// testResults returns n distinct info results of a scan
+func testResults(scanID string, n int) []*model.ScanResult {
+	results := make([]*model.ScanResult, n)
+	for i := range results {
+		results[i] = &model.ScanResult{
+			ScanID:      scanID,
+			TemplateID:  fmt.Sprintf("template-%d", i),
+			Severity:    "info",
+			Matched:     true,
+			Host:        "http://example.com",
+			MatchedAt:   time.Now(),
+			MatcherName: "status",
+			Metadata:    map[string]interface{}{"index": i},
+		}
+	}
+	return results
+}
+
+func TestScanRepositoryAddResultsBatch(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	scan := createTestScan(t, repo, "http://example.com")
+
+	// More results than fit in one statement, and a duplicate
+	results := testResults(scan.ID, resultBatchSize+10)
+	duplicate := *results[0]
+	results = append(results, &duplicate)
+
+	inserted, err := repo.AddResultsBatch(ctx, nil, results)
+	if err != nil {
+		t.Fatalf("AddResultsBatch() error = %v", err)
+	}
+	if want := resultBatchSize + 10; inserted != want {
+		t.Errorf("AddResultsBatch() = %d, want %d without the duplicate", inserted, want)
+	}
+	count, err := repo.CountResults(ctx, scan.ID, model.ResultFilter{})
+	if err != nil {
+		t.Fatalf("CountResults() error = %v", err)
+	}
+	if count != inserted {
+		t.Errorf("stored %d results, want %d", count, inserted)
+	}
+
+	if inserted, err := repo.AddResultsBatch(ctx, nil, nil); inserted != 0 || err != nil {
+		t.Errorf("AddResultsBatch(nil) = %d, %v, want 0, nil", inserted, err)
+	}
+}
+
+func TestScanRepositoryCompletesInTransaction(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	scan := createTestScan(t, repo, "http://example.com")
+	scan.Status = model.ScanStatusRunning
+	if err := repo.UpdateStatus(ctx, scan, model.ScanStatusPending); err != nil {
+		t.Fatalf("UpdateStatus(running) error = %v", err)
+	}
+	countResults := func() int {
+		t.Helper()
+		count, err := repo.CountResults(ctx, scan.ID, model.ResultFilter{})
+		if err != nil {
+			t.Fatalf("CountResults() error = %v", err)
+		}
+		return count
+	}
+
+	// The second statement of the batch fails on a result of an unknown
+	// scan, and rolling back keeps the first one too
+	tx, err := repo.BeginTx(ctx)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	results := append(testResults(scan.ID, resultBatchSize), testResults(model.NewUUID(), 1)...)
+	if _, err := repo.AddResultsBatch(ctx, tx, results); err == nil {
+		t.Fatal("AddResultsBatch() with a result of an unknown scan succeeded")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if count := countResults(); count != 0 {
+		t.Fatalf("stored %d results after the rollback, want none", count)
+	}
+
+	// Results, summary and status are stored together on commit
+	tx, err = repo.BeginTx(ctx)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := repo.AddResultsBatch(ctx, tx, testResults(scan.ID, 3)); err != nil {
+		t.Fatalf("AddResultsBatch() error = %v", err)
+	}
+	summary, err := repo.GetResultSummaryWithTx(ctx, tx, scan.ID)
+	if err != nil {
+		t.Fatalf("GetResultSummaryWithTx() error = %v", err)
+	}
+	want := model.ResultSummary{Info: 3}
+	if *summary != want {
+		t.Errorf("GetResultSummaryWithTx() = %+v, want %+v", *summary, want)
+	}
+	scan.Status = model.ScanStatusCompleted
+	scan.ResultSummary = summary
+	if err := repo.UpdateStatusWithTx(ctx, tx, scan, model.ScanStatusRunning); err != nil {
+		t.Fatalf("UpdateStatusWithTx() error = %v", err)
+	}
+	if count := countResults(); count != 0 {
+		t.Errorf("stored %d results before the commit, want none", count)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	stored, err := repo.Get(ctx, scan.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if stored.Status != model.ScanStatusCompleted || stored.ResultSummary == nil || *stored.ResultSummary != want {
+		t.Errorf("stored scan = %s with summary %+v, want completed with %+v", stored.Status, stored.ResultSummary, want)
+	}
+	if count := countResults(); count != 3 {
+		t.Errorf("stored %d results, want 3", count)
+	}
+}
+
+func BenchmarkScanRepositoryAddResults(b *testing.B) {
+	repo := NewScanRepository(newTestDB(b), testConfig(), zap.NewNop())
+	ctx := context.Background()
+
+	for _, n := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("single/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				results := testResults(createTestScan(b, repo, "http://example.com").ID, n)
+				b.StartTimer()
+
+				for _, result := range results {
+					if _, err := repo.AddResult(ctx, result); err != nil {
+						b.Fatalf("AddResult() error = %v", err)
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("batch/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				results := testResults(createTestScan(b, repo, "http://example.com").ID, n)
+				b.StartTimer()
+
+				if _, err := repo.AddResultsBatch(ctx, nil, results); err != nil {
+					b.Fatalf("AddResultsBatch() error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestScanRepositoryStoresResultMetadata(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	scan := createTestScan(t, repo, "http://example.com")
+
+	// Metadata as the nuclei service builds it from a rich event, including
+	// a nested interaction
+	metadata := map[string]interface{}{
+		"ip":           "93.184.216.34",
+		"timestamp":    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		"type":         "http",
+		"curl_command": "curl -X GET http://example.com",
+		"interaction": map[string]interface{}{
+			"protocol":  "dns",
+			"unique-id": "c59t2cp2l1d3q0f7n8i0",
+		},
+	}
+	result := &model.ScanResult{
+		ScanID:     scan.ID,
+		TemplateID: "cve-2021-1234",
+		Severity:   "high",
+		Matched:    true,
+		Host:       "http://example.com",
+		MatchedAt:  time.Now(),
+		Metadata:   metadata,
+	}
+	if _, err := repo.AddResult(ctx, result); err != nil {
+		t.Fatalf("AddResult() error = %v", err)
+	}
+
+	results, err := repo.GetResults(ctx, scan.ID, model.ResultFilter{}, model.Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetResults() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("GetResults() returned %d results, want 1", len(results))
+	}
+
+	// Values come back as decoded JSON
+	encoded, _ := json.Marshal(metadata)
+	var want map[string]interface{}
+	json.Unmarshal(encoded, &want)
+	if got := results[0].Metadata; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored metadata = %v, want %v", got, want)
+	}
+}
+
+func TestEncodeMetadata(t *testing.T) {
+	for _, metadata := range []map[string]interface{}{nil, {}} {
+		if encoded, err := encodeMetadata(metadata); encoded != nil || err != nil {
+			t.Errorf("encodeMetadata(%v) = %v, %v, want nil, nil", metadata, encoded, err)
+		}
+	}
+
+	encoded, err := encodeMetadata(map[string]interface{}{"type": "http"})
+	if err != nil {
+		t.Fatalf("encodeMetadata() error = %v", err)
+	}
+	if got, ok := encoded.([]byte); !ok || string(got) != `{"type":"http"}` {
+		t.Errorf("encodeMetadata() = %v, want the JSON object", encoded)
+	}
+}
+
+func TestScanRepositoryGetResultsPages(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	scan := createTestScan(t, repo, "http://example.com")
+
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 150; i++ {
+		result := &model.ScanResult{
+			ScanID:     scan.ID,
+			TemplateID: fmt.Sprintf("template-%03d", i),
+			Severity:   "info",
+			Host:       "http://example.com",
+			MatchedAt:  start.Add(time.Duration(i) * time.Second),
+		}
+		if _, err := repo.AddResult(ctx, result); err != nil {
+			t.Fatalf("AddResult() error = %v", err)
+		}
+	}
+
+	count, err := repo.CountResults(ctx, scan.ID, model.ResultFilter{})
+	if err != nil {
+		t.Fatalf("CountResults() error = %v", err)
+	}
+	if count != 150 {
+		t.Fatalf("CountResults() = %d, want 150", count)
+	}
+
+	tests := []struct {
+		offset    int
+		wantCount int
+		wantFirst string
+	}{
+		{0, 50, "template-000"},
+		{50, 50, "template-050"},
+		{100, 50, "template-100"},
+		{140, 10, "template-140"},
+		{150, 0, ""},
+	}
+	for _, tt := range tests {
+		results, err := repo.GetResults(ctx, scan.ID, model.ResultFilter{}, model.Page{Limit: 50, Offset: tt.offset})
+		if err != nil {
+			t.Fatalf("GetResults(offset %d) error = %v", tt.offset, err)
+		}
+		if len(results) != tt.wantCount {
+			t.Errorf("GetResults(offset %d) returned %d results, want %d", tt.offset, len(results), tt.wantCount)
+			continue
+		}
+		if tt.wantCount > 0 && results[0].TemplateID != tt.wantFirst {
+			t.Errorf("GetResults(offset %d) starts at %s, want %s", tt.offset, results[0].TemplateID, tt.wantFirst)
+		}
+	}
+}
+
+func TestScanRepositoryGetResultsFilters(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	scan := createTestScan(t, repo, "http://example.com")
+
+	fixtures := []*model.ScanResult{
+		{TemplateID: "cve-2021-1234", Severity: "critical", Matched: true},
+		{TemplateID: "cve-2022-5678", Severity: "critical", Matched: true},
+		{TemplateID: "exposed-panel", Severity: "medium", Matched: true},
+		{TemplateID: "tech-detect", Severity: "info", Matched: false},
+	}
+	for _, result := range fixtures {
+		result.ScanID = scan.ID
+		result.Host = "http://example.com"
+		result.MatchedAt = time.Now()
+		if _, err := repo.AddResult(ctx, result); err != nil {
+			t.Fatalf("AddResult() error = %v", err)
+		}
+	}
+
+	critical, unknown, panel := "critical", "catastrophic", "exposed-panel"
+	matched := false
+	tests := []struct {
+		name   string
+		filter model.ResultFilter
+		want   []string
+	}{
+		{"no filter", model.ResultFilter{}, []string{"cve-2021-1234", "cve-2022-5678", "exposed-panel", "tech-detect"}},
+		{"severity", model.ResultFilter{Severity: &critical}, []string{"cve-2021-1234", "cve-2022-5678"}},
+		{"unknown severity", model.ResultFilter{Severity: &unknown}, nil},
+		{"template", model.ResultFilter{TemplateID: &panel}, []string{"exposed-panel"}},
+		{"unmatched", model.ResultFilter{Matched: &matched}, []string{"tech-detect"}},
+		{"severity and template", model.ResultFilter{Severity: &critical, TemplateID: &panel}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.GetResults(ctx, scan.ID, tt.filter, model.Page{Limit: 10})
+			if err != nil {
+				t.Fatalf("GetResults() error = %v", err)
+			}
+			var ids []string
+			for _, result := range results {
+				ids = append(ids, result.TemplateID)
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("GetResults() = %v, want %v", ids, tt.want)
+			}
+			count, err := repo.CountResults(ctx, scan.ID, tt.filter)
+			if err != nil {
+				t.Fatalf("CountResults() error = %v", err)
+			}
+			if count != len(tt.want) {
+				t.Errorf("CountResults() = %d, want %d", count, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestScanRepositoryStoresTargets(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+
+	targets := []string{"http://a.example.com", "https://b.example.com:8443/app"}
+	scan := &model.Scan{
+		ID:       model.NewUUID(),
+		Target:   targets[0],
+		Targets:  targets,
+		Status:   model.ScanStatusPending,
+		Priority: model.ScanPriorityNormal,
+	}
+	if err := repo.Create(ctx, scan); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	stored, err := repo.Get(ctx, scan.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if stored.Target != targets[0] || !slices.Equal(stored.Targets, targets) {
+		t.Errorf("Get() target %q and targets %v, want %q and %v", stored.Target, stored.Targets, targets[0], targets)
+	}
+}
+
+func TestScanRepositoryListByTarget(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+
+	target := "https://a.example.com/app"
+	first := createTestScan(t, repo, target)
+	second := createTestScan(t, repo, target)
+	createTestScan(t, repo, "https://b.example.com")
+
+	scans, err := repo.List(ctx, nil, &target, nil, nil, model.ScanOrderNewest, model.Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	ids := make([]string, len(scans))
+	for i, scan := range scans {
+		ids[i] = scan.ID
+	}
+	slices.Sort(ids)
+	want := []string{first.ID, second.ID}
+	slices.Sort(want)
+	if !slices.Equal(ids, want) {
+		t.Errorf("List(target %s) = %v, want %v", target, ids, want)
+	}
+
+	count, err := repo.Count(ctx, nil, &target, nil, nil)
+	if err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Count(target %s) = %d, want 2", target, count)
+	}
+}
+
+func TestScanRepositoryStoresScanError(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	scan := createTestScan(t, repo, "http://example.com")
+
+	scan.Status = model.ScanStatusRunning
+	if err := repo.UpdateStatus(ctx, scan, model.ScanStatusPending); err != nil {
+		t.Fatalf("UpdateStatus(running) error = %v", err)
+	}
+	scan.Status = model.ScanStatusFailed
+	scan.Error = "nuclei engine failed: context deadline exceeded"
+	if err := repo.UpdateStatus(ctx, scan, model.ScanStatusRunning); err != nil {
+		t.Fatalf("UpdateStatus(failed) error = %v", err)
+	}
+
+	stored, err := repo.Get(ctx, scan.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if stored.Status != model.ScanStatusFailed {
+		t.Errorf("Status = %q, want %q", stored.Status, model.ScanStatusFailed)
+	}
+	if stored.Error != scan.Error {
+		t.Errorf("Error = %q, want %q", stored.Error, scan.Error)
+	}
+
+	scans, err := repo.List(ctx, nil, nil, nil, nil, model.ScanOrderNewest, model.Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(scans) != 1 || scans[0].Error != scan.Error {
+		t.Errorf("List() = %v, want the failed scan with its error", scans)
+	}
+}
+
+func TestScanRepositoryTags(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+
+	tagged := map[string][]string{
+		"http://ci.example.com":      {"ci", "nightly"},
+		"http://nightly.example.com": {"nightly"},
+		"http://adhoc.example.com":   nil,
+	}
+	ids := map[string]string{}
+	for target, tags := range tagged {
+		scan := &model.Scan{
+			ID:      model.NewUUID(),
+			Target:  target,
+			Targets: []string{target},
+			Status:  model.ScanStatusPending,
+			Tags:    tags,
+		}
+		if err := repo.Create(ctx, scan); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		ids[target] = scan.ID
+	}
+
+	stored, err := repo.Get(ctx, ids["http://ci.example.com"])
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !slices.Equal(stored.Tags, []string{"ci", "nightly"}) {
+		t.Errorf("Get() tags = %v, want [ci nightly]", stored.Tags)
+	}
+
+	tests := []struct {
+		name        string
+		tags        []string
+		wantTargets []string
+	}{
+		{"no filter", nil, []string{"http://adhoc.example.com", "http://ci.example.com", "http://nightly.example.com"}},
+		{"one tag", []string{"nightly"}, []string{"http://ci.example.com", "http://nightly.example.com"}},
+		{"every tag must match", []string{"ci", "nightly"}, []string{"http://ci.example.com"}},
+		{"unknown tag", []string{"weekly"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scans, err := repo.List(ctx, nil, nil, nil, tt.tags, model.ScanOrderNewest, model.Page{Limit: 10})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			var targets []string
+			for _, scan := range scans {
+				targets = append(targets, scan.Target)
+			}
+			slices.Sort(targets)
+			if !slices.Equal(targets, tt.wantTargets) {
+				t.Errorf("List() targets = %v, want %v", targets, tt.wantTargets)
+			}
+
+			count, err := repo.Count(ctx, nil, nil, nil, tt.tags)
+			if err != nil {
+				t.Fatalf("Count() error = %v", err)
+			}
+			if count != len(tt.wantTargets) {
+				t.Errorf("Count() = %d, want %d", count, len(tt.wantTargets))
+			}
+		})
+	}
+}
+
+func TestScanRepositoryListByPriority(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+
+	submitted := []struct {
+		target   string
+		priority int
+	}{
+		{"http://low.example.com", model.ScanPriorityLow},
+		{"http://normal-1.example.com", model.ScanPriorityNormal},
+		{"http://high.example.com", model.ScanPriorityHigh},
+		{"http://normal-2.example.com", model.ScanPriorityNormal},
+	}
+	for _, s := range submitted {
+		scan := &model.Scan{
+			ID:       model.NewUUID(),
+			Target:   s.target,
+			Targets:  []string{s.target},
+			Status:   model.ScanStatusPending,
+			Priority: s.priority,
+		}
+		if err := repo.Create(ctx, scan); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	pending := model.ScanStatusPending
+	scans, err := repo.List(ctx, &pending, nil, nil, nil, model.ScanOrderPriority, model.Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	var targets []string
+	for _, scan := range scans {
+		targets = append(targets, scan.Target)
+	}
+	want := []string{"http://high.example.com", "http://normal-1.example.com", "http://normal-2.example.com", "http://low.example.com"}
+	if !slices.Equal(targets, want) {
+		t.Errorf("List() = %v, want %v", targets, want)
+	}
+}
+
+func TestScanRepositoryCountByStatus(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+
+	seeded := map[model.ScanStatus]int{
+		model.ScanStatusPending:   3,
+		model.ScanStatusRunning:   2,
+		model.ScanStatusCompleted: 4,
+		model.ScanStatusFailed:    1,
+	}
+	for status, n := range seeded {
+		for i := 0; i < n; i++ {
+			scan := createTestScan(t, repo, "http://example.com")
+			if status == model.ScanStatusPending {
+				continue
+			}
+			scan.Status = status
+			if err := repo.UpdateStatus(ctx, scan, model.ScanStatusPending); err != nil {
+				t.Fatalf("UpdateStatus(%s) error = %v", status, err)
+			}
+		}
+	}
+
+	for _, status := range []model.ScanStatus{model.ScanStatusPending, model.ScanStatusRunning, model.ScanStatusCompleted, model.ScanStatusFailed, model.ScanStatusCancelled} {
+		count, err := repo.CountByStatus(ctx, status)
+		if err != nil {
+			t.Fatalf("CountByStatus(%s) error = %v", status, err)
+		}
+		if count != seeded[status] {
+			t.Errorf("CountByStatus(%s) = %d, want %d", status, count, seeded[status])
+		}
+	}
+
+	byStatus, err := repo.CountAllByStatus(ctx)
+	if err != nil {
+		t.Fatalf("CountAllByStatus() error = %v", err)
+	}
+	want := map[string]int{}
+	for status, n := range seeded {
+		want[status] = n
+	}
+	if !maps.Equal(byStatus, want) {
+		t.Errorf("CountAllByStatus() = %v, want %v", byStatus, want)
+	}
+}
+
+func TestScanRepositoryUpdateLabels(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	scan := createTestScan(t, repo, "http://example.com")
+
+	updates := []struct {
+		name   string
+		labels map[string]string
+		want   map[string]string
+	}{
+		{"first labels", map[string]string{"project": "web-app", "ticket": "SEC-123"},
+			map[string]string{"project": "web-app", "ticket": "SEC-123"}},
+		{"merge keeps other labels", map[string]string{"env": "staging"},
+			map[string]string{"project": "web-app", "ticket": "SEC-123", "env": "staging"}},
+		{"overwrite existing label", map[string]string{"ticket": "SEC-456"},
+			map[string]string{"project": "web-app", "ticket": "SEC-456", "env": "staging"}},
+	}
+	for _, tt := range updates {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := repo.UpdateLabels(ctx, scan.ID, tt.labels); err != nil {
+				t.Fatalf("UpdateLabels() error = %v", err)
+			}
+			stored, err := repo.Get(ctx, scan.ID)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if !maps.Equal(stored.Labels, tt.want) {
+				t.Errorf("labels = %v, want %v", stored.Labels, tt.want)
+			}
+		})
+	}
+
+	if err := repo.UpdateLabels(ctx, model.NewUUID(), map[string]string{"project": "web-app"}); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("UpdateLabels(unknown scan) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestScanRepositoryResultSummary(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	scan := createTestScan(t, repo, "http://example.com")
+
+	severities := []string{"critical", "critical", "HIGH", "medium", "low", "low", "low", "info", "unknown"}
+	for i, severity := range severities {
+		result := &model.ScanResult{
+			ScanID:     scan.ID,
+			TemplateID: fmt.Sprintf("template-%d", i),
+			Severity:   severity,
+			Host:       "http://example.com",
+			MatchedAt:  time.Now(),
+		}
+		if _, err := repo.AddResult(ctx, result); err != nil {
+			t.Fatalf("AddResult() error = %v", err)
+		}
+	}
+
+	summary, err := repo.GetResultSummary(ctx, scan.ID)
+	if err != nil {
+		t.Fatalf("GetResultSummary() error = %v", err)
+	}
+	want := model.ResultSummary{Critical: 2, High: 1, Medium: 1, Low: 3, Info: 1}
+	if *summary != want {
+		t.Errorf("GetResultSummary() = %+v, want %+v", *summary, want)
+	}
+
+	// The worker stores the summary with the scan's final status
+	scan.Status = model.ScanStatusRunning
+	if err := repo.UpdateStatus(ctx, scan, model.ScanStatusPending); err != nil {
+		t.Fatalf("UpdateStatus(running) error = %v", err)
+	}
+	scan.Status = model.ScanStatusCompleted
+	scan.ResultSummary = summary
+	if err := repo.UpdateStatus(ctx, scan, model.ScanStatusRunning); err != nil {
+		t.Fatalf("UpdateStatus(completed) error = %v", err)
+	}
+	stored, err := repo.Get(ctx, scan.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if stored.ResultSummary == nil || *stored.ResultSummary != want {
+		t.Errorf("stored summary = %+v, want %+v", stored.ResultSummary, want)
+	}
+}
+
+func TestScanRepositoryFailStaleScans(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewScanRepository(db, testConfig(), zap.NewNop())
+	ctx := context.Background()
+
+	running := func(target string) *model.Scan {
+		scan := createTestScan(t, repo, target)
+		scan.Status = model.ScanStatusRunning
+		if err := repo.UpdateStatus(ctx, scan, model.ScanStatusPending); err != nil {
+			t.Fatalf("UpdateStatus(running) error = %v", err)
+		}
+		return scan
+	}
+	stalled := running("http://stalled.example.com")
+	alive := running("http://alive.example.com")
+	pending := createTestScan(t, repo, "http://pending.example.com")
+
+	// The stalled scan's worker stopped sending heartbeats ten minutes ago
+	if _, err := db.ExecContext(ctx, `UPDATE scans SET last_heartbeat_at = NOW() - INTERVAL '10 minutes', updated_at = NOW() - INTERVAL '10 minutes' WHERE id = $1`, stalled.ID); err != nil {
+		t.Fatalf("backdating heartbeat: %v", err)
+	}
+	if err := repo.Heartbeat(ctx, alive.ID); err != nil {
+		t.Fatalf("Heartbeat() error = %v", err)
+	}
+
+	failed, err := repo.FailStaleScans(ctx, time.Now().Add(-time.Minute), "scan stalled")
+	if err != nil {
+		t.Fatalf("FailStaleScans() error = %v", err)
+	}
+	if failed != 1 {
+		t.Errorf("FailStaleScans() = %d, want 1", failed)
+	}
+
+	tests := []struct {
+		scan       *model.Scan
+		wantStatus model.ScanStatus
+		wantError  string
+	}{
+		{stalled, model.ScanStatusFailed, "scan stalled"},
+		{alive, model.ScanStatusRunning, ""},
+		{pending, model.ScanStatusPending, ""},
+	}
+	for _, tt := range tests {
+		stored, err := repo.Get(ctx, tt.scan.ID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if stored.Status != tt.wantStatus || stored.Error != tt.wantError {
+			t.Errorf("%s = %q %q, want %q %q", tt.scan.Target, stored.Status, stored.Error, tt.wantStatus, tt.wantError)
+		}
+	}
+}
+
+func TestScanRepositoryFalsePositives(t *testing.T) {
+	repo := NewScanRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	scan := createTestScan(t, repo, "http://example.com")
+
+	for _, templateID := range []string{"cve-2021-1234", "tech-detect"} {
+		result := &model.ScanResult{ScanID: scan.ID, TemplateID: templateID, Severity: "high", Host: "http://example.com", MatchedAt: time.Now()}
+		if _, err := repo.AddResult(ctx, result); err != nil {
+			t.Fatalf("AddResult() error = %v", err)
+		}
+	}
+	results, err := repo.GetResults(ctx, scan.ID, model.ResultFilter{}, model.Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetResults() error = %v", err)
+	}
+	var falsePositive *model.ScanResult
+	for _, result := range results {
+		if result.TemplateID == "tech-detect" {
+			falsePositive = result
+		}
+	}
+	if falsePositive == nil {
+		t.Fatalf("GetResults() = %d results, want tech-detect among them", len(results))
+	}
+
+	if err := repo.UpdateResultFalsePositive(ctx, scan.ID, falsePositive.ID, true, "banner only"); err != nil {
+		t.Fatalf("UpdateResultFalsePositive() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter model.ResultFilter
+		want   []string
+	}{
+		{"excluded by default", model.ResultFilter{}, []string{"cve-2021-1234"}},
+		{"included on request", model.ResultFilter{IncludeFalsePositives: true}, []string{"cve-2021-1234", "tech-detect"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := repo.GetResults(ctx, scan.ID, tt.filter, model.Page{Limit: 10})
+			if err != nil {
+				t.Fatalf("GetResults() error = %v", err)
+			}
+			var ids []string
+			for _, result := range results {
+				ids = append(ids, result.TemplateID)
+				if result.TemplateID == "tech-detect" && (!result.IsFalsePositive || result.FalsePositiveNote != "banner only") {
+					t.Errorf("tech-detect = %v %q, want marked with its note", result.IsFalsePositive, result.FalsePositiveNote)
+				}
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("GetResults() = %v, want %v", ids, tt.want)
+			}
+			count, err := repo.CountResults(ctx, scan.ID, tt.filter)
+			if err != nil {
+				t.Fatalf("CountResults() error = %v", err)
+			}
+			if count != len(tt.want) {
+				t.Errorf("CountResults() = %d, want %d", count, len(tt.want))
+			}
+		})
+	}
+
+	if err := repo.UpdateResultFalsePositive(ctx, scan.ID, model.NewUUID(), true, ""); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("UpdateResultFalsePositive(unknown result) error = %v, want ErrNotFound", err)
+	}
+}
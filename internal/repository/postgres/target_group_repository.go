@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/config"
+	apperrors "nuclei-service-demo/internal/errors"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+)
+
+// TargetGroupRepository implements repository.TargetGroupRepository
+type TargetGroupRepository struct {
+	db     *sql.DB
+	cfg    *config.Config
+	logger *zap.Logger
+}
+
+// NewTargetGroupRepository creates a new target group repository
+func NewTargetGroupRepository(db *sql.DB, cfg *config.Config, logger *zap.Logger) *TargetGroupRepository {
+	return &TargetGroupRepository{
+		db:     db,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// List returns all target groups
+func (r *TargetGroupRepository) List(ctx context.Context) ([]*model.TargetGroup, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Listing target groups from database")
+
+	// Build query
+	query := `
+		SELECT g.id, g.name, g.targets, g.created_at, g.updated_at
+		FROM target_groups g
+		ORDER BY g.name
+	`
+
+	// Execute query
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.logger.Error("Failed to execute target group list query", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+	defer rows.Close()
+
+	// Scan results
+	groups := []*model.TargetGroup{}
+	for rows.Next() {
+		var group model.TargetGroup
+		if err := rows.Scan(
+			&group.ID,
+			&group.Name,
+			pq.Array(&group.Targets),
+			&group.CreatedAt,
+			&group.UpdatedAt,
+		); err != nil {
+			r.logger.Error("Failed to scan target group row", zap.Error(err))
+			return nil, apperrors.WrapPostgresError(err)
+		}
+		groups = append(groups, &group)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate target group rows", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Retrieved target groups from database", zap.Int("count", len(groups)))
+	return groups, nil
+}
+
+// Get returns a target group by ID
+func (r *TargetGroupRepository) Get(ctx context.Context, id string) (*model.TargetGroup, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Getting target group from database", zap.String("id", id))
+
+	// Build query
+	query := `
+		SELECT g.id, g.name, g.targets, g.created_at, g.updated_at
+		FROM target_groups g
+		WHERE g.id = $1
+	`
+
+	// Execute query
+	var group model.TargetGroup
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&group.ID,
+		&group.Name,
+		pq.Array(&group.Targets),
+		&group.CreatedAt,
+		&group.UpdatedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			r.logger.Warn("Target group not found", zap.String("id", id))
+			return nil, repository.ErrNotFound
+		}
+		r.logger.Error("Failed to get target group", zap.Error(err), zap.String("id", id))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Retrieved target group from database", zap.String("id", id))
+	return &group, nil
+}
+
+// Create creates a new target group
+func (r *TargetGroupRepository) Create(ctx context.Context, group *model.TargetGroup) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Creating target group in database",
+		zap.String("id", group.ID),
+		zap.String("name", group.Name),
+		zap.Int("targets", len(group.Targets)))
+
+	// Build query
+	query := `
+		INSERT INTO target_groups (id, name, targets, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	// Execute query
+	_, err := r.db.ExecContext(ctx, query,
+		group.ID,
+		group.Name,
+		pq.Array(group.Targets),
+		group.CreatedAt,
+		group.UpdatedAt,
+	)
+	if err != nil {
+		r.logger.Error("Failed to create target group", zap.Error(err), zap.String("id", group.ID))
+		return apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Successfully created target group", zap.String("id", group.ID))
+	return nil
+}
+
+// Delete deletes a target group by ID
+func (r *TargetGroupRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Deleting target group from database", zap.String("id", id))
+
+	// Build query
+	query := `
+		DELETE FROM target_groups
+		WHERE id = $1
+	`
+
+	// Execute query
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		r.logger.Error("Failed to delete target group", zap.Error(err), zap.String("id", id))
+		return apperrors.WrapPostgresError(err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get deleted target group count", zap.Error(err), zap.String("id", id))
+		return apperrors.WrapPostgresError(err)
+	}
+	if deleted == 0 {
+		r.logger.Warn("Target group not found", zap.String("id", id))
+		return repository.ErrNotFound
+	}
+
+	r.logger.Info("Successfully deleted target group", zap.String("id", id))
+	return nil
+}
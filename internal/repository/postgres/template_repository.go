@@ -9,10 +9,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
 
 	"nuclei-service-demo/internal/config"
+	apperrors "nuclei-service-demo/internal/errors"
 	"nuclei-service-demo/internal/model"
 	"nuclei-service-demo/internal/repository"
 )
@@ -33,8 +35,11 @@ func NewTemplateRepository(db *sql.DB, cfg *config.Config, logger *zap.Logger) *
 	}
 }
 
-// List returns a list of templates
-func (r *TemplateRepository) List(ctx context.Context, tags, author, severity, templateType *string) ([]*model.Template, error) {
+// List returns a page of templates. A zero page limit returns every matching template.
+func (r *TemplateRepository) List(ctx context.Context, tags, author, severity, templateType *string, page model.Page) ([]*model.Template, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
 	r.logger.Info("Listing templates from database",
 		zap.String("tags", safePtr(tags)),
 		zap.String("author", safePtr(author)),
@@ -43,30 +48,13 @@ func (r *TemplateRepository) List(ctx context.Context, tags, author, severity, t
 
 	// Build query
 	query := `
-		SELECT t.id, t.path, t.author, t.severity
+		SELECT t.id, t.path, t.author, t.severity, t.tags
 		FROM templates t
 		WHERE 1=1
 	`
-	args := []interface{}{}
-
-	// Remove tags filter since the column doesn't exist
-	// if tags != nil {
-	// 	query += ` AND t.tags @> $1`
-	// 	args = append(args, *tags)
-	// }
-	if author != nil {
-		query += ` AND t.author = $1`
-		args = append(args, *author)
-	}
-	if severity != nil {
-		query += ` AND t.severity = $2`
-		args = append(args, *severity)
-	}
-	// Remove type filter since the column doesn't exist
-	// if templateType != nil {
-	// 	query += ` AND t.type = $4`
-	// 	args = append(args, *templateType)
-	// }
+	query, args := appendTemplateFilter(query, nil, tags, author, severity, templateType)
+	query += ` ORDER BY t.id`
+	query, args = appendPage(query, args, page)
 
 	r.logger.Info("Executing template list query",
 		zap.String("query", query),
@@ -76,26 +64,26 @@ func (r *TemplateRepository) List(ctx context.Context, tags, author, severity, t
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		r.logger.Error("Failed to execute template list query", zap.Error(err))
-		return nil, err
+		return nil, apperrors.WrapPostgresError(err)
 	}
 	defer rows.Close()
 
 	// Scan results
 	var templates []*model.Template
 	for rows.Next() {
-		var template model.Template
+		template := model.Template{Tags: []string{}}
 		if err := rows.Scan(
 			&template.ID,
 			&template.Path,
 			&template.Author,
 			&template.Severity,
+			pq.Array(&template.Tags),
 		); err != nil {
 			r.logger.Error("Failed to scan template row", zap.Error(err))
-			return nil, err
+			return nil, apperrors.WrapPostgresError(err)
 		}
 		// Set default values for missing columns
 		template.Type = "unknown"
-		template.Tags = []string{}
 		templates = append(templates, &template)
 	}
 
@@ -103,13 +91,61 @@ func (r *TemplateRepository) List(ctx context.Context, tags, author, severity, t
 	return templates, nil
 }
 
+// Count returns the number of templates matching the filters
+func (r *TemplateRepository) Count(ctx context.Context, tags, author, severity, templateType *string) (int, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	// Build query
+	query := `
+		SELECT COUNT(*)
+		FROM templates t
+		WHERE 1=1
+	`
+	query, args := appendTemplateFilter(query, nil, tags, author, severity, templateType)
+
+	// Execute query
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		r.logger.Error("Failed to count templates", zap.Error(err))
+		return 0, apperrors.WrapPostgresError(err)
+	}
+
+	return count, nil
+}
+
+// appendTemplateFilter adds the template list filters to query
+func appendTemplateFilter(query string, args []interface{}, tags, author, severity, templateType *string) (string, []interface{}) {
+	if tags != nil {
+		args = append(args, *tags)
+		query += fmt.Sprintf(` AND $%d = ANY(t.tags)`, len(args))
+	}
+	if author != nil {
+		args = append(args, *author)
+		query += fmt.Sprintf(` AND t.author = $%d`, len(args))
+	}
+	if severity != nil {
+		args = append(args, *severity)
+		query += fmt.Sprintf(` AND t.severity = $%d`, len(args))
+	}
+	// Remove type filter since the column doesn't exist
+	// if templateType != nil {
+	// 	query += ` AND t.type = $4`
+	// 	args = append(args, *templateType)
+	// }
+	return query, args
+}
+
 // Get returns a template by ID
 func (r *TemplateRepository) Get(ctx context.Context, id string) (*model.Template, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
 	r.logger.Info("Getting template from database", zap.String("id", id))
 
 	// Build query
 	query := `
-		SELECT t.id, t.path, t.author, t.severity
+		SELECT t.id, t.path, t.author, t.severity, t.tags
 		FROM templates t
 		WHERE t.id = $1
 	`
@@ -117,31 +153,143 @@ func (r *TemplateRepository) Get(ctx context.Context, id string) (*model.Templat
 	r.logger.Info("Executing template get query", zap.String("query", query))
 
 	// Execute query
-	var template model.Template
+	template := model.Template{Tags: []string{}}
 	if err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&template.ID,
 		&template.Path,
 		&template.Author,
 		&template.Severity,
+		pq.Array(&template.Tags),
 	); err != nil {
 		if err == sql.ErrNoRows {
 			r.logger.Warn("Template not found", zap.String("id", id))
 			return nil, repository.ErrNotFound
 		}
 		r.logger.Error("Failed to get template", zap.Error(err), zap.String("id", id))
-		return nil, err
+		return nil, apperrors.WrapPostgresError(err)
 	}
 
 	// Set default values for missing columns
 	template.Type = "unknown"
-	template.Tags = []string{}
 
 	r.logger.Info("Retrieved template from database", zap.String("id", id))
 	return &template, nil
 }
 
+// Search returns templates matching a full-text query, best matches first
+func (r *TemplateRepository) Search(ctx context.Context, query string) ([]*model.Template, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Searching templates in database", zap.String("q", query))
+
+	// Build query
+	sqlQuery := `
+		SELECT t.id, t.name, t.path, t.author, t.severity, t.description, t.tags
+		FROM templates t, plainto_tsquery('english', $1) q
+		WHERE to_tsvector('english', t.name || ' ' || t.description || ' ' || t.id) @@ q
+		ORDER BY ts_rank(to_tsvector('english', t.name || ' ' || t.description || ' ' || t.id), q) DESC, t.id
+	`
+
+	r.logger.Info("Executing template search query", zap.String("query", sqlQuery))
+
+	// Execute query
+	rows, err := r.db.QueryContext(ctx, sqlQuery, query)
+	if err != nil {
+		r.logger.Error("Failed to execute template search query", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+	defer rows.Close()
+
+	// Scan results
+	templates := []*model.Template{}
+	for rows.Next() {
+		template := model.Template{Tags: []string{}}
+		if err := rows.Scan(
+			&template.ID,
+			&template.Name,
+			&template.Path,
+			&template.Author,
+			&template.Severity,
+			&template.Description,
+			pq.Array(&template.Tags),
+		); err != nil {
+			r.logger.Error("Failed to scan template row", zap.Error(err))
+			return nil, apperrors.WrapPostgresError(err)
+		}
+		// Set default values for missing columns
+		template.Type = "unknown"
+		templates = append(templates, &template)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate template rows", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Found templates in database", zap.Int("count", len(templates)))
+	return templates, nil
+}
+
+// ListByTags returns up to limit templates other than excludeID sharing at
+// least one of tags, those sharing the most tags first
+func (r *TemplateRepository) ListByTags(ctx context.Context, tags []string, excludeID string, limit int) ([]*model.Template, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Listing templates by tags",
+		zap.Strings("tags", tags),
+		zap.String("exclude_id", excludeID),
+		zap.Int("limit", limit))
+
+	// Build query
+	query := `
+		SELECT t.id, t.path, t.author, t.severity, t.tags
+		FROM templates t
+		WHERE t.id <> $2 AND t.tags && $1::text[]
+		ORDER BY cardinality(ARRAY(SELECT unnest(t.tags) INTERSECT SELECT unnest($1::text[]))) DESC, t.id
+		LIMIT $3
+	`
+
+	// Execute query
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(tags), excludeID, limit)
+	if err != nil {
+		r.logger.Error("Failed to execute template tags query", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+	defer rows.Close()
+
+	// Scan results
+	templates := []*model.Template{}
+	for rows.Next() {
+		template := model.Template{Tags: []string{}}
+		if err := rows.Scan(
+			&template.ID,
+			&template.Path,
+			&template.Author,
+			&template.Severity,
+			pq.Array(&template.Tags),
+		); err != nil {
+			r.logger.Error("Failed to scan template row", zap.Error(err))
+			return nil, apperrors.WrapPostgresError(err)
+		}
+		// Set default values for missing columns
+		template.Type = "unknown"
+		templates = append(templates, &template)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate template rows", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Retrieved related templates from database", zap.Int("count", len(templates)))
+	return templates, nil
+}
+
 // Create creates a new template
 func (r *TemplateRepository) Create(ctx context.Context, template *model.Template) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
 	r.logger.Info("Creating template in database",
 		zap.String("id", template.ID),
 		zap.String("author", template.Author),
@@ -149,8 +297,8 @@ func (r *TemplateRepository) Create(ctx context.Context, template *model.Templat
 
 	// Build query
 	query := `
-		INSERT INTO templates (id, path, author, severity)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO templates (id, name, path, author, severity, type, description, tags)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
 	r.logger.Info("Executing template create query", zap.String("query", query))
@@ -158,13 +306,17 @@ func (r *TemplateRepository) Create(ctx context.Context, template *model.Templat
 	// Execute query
 	_, err := r.db.ExecContext(ctx, query,
 		template.ID,
+		template.Name,
 		template.Path,
 		template.Author,
 		template.Severity,
+		template.Type,
+		template.Description,
+		pq.Array(template.Tags),
 	)
 	if err != nil {
 		r.logger.Error("Failed to create template", zap.Error(err), zap.String("id", template.ID))
-		return err
+		return apperrors.WrapPostgresError(err)
 	}
 
 	r.logger.Info("Successfully created template", zap.String("id", template.ID))
@@ -173,13 +325,16 @@ func (r *TemplateRepository) Create(ctx context.Context, template *model.Templat
 
 // Update updates a template
 func (r *TemplateRepository) Update(ctx context.Context, template *model.Template) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
 	r.logger.Info("Updating template in database", zap.String("id", template.ID))
 
 	// Build query
 	query := `
 		UPDATE templates
-		SET path = $1, author = $2, severity = $3
-		WHERE id = $4
+		SET path = $1, author = $2, severity = $3, tags = $4
+		WHERE id = $5
 	`
 
 	r.logger.Info("Executing template update query", zap.String("query", query))
@@ -189,11 +344,12 @@ func (r *TemplateRepository) Update(ctx context.Context, template *model.Templat
 		template.Path,
 		template.Author,
 		template.Severity,
+		pq.Array(template.Tags),
 		template.ID,
 	)
 	if err != nil {
 		r.logger.Error("Failed to update template", zap.Error(err), zap.String("id", template.ID))
-		return err
+		return apperrors.WrapPostgresError(err)
 	}
 
 	r.logger.Info("Successfully updated template", zap.String("id", template.ID))
@@ -202,6 +358,9 @@ func (r *TemplateRepository) Update(ctx context.Context, template *model.Templat
 
 // Delete deletes a template by ID
 func (r *TemplateRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
 	r.logger.Info("Deleting template from database", zap.String("id", id))
 
 	// Build query
@@ -216,7 +375,7 @@ func (r *TemplateRepository) Delete(ctx context.Context, id string) error {
 	_, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		r.logger.Error("Failed to delete template", zap.Error(err), zap.String("id", id))
-		return err
+		return apperrors.WrapPostgresError(err)
 	}
 
 	r.logger.Info("Successfully deleted template", zap.String("id", id))
@@ -225,6 +384,9 @@ func (r *TemplateRepository) Delete(ctx context.Context, id string) error {
 
 // Refresh refreshes the template cache
 func (r *TemplateRepository) Refresh(ctx context.Context) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
 	r.logger.Info("Refreshing template cache")
 
 	// Build query
@@ -238,20 +400,186 @@ func (r *TemplateRepository) Refresh(ctx context.Context) error {
 	_, err := r.db.ExecContext(ctx, query)
 	if err != nil {
 		r.logger.Error("Failed to refresh template cache", zap.Error(err))
-		return err
+		return apperrors.WrapPostgresError(err)
 	}
 
 	r.logger.Info("Successfully refreshed template cache")
 	return nil
 }
 
+// UpsertTemplate creates a template, or updates it when the stored copy was
+// loaded from an older file
+func (r *TemplateRepository) UpsertTemplate(ctx context.Context, template *model.Template) error {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Upserting template in database",
+		zap.String("id", template.ID),
+		zap.String("path", template.Path))
+
+	// Build query
+	query := `
+		INSERT INTO templates (id, name, path, author, severity, type, description, tags, last_modified_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE
+		SET name = EXCLUDED.name,
+			path = EXCLUDED.path,
+			author = EXCLUDED.author,
+			severity = EXCLUDED.severity,
+			type = EXCLUDED.type,
+			description = EXCLUDED.description,
+			tags = EXCLUDED.tags,
+			last_modified_at = EXCLUDED.last_modified_at,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE templates.last_modified_at IS NULL OR templates.last_modified_at < $9
+	`
+
+	// Execute query
+	_, err := r.db.ExecContext(ctx, query,
+		template.ID,
+		template.Name,
+		template.Path,
+		template.Author,
+		template.Severity,
+		template.Type,
+		template.Description,
+		pq.Array(template.Tags),
+		template.LastModifiedAt,
+	)
+	if err != nil {
+		r.logger.Error("Failed to upsert template", zap.Error(err), zap.String("id", template.ID))
+		return apperrors.WrapPostgresError(err)
+	}
+
+	return nil
+}
+
+// CheckIDCollision returns the path of a different file already stored under
+// id, or an empty string when the ID is free or belongs to path
+func (r *TemplateRepository) CheckIDCollision(ctx context.Context, id, path string) (string, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	// Build query
+	query := `
+		SELECT t.path
+		FROM templates t
+		WHERE t.id = $1 AND t.path <> $2
+	`
+
+	// Execute query
+	var existing string
+	if err := r.db.QueryRowContext(ctx, query, id, path).Scan(&existing); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		r.logger.Error("Failed to check template ID collision", zap.Error(err), zap.String("id", id))
+		return "", apperrors.WrapPostgresError(err)
+	}
+
+	return existing, nil
+}
+
+// ListModTimes returns the stored file modification time of each template, keyed by path
+func (r *TemplateRepository) ListModTimes(ctx context.Context) (map[string]time.Time, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	// Build query
+	query := `
+		SELECT path, last_modified_at
+		FROM templates
+		WHERE last_modified_at IS NOT NULL
+	`
+
+	// Execute query
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.logger.Error("Failed to list template modification times", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+	defer rows.Close()
+
+	// Scan results
+	modTimes := make(map[string]time.Time)
+	for rows.Next() {
+		var path string
+		var modTime time.Time
+		if err := rows.Scan(&path, &modTime); err != nil {
+			r.logger.Error("Failed to scan template modification time", zap.Error(err))
+			return nil, apperrors.WrapPostgresError(err)
+		}
+		modTimes[path] = modTime
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("Failed to iterate template modification times", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	return modTimes, nil
+}
+
+// DeleteMissing deletes templates whose path is not in paths and returns how many were deleted
+func (r *TemplateRepository) DeleteMissing(ctx context.Context, paths []string) (int, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Deleting templates missing from disk", zap.Int("known_paths", len(paths)))
+
+	// Build query
+	query := `
+		DELETE FROM templates
+		WHERE NOT (path = ANY($1))
+	`
+
+	// Execute query
+	res, err := r.db.ExecContext(ctx, query, pq.Array(paths))
+	if err != nil {
+		r.logger.Error("Failed to delete missing templates", zap.Error(err))
+		return 0, apperrors.WrapPostgresError(err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get deleted template count", zap.Error(err))
+		return 0, apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Deleted templates missing from disk", zap.Int64("deleted", deleted))
+	return int(deleted), nil
+}
+
+// GetStats returns aggregate template statistics
+func (r *TemplateRepository) GetStats(ctx context.Context) (*model.TemplateStats, error) {
+	ctx, cancel := queryCtx(ctx, r.cfg)
+	defer cancel()
+
+	r.logger.Info("Getting template statistics from database")
+
+	// Count templates by severity
+	bySeverity, total, err := queryCounts(ctx, r.db, `
+		SELECT COALESCE(NULLIF(severity, ''), 'unknown'), COUNT(*)
+		FROM templates
+		GROUP BY 1
+	`)
+	if err != nil {
+		r.logger.Error("Failed to count templates by severity", zap.Error(err))
+		return nil, apperrors.WrapPostgresError(err)
+	}
+
+	r.logger.Info("Retrieved template statistics from database", zap.Int("total", total))
+	return &model.TemplateStats{
+		Total:      total,
+		BySeverity: bySeverity,
+	}, nil
+}
+
 // scanTemplateDirectory scans a directory for template files
 func (r *TemplateRepository) scanTemplateDirectory(dir string) ([]*model.Template, error) {
 	var templates []*model.Template
 
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return err
+			return apperrors.WrapPostgresError(err)
 		}
 
 		if !info.IsDir() && strings.HasSuffix(path, ".yaml") {
@@ -307,9 +635,9 @@ func (r *TemplateRepository) parseTemplateFile(path string) (*model.Template, er
 		ID:          id,
 		Name:        getString(info, "name"),
 		Author:      getString(info, "author"),
-		Tags:        getStringSlice(info, "tags"),
+		Tags:        model.TemplateTags(raw),
 		Severity:    getString(info, "severity"),
-		Type:        getString(info, "type"),
+		Type:        model.DetectTemplateType(raw),
 		Description: getString(info, "description"),
 		Path:        path,
 		CreatedAt:   time.Now(),
@@ -327,19 +655,6 @@ func getString(m map[string]interface{}, key string) string {
 	return ""
 }
 
-func getStringSlice(m map[string]interface{}, key string) []string {
-	if val, ok := m[key].([]interface{}); ok {
-		result := make([]string, len(val))
-		for i, v := range val {
-			if s, ok := v.(string); ok {
-				result[i] = s
-			}
-		}
-		return result
-	}
-	return nil
-}
-
 // Helper function to safely dereference string pointers for logging
 func safePtr(s *string) string {
 	if s == nil {
@@ -0,0 +1,250 @@
+package postgres
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/model"
+)
+
+// createTestTemplates stores templates
+func createTestTemplates(t *testing.T, repo *TemplateRepository, templates ...*model.Template) {
+	t.Helper()
+
+	for _, template := range templates {
+		if template.Type == "" {
+			template.Type = "http"
+		}
+		if template.Path == "" {
+			template.Path = "templates/" + template.ID + ".yaml"
+		}
+		if err := repo.Create(context.Background(), template); err != nil {
+			t.Fatalf("Create(%s) error = %v", template.ID, err)
+		}
+	}
+}
+
+// templateIDs returns the IDs of templates in order
+func templateIDs(templates []*model.Template) []string {
+	ids := make([]string, len(templates))
+	for i, template := range templates {
+		ids[i] = template.ID
+	}
+	return ids
+}
+
+func TestTemplateRepositorySearch(t *testing.T) {
+	repo := NewTemplateRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	createTestTemplates(t, repo,
+		&model.Template{ID: "wordpress-login", Name: "WordPress Login Panel", Author: "pdteam", Severity: "info", Description: "Detects the WordPress login page"},
+		&model.Template{ID: "jenkins-panel", Name: "Jenkins Dashboard", Author: "pdteam", Severity: "info", Description: "Detects an exposed Jenkins dashboard"},
+	)
+
+	templates, err := repo.Search(ctx, "wordpress login")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if ids := templateIDs(templates); len(ids) != 1 || ids[0] != "wordpress-login" {
+		t.Errorf("Search(wordpress login) = %v, want [wordpress-login]", ids)
+	}
+	if templates[0].Name != "WordPress Login Panel" {
+		t.Errorf("Search() name = %q, want the stored name", templates[0].Name)
+	}
+
+	templates, err = repo.Search(ctx, "grafana")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if templates == nil || len(templates) != 0 {
+		t.Errorf("Search(grafana) = %v, want an empty list", templateIDs(templates))
+	}
+}
+
+func TestTemplateRepositoryUpsertOnlyNewerFiles(t *testing.T) {
+	repo := NewTemplateRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+
+	loadedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, id := range []string{"unchanged", "changed"} {
+		template := &model.Template{ID: id, Name: id, Author: "original", Severity: "info", Type: "http",
+			Path: "templates/" + id + ".yaml", LastModifiedAt: loadedAt}
+		if err := repo.UpsertTemplate(ctx, template); err != nil {
+			t.Fatalf("UpsertTemplate(%s) error = %v", id, err)
+		}
+	}
+
+	// A refresh sees both files again; only "changed" has a newer modification time
+	modifiedAt := loadedAt.Add(time.Hour)
+	upserts := []*model.Template{
+		{ID: "unchanged", Name: "unchanged", Author: "rewritten", Severity: "info", Type: "http",
+			Path: "templates/unchanged.yaml", LastModifiedAt: loadedAt},
+		{ID: "changed", Name: "changed", Author: "rewritten", Severity: "high", Type: "http",
+			Path: "templates/changed.yaml", LastModifiedAt: modifiedAt},
+	}
+	for _, template := range upserts {
+		if err := repo.UpsertTemplate(ctx, template); err != nil {
+			t.Fatalf("UpsertTemplate(%s) error = %v", template.ID, err)
+		}
+	}
+
+	tests := []struct {
+		id         string
+		wantAuthor string
+		wantMod    time.Time
+	}{
+		{"unchanged", "original", loadedAt},
+		{"changed", "rewritten", modifiedAt},
+	}
+	modTimes, err := repo.ListModTimes(ctx)
+	if err != nil {
+		t.Fatalf("ListModTimes() error = %v", err)
+	}
+	for _, tt := range tests {
+		template, err := repo.Get(ctx, tt.id)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", tt.id, err)
+		}
+		if template.Author != tt.wantAuthor {
+			t.Errorf("%s author = %q, want %q", tt.id, template.Author, tt.wantAuthor)
+		}
+		if mod := modTimes["templates/"+tt.id+".yaml"]; !mod.Equal(tt.wantMod) {
+			t.Errorf("%s stored modification time = %v, want %v", tt.id, mod, tt.wantMod)
+		}
+	}
+}
+
+func TestTemplateRepositoryGetStats(t *testing.T) {
+	repo := NewTemplateRepository(newTestDB(t), testConfig(), zap.NewNop())
+	createTestTemplates(t, repo,
+		&model.Template{ID: "critical-1", Name: "a", Author: "a", Severity: "critical"},
+		&model.Template{ID: "critical-2", Name: "b", Author: "a", Severity: "critical"},
+		&model.Template{ID: "high-1", Name: "c", Author: "a", Severity: "high"},
+		&model.Template{ID: "no-severity", Name: "d", Author: "a", Severity: ""},
+	)
+
+	stats, err := repo.GetStats(context.Background())
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if stats.Total != 4 {
+		t.Errorf("Total = %d, want 4", stats.Total)
+	}
+	want := map[string]int{"critical": 2, "high": 1, "unknown": 1}
+	if !maps.Equal(stats.BySeverity, want) {
+		t.Errorf("BySeverity = %v, want %v", stats.BySeverity, want)
+	}
+}
+
+func TestTemplateRepositoryListByTag(t *testing.T) {
+	repo := NewTemplateRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	createTestTemplates(t, repo,
+		&model.Template{ID: "cve-2021-1234", Name: "a", Author: "a", Severity: "critical", Tags: []string{"cve", "rce"}},
+		&model.Template{ID: "tech-detect", Name: "b", Author: "a", Severity: "info", Tags: []string{"tech"}},
+		&model.Template{ID: "no-tags", Name: "c", Author: "a", Severity: "low"},
+	)
+	// Templates loaded from disk are stored through UpsertTemplate
+	upserted := &model.Template{ID: "cve-2022-5678", Name: "d", Author: "a", Severity: "high", Type: "http",
+		Path: "templates/cve-2022-5678.yaml", Tags: []string{"cve"}, LastModifiedAt: time.Now()}
+	if err := repo.UpsertTemplate(ctx, upserted); err != nil {
+		t.Fatalf("UpsertTemplate() error = %v", err)
+	}
+
+	tag := "cve"
+	templates, err := repo.List(ctx, &tag, nil, nil, nil, model.Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	ids := templateIDs(templates)
+	slices.Sort(ids)
+	if want := []string{"cve-2021-1234", "cve-2022-5678"}; !slices.Equal(ids, want) {
+		t.Errorf("List(tag cve) = %v, want %v", ids, want)
+	}
+	for _, template := range templates {
+		if !slices.Contains(template.Tags, "cve") {
+			t.Errorf("%s tags = %v, want them to include cve", template.ID, template.Tags)
+		}
+	}
+
+	count, err := repo.Count(ctx, &tag, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Count(tag cve) = %d, want 2", count)
+	}
+
+	template, err := repo.Get(ctx, "cve-2021-1234")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !slices.Equal(template.Tags, []string{"cve", "rce"}) {
+		t.Errorf("Get() tags = %v, want [cve rce]", template.Tags)
+	}
+}
+
+func TestTemplateRepositoryListByTags(t *testing.T) {
+	repo := NewTemplateRepository(newTestDB(t), testConfig(), zap.NewNop())
+	createTestTemplates(t, repo,
+		&model.Template{ID: "source", Name: "a", Author: "a", Severity: "high", Tags: []string{"cve", "rce", "apache"}},
+		&model.Template{ID: "three-shared", Name: "b", Author: "a", Severity: "high", Tags: []string{"apache", "cve", "rce", "oast"}},
+		&model.Template{ID: "two-shared", Name: "c", Author: "a", Severity: "high", Tags: []string{"cve", "rce"}},
+		&model.Template{ID: "one-shared", Name: "d", Author: "a", Severity: "info", Tags: []string{"apache", "tech"}},
+		&model.Template{ID: "unrelated", Name: "e", Author: "a", Severity: "info", Tags: []string{"tech"}},
+		&model.Template{ID: "untagged", Name: "f", Author: "a", Severity: "info"},
+	)
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{"most shared tags first", 10, []string{"three-shared", "two-shared", "one-shared"}},
+		{"limited", 2, []string{"three-shared", "two-shared"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			related, err := repo.ListByTags(context.Background(), []string{"cve", "rce", "apache"}, "source", tt.limit)
+			if err != nil {
+				t.Fatalf("ListByTags() error = %v", err)
+			}
+			if ids := templateIDs(related); !slices.Equal(ids, tt.want) {
+				t.Errorf("ListByTags() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateRepositoryCheckIDCollision(t *testing.T) {
+	repo := NewTemplateRepository(newTestDB(t), testConfig(), zap.NewNop())
+	ctx := context.Background()
+	createTestTemplates(t, repo, &model.Template{ID: "login-panel", Path: "templates/a/login.yaml"})
+
+	tests := []struct {
+		name string
+		id   string
+		path string
+		want string
+	}{
+		{"same file", "login-panel", "templates/a/login.yaml", ""},
+		{"other file with the ID", "login-panel", "templates/b/login.yaml", "templates/a/login.yaml"},
+		{"unused ID", "jenkins-panel", "templates/b/login.yaml", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.CheckIDCollision(ctx, tt.id, tt.path)
+			if err != nil {
+				t.Fatalf("CheckIDCollision() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CheckIDCollision(%s, %s) = %q, want %q", tt.id, tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,21 +2,36 @@ package repository
 
 import (
 	"context"
-	"errors"
+	"database/sql"
+	"time"
+
+	apperrors "nuclei-service-demo/internal/errors"
 	"nuclei-service-demo/internal/model"
 )
 
 // Common errors
 var (
-	ErrNotFound = errors.New("not found")
+	// ErrNotFound is returned when a record does not exist; it is the typed
+	// apperrors.ErrNotFound
+	ErrNotFound = apperrors.ErrNotFound
+	// ErrStatusChanged is returned when a conditional status update finds the
+	// record in another status; it is the typed apperrors.ErrStatusChanged
+	ErrStatusChanged = apperrors.ErrStatusChanged
 )
 
 // TemplateRepository defines the interface for template operations
 type TemplateRepository interface {
 	// List returns a list of templates
-	List(ctx context.Context, tags, author, severity, templateType *string) ([]*model.Template, error)
+	List(ctx context.Context, tags, author, severity, templateType *string, page model.Page) ([]*model.Template, error)
+	// Count returns the number of templates matching the filters
+	Count(ctx context.Context, tags, author, severity, templateType *string) (int, error)
 	// Get returns a template by ID
 	Get(ctx context.Context, id string) (*model.Template, error)
+	// Search returns templates matching a full-text query
+	Search(ctx context.Context, query string) ([]*model.Template, error)
+	// ListByTags returns up to limit templates other than excludeID sharing
+	// at least one of tags, those sharing the most tags first
+	ListByTags(ctx context.Context, tags []string, excludeID string, limit int) ([]*model.Template, error)
 	// Create creates a new template
 	Create(ctx context.Context, template *model.Template) error
 	// Update updates a template
@@ -25,22 +40,110 @@ type TemplateRepository interface {
 	Delete(ctx context.Context, id string) error
 	// Refresh refreshes the template cache
 	Refresh(ctx context.Context) error
+	// UpsertTemplate creates a template or updates it if its file is newer than the stored one
+	UpsertTemplate(ctx context.Context, template *model.Template) error
+	// CheckIDCollision returns the path of a different file already stored under id, or an empty string
+	CheckIDCollision(ctx context.Context, id, path string) (string, error)
+	// ListModTimes returns the stored file modification time of each template, keyed by path
+	ListModTimes(ctx context.Context) (map[string]time.Time, error)
+	// DeleteMissing deletes templates whose path is not in paths and returns how many were deleted
+	DeleteMissing(ctx context.Context, paths []string) (int, error)
+	// GetStats returns aggregate template statistics
+	GetStats(ctx context.Context) (*model.TemplateStats, error)
 }
 
 // ScanRepository defines the interface for scan operations
 type ScanRepository interface {
-	// List returns a list of scans
-	List(ctx context.Context, status, target, templateID *string) ([]*model.Scan, error)
+	// List returns a list of scans in the given order
+	List(ctx context.Context, status, target, templateID *string, tags []string, order model.ScanOrder, page model.Page) ([]*model.Scan, error)
+	// Count returns the number of scans matching the filters
+	Count(ctx context.Context, status, target, templateID *string, tags []string) (int, error)
 	// Get returns a scan by ID
 	Get(ctx context.Context, id string) (*model.Scan, error)
 	// Create creates a new scan
 	Create(ctx context.Context, scan *model.Scan) error
 	// Update updates a scan
 	Update(ctx context.Context, scan *model.Scan) error
+	// UpdateStatus stores a scan's status, error and result summary only if
+	// the stored status is still from, returning ErrStatusChanged otherwise
+	UpdateStatus(ctx context.Context, scan *model.Scan, from model.ScanStatus) error
+	// UpdateStatusWithTx stores a scan's status like UpdateStatus within a transaction
+	UpdateStatusWithTx(ctx context.Context, tx *sql.Tx, scan *model.Scan, from model.ScanStatus) error
+	// UpdateLabels merges labels into a scan's labels, keeping labels not in labels
+	UpdateLabels(ctx context.Context, id string, labels map[string]string) error
 	// Delete deletes a scan by ID
 	Delete(ctx context.Context, id string) error
-	// AddResult adds a scan result
-	AddResult(ctx context.Context, result *model.ScanResult) error
-	// GetResults returns scan results for a scan
-	GetResults(ctx context.Context, scanID string) ([]*model.ScanResult, error)
+	// AddResult adds a scan result, reporting whether it was inserted or skipped as a duplicate
+	AddResult(ctx context.Context, result *model.ScanResult) (bool, error)
+	// AddResultsBatch adds scan results with multi-row inserts, within tx or
+	// directly when tx is nil, and returns how many were inserted
+	AddResultsBatch(ctx context.Context, tx *sql.Tx, results []*model.ScanResult) (int, error)
+	// BeginTx starts a new transaction
+	BeginTx(ctx context.Context) (*sql.Tx, error)
+	// GetResults returns a page of scan results for a scan matching the filter
+	GetResults(ctx context.Context, scanID string, filter model.ResultFilter, page model.Page) ([]*model.ScanResult, error)
+	// CountResults returns the number of scan results for a scan matching the filter
+	CountResults(ctx context.Context, scanID string, filter model.ResultFilter) (int, error)
+	// UpdateResultFalsePositive marks or unmarks a scan result as a false positive
+	UpdateResultFalsePositive(ctx context.Context, scanID, resultID string, fp bool, note string) error
+	// SaveVerboseLog stores the nuclei output captured by a verbose scan
+	SaveVerboseLog(ctx context.Context, id, log string) error
+	// GetVerboseLog returns a scan's stored verbose log, or an empty string
+	GetVerboseLog(ctx context.Context, id string) (string, error)
+	// GetResultSummary counts a scan's results by severity
+	GetResultSummary(ctx context.Context, scanID string) (*model.ResultSummary, error)
+	// GetResultSummaryWithTx counts a scan's results by severity within a transaction
+	GetResultSummaryWithTx(ctx context.Context, tx *sql.Tx, scanID string) (*model.ResultSummary, error)
+	// GetStats returns aggregate scan and result statistics
+	GetStats(ctx context.Context) (*model.ScanStats, error)
+	// CountByStatus returns the number of scans with the given status
+	CountByStatus(ctx context.Context, status string) (int, error)
+	// CountAllByStatus returns the number of scans per status; statuses
+	// without scans are omitted
+	CountAllByStatus(ctx context.Context) (map[string]int, error)
+	// Heartbeat records that a running scan is still being processed
+	Heartbeat(ctx context.Context, id string) error
+	// FailStaleScans marks running scans whose last heartbeat is older than
+	// before as failed with message, returning how many were failed
+	FailStaleScans(ctx context.Context, before time.Time, message string) (int, error)
+}
+
+// TargetGroupRepository defines the interface for target group operations
+type TargetGroupRepository interface {
+	// List returns all target groups
+	List(ctx context.Context) ([]*model.TargetGroup, error)
+	// Get returns a target group by ID
+	Get(ctx context.Context, id string) (*model.TargetGroup, error)
+	// Create creates a new target group
+	Create(ctx context.Context, group *model.TargetGroup) error
+	// Delete deletes a target group by ID
+	Delete(ctx context.Context, id string) error
+}
+
+// ProfileRepository defines the interface for scan profile operations
+type ProfileRepository interface {
+	// List returns all scan profiles
+	List(ctx context.Context) ([]*model.ScanProfile, error)
+	// Get returns a scan profile by ID
+	Get(ctx context.Context, id string) (*model.ScanProfile, error)
+	// Create creates a new scan profile
+	Create(ctx context.Context, profile *model.ScanProfile) error
+	// Update updates a scan profile
+	Update(ctx context.Context, profile *model.ScanProfile) error
+	// Delete deletes a scan profile by ID
+	Delete(ctx context.Context, id string) error
+}
+
+// APIKeyRepository defines the interface for API key operations
+type APIKeyRepository interface {
+	// List returns all API keys
+	List(ctx context.Context) ([]*model.APIKey, error)
+	// GetByHash returns the API key with the given key hash
+	GetByHash(ctx context.Context, hash string) (*model.APIKey, error)
+	// Create creates a new API key
+	Create(ctx context.Context, key *model.APIKey) error
+	// Delete deletes an API key by ID
+	Delete(ctx context.Context, id string) error
+	// Count returns the number of stored API keys
+	Count(ctx context.Context) (int, error)
 }
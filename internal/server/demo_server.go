@@ -2,12 +2,25 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/logger"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -19,14 +32,45 @@ type DemoServer struct {
 	logger *zap.Logger
 	router *mux.Router
 	http   *http.Server
+
+	// ssrfAllowedHosts restricts the SSRF endpoint's targets; empty allows any host
+	ssrfAllowedHosts []string
+	// uploadDir is the temporary directory the file upload endpoint writes to
+	uploadDir string
+
+	// rateLimitMu guards rateLimitHits, the request count per client IP in the
+	// current rate limit window
+	rateLimitMu   sync.Mutex
+	rateLimitHits map[string]int
 }
 
+const (
+	// rateLimitMax is the number of requests a client may make per window
+	rateLimitMax = 5
+	// rateLimitWindow is how long a client's request count is kept
+	rateLimitWindow = time.Minute
+)
+
+// ErrDemoDisabled is returned by NewDemoServer when DEMO_ENABLED is false
+var ErrDemoDisabled = errors.New("demo server is disabled")
+
 // NewDemoServer creates a new demo server instance
 func NewDemoServer(cfg *config.Config) (*DemoServer, error) {
-	// Create logger
-	logger, err := zap.NewProduction()
+	// Never expose the vulnerable endpoints unless explicitly enabled
+	if !cfg.Server.DemoEnabled {
+		return nil, ErrDemoDisabled
+	}
+
+	// Create logger at the configured level
+	logger, err := logger.NewLogger(cfg.Server.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create upload directory
+	uploadDir, err := os.MkdirTemp("", "demo-uploads-")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create logger: %w", err)
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
 	}
 
 	// Create router
@@ -39,10 +83,17 @@ func NewDemoServer(cfg *config.Config) (*DemoServer, error) {
 		http: &http.Server{
 			Addr:         fmt.Sprintf(":%d", cfg.Server.DemoPort),
 			Handler:      router,
-			ReadTimeout:  15 * time.Second,
-			WriteTimeout: 15 * time.Second,
-			IdleTimeout:  60 * time.Second,
+			ReadTimeout:  time.Duration(cfg.Server.ReadTimeoutSeconds) * time.Second,
+			WriteTimeout: time.Duration(cfg.Server.WriteTimeoutSeconds) * time.Second,
+			IdleTimeout:  time.Duration(cfg.Server.IdleTimeoutSeconds) * time.Second,
+			// Expose the raw connection to handlers for the smuggling endpoint
+			ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+				return context.WithValue(ctx, rawRequestConnKey, c)
+			},
 		},
+		ssrfAllowedHosts: cfg.Server.SSRFAllowedHosts,
+		uploadDir:        uploadDir,
+		rateLimitHits:    make(map[string]int),
 	}
 
 	// Register routes
@@ -51,15 +102,23 @@ func NewDemoServer(cfg *config.Config) (*DemoServer, error) {
 	return srv, nil
 }
 
-// Start starts the demo server
+// Start starts the demo server, recording the raw input of each connection
+// for the smuggling endpoint
 func (s *DemoServer) Start() error {
 	s.logger.Info("Starting demo server", zap.String("addr", s.http.Addr))
-	return s.http.ListenAndServe()
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+	return s.http.Serve(rawRequestListener{ln})
 }
 
 // Shutdown gracefully shuts down the demo server
 func (s *DemoServer) Shutdown(ctx context.Context) error {
 	s.logger.Info("Shutting down demo server")
+	if err := os.RemoveAll(s.uploadDir); err != nil {
+		s.logger.Error("Failed to remove upload directory", zap.Error(err))
+	}
 	return s.http.Shutdown(ctx)
 }
 
@@ -94,6 +153,50 @@ func (s *DemoServer) registerRoutes() {
 
 	// 10. WordPress Brandfolder Open Redirect
 	s.router.HandleFunc("/vuln/brandfolder-redirect", s.handleBrandfolderRedirect()).Methods(http.MethodGet)
+
+	// 11. SQL Injection
+	s.router.HandleFunc("/vuln/sqli", s.handleSQLInjection()).Methods(http.MethodGet)
+
+	// 12. Server-Side Request Forgery
+	s.router.HandleFunc("/vuln/ssrf", s.handleSSRF()).Methods(http.MethodGet)
+
+	// 13. Server-Side Template Injection
+	s.router.HandleFunc("/vuln/ssti", s.handleSSTI()).Methods(http.MethodGet)
+
+	// 14. JWT "none" Algorithm Bypass
+	s.router.HandleFunc("/vuln/jwt-none", s.handleJWTNoneAlg()).Methods(http.MethodGet)
+
+	// 15. XML External Entity
+	s.router.HandleFunc("/vuln/xxe", s.handleXXE()).Methods(http.MethodPost)
+
+	// 16. Path Traversal
+	s.router.HandleFunc("/vuln/path-traversal", s.handlePathTraversal()).Methods(http.MethodGet)
+
+	// 17. HTTP Header Injection
+	s.router.HandleFunc("/vuln/header-injection", s.handleHeaderInjection()).Methods(http.MethodGet)
+
+	// 18. Arbitrary File Upload
+	s.router.HandleFunc("/vuln/file-upload", s.handleFileUpload()).Methods(http.MethodPost)
+	s.router.HandleFunc("/vuln/uploads/{filename}", s.handleUploadedFile()).Methods(http.MethodGet)
+
+	// 19. CORS Misconfiguration
+	s.router.HandleFunc("/vuln/cors", s.handleCORSMisconfiguration()).Methods(http.MethodGet)
+
+	// 20. Rate Limit Bypass
+	s.router.HandleFunc("/vuln/ratelimit-bypass", s.handleRateLimitBypass()).Methods(http.MethodGet)
+
+	// 21. Authentication Bypass
+	s.router.HandleFunc("/vuln/auth-bypass", s.handleAuthBypass()).Methods(http.MethodGet)
+	s.router.HandleFunc("/vuln/auth-bypass/admin", s.handleAuthBypassAdmin()).Methods(http.MethodGet)
+
+	// 22. Directory Listing
+	s.router.PathPrefix("/vuln/dir-listing/").Handler(s.handleDirListing()).Methods(http.MethodGet)
+
+	// 23. HTTP Request Smuggling
+	s.router.HandleFunc("/vuln/smuggling", s.handleSmuggling()).Methods(http.MethodGet, http.MethodPost)
+
+	// 24. Insecure Deserialization
+	s.router.HandleFunc("/vuln/deserialize", s.handleDeserialize()).Methods(http.MethodPost)
 }
 
 func (s *DemoServer) handleOpenRedirect() http.HandlerFunc {
@@ -189,3 +292,553 @@ func (s *DemoServer) handleBrandfolderRedirect() http.HandlerFunc {
 		http.Redirect(w, r, url, http.StatusFound)
 	}
 }
+
+// demoProduct is a row in the simulated products table
+type demoProduct struct {
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// demoProducts is the in-memory products table queried by handleSQLInjection
+var demoProducts = []demoProduct{
+	{ID: 1, Name: "laptop", Price: 999.99},
+	{ID: 2, Name: "phone", Price: 599.99},
+	{ID: 3, Name: "tablet", Price: 399.99},
+}
+
+// sqliTautology matches injected "' OR 'a'='a" style conditions
+var sqliTautology = regexp.MustCompile(`(?i)'\s*or\s*'?(\w+)'?\s*=\s*'?(\w+)`)
+
+func (s *DemoServer) handleSQLInjection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		query := "SELECT * FROM products WHERE name = '" + q + "'"
+		w.Header().Set("Content-Type", "application/json")
+
+		// Unbalanced quotes break the statement, leaking it in the error
+		if strings.Count(query, "'")%2 != 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "pq: unterminated quoted string at or near \"" + query + "\"",
+			})
+			return
+		}
+
+		// Evaluate the WHERE clause against the in-memory table
+		rows := []demoProduct{}
+		if m := sqliTautology.FindStringSubmatch(q); m != nil && m[1] == m[2] {
+			rows = demoProducts
+		} else {
+			for _, product := range demoProducts {
+				if product.Name == q {
+					rows = append(rows, product)
+				}
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"query": query,
+			"rows":  rows,
+		})
+	}
+}
+
+// ssrfMaxBodyBytes is how much of the fetched response handleSSRF echoes back
+const ssrfMaxBodyBytes = 1024
+
+func (s *DemoServer) handleSSRF() http.HandlerFunc {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("url")
+		u, err := url.Parse(target)
+		if err != nil || u.Host == "" {
+			http.Error(w, "Invalid url parameter", http.StatusBadRequest)
+			return
+		}
+
+		// Keep the demo from reaching infrastructure outside the allowlist
+		if !s.ssrfHostAllowed(u) {
+			http.Error(w, "Host not allowed", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := client.Get(target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, ssrfMaxBodyBytes))
+		fmt.Fprintf(w, "Status: %d\n\n%s", resp.StatusCode, body)
+	}
+}
+
+// ssrfHostAllowed reports whether the SSRF endpoint may fetch the given URL
+func (s *DemoServer) ssrfHostAllowed(u *url.URL) bool {
+	if len(s.ssrfAllowedHosts) == 0 {
+		return true
+	}
+	for _, host := range s.ssrfAllowedHosts {
+		if host == u.Host || host == u.Hostname() {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *DemoServer) handleSSTI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expr := r.URL.Query().Get("expr")
+		tmpl, err := template.New("t").Parse(expr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		tmpl.Execute(w, map[string]interface{}{"secret": "flag{ssti_demo}"})
+	}
+}
+
+// handleJWTNoneAlg trusts the claims of any JWT without checking its signature,
+// mirroring servers that honour "alg": "none". An attacker forges a token as
+// base64url({"alg":"none","typ":"JWT"}) + "." + base64url({"admin":true}) + "."
+// (empty signature) and is granted admin access.
+func (s *DemoServer) handleJWTNoneAlg() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Query().Get("token"), ".")
+		if len(parts) < 2 {
+			http.Error(w, "Malformed token", http.StatusBadRequest)
+			return
+		}
+
+		// Decode the claims without verifying the signature
+		payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+		if err != nil {
+			http.Error(w, "Malformed token claims", http.StatusBadRequest)
+			return
+		}
+		var claims map[string]interface{}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			http.Error(w, "Malformed token claims", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if admin, _ := claims["admin"].(bool); !admin {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{"claims": claims})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"claims": claims,
+			"secret": "flag{jwt_none_demo}",
+		})
+	}
+}
+
+// xxeMockPasswd is returned in place of any external entity so the demo never reads real files
+const xxeMockPasswd = `root:x:0:0:root:/root:/bin/bash
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+www-data:x:33:33:www-data:/var/www:/usr/sbin/nologin
+`
+
+// xxeEntity matches external entity declarations such as <!ENTITY xxe SYSTEM "file:///etc/passwd">
+var xxeEntity = regexp.MustCompile(`<!ENTITY\s+(\w+)\s+(?:SYSTEM|PUBLIC)\s+(?:"[^"]*"\s+)?"[^"]*"\s*>`)
+
+// xmlNode is a generic XML element used to reflect arbitrary documents
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",chardata"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+// toMap converts the node into a JSON-friendly structure
+func (n xmlNode) toMap() map[string]interface{} {
+	attrs := make(map[string]string, len(n.Attrs))
+	for _, attr := range n.Attrs {
+		attrs[attr.Name.Local] = attr.Value
+	}
+	children := make([]map[string]interface{}, 0, len(n.Nodes))
+	for _, child := range n.Nodes {
+		children = append(children, child.toMap())
+	}
+	return map[string]interface{}{
+		"name":       n.XMLName.Local,
+		"attributes": attrs,
+		"content":    strings.TrimSpace(n.Content),
+		"children":   children,
+	}
+}
+
+func (s *DemoServer) handleXXE() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		doc := string(body)
+
+		// encoding/xml never resolves external entities, so simulate a vulnerable
+		// parser by expanding every declared entity to mock file contents
+		if strings.Contains(doc, "<!ENTITY") {
+			for _, m := range xxeEntity.FindAllStringSubmatch(doc, -1) {
+				var escaped strings.Builder
+				xml.EscapeText(&escaped, []byte(xxeMockPasswd))
+				doc = strings.ReplaceAll(doc, "&"+m[1]+";", escaped.String())
+			}
+		}
+
+		var root xmlNode
+		if err := xml.Unmarshal([]byte(doc), &root); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(root.toMap())
+	}
+}
+
+// pathTraversalBaseDir is the directory handlePathTraversal intends to serve from
+const pathTraversalBaseDir = "./static"
+
+// handlePathTraversal serves files relative to pathTraversalBaseDir. filepath.Join
+// cleans the result but does not confine it, so "../" sequences escape the base
+// directory (e.g. ?file=../../../../etc/passwd).
+func (s *DemoServer) handlePathTraversal() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := filepath.Join(pathTraversalBaseDir, r.URL.Query().Get("file"))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}
+}
+
+// dirListingBaseDir is the directory handleDirListing exposes. It has no
+// index.html, unlike pathTraversalBaseDir, so http.FileServer lists it
+const dirListingBaseDir = "./static/files"
+
+// handleDirListing serves dirListingBaseDir with http.FileServer, which lists
+// any directory without an index.html, like a web server with autoindex left on
+func (s *DemoServer) handleDirListing() http.Handler {
+	return http.StripPrefix("/vuln/dir-listing/", http.FileServer(http.Dir(dirListingBaseDir)))
+}
+
+// handleHeaderInjection reflects the value parameter into X-Custom-Header without
+// sanitization. Go's net/http replaces CR and LF in header values with spaces when
+// writing the response, so w.Header().Set alone cannot be exploited; the handler
+// hijacks the connection and writes the raw response itself so injected "\r\n"
+// sequences start new headers (e.g. ?value=x%0d%0aSet-Cookie:%20injected=1).
+func (s *DemoServer) handleHeaderInjection() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value := r.URL.Query().Get("value")
+		body := "Header set\n"
+
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			w.Header().Set("X-Custom-Header", value)
+			fmt.Fprint(w, body)
+			return
+		}
+
+		conn, buf, err := hijacker.Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\n")
+		fmt.Fprintf(buf, "X-Custom-Header: %s\r\n", value)
+		fmt.Fprintf(buf, "Content-Type: text/plain\r\n")
+		fmt.Fprintf(buf, "Content-Length: %d\r\n", len(body))
+		fmt.Fprintf(buf, "Connection: close\r\n\r\n")
+		fmt.Fprint(buf, body)
+		buf.Flush()
+	}
+}
+
+func (s *DemoServer) handleFileUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		// Store the file under the client-supplied name with no type or extension checks
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := os.WriteFile(filepath.Join(s.uploadDir, header.Filename), data, 0644); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "Uploaded to /vuln/uploads/%s\n", header.Filename)
+	}
+}
+
+func (s *DemoServer) handleUploadedFile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filename := mux.Vars(r)["filename"]
+		data, err := os.ReadFile(filepath.Join(s.uploadDir, filename))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func (s *DemoServer) handleCORSMisconfiguration() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Trust any origin and allow it to read the response with credentials
+		w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"secret": "cors_secret_token"})
+	}
+}
+
+func (s *DemoServer) handleRateLimitBypass() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Identify the client by X-Forwarded-For when present, so a spoofed
+		// header gets a fresh quota
+		client := r.Header.Get("X-Forwarded-For")
+		if client == "" {
+			client = r.RemoteAddr
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				client = host
+			}
+		}
+
+		s.rateLimitMu.Lock()
+		hits := s.rateLimitHits[client] + 1
+		s.rateLimitHits[client] = hits
+		if hits == 1 {
+			// Start the client's window on its first request
+			time.AfterFunc(rateLimitWindow, func() {
+				s.rateLimitMu.Lock()
+				delete(s.rateLimitHits, client)
+				s.rateLimitMu.Unlock()
+			})
+		}
+		s.rateLimitMu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if hits > rateLimitMax {
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]string{"error": "rate limit exceeded"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":    "ok",
+			"remaining": rateLimitMax - hits,
+		})
+	}
+}
+
+// authBypassToken is the hardcoded token accepted by the auth bypass endpoint
+const authBypassToken = "secret123"
+
+// handleAuthBypass checks the token with a plain == comparison, which returns
+// as soon as a byte differs and so leaks the token through response timing;
+// the token itself is hardcoded and guessable
+func (s *DemoServer) handleAuthBypass() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == authBypassToken {
+			writeAuthBypassGranted(w)
+			return
+		}
+		writeAuthBypassDenied(w)
+	}
+}
+
+// handleAuthBypassAdmin grants admin access to any token containing "admin",
+// so a value such as "anyvalueadmin" is accepted
+func (s *DemoServer) handleAuthBypassAdmin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if strings.Contains(token, "admin") {
+			writeAuthBypassGranted(w)
+			return
+		}
+		writeAuthBypassDenied(w)
+	}
+}
+
+// writeAuthBypassGranted writes the admin response of the auth bypass endpoints
+func writeAuthBypassGranted(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"role": "admin", "secret": "auth_bypass_flag"})
+}
+
+// writeAuthBypassDenied writes the rejection of the auth bypass endpoints
+func writeAuthBypassDenied(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{"error": "invalid token"})
+}
+
+// rawRequestConnKey stores the connection's *rawRequestConn on the request context
+const rawRequestConnKey contextKey = "raw_request_conn"
+
+// maxRawRequestBytes caps how much of a connection's input rawRequestConn keeps
+const maxRawRequestBytes = 64 << 10
+
+// rawRequestListener wraps accepted connections in rawRequestConn
+type rawRequestListener struct {
+	net.Listener
+}
+
+// Accept waits for the next connection and starts recording its input
+func (l rawRequestListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &rawRequestConn{Conn: conn}, nil
+}
+
+// rawRequestConn records the most recent input read from a connection, so
+// handlers can see headers net/http removes while parsing a request
+type rawRequestConn struct {
+	net.Conn
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Read reads from the connection, keeping the last maxRawRequestBytes read
+func (c *rawRequestConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+
+	c.mu.Lock()
+	c.buf = append(c.buf, p[:n]...)
+	if over := len(c.buf) - maxRawRequestBytes; over > 0 {
+		c.buf = c.buf[over:]
+	}
+	c.mu.Unlock()
+
+	return n, err
+}
+
+// headerLines returns the raw header lines of the most recent request read
+// with the given request line, or nil if it is no longer recorded
+func (c *rawRequestConn) headerLines(requestLine string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data := string(c.buf)
+	start := strings.LastIndex(data, requestLine+"\r\n")
+	if start < 0 {
+		return nil
+	}
+	block := data[start+len(requestLine)+2:]
+	if end := strings.Index(block, "\r\n\r\n"); end >= 0 {
+		block = block[:end]
+	}
+	return strings.Split(block, "\r\n")
+}
+
+// handleSmuggling reports a request carrying both Content-Length and
+// Transfer-Encoding: chunked, the ambiguity behind CL.TE and TE.CL request
+// smuggling. net/http resolves it by dropping Content-Length before the
+// handler runs, so the headers are read from the raw connection input
+func (s *DemoServer) handleSmuggling() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var contentLength, transferEncoding string
+		if conn, ok := r.Context().Value(rawRequestConnKey).(*rawRequestConn); ok {
+			requestLine := r.Method + " " + r.RequestURI + " " + r.Proto
+			for _, line := range conn.headerLines(requestLine) {
+				name, value, found := strings.Cut(line, ":")
+				if !found {
+					continue
+				}
+				switch strings.ToLower(strings.TrimSpace(name)) {
+				case "content-length":
+					contentLength = strings.TrimSpace(value)
+				case "transfer-encoding":
+					transferEncoding = strings.TrimSpace(value)
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if contentLength == "" || !strings.Contains(strings.ToLower(transferEncoding), "chunked") {
+			json.NewEncoder(w).Encode(map[string]bool{"vulnerable": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"vulnerable":  true,
+			"detected_cl": contentLength,
+			"detected_te": transferEncoding,
+		})
+	}
+}
+
+// runnable is implemented by decoded values that handleDeserialize runs
+type runnable interface {
+	Run() (string, error)
+}
+
+// deserializeCommand is a gadget handleDeserialize will decode from any
+// request; running it echoes Cmd back
+type deserializeCommand struct {
+	Cmd string
+}
+
+// Run echoes Cmd through the echo binary
+func (c deserializeCommand) Run() (string, error) {
+	out, err := exec.Command("echo", c.Cmd).Output()
+	return string(out), err
+}
+
+func init() {
+	// Clients send the gadget under the wire name "Command"
+	gob.RegisterName("Command", deserializeCommand{})
+}
+
+// handleDeserialize decodes the GOB-encoded request body into an interface
+// value and runs it if it is runnable, trusting the client to choose the
+// type, as with unsafe deserialization of Java or PHP objects
+func (s *DemoServer) handleDeserialize() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var value interface{}
+		if err := gob.NewDecoder(r.Body).Decode(&value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		task, ok := value.(runnable)
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"type": fmt.Sprintf("%T", value)})
+			return
+		}
+		out, err := task.Run()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(out))
+	}
+}
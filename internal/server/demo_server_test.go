@@ -0,0 +1,577 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"nuclei-service-demo/internal/config"
+)
+
+// newTestDemoServer returns an enabled demo server that only logs errors,
+// after configure adjusts its configuration
+func newTestDemoServer(t *testing.T, configure func(cfg *config.Config)) *DemoServer {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Server.DemoEnabled = true
+	cfg.Server.LogLevel = "error"
+	if configure != nil {
+		configure(cfg)
+	}
+	srv, err := NewDemoServer(cfg)
+	if err != nil {
+		t.Fatalf("NewDemoServer() error = %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(srv.uploadDir) })
+	return srv
+}
+
+// serveDemo sends req through the demo server's routes
+func serveDemo(s *DemoServer, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDemoSSRF(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("internal-metadata " + strings.Repeat("x", 2*ssrfMaxBodyBytes)))
+	}))
+	defer target.Close()
+	targetURL, _ := url.Parse(target.URL)
+
+	tests := []struct {
+		name         string
+		allowedHosts []string
+		url          string
+		wantCode     int
+	}{
+		{name: "any host without an allowlist", url: target.URL, wantCode: http.StatusOK},
+		{name: "allowed host", allowedHosts: []string{targetURL.Hostname()}, url: target.URL, wantCode: http.StatusOK},
+		{name: "allowed host and port", allowedHosts: []string{targetURL.Host}, url: target.URL, wantCode: http.StatusOK},
+		{name: "host not allowed", allowedHosts: []string{"internal.example.com"}, url: target.URL, wantCode: http.StatusBadRequest},
+		{name: "missing url", url: "", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestDemoServer(t, func(cfg *config.Config) { cfg.Server.SSRFAllowedHosts = tt.allowedHosts })
+
+			req := httptest.NewRequest(http.MethodGet, "/vuln/ssrf?url="+url.QueryEscape(tt.url), nil)
+			rec := serveDemo(srv, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			status, body, _ := strings.Cut(rec.Body.String(), "\n\n")
+			if status != "Status: 418" {
+				t.Errorf("status line = %q, want the target's status", status)
+			}
+			if !strings.HasPrefix(body, "internal-metadata") || len(body) != ssrfMaxBodyBytes {
+				t.Errorf("body = %d bytes starting %.20q, want the first %d bytes of the target's response", len(body), body, ssrfMaxBodyBytes)
+			}
+		})
+	}
+}
+
+// noneAlgToken returns an unsigned JWT carrying claims
+func noneAlgToken(claims string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + "."
+}
+
+func TestDemoJWTNoneAlg(t *testing.T) {
+	srv := newTestDemoServer(t, nil)
+
+	tests := []struct {
+		name       string
+		token      string
+		wantCode   int
+		wantSecret bool
+	}{
+		{name: "forged admin token", token: noneAlgToken(`{"sub":"attacker","admin":true}`), wantCode: http.StatusOK, wantSecret: true},
+		{name: "non-admin token", token: noneAlgToken(`{"sub":"user","admin":false}`), wantCode: http.StatusForbidden},
+		{name: "malformed token", token: "not-a-jwt", wantCode: http.StatusBadRequest},
+		{name: "claims not JSON", token: noneAlgToken("admin"), wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/vuln/jwt-none?token="+url.QueryEscape(tt.token), nil)
+			rec := serveDemo(srv, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if got := strings.Contains(rec.Body.String(), "flag{jwt_none_demo}"); got != tt.wantSecret {
+				t.Errorf("response %q contains the secret = %v, want %v", rec.Body.String(), got, tt.wantSecret)
+			}
+		})
+	}
+}
+
+func TestDemoXXE(t *testing.T) {
+	srv := newTestDemoServer(t, nil)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   int
+		wantPasswd bool
+	}{
+		{
+			name:       "external entity",
+			body:       `<?xml version="1.0"?><!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><user><name>&xxe;</name></user>`,
+			wantCode:   http.StatusOK,
+			wantPasswd: true,
+		},
+		{name: "plain document", body: `<user><name>alice</name></user>`, wantCode: http.StatusOK},
+		{name: "malformed document", body: `<user><name>alice</user>`, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/vuln/xxe", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/xml")
+			rec := serveDemo(srv, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var doc map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+				t.Fatalf("response is not JSON: %v", err)
+			}
+			if doc["name"] != "user" {
+				t.Errorf("root element = %v, want user", doc["name"])
+			}
+			if got := strings.Contains(rec.Body.String(), "root:x:0:0:root"); got != tt.wantPasswd {
+				t.Errorf("response %q contains passwd content = %v, want %v", rec.Body.String(), got, tt.wantPasswd)
+			}
+		})
+	}
+}
+
+// inDir makes dir the working directory until the test ends, for endpoints
+// serving paths relative to it
+func inDir(t *testing.T, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("os.Getwd() error = %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("os.Chdir() error = %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// writeTestFile writes content to path under dir, creating its directories
+func writeTestFile(t *testing.T, dir, path, content string) {
+	t.Helper()
+
+	path = filepath.Join(dir, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("os.MkdirAll() error = %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+}
+
+func TestDemoPathTraversal(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "static/index.html", "<h1>Demo</h1>")
+	writeTestFile(t, dir, "secret.txt", "outside the base directory")
+	inDir(t, dir)
+	srv := newTestDemoServer(t, nil)
+
+	tests := []struct {
+		name     string
+		file     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "file in the base directory", file: "index.html", wantCode: http.StatusOK, wantBody: "<h1>Demo</h1>"},
+		{name: "traversal out of the base directory", file: "../secret.txt", wantCode: http.StatusOK, wantBody: "outside the base directory"},
+		{name: "missing file", file: "missing.html", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/vuln/path-traversal?file="+url.QueryEscape(tt.file), nil)
+			rec := serveDemo(srv, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDemoFileUploadRoundTrip(t *testing.T) {
+	srv := newTestDemoServer(t, nil)
+	content := "<?php echo 'nuclei-upload-check'; ?>"
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "shell.php")
+	if err != nil {
+		t.Fatalf("CreateFormFile() error = %v", err)
+	}
+	part.Write([]byte(content))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/vuln/file-upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := serveDemo(srv, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload status = %d, want %d (%s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "/vuln/uploads/shell.php") {
+		t.Errorf("upload response = %q, want the uploaded file's path", rec.Body.String())
+	}
+
+	rec = serveDemo(srv, httptest.NewRequest(http.MethodGet, "/vuln/uploads/shell.php", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("download status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec.Body.String() != content {
+		t.Errorf("downloaded %q, want the uploaded %q", rec.Body.String(), content)
+	}
+
+	rec = serveDemo(srv, httptest.NewRequest(http.MethodGet, "/vuln/uploads/missing.php", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing upload status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestDemoCORSMisconfiguration(t *testing.T) {
+	srv := newTestDemoServer(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/vuln/cors", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec := serveDemo(srv, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://evil.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the reflected origin", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["secret"] != "cors_secret_token" {
+		t.Errorf("body = %q, want the secret", rec.Body.String())
+	}
+}
+
+func TestDemoServerShutdown(t *testing.T) {
+	// Reserve a free port for the demo server to listen on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	srv := newTestDemoServer(t, func(cfg *config.Config) { cfg.Server.DemoPort = port })
+	started := make(chan error, 1)
+	go func() { started <- srv.Start() }()
+
+	addr := fmt.Sprintf("http://127.0.0.1:%d/vuln/cors", port)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(addr)
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("demo server did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	select {
+	case err := <-started:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("Start() error = %v, want %v", err, http.ErrServerClosed)
+		}
+	case <-ctx.Done():
+		t.Fatal("Start() did not return within the shutdown timeout")
+	}
+	if _, err := http.Get(addr); err == nil {
+		t.Error("demo server still accepts requests after Shutdown")
+	}
+	if _, err := os.Stat(srv.uploadDir); !os.IsNotExist(err) {
+		t.Errorf("upload directory still exists after Shutdown: %v", err)
+	}
+}
+
+func TestNewDemoServerDisabled(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.DemoEnabled = false
+
+	srv, err := NewDemoServer(cfg)
+	if !errors.Is(err, ErrDemoDisabled) {
+		t.Fatalf("NewDemoServer() error = %v, want %v", err, ErrDemoDisabled)
+	}
+	if srv != nil {
+		t.Fatal("NewDemoServer() returned a server with vulnerable routes while disabled")
+	}
+
+	// Enabled, the same configuration registers the vulnerable routes
+	var routes []string
+	enabled := newTestDemoServer(t, nil)
+	enabled.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if path, err := route.GetPathTemplate(); err == nil && strings.HasPrefix(path, "/vuln/") {
+			routes = append(routes, path)
+		}
+		return nil
+	})
+	if len(routes) == 0 {
+		t.Error("an enabled demo server registered no /vuln/ routes")
+	}
+}
+
+func TestDemoRateLimitBypass(t *testing.T) {
+	srv := newTestDemoServer(t, nil)
+	request := func(forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/vuln/ratelimit-bypass", nil)
+		req.RemoteAddr = "192.0.2.10:41234"
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		return serveDemo(srv, req).Code
+	}
+
+	for i := 1; i <= rateLimitMax; i++ {
+		if code := request(""); code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want %d", i, code, http.StatusOK)
+		}
+	}
+	if code := request(""); code != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	// The same client gets a fresh quota by spoofing X-Forwarded-For
+	if code := request("203.0.113.7"); code != http.StatusOK {
+		t.Errorf("spoofed X-Forwarded-For status = %d, want %d", code, http.StatusOK)
+	}
+	if code := request(""); code != http.StatusTooManyRequests {
+		t.Errorf("status without the header = %d, want the client still limited", code)
+	}
+}
+
+func TestDemoAuthBypass(t *testing.T) {
+	srv := newTestDemoServer(t, nil)
+
+	tests := []struct {
+		name        string
+		path        string
+		token       string
+		wantGranted bool
+	}{
+		{name: "hardcoded token", path: "/vuln/auth-bypass", token: "secret123", wantGranted: true},
+		{name: "wrong token", path: "/vuln/auth-bypass", token: "secret124"},
+		{name: "token containing admin", path: "/vuln/auth-bypass/admin", token: "anyvalueadmin", wantGranted: true},
+		{name: "token without admin", path: "/vuln/auth-bypass/admin", token: "anyvalue"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveDemo(srv, httptest.NewRequest(http.MethodGet, tt.path+"?token="+url.QueryEscape(tt.token), nil))
+
+			wantCode := http.StatusUnauthorized
+			if tt.wantGranted {
+				wantCode = http.StatusOK
+			}
+			if rec.Code != wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, wantCode, rec.Body.String())
+			}
+			var body map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("response is not JSON: %v", err)
+			}
+			if granted := body["role"] == "admin" && body["secret"] == "auth_bypass_flag"; granted != tt.wantGranted {
+				t.Errorf("body = %v, want admin access %v", body, tt.wantGranted)
+			}
+		})
+	}
+}
+
+func TestDemoDirListing(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "static/files/backup.sql", "-- dump")
+	writeTestFile(t, dir, "static/files/config.bak", "password=demo")
+	inDir(t, dir)
+	srv := newTestDemoServer(t, nil)
+
+	rec := serveDemo(srv, httptest.NewRequest(http.MethodGet, "/vuln/dir-listing/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	for _, name := range []string{"backup.sql", "config.bak"} {
+		if !strings.Contains(rec.Body.String(), `<a href="`+name+`">`) {
+			t.Errorf("listing %q does not link %s", rec.Body.String(), name)
+		}
+	}
+
+	rec = serveDemo(srv, httptest.NewRequest(http.MethodGet, "/vuln/dir-listing/config.bak", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "password=demo" {
+		t.Errorf("config.bak = %d %q, want the file contents", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDemoSmuggling(t *testing.T) {
+	srv := newTestDemoServer(t, nil)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	go srv.http.Serve(rawRequestListener{ln})
+	t.Cleanup(func() { srv.http.Close() })
+
+	tests := []struct {
+		name           string
+		headers        string
+		body           string
+		wantVulnerable bool
+	}{
+		{
+			name:           "content length and chunked",
+			headers:        "Content-Length: 6\r\nTransfer-Encoding: chunked\r\n",
+			body:           "0\r\n\r\n",
+			wantVulnerable: true,
+		},
+		{name: "content length only", headers: "Content-Length: 5\r\n", body: "hello"},
+		{name: "chunked only", headers: "Transfer-Encoding: chunked\r\n", body: "0\r\n\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatalf("net.Dial() error = %v", err)
+			}
+			defer conn.Close()
+			fmt.Fprintf(conn, "POST /vuln/smuggling HTTP/1.1\r\nHost: demo\r\n%sConnection: close\r\n\r\n%s", tt.headers, tt.body)
+
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				t.Fatalf("reading response: %v", err)
+			}
+			defer resp.Body.Close()
+			var body struct {
+				Vulnerable bool   `json:"vulnerable"`
+				DetectedCL string `json:"detected_cl"`
+				DetectedTE string `json:"detected_te"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("response is not JSON: %v", err)
+			}
+			if body.Vulnerable != tt.wantVulnerable {
+				t.Fatalf("vulnerable = %v, want %v", body.Vulnerable, tt.wantVulnerable)
+			}
+			if tt.wantVulnerable && (body.DetectedCL != "6" || body.DetectedTE != "chunked") {
+				t.Errorf("detected CL %q and TE %q, want 6 and chunked", body.DetectedCL, body.DetectedTE)
+			}
+		})
+	}
+}
+
+// gobPayload returns value GOB-encoded as an interface value, the way a
+// client picks the type the demo server decodes
+func gobPayload(t *testing.T, value interface{}) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		t.Fatalf("encoding payload: %v", err)
+	}
+	return &buf
+}
+
+func TestDemoDeserialize(t *testing.T) {
+	srv := newTestDemoServer(t, nil)
+
+	tests := []struct {
+		name     string
+		body     io.Reader
+		wantCode int
+		wantBody string
+	}{
+		{name: "command gadget", body: gobPayload(t, deserializeCommand{Cmd: "nuclei-deserialize-check"}), wantCode: http.StatusOK, wantBody: "nuclei-deserialize-check\n"},
+		{name: "plain value", body: gobPayload(t, "hello"), wantCode: http.StatusOK, wantBody: `{"type":"string"}` + "\n"},
+		{name: "not GOB", body: strings.NewReader("not gob"), wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/vuln/deserialize", tt.body)
+			req.Header.Set("Content-Type", "application/octet-stream")
+			rec := serveDemo(srv, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDemoServerTimeoutsFromEnvironment(t *testing.T) {
+	t.Setenv("SERVER_READ_TIMEOUT", "30")
+	t.Setenv("SERVER_WRITE_TIMEOUT", "300")
+	t.Setenv("SERVER_IDLE_TIMEOUT", "120")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load() error = %v", err)
+	}
+
+	srv := newTestDemoServer(t, func(c *config.Config) {
+		c.Server.ReadTimeoutSeconds = cfg.Server.ReadTimeoutSeconds
+		c.Server.WriteTimeoutSeconds = cfg.Server.WriteTimeoutSeconds
+		c.Server.IdleTimeoutSeconds = cfg.Server.IdleTimeoutSeconds
+	})
+	if srv.http.ReadTimeout != 30*time.Second {
+		t.Errorf("ReadTimeout = %v, want 30s", srv.http.ReadTimeout)
+	}
+	if srv.http.WriteTimeout != 300*time.Second {
+		t.Errorf("WriteTimeout = %v, want 300s", srv.http.WriteTimeout)
+	}
+	if srv.http.IdleTimeout != 120*time.Second {
+		t.Errorf("IdleTimeout = %v, want 120s", srv.http.IdleTimeout)
+	}
+}
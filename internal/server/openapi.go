@@ -0,0 +1,809 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"go.uber.org/zap"
+)
+
+// swaggerUIPage renders the Swagger UI for the OpenAPI spec
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Nuclei Service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function () {
+      SwaggerUIBundle({ url: "/api/v1/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
+
+// newOpenAPISpec builds the OpenAPI 3.0 document describing the API
+func newOpenAPISpec() *openapi3.T {
+	paths := openapi3.NewPaths()
+
+	// Template routes
+	paths.Set("/api/v1/templates", &openapi3.PathItem{
+		Get: newOperation("listTemplates", "List templates", "templates",
+			[]*openapi3.Parameter{
+				queryParam("tags", "Only return templates carrying this tag"),
+				queryParam("author", "Filter by template author"),
+				queryParam("severity", "Filter by severity level"),
+				queryParam("type", "Filter by template type"),
+				integerQueryParam("limit", "Maximum number of templates to return (default 50, max 500)"),
+				integerQueryParam("offset", "Number of templates to skip"),
+			},
+			jsonResponse(http.StatusOK, "Page of templates", listResponseSchema(templateSchema())),
+			textResponse(http.StatusBadRequest, "Invalid query parameter"),
+		),
+		Post: withFormDataBody(
+			newOperation("uploadTemplate", "Upload template", "templates", nil,
+				jsonResponse(http.StatusOK, "Uploaded template", templateSchema()),
+				textResponse(http.StatusBadRequest, "Invalid template"),
+				textResponse(http.StatusConflict, "Template already exists"),
+				textResponse(http.StatusRequestEntityTooLarge, "Template file too large"),
+			),
+			openapi3.NewObjectSchema().WithProperty("file", openapi3.NewStringSchema().WithFormat("binary")),
+		),
+	})
+	paths.Set("/api/v1/templates/import", &openapi3.PathItem{
+		Post: withFormDataBody(
+			newOperation("importTemplates", "Import templates from a ZIP archive", "templates", nil,
+				jsonResponse(http.StatusOK, "Import summary", importResultSchema()),
+				textResponse(http.StatusBadRequest, "Invalid archive"),
+				textResponse(http.StatusRequestEntityTooLarge, "Archive too large"),
+			),
+			openapi3.NewObjectSchema().WithProperty("zip", openapi3.NewStringSchema().WithFormat("binary")),
+		),
+	})
+	paths.Set("/api/v1/templates/validate", &openapi3.PathItem{
+		Post: withYAMLBody(
+			newOperation("validateTemplate", "Validate template YAML without storing it", "templates", nil,
+				jsonResponse(http.StatusOK, "Validation result", templateValidationSchema()),
+				textResponse(http.StatusRequestEntityTooLarge, "Template too large"),
+			),
+		),
+	})
+	paths.Set("/api/v1/templates/export", &openapi3.PathItem{
+		Get: newOperation("exportTemplates", "Export templates as a ZIP archive", "templates",
+			[]*openapi3.Parameter{
+				queryParam("tags", "Only export templates carrying this tag"),
+				queryParam("author", "Filter by template author"),
+				queryParam("severity", "Filter by severity level"),
+				queryParam("type", "Filter by template type"),
+			},
+			binaryResponse(http.StatusOK, "ZIP archive of template YAML files", "application/zip"),
+		),
+	})
+	paths.Set("/api/v1/templates/search", &openapi3.PathItem{
+		Get: newOperation("searchTemplates", "Search templates", "templates",
+			[]*openapi3.Parameter{queryParam("q", "Full-text query over name, description and ID (at least two characters)")},
+			jsonResponse(http.StatusOK, "Matching templates", openapi3.NewArraySchema().WithItems(templateSchema())),
+		),
+	})
+	paths.Set("/api/v1/templates/stats", &openapi3.PathItem{
+		Get: newOperation("getTemplateStats", "Get template counts by severity", "templates", nil,
+			jsonResponse(http.StatusOK, "Template statistics", templateStatsSchema()),
+		),
+	})
+	paths.Set("/api/v1/templates/{id}", &openapi3.PathItem{
+		Get: newOperation("getTemplate", "Get template details", "templates",
+			[]*openapi3.Parameter{pathParam("id")},
+			jsonResponse(http.StatusOK, "Template details", templateSchema()),
+			textResponse(http.StatusNotFound, "Template not found"),
+		),
+	})
+	paths.Set("/api/v1/templates/{id}/content", &openapi3.PathItem{
+		Get: newOperation("getTemplateContent", "Get template YAML", "templates",
+			[]*openapi3.Parameter{pathParam("id")},
+			textResponse(http.StatusOK, "Template YAML"),
+			textResponse(http.StatusNotFound, "Template not found"),
+		),
+	})
+	paths.Set("/api/v1/templates/{id}/related", &openapi3.PathItem{
+		Get: newOperation("getRelatedTemplates", "Get templates sharing tags", "templates",
+			[]*openapi3.Parameter{pathParam("id")},
+			jsonResponse(http.StatusOK, "Up to 10 templates, most shared tags first", openapi3.NewArraySchema().WithItems(templateSchema())),
+			textResponse(http.StatusNotFound, "Template not found"),
+		),
+	})
+	paths.Set("/api/v1/templates/refresh", &openapi3.PathItem{
+		Post: newOperation("refreshTemplates", "Refresh template cache", "templates", nil,
+			jsonResponse(http.StatusOK, "Templates refreshed", refreshResultSchema()),
+			textResponse(http.StatusConflict, "Template refresh already in progress"),
+		),
+	})
+	paths.Set("/api/v1/templates/refresh/status", &openapi3.PathItem{
+		Get: newOperation("getTemplateRefreshStatus", "Get template refresh status", "templates", nil,
+			jsonResponse(http.StatusOK, "Current or most recent template refresh", refreshStatusSchema()),
+		),
+	})
+
+	// Scan routes
+	paths.Set("/api/v1/scans", &openapi3.PathItem{
+		Get: newOperation("listScans", "List scans", "scans",
+			[]*openapi3.Parameter{
+				queryParam("status", "Filter by scan status"),
+				queryParam("target", "Filter by target URL"),
+				queryParam("template_id", "Filter by template ID"),
+				queryParam("tags", "Comma-separated tags; scans must carry every tag"),
+				integerQueryParam("limit", "Maximum number of scans to return (default 50, max 500)"),
+				integerQueryParam("offset", "Number of scans to skip"),
+			},
+			jsonResponse(http.StatusOK, "Page of scans, newest first", listResponseSchema(scanSchema())),
+			textResponse(http.StatusBadRequest, "Invalid query parameter"),
+		),
+		Post: withRequestBody(
+			newOperation("startScan", "Start new scan", "scans", nil,
+				jsonResponse(http.StatusOK, "Created scan, or an array of scans when target_group_id is set", scanSchema()),
+				textResponse(http.StatusBadRequest, "Invalid request body, target or options"),
+				textResponse(http.StatusNotFound, "Target group or profile not found"),
+				textResponse(http.StatusRequestEntityTooLarge, "Request body too large"),
+				textResponse(http.StatusTooManyRequests, "Scan queue is full"),
+			),
+			startScanInputSchema(),
+		),
+	})
+	paths.Set("/api/v1/scans/dry-run", &openapi3.PathItem{
+		Post: withRequestBody(
+			newOperation("dryRunScan", "Resolve the templates a scan would run without executing it", "scans", nil,
+				jsonResponse(http.StatusOK, "Dry-run scan with an unmatched result per selected template", scanSchema()),
+				textResponse(http.StatusBadRequest, "Invalid request body, target or options"),
+				textResponse(http.StatusNotFound, "Target group or profile not found"),
+				textResponse(http.StatusRequestEntityTooLarge, "Request body too large"),
+			),
+			startScanInputSchema(),
+		),
+	})
+	paths.Set("/api/v1/scans/{id}", &openapi3.PathItem{
+		Get: newOperation("getScan", "Get scan", "scans",
+			[]*openapi3.Parameter{pathParam("id")},
+			jsonResponse(http.StatusOK, "Scan details", scanSchema()),
+			textResponse(http.StatusNotFound, "Scan not found"),
+		),
+		Delete: newOperation("deleteScan", "Delete scan", "scans",
+			[]*openapi3.Parameter{pathParam("id")},
+			textResponse(http.StatusOK, "Scan deleted"),
+			textResponse(http.StatusNotFound, "Scan not found"),
+		),
+		Patch: withRequestBody(
+			newOperation("updateScanLabels", "Add or overwrite scan labels, keeping labels not in the request", "scans",
+				[]*openapi3.Parameter{pathParam("id")},
+				jsonResponse(http.StatusOK, "Updated scan", scanSchema()),
+				textResponse(http.StatusBadRequest, "Invalid request body or labels"),
+				textResponse(http.StatusNotFound, "Scan not found"),
+				textResponse(http.StatusRequestEntityTooLarge, "Request body too large"),
+			),
+			openapi3.NewObjectSchema().WithProperty("labels", scanLabelsSchema()),
+		),
+	})
+	paths.Set("/api/v1/scans/compare", &openapi3.PathItem{
+		Get: newOperation("compareScans", "Compare the results of two scans", "scans",
+			[]*openapi3.Parameter{
+				queryParam("id1", "Baseline scan ID").WithRequired(true),
+				queryParam("id2", "Scan ID compared against the baseline").WithRequired(true),
+			},
+			jsonResponse(http.StatusOK, "Findings new, resolved and common in id2 relative to id1", scanComparisonSchema()),
+			textResponse(http.StatusBadRequest, "Missing scan ID"),
+			textResponse(http.StatusNotFound, "Scan not found"),
+		),
+	})
+	paths.Set("/api/v1/scans/{id}/log", &openapi3.PathItem{
+		Get: newOperation("getScanLog", "Get the verbose nuclei log of a scan", "scans",
+			[]*openapi3.Parameter{pathParam("id")},
+			binaryResponse(http.StatusOK, "Captured nuclei output", "text/plain"),
+			textResponse(http.StatusNotFound, "Scan not found or has no verbose log"),
+		),
+	})
+	paths.Set("/api/v1/scans/{id}/results", &openapi3.PathItem{
+		Get: newOperation("getScanResults", "Get scan results", "scans",
+			[]*openapi3.Parameter{
+				pathParam("id"),
+				queryParam("severity", "Filter by result severity"),
+				queryParam("template_id", "Filter by template ID"),
+				queryParam("matched", "Filter by matched flag (true or false)"),
+				queryParam("include_false_positives", "Include results marked as false positives (true or false)"),
+				integerQueryParam("limit", "Maximum number of results to return (default 50, max 500)"),
+				integerQueryParam("offset", "Number of results to skip"),
+			},
+			jsonResponse(http.StatusOK, "Page of scan results", listResponseSchema(scanResultSchema())),
+			textResponse(http.StatusBadRequest, "Invalid query parameter"),
+			textResponse(http.StatusNotFound, "Scan not found"),
+		),
+	})
+
+	paths.Set("/api/v1/scans/{id}/results/{resultID}/false-positive", &openapi3.PathItem{
+		Patch: withRequestBody(
+			newOperation("markFalsePositive", "Mark or unmark a scan result as a false positive", "scans",
+				[]*openapi3.Parameter{pathParam("id"), pathParam("resultID")},
+				textResponse(http.StatusOK, "Scan result updated"),
+				textResponse(http.StatusBadRequest, "Invalid request body"),
+				textResponse(http.StatusNotFound, "Scan result not found"),
+				textResponse(http.StatusRequestEntityTooLarge, "Request body too large"),
+			),
+			openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+				"false_positive": openapi3.NewBoolSchema(),
+				"note":           openapi3.NewStringSchema(),
+			}),
+		),
+	})
+	paths.Set("/api/v1/scans/{id}/results/export/elasticsearch", &openapi3.PathItem{
+		Post: withRequestBody(
+			newOperation("exportResultsToElasticsearch", "Index scan results into Elasticsearch", "scans",
+				[]*openapi3.Parameter{pathParam("id")},
+				jsonResponse(http.StatusOK, "Number of indexed results", openapi3.NewObjectSchema().WithProperty("indexed", openapi3.NewIntegerSchema())),
+				textResponse(http.StatusBadRequest, "Invalid request body or export options"),
+				textResponse(http.StatusNotFound, "Scan not found"),
+				textResponse(http.StatusConflict, "Scan is still running"),
+				textResponse(http.StatusRequestEntityTooLarge, "Request body too large"),
+				textResponse(http.StatusBadGateway, "Elasticsearch rejected the export"),
+			),
+			openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+				"url":     openapi3.NewStringSchema(),
+				"index":   openapi3.NewStringSchema(),
+				"api_key": openapi3.NewStringSchema(),
+			}),
+		),
+	})
+
+	// Target routes
+	paths.Set("/api/v1/targets/{target}/scans", &openapi3.PathItem{
+		Get: newOperation("listScansByTarget", "List scans of a target", "scans",
+			[]*openapi3.Parameter{
+				pathParam("target").WithDescription("Path-escaped target URL, with / encoded as %2F"),
+				integerQueryParam("limit", "Maximum number of scans to return (default 50, max 500)"),
+				integerQueryParam("offset", "Number of scans to skip"),
+			},
+			jsonResponse(http.StatusOK, "Page of scans, newest first", listResponseSchema(scanSchema())),
+			textResponse(http.StatusBadRequest, "Invalid target or query parameter"),
+		),
+	})
+
+	// Target group routes
+	paths.Set("/api/v1/target-groups", &openapi3.PathItem{
+		Get: newOperation("listTargetGroups", "List target groups", "target-groups", nil,
+			jsonResponse(http.StatusOK, "List of target groups", openapi3.NewArraySchema().WithItems(targetGroupSchema())),
+		),
+		Post: withRequestBody(
+			newOperation("createTargetGroup", "Create target group", "target-groups", nil,
+				jsonResponse(http.StatusOK, "Created target group", targetGroupSchema()),
+				textResponse(http.StatusBadRequest, "Invalid request body, name or targets"),
+				textResponse(http.StatusRequestEntityTooLarge, "Request body too large"),
+			),
+			openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+				"name":    openapi3.NewStringSchema(),
+				"targets": openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+			}),
+		),
+	})
+	paths.Set("/api/v1/target-groups/{id}", &openapi3.PathItem{
+		Get: newOperation("getTargetGroup", "Get target group", "target-groups",
+			[]*openapi3.Parameter{pathParam("id")},
+			jsonResponse(http.StatusOK, "Target group", targetGroupSchema()),
+			textResponse(http.StatusNotFound, "Target group not found"),
+		),
+		Delete: newOperation("deleteTargetGroup", "Delete target group", "target-groups",
+			[]*openapi3.Parameter{pathParam("id")},
+			textResponse(http.StatusOK, "Target group deleted"),
+			textResponse(http.StatusNotFound, "Target group not found"),
+		),
+	})
+
+	// Scan profile routes
+	paths.Set("/api/v1/profiles", &openapi3.PathItem{
+		Get: newOperation("listProfiles", "List scan profiles", "profiles", nil,
+			jsonResponse(http.StatusOK, "List of scan profiles", openapi3.NewArraySchema().WithItems(profileSchema())),
+		),
+		Post: withRequestBody(
+			newOperation("createProfile", "Create scan profile", "profiles", nil,
+				jsonResponse(http.StatusOK, "Created scan profile", profileSchema()),
+				textResponse(http.StatusBadRequest, "Invalid request body, name or options"),
+				textResponse(http.StatusRequestEntityTooLarge, "Request body too large"),
+			),
+			profileInputSchema(),
+		),
+	})
+	paths.Set("/api/v1/profiles/{id}", &openapi3.PathItem{
+		Get: newOperation("getProfile", "Get scan profile", "profiles",
+			[]*openapi3.Parameter{pathParam("id")},
+			jsonResponse(http.StatusOK, "Scan profile", profileSchema()),
+			textResponse(http.StatusNotFound, "Profile not found"),
+		),
+		Put: withRequestBody(
+			newOperation("updateProfile", "Update scan profile", "profiles",
+				[]*openapi3.Parameter{pathParam("id")},
+				jsonResponse(http.StatusOK, "Updated scan profile", profileSchema()),
+				textResponse(http.StatusBadRequest, "Invalid request body, name or options"),
+				textResponse(http.StatusNotFound, "Profile not found"),
+				textResponse(http.StatusRequestEntityTooLarge, "Request body too large"),
+			),
+			profileInputSchema(),
+		),
+		Delete: newOperation("deleteProfile", "Delete scan profile", "profiles",
+			[]*openapi3.Parameter{pathParam("id")},
+			textResponse(http.StatusOK, "Profile deleted"),
+			textResponse(http.StatusNotFound, "Profile not found"),
+		),
+	})
+
+	// API key routes
+	paths.Set("/api/v1/api-keys", &openapi3.PathItem{
+		Get: newOperation("listAPIKeys", "List API keys", "api-keys", nil,
+			jsonResponse(http.StatusOK, "List of API keys, without their keys", openapi3.NewArraySchema().WithItems(apiKeySchema())),
+			textResponse(http.StatusUnauthorized, "Missing, unknown or expired API key"),
+			textResponse(http.StatusForbidden, "API key lacks the admin permission"),
+		),
+		Post: withRequestBody(
+			newOperation("createAPIKey", "Create API key", "api-keys", nil,
+				jsonResponse(http.StatusOK, "Created API key, including the plaintext key shown only once", createdAPIKeySchema()),
+				textResponse(http.StatusBadRequest, "Invalid request body, name, permissions or expiry"),
+				textResponse(http.StatusUnauthorized, "Missing, unknown or expired API key"),
+				textResponse(http.StatusForbidden, "API key lacks the admin permission"),
+				textResponse(http.StatusRequestEntityTooLarge, "Request body too large"),
+			),
+			openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+				"name":        openapi3.NewStringSchema(),
+				"permissions": permissionsSchema(),
+				"expires_at":  openapi3.NewDateTimeSchema(),
+			}),
+		),
+	})
+	paths.Set("/api/v1/api-keys/{id}", &openapi3.PathItem{
+		Delete: newOperation("deleteAPIKey", "Delete API key", "api-keys",
+			[]*openapi3.Parameter{pathParam("id")},
+			textResponse(http.StatusOK, "API key deleted"),
+			textResponse(http.StatusUnauthorized, "Missing, unknown or expired API key"),
+			textResponse(http.StatusForbidden, "API key lacks the admin permission"),
+			textResponse(http.StatusNotFound, "API key not found"),
+		),
+	})
+
+	// Worker routes
+	paths.Set("/api/v1/worker/status", &openapi3.PathItem{
+		Get: newOperation("getWorkerStatus", "Get scan worker status", "worker", nil,
+			jsonResponse(http.StatusOK, "Scan worker status", workerStatusSchema()),
+		),
+	})
+	paths.Set("/api/v1/worker/pause", &openapi3.PathItem{
+		Post: newOperation("pauseWorker", "Pause scan worker", "worker", nil,
+			jsonResponse(http.StatusOK, "Scan worker status after pausing", workerStatusSchema()),
+		),
+	})
+	paths.Set("/api/v1/worker/resume", &openapi3.PathItem{
+		Post: newOperation("resumeWorker", "Resume scan worker", "worker", nil,
+			jsonResponse(http.StatusOK, "Scan worker status after resuming", workerStatusSchema()),
+		),
+	})
+
+	// Stats routes
+	paths.Set("/api/v1/stats", &openapi3.PathItem{
+		Get: newOperation("getStats", "Get scan and template statistics", "stats", nil,
+			jsonResponse(http.StatusOK, "Aggregate statistics", statsSchema()),
+		),
+	})
+
+	// Documentation routes
+	paths.Set("/api/v1/openapi.json", &openapi3.PathItem{
+		Get: newOperation("getOpenAPISpec", "Get OpenAPI spec", "docs", nil,
+			jsonResponse(http.StatusOK, "OpenAPI 3.0 document", openapi3.NewObjectSchema()),
+		),
+	})
+	paths.Set("/api/v1/docs", &openapi3.PathItem{
+		Get: newOperation("getDocs", "Swagger UI", "docs", nil,
+			textResponse(http.StatusOK, "Swagger UI HTML page"),
+		),
+	})
+
+	return &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:       "Nuclei Service API",
+			Description: "REST API for managing Nuclei templates and vulnerability scans",
+			Version:     "1.0.0",
+		},
+		Paths: paths,
+	}
+}
+
+// newOperation creates an operation with the given parameters and responses
+func newOperation(id, summary, tag string, params []*openapi3.Parameter, responses ...*statusResponse) *openapi3.Operation {
+	op := openapi3.NewOperation()
+	op.OperationID = id
+	op.Summary = summary
+	op.Tags = []string{tag}
+	for _, param := range params {
+		op.AddParameter(param)
+	}
+	for _, resp := range responses {
+		op.AddResponse(resp.status, resp.response)
+	}
+	return op
+}
+
+// withRequestBody attaches a required JSON request body to an operation
+func withRequestBody(op *openapi3.Operation, schema *openapi3.Schema) *openapi3.Operation {
+	op.RequestBody = &openapi3.RequestBodyRef{
+		Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchema(schema),
+	}
+	return op
+}
+
+// withFormDataBody attaches a required multipart/form-data request body to an operation
+func withFormDataBody(op *openapi3.Operation, schema *openapi3.Schema) *openapi3.Operation {
+	op.RequestBody = &openapi3.RequestBodyRef{
+		Value: openapi3.NewRequestBody().WithRequired(true).WithFormDataSchema(schema),
+	}
+	return op
+}
+
+// withYAMLBody attaches a required application/yaml request body to an operation
+func withYAMLBody(op *openapi3.Operation) *openapi3.Operation {
+	op.RequestBody = &openapi3.RequestBodyRef{
+		Value: openapi3.NewRequestBody().WithRequired(true).
+			WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"application/yaml"})),
+	}
+	return op
+}
+
+// statusResponse pairs a response with its HTTP status code
+type statusResponse struct {
+	status   int
+	response *openapi3.Response
+}
+
+// jsonResponse creates a JSON response with the given schema
+func jsonResponse(status int, description string, schema *openapi3.Schema) *statusResponse {
+	return &statusResponse{
+		status:   status,
+		response: openapi3.NewResponse().WithDescription(description).WithJSONSchema(schema),
+	}
+}
+
+// textResponse creates a response without a JSON body
+func textResponse(status int, description string) *statusResponse {
+	return &statusResponse{
+		status:   status,
+		response: openapi3.NewResponse().WithDescription(description),
+	}
+}
+
+// binaryResponse creates a response whose body is a file of the given media type
+func binaryResponse(status int, description, mediaType string) *statusResponse {
+	return &statusResponse{
+		status: status,
+		response: openapi3.NewResponse().WithDescription(description).
+			WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema().WithFormat("binary"), []string{mediaType})),
+	}
+}
+
+// pathParam creates a required string path parameter
+func pathParam(name string) *openapi3.Parameter {
+	return openapi3.NewPathParameter(name).WithSchema(openapi3.NewStringSchema())
+}
+
+// queryParam creates an optional string query parameter
+func queryParam(name, description string) *openapi3.Parameter {
+	return openapi3.NewQueryParameter(name).WithDescription(description).WithSchema(openapi3.NewStringSchema())
+}
+
+// integerQueryParam creates an optional integer query parameter
+func integerQueryParam(name, description string) *openapi3.Parameter {
+	return openapi3.NewQueryParameter(name).WithDescription(description).WithSchema(openapi3.NewIntegerSchema())
+}
+
+// templateSchema describes model.Template
+func templateSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"id":          openapi3.NewStringSchema(),
+		"name":        openapi3.NewStringSchema(),
+		"author":      openapi3.NewStringSchema(),
+		"tags":        openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+		"severity":    openapi3.NewStringSchema(),
+		"type":        openapi3.NewStringSchema(),
+		"description": openapi3.NewStringSchema(),
+		"path":        openapi3.NewStringSchema(),
+		"created_at":  openapi3.NewDateTimeSchema(),
+		"updated_at":  openapi3.NewDateTimeSchema(),
+	})
+}
+
+// refreshStatusSchema describes model.TemplateRefreshStatus
+func refreshStatusSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"running":          openapi3.NewBoolSchema(),
+		"last_refresh_at":  openapi3.NewDateTimeSchema(),
+		"last_duration_ms": openapi3.NewIntegerSchema(),
+		"template_count":   openapi3.NewIntegerSchema(),
+		"last_result":      refreshResultSchema(),
+		"last_error":       openapi3.NewStringSchema(),
+	})
+}
+
+// workerStatusSchema describes model.WorkerStatus
+func workerStatusSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"paused":       openapi3.NewBoolSchema(),
+		"active_scans": openapi3.NewIntegerSchema(),
+		"last_run":     openapi3.NewDateTimeSchema(),
+	})
+}
+
+// refreshResultSchema describes model.RefreshResult
+func refreshResultSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"loaded":    openapi3.NewIntegerSchema(),
+		"unchanged": openapi3.NewIntegerSchema(),
+		"removed":   openapi3.NewIntegerSchema(),
+		"failed":    openapi3.NewIntegerSchema(),
+		"errors":    openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+	})
+}
+
+// importResultSchema describes model.ImportResult
+func importResultSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"imported": openapi3.NewIntegerSchema(),
+		"failed":   openapi3.NewIntegerSchema(),
+		"errors":   openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+	})
+}
+
+// templateValidationSchema describes model.TemplateValidation
+func templateValidationSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"valid":  openapi3.NewBoolSchema(),
+		"errors": openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+	})
+}
+
+// scanOptionsSchema describes model.ScanOptions
+func scanOptionsSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"concurrency":      openapi3.NewIntegerSchema(),
+		"rate_limit":       openapi3.NewIntegerSchema(),
+		"timeout":          openapi3.NewIntegerSchema(),
+		"retries":          openapi3.NewIntegerSchema(),
+		"headless":         openapi3.NewBoolSchema(),
+		"follow_redirects": openapi3.NewBoolSchema(),
+		"proxy":            openapi3.NewStringSchema(),
+		"tls_skip_verify":  openapi3.NewBoolSchema(),
+		"dry_run":          openapi3.NewBoolSchema(),
+		"passive":          openapi3.NewBoolSchema(),
+		"passive_input":    openapi3.NewStringSchema(),
+		"verbose":          openapi3.NewBoolSchema(),
+		"dns_resolvers":    openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+		"custom_headers":   openapi3.NewObjectSchema().WithAdditionalProperties(openapi3.NewStringSchema()),
+		"custom_cookies":   openapi3.NewObjectSchema().WithAdditionalProperties(openapi3.NewStringSchema()),
+	})
+}
+
+// scanResultSchema describes model.ScanResult
+func scanResultSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"id":                openapi3.NewStringSchema(),
+		"scan_id":           openapi3.NewStringSchema(),
+		"template_id":       openapi3.NewStringSchema(),
+		"template_name":     openapi3.NewStringSchema(),
+		"severity":          openapi3.NewStringSchema(),
+		"matched":           openapi3.NewBoolSchema(),
+		"host":              openapi3.NewStringSchema(),
+		"matched_at":        openapi3.NewDateTimeSchema(),
+		"matcher_name":      openapi3.NewStringSchema(),
+		"extracted_results": openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+		"request":           openapi3.NewStringSchema(),
+		"response":          openapi3.NewStringSchema(),
+		"metadata":          openapi3.NewObjectSchema(),
+
+		"is_false_positive":   openapi3.NewBoolSchema(),
+		"false_positive_note": openapi3.NewStringSchema(),
+	})
+}
+
+// listResponseSchema describes model.ListResponse with items of the given schema
+func listResponseSchema(items *openapi3.Schema) *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"data": openapi3.NewArraySchema().WithItems(items),
+		"meta": openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+			"total":  openapi3.NewIntegerSchema(),
+			"limit":  openapi3.NewIntegerSchema(),
+			"offset": openapi3.NewIntegerSchema(),
+		}),
+	})
+}
+
+// scanComparisonSchema describes model.ScanComparison
+func scanComparisonSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"new":      openapi3.NewArraySchema().WithItems(scanResultSchema()),
+		"resolved": openapi3.NewArraySchema().WithItems(scanResultSchema()),
+		"common":   openapi3.NewArraySchema().WithItems(scanResultSchema()),
+	})
+}
+
+// targetGroupSchema describes model.TargetGroup
+func targetGroupSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"id":         openapi3.NewStringSchema(),
+		"name":       openapi3.NewStringSchema(),
+		"targets":    openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+		"created_at": openapi3.NewDateTimeSchema(),
+		"updated_at": openapi3.NewDateTimeSchema(),
+	})
+}
+
+// profileSchema describes model.ScanProfile
+func profileSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"id":          openapi3.NewStringSchema(),
+		"name":        openapi3.NewStringSchema(),
+		"description": openapi3.NewStringSchema(),
+		"options":     scanOptionsSchema(),
+		"created_at":  openapi3.NewDateTimeSchema(),
+		"updated_at":  openapi3.NewDateTimeSchema(),
+	})
+}
+
+// profileInputSchema describes model.ScanProfileInput
+func profileInputSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"name":        openapi3.NewStringSchema(),
+		"description": openapi3.NewStringSchema(),
+		"options":     scanOptionsSchema(),
+	})
+}
+
+// permissionsSchema describes the permissions of an API key
+func permissionsSchema() *openapi3.Schema {
+	return openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema().WithEnum("read", "write", "admin"))
+}
+
+// apiKeySchema describes model.APIKey
+func apiKeySchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"id":          openapi3.NewStringSchema(),
+		"name":        openapi3.NewStringSchema(),
+		"permissions": permissionsSchema(),
+		"created_at":  openapi3.NewDateTimeSchema(),
+		"expires_at":  openapi3.NewDateTimeSchema(),
+	})
+}
+
+// createdAPIKeySchema describes model.CreatedAPIKey
+func createdAPIKeySchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"id":          openapi3.NewStringSchema(),
+		"key":         openapi3.NewStringSchema(),
+		"name":        openapi3.NewStringSchema(),
+		"permissions": permissionsSchema(),
+		"created_at":  openapi3.NewDateTimeSchema(),
+		"expires_at":  openapi3.NewDateTimeSchema(),
+	})
+}
+
+// scanSchema describes model.Scan
+func scanSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"id":             openapi3.NewStringSchema(),
+		"target":         openapi3.NewStringSchema(),
+		"targets":        openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+		"status":         openapi3.NewStringSchema().WithEnum("pending", "running", "completed", "failed", "cancelled", "dry_run"),
+		"template_ids":   openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+		"workflow_ids":   openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+		"tags":           openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+		"labels":         scanLabelsSchema(),
+		"priority":       scanPrioritySchema(),
+		"options":        scanOptionsSchema(),
+		"error":          openapi3.NewStringSchema(),
+		"result_summary": resultSummarySchema(),
+		"created_at":     openapi3.NewDateTimeSchema(),
+		"updated_at":     openapi3.NewDateTimeSchema(),
+		"started_at":     openapi3.NewDateTimeSchema(),
+		"completed_at":   openapi3.NewDateTimeSchema(),
+		"results":        openapi3.NewArraySchema().WithItems(scanResultSchema()),
+	})
+}
+
+// resultSummarySchema describes model.ResultSummary
+func resultSummarySchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"critical": openapi3.NewIntegerSchema(),
+		"high":     openapi3.NewIntegerSchema(),
+		"medium":   openapi3.NewIntegerSchema(),
+		"low":      openapi3.NewIntegerSchema(),
+		"info":     openapi3.NewIntegerSchema(),
+	})
+}
+
+// scanLabelsSchema describes scan labels, a map of label names to values
+func scanLabelsSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithAdditionalProperties(openapi3.NewStringSchema())
+}
+
+// scanPrioritySchema describes a scan priority: 0 (low), 1 (normal) or 2 (high)
+func scanPrioritySchema() *openapi3.Schema {
+	return openapi3.NewIntegerSchema().WithMin(0).WithMax(2)
+}
+
+// startScanInputSchema describes model.StartScanInput
+func startScanInputSchema() *openapi3.Schema {
+	// One of target, targets or target_group_id is required
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"target":          openapi3.NewStringSchema(),
+		"targets":         openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+		"target_group_id": openapi3.NewStringSchema(),
+		"profile_id":      openapi3.NewStringSchema(),
+		"template_ids":    openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+		"workflow_ids":    openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+		"tags":            openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()),
+		"priority":        scanPrioritySchema(),
+		"options":         scanOptionsSchema(),
+	})
+}
+
+// statsSchema describes model.Stats
+func statsSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"scans": openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+			"total":     openapi3.NewIntegerSchema(),
+			"by_status": countsSchema(),
+			"last_24h":  openapi3.NewIntegerSchema(),
+		}),
+		"templates": templateStatsSchema(),
+		"results": openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+			"total":       openapi3.NewIntegerSchema(),
+			"by_severity": countsSchema(),
+		}),
+		"worker": openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+			"active_scans":    openapi3.NewIntegerSchema(),
+			"total_processed": openapi3.NewIntegerSchema(),
+			"total_failed":    openapi3.NewIntegerSchema(),
+			"last_run_at":     openapi3.NewDateTimeSchema(),
+		}),
+	})
+}
+
+// templateStatsSchema describes model.TemplateStats
+func templateStatsSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithProperties(map[string]*openapi3.Schema{
+		"total":       openapi3.NewIntegerSchema(),
+		"by_severity": countsSchema(),
+	})
+}
+
+// countsSchema describes a map of keys to counts
+func countsSchema() *openapi3.Schema {
+	return openapi3.NewObjectSchema().WithAdditionalProperties(openapi3.NewIntegerSchema())
+}
+
+// handleOpenAPISpec handles GET /api/v1/openapi.json
+func (s *Server) handleOpenAPISpec(spec *openapi3.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := loggerFromContext(r.Context(), s.logger)
+
+		// Write response
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(spec); err != nil {
+			logger.Error("Failed to encode response", zap.Error(err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// handleDocs handles GET /api/v1/docs
+func (s *Server) handleDocs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(swaggerUIPage))
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/config"
+)
+
+func TestOpenAPISpecIsValid(t *testing.T) {
+	data, err := json.Marshal(newOpenAPISpec())
+	if err != nil {
+		t.Fatalf("failed to encode spec: %v", err)
+	}
+
+	spec, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		t.Fatalf("failed to load spec: %v", err)
+	}
+	if err := spec.Validate(context.Background()); err != nil {
+		t.Fatalf("spec is invalid: %v", err)
+	}
+}
+
+func TestOpenAPISpecDocumentsEveryRoute(t *testing.T) {
+	spec := newOpenAPISpec()
+	s := &Server{cfg: &config.Config{}, logger: zap.NewNop(), router: mux.NewRouter()}
+	s.registerRoutes(nil, nil, nil, nil, nil, nil, nil, spec)
+
+	routes := 0
+	err := s.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		routes++
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		item := spec.Paths.Value(path)
+		if item == nil {
+			t.Errorf("route %s has no path entry", path)
+			return nil
+		}
+		for _, method := range methods {
+			if item.GetOperation(method) == nil {
+				t.Errorf("route %s %s has no operation", method, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk routes: %v", err)
+	}
+	if routes == 0 {
+		t.Fatal("no routes were registered")
+	}
+}
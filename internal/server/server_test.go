@@ -0,0 +1,1300 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"nuclei-service-demo/internal/config"
+	apperrors "nuclei-service-demo/internal/errors"
+	"nuclei-service-demo/internal/export"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+	"nuclei-service-demo/internal/service"
+)
+
+// fakeScanService records the input of the scans it is asked to start, and
+// fails them with err if it is set. It serves results, and the filter they
+// were asked for, from results, and lists scans from scans. Methods a test
+// does not use panic through the nil embedded interface
+type fakeScanService struct {
+	service.ScanService
+
+	started []model.StartScanInput
+	err     error
+
+	results []*model.ScanResult
+	filter  model.ResultFilter
+
+	scans    []model.Scan
+	listTags []string
+}
+
+// ListScans records tags and returns the scans of the target, or all of
+// them without one
+func (f *fakeScanService) ListScans(ctx context.Context, status, target, templateID *string, tags []string, page model.Page) (*model.ListResponse[model.Scan], error) {
+	f.listTags = tags
+	scans := []model.Scan{}
+	for _, scan := range f.scans {
+		if target == nil || slices.Contains(scan.Targets, *target) {
+			scans = append(scans, scan)
+		}
+	}
+	return &model.ListResponse[model.Scan]{Data: scans, Meta: model.PageMeta{Total: len(scans), Limit: page.Limit}}, nil
+}
+
+// StartScan records input and returns a pending scan
+func (f *fakeScanService) StartScan(ctx context.Context, input model.StartScanInput) (*model.Scan, error) {
+	f.started = append(f.started, input)
+	if f.err != nil {
+		return nil, f.err
+	}
+	targets := input.ScanTargets()
+	return &model.Scan{ID: "scan-1", Target: targets[0], Targets: targets, Status: model.ScanStatusPending}, nil
+}
+
+// GetScan returns a completed scan with the given ID
+func (f *fakeScanService) GetScan(ctx context.Context, id string) (*model.Scan, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &model.Scan{ID: id, Status: model.ScanStatusCompleted}, nil
+}
+
+// GetScanResults records filter and returns the results whose severity,
+// template and matched flag it allows, like the repository does
+func (f *fakeScanService) GetScanResults(ctx context.Context, scanID string, filter model.ResultFilter, page model.Page) (*model.ListResponse[*model.ScanResult], error) {
+	f.filter = filter
+	results := []*model.ScanResult{}
+	for _, result := range f.results {
+		if (filter.Severity == nil || result.Severity == *filter.Severity) &&
+			(filter.TemplateID == nil || result.TemplateID == *filter.TemplateID) &&
+			(filter.Matched == nil || result.Matched == *filter.Matched) {
+			results = append(results, result)
+		}
+	}
+	return &model.ListResponse[*model.ScanResult]{Data: results, Meta: model.PageMeta{Total: len(results), Limit: page.Limit}}, nil
+}
+
+// CompareScans returns a comparison with one common finding
+func (f *fakeScanService) CompareScans(ctx context.Context, id1, id2 string) (*model.ScanComparison, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &model.ScanComparison{
+		New:      []*model.ScanResult{},
+		Resolved: []*model.ScanResult{},
+		Common:   []*model.ScanResult{{ID: "result-1", ScanID: id2, TemplateID: "tech-detect"}},
+	}, nil
+}
+
+// newTestServer returns a server with a default configuration that logs nowhere
+func newTestServer() *Server {
+	return &Server{cfg: &config.Config{}, logger: zap.NewNop()}
+}
+
+// ptr returns a pointer to v
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// startTestScan posts body to the start scan handler and returns the input
+// the scan service received
+func startTestScan(t *testing.T, body string) model.StartScanInput {
+	t.Helper()
+
+	scans := &fakeScanService{}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scans", strings.NewReader(body))
+	newTestServer().handleStartScan(scans, nil, false).ServeHTTP(rec, req)
+
+	if len(scans.started) != 1 {
+		t.Fatalf("started %d scans, want 1 (status %d: %s)", len(scans.started), rec.Code, rec.Body.String())
+	}
+	return scans.started[0]
+}
+
+func TestCorrelationIDMiddlewareEchoesRequestID(t *testing.T) {
+	var seen string
+	handler := correlationIDMiddleware(zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestIDFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/scans", nil)
+	req.Header.Set(requestIDHeader, "test-request-id")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(requestIDHeader); got != "test-request-id" {
+		t.Errorf("response %s = %q, want %q", requestIDHeader, got, "test-request-id")
+	}
+	if seen != "test-request-id" {
+		t.Errorf("request ID in context = %q, want %q", seen, "test-request-id")
+	}
+}
+
+func TestCorrelationIDMiddlewareGeneratesRequestID(t *testing.T) {
+	handler := correlationIDMiddleware(zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scans", nil))
+
+	if rec.Header().Get(requestIDHeader) == "" {
+		t.Errorf("response %s is empty, want a generated ID", requestIDHeader)
+	}
+}
+
+func TestCompressionMiddlewareGzipsJSON(t *testing.T) {
+	handler := compressionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("body is not gzip: %v", err)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(gz).Decode(&body); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if body["status"] != "ok" {
+		t.Errorf("body = %v, want status ok", body)
+	}
+}
+
+func TestCompressionMiddlewareSniffsUncompressedContentType(t *testing.T) {
+	handler := compressionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>docs</body></html>"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/docs", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", got)
+	}
+}
+
+func TestCompressionMiddlewareSkipsResponsesWithoutBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		status int
+	}{
+		{name: "no content", method: http.MethodDelete, status: http.StatusNoContent},
+		{name: "not modified", method: http.MethodGet, status: http.StatusNotModified},
+		{name: "head", method: http.MethodHead, status: http.StatusOK},
+		{name: "empty body", method: http.MethodPost, status: http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := compressionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+
+			req := httptest.NewRequest(tt.method, "/api/v1/scans/1", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("Content-Encoding = %q, want none", got)
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("body = %q, want empty", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCompressionMiddlewareWithoutAcceptEncoding(t *testing.T) {
+	handler := compressionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil))
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q, want none", got)
+	}
+	if rec.Body.String() != "plain" {
+		t.Errorf("body = %q, want plain", rec.Body.String())
+	}
+}
+
+func TestHandleStartScanPassesFollowRedirects(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want *bool
+	}{
+		{name: "enabled", body: `{"target": "http://example.com", "options": {"follow_redirects": true}}`, want: ptr(true)},
+		{name: "disabled", body: `{"target": "http://example.com", "options": {"follow_redirects": false}}`, want: ptr(false)},
+		{name: "omitted", body: `{"target": "http://example.com", "options": {}}`, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := startTestScan(t, tt.body)
+
+			got := input.Options.FollowRedirects
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("FollowRedirects = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleStartScanPassesCustomHeaders(t *testing.T) {
+	input := startTestScan(t, `{"target": "http://example.com", "options": {"custom_headers": {"Authorization": "Bearer token", "X-Tenant": "acme"}}}`)
+
+	want := map[string]string{"Authorization": "Bearer token", "X-Tenant": "acme"}
+	if !maps.Equal(input.Options.CustomHeaders, want) {
+		t.Errorf("CustomHeaders = %v, want %v", input.Options.CustomHeaders, want)
+	}
+}
+
+func TestHandleStartScanPassesCustomCookies(t *testing.T) {
+	input := startTestScan(t, `{"target": "http://example.com", "options": {"custom_cookies": {"session": "abc123"}}}`)
+
+	want := map[string]string{"session": "abc123"}
+	if !maps.Equal(input.Options.CustomCookies, want) {
+		t.Errorf("CustomCookies = %v, want %v", input.Options.CustomCookies, want)
+	}
+}
+
+func TestHandleDryRunScanSetsOption(t *testing.T) {
+	scans := &fakeScanService{}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scans/dry-run", strings.NewReader(`{"target": "http://example.com"}`))
+	newTestServer().handleStartScan(scans, nil, true).ServeHTTP(rec, req)
+
+	if len(scans.started) != 1 {
+		t.Fatalf("started %d scans, want 1 (status %d: %s)", len(scans.started), rec.Code, rec.Body.String())
+	}
+	if options := scans.started[0].Options; options == nil || !options.DryRun {
+		t.Errorf("Options = %+v, want DryRun set", options)
+	}
+}
+
+func TestHandleStartScanPassesWorkflowIDs(t *testing.T) {
+	scans := &fakeScanService{}
+	rec := httptest.NewRecorder()
+	body := `{"target": "http://example.com", "workflow_ids": ["wordpress-workflow", "workflows/jira"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scans", strings.NewReader(body))
+	newTestServer().handleStartScan(scans, nil, false).ServeHTTP(rec, req)
+
+	if len(scans.started) != 1 {
+		t.Fatalf("started %d scans, want 1 (status %d: %s)", len(scans.started), rec.Code, rec.Body.String())
+	}
+	want := []string{"wordpress-workflow", "workflows/jira"}
+	if got := scans.started[0].WorkflowIDs; !slices.Equal(got, want) {
+		t.Errorf("WorkflowIDs = %v, want %v", got, want)
+	}
+}
+
+func TestHandleStartScanPassesPassiveOptions(t *testing.T) {
+	scans := &fakeScanService{}
+	rec := httptest.NewRecorder()
+	body := `{"target": "http://example.com", "options": {"passive": true, "passive_input": "site/responses"}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scans", strings.NewReader(body))
+	newTestServer().handleStartScan(scans, nil, false).ServeHTTP(rec, req)
+
+	if len(scans.started) != 1 {
+		t.Fatalf("started %d scans, want 1 (status %d: %s)", len(scans.started), rec.Code, rec.Body.String())
+	}
+	if options := scans.started[0].Options; options == nil || !options.Passive || options.PassiveInput != "site/responses" {
+		t.Errorf("Options = %+v, want passive with input site/responses", options)
+	}
+}
+
+func TestHandleStartScanPassesDNSResolvers(t *testing.T) {
+	scans := &fakeScanService{}
+	rec := httptest.NewRecorder()
+	body := `{"target": "http://example.com", "options": {"dns_resolvers": ["1.1.1.1:53", "dns.internal:5353"]}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scans", strings.NewReader(body))
+	newTestServer().handleStartScan(scans, nil, false).ServeHTTP(rec, req)
+
+	if len(scans.started) != 1 {
+		t.Fatalf("started %d scans, want 1 (status %d: %s)", len(scans.started), rec.Code, rec.Body.String())
+	}
+	want := []string{"1.1.1.1:53", "dns.internal:5353"}
+	if options := scans.started[0].Options; options == nil || !slices.Equal(options.DNSResolvers, want) {
+		t.Errorf("Options = %+v, want dns resolvers %v", options, want)
+	}
+}
+
+func TestHandleStartScanTargets(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantTargets []string
+	}{
+		{name: "two targets", body: `{"targets": ["http://a.example.com", "http://b.example.com"]}`,
+			wantStatus: http.StatusOK, wantTargets: []string{"http://a.example.com", "http://b.example.com"}},
+		{name: "single target", body: `{"target": "http://a.example.com"}`,
+			wantStatus: http.StatusOK, wantTargets: []string{"http://a.example.com"}},
+		{name: "target and targets", body: `{"target": "http://a.example.com", "targets": ["http://b.example.com"]}`,
+			wantStatus: http.StatusBadRequest},
+		{name: "second target invalid", body: `{"targets": ["http://a.example.com", "ftp://b.example.com"]}`,
+			wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scans := &fakeScanService{}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/scans", strings.NewReader(tt.body))
+			newTestServer().handleStartScan(scans, nil, false).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(scans.started) != 0 {
+					t.Errorf("started %d scans, want none", len(scans.started))
+				}
+				return
+			}
+			if got := scans.started[0].ScanTargets(); !slices.Equal(got, tt.wantTargets) {
+				t.Errorf("targets = %v, want %v", got, tt.wantTargets)
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{"not found", repository.ErrNotFound, http.StatusNotFound, "not found"},
+		{"validation detail", fmt.Errorf("%w: concurrency must be between 1 and 500", model.ErrInvalidScanOptions),
+			http.StatusBadRequest, "invalid scan options: concurrency must be between 1 and 500"},
+		{"service sentinel", service.ErrProfileNotFound, http.StatusNotFound, "scan profile not found"},
+		{"conflict", fmt.Errorf("%w: cve-2021-1234", service.ErrTemplateExists), http.StatusConflict, "template already exists: cve-2021-1234"},
+		{"queue full", model.ErrQueueFull, http.StatusTooManyRequests, "scan queue is full"},
+		{"export failed", fmt.Errorf("%w: cluster returned 401", export.ErrExportFailed), http.StatusBadGateway, "export failed: cluster returned 401"},
+		{"cause is not reported", &apperrors.Error{Code: apperrors.CodeConflict, Message: "a record with the same values already exists", Err: errors.New("pq: duplicate key")},
+			http.StatusConflict, "a record with the same values already exists"},
+		{"untyped", errors.New("connection refused"), http.StatusInternalServerError, "Internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeError(rec, zap.NewNop(), tt.err, "Failed")
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if body := strings.TrimSpace(rec.Body.String()); body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandleStartScanReportsServiceErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"invalid options", fmt.Errorf("%w: rate_limit must be between 1 and 10000", model.ErrInvalidScanOptions), http.StatusBadRequest},
+		{"invalid priority", model.ErrInvalidPriority, http.StatusBadRequest},
+		{"missing profile", service.ErrProfileNotFound, http.StatusNotFound},
+		{"missing target group", repository.ErrNotFound, http.StatusNotFound},
+		{"queue full", model.ErrQueueFull, http.StatusTooManyRequests},
+		{"internal", errors.New("database is down"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scans := &fakeScanService{err: tt.err}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/scans", strings.NewReader(`{"target": "http://example.com"}`))
+			newTestServer().handleStartScan(scans, nil, false).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestPermissionMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []string
+		method      string
+		path        string
+		wantStatus  int
+	}{
+		{"read key reads", []string{model.PermissionRead}, http.MethodGet, "/api/v1/scans", http.StatusOK},
+		{"read key heads", []string{model.PermissionRead}, http.MethodHead, "/api/v1/scans", http.StatusOK},
+		{"read key cannot write", []string{model.PermissionRead}, http.MethodPost, "/api/v1/scans", http.StatusForbidden},
+		{"read key cannot delete", []string{model.PermissionRead}, http.MethodDelete, "/api/v1/scans/scan-1", http.StatusForbidden},
+		{"write key writes", []string{model.PermissionWrite}, http.MethodPost, "/api/v1/scans", http.StatusOK},
+		{"write key reads", []string{model.PermissionWrite}, http.MethodGet, "/api/v1/scans", http.StatusOK},
+		{"write key cannot list API keys", []string{model.PermissionWrite}, http.MethodGet, "/api/v1/api-keys", http.StatusForbidden},
+		{"write key cannot create API keys", []string{model.PermissionWrite}, http.MethodPost, "/api/v1/api-keys", http.StatusForbidden},
+		{"admin key manages API keys", []string{model.PermissionAdmin}, http.MethodDelete, "/api/v1/api-keys/key-1", http.StatusOK},
+		{"admin key writes", []string{model.PermissionAdmin}, http.MethodPost, "/api/v1/scans", http.StatusOK},
+		{"key without permissions", nil, http.MethodGet, "/api/v1/scans", http.StatusForbidden},
+	}
+
+	handler := permissionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &model.APIKey{ID: "key-1", Permissions: tt.permissions}
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req = req.WithContext(context.WithValue(req.Context(), apiKeyKey, key))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestPermissionMiddlewareWithoutKey(t *testing.T) {
+	called := false
+	handler := permissionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/api-keys/key-1", nil))
+
+	if !called || rec.Code != http.StatusOK {
+		t.Errorf("request without a key = %d, called %v; want it passed through as authentication is disabled", rec.Code, called)
+	}
+}
+
+// fakeAPIKeyRepository stores API keys by hash in memory. Methods a test does
+// not use panic through the nil embedded interface
+type fakeAPIKeyRepository struct {
+	repository.APIKeyRepository
+	keys map[string]*model.APIKey
+}
+
+func (f *fakeAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	key, ok := f.keys[hash]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return key, nil
+}
+
+func (f *fakeAPIKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	f.keys[key.Key] = key
+	return nil
+}
+
+func (f *fakeAPIKeyRepository) Count(ctx context.Context) (int, error) {
+	return len(f.keys), nil
+}
+
+func TestAuthMiddlewareEnforcesStoredKeys(t *testing.T) {
+	// No API_KEY is configured, so only the stored key turns authentication on
+	apiKeyService := service.NewAPIKeyService(&fakeAPIKeyRepository{keys: map[string]*model.APIKey{}}, &config.Config{}, zap.NewNop())
+	handler := authMiddleware(apiKeyService, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(key string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/api-keys", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := request(""); code != http.StatusOK {
+		t.Fatalf("request without stored keys = %d, want %d", code, http.StatusOK)
+	}
+
+	created, err := apiKeyService.CreateAPIKey(context.Background(), model.CreateAPIKeyInput{Name: "ci", Permissions: []string{model.PermissionAdmin}})
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{"without a key", "", http.StatusUnauthorized},
+		{"with an unknown key", "not-a-key", http.StatusUnauthorized},
+		{"with the stored key", created.PlaintextKey, http.StatusOK},
+	} {
+		if code := request(tt.key); code != tt.wantStatus {
+			t.Errorf("request %s = %d, want %d", tt.name, code, tt.wantStatus)
+		}
+	}
+}
+
+func TestRequestSizeLimitMiddleware(t *testing.T) {
+	body := `{"target": "http://example.com"}`
+	limit := int64(len(body))
+	// the extra byte is inside the JSON, so the handler has to read it
+	over := `{"target": "http://example.com/"}`
+
+	tests := []struct {
+		name     string
+		body     string
+		streamed bool
+		want     int
+	}{
+		{name: "at the limit", body: body, want: http.StatusOK},
+		{name: "one byte over", body: over, want: http.StatusRequestEntityTooLarge},
+		{name: "streamed at the limit", body: body, streamed: true, want: http.StatusOK},
+		{name: "streamed one byte over", body: over, streamed: true, want: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scans := &fakeScanService{}
+			handler := requestSizeLimitMiddleware(limit)(newTestServer().handleStartScan(scans, nil, false))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/scans", strings.NewReader(tt.body))
+			if tt.streamed {
+				// no declared length, so only the body reader enforces the limit
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want == http.StatusRequestEntityTooLarge && len(scans.started) != 0 {
+				t.Errorf("started %d scans, want none for a rejected body", len(scans.started))
+			}
+		})
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowedOrigins []string
+		origin         string
+		wantOrigin     string
+		wantVary       bool
+	}{
+		{name: "allowed origin", allowedOrigins: []string{"https://app.example.com", "https://admin.example.com"}, origin: "https://admin.example.com", wantOrigin: "https://admin.example.com", wantVary: true},
+		{name: "unlisted origin", allowedOrigins: []string{"https://app.example.com"}, origin: "https://evil.example.com", wantOrigin: "", wantVary: true},
+		{name: "no origin", allowedOrigins: []string{"https://app.example.com"}, origin: "", wantOrigin: "", wantVary: true},
+		{name: "no allowlist", allowedOrigins: nil, origin: "https://evil.example.com", wantOrigin: "*"},
+	}
+	for _, tt := range tests {
+		for _, method := range []string{http.MethodGet, http.MethodOptions} {
+			t.Run(tt.name+" "+method, func(t *testing.T) {
+				reached := false
+				handler := corsMiddleware(tt.allowedOrigins)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					reached = true
+				}))
+
+				req := httptest.NewRequest(method, "/api/v1/scans", nil)
+				if tt.origin != "" {
+					req.Header.Set("Origin", tt.origin)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+
+				if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+					t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+				}
+				if got := rec.Header().Get("Vary") == "Origin"; got != tt.wantVary {
+					t.Errorf("Vary = %q, want Origin %v", rec.Header().Get("Vary"), tt.wantVary)
+				}
+				// preflight requests are answered without reaching the handler
+				if reached != (method != http.MethodOptions) {
+					t.Errorf("handler reached = %v for %s", reached, method)
+				}
+				if method == http.MethodOptions && rec.Code != http.StatusOK {
+					t.Errorf("preflight status = %d, want %d", rec.Code, http.StatusOK)
+				}
+			})
+		}
+	}
+}
+
+// fakeTemplateRepository serves templates from a map and records the
+// filters it was last listed with. Methods a test does not use panic through
+// the nil embedded interface
+type fakeTemplateRepository struct {
+	repository.TemplateRepository
+
+	templates map[string]*model.Template
+	filter    model.TemplateFilter
+}
+
+// Get returns the stored template
+func (f *fakeTemplateRepository) Get(ctx context.Context, id string) (*model.Template, error) {
+	template, ok := f.templates[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return template, nil
+}
+
+// Create stores the template by ID
+func (f *fakeTemplateRepository) Create(ctx context.Context, template *model.Template) error {
+	if f.templates == nil {
+		f.templates = make(map[string]*model.Template)
+	}
+	f.templates[template.ID] = template
+	return nil
+}
+
+// List records the filters and returns the stored templates, ignoring them
+// and the page
+func (f *fakeTemplateRepository) List(ctx context.Context, tags, author, severity, templateType *string, page model.Page) ([]*model.Template, error) {
+	f.filter = model.TemplateFilter{Tags: tags, Author: author, Severity: severity, Type: templateType}
+	templates := make([]*model.Template, 0, len(f.templates))
+	for _, template := range f.templates {
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+// Count returns the number of stored templates
+func (f *fakeTemplateRepository) Count(ctx context.Context, tags, author, severity, templateType *string) (int, error) {
+	return len(f.templates), nil
+}
+
+func TestHandleGetTemplateContent(t *testing.T) {
+	content := "id: cve-2021-1234\ninfo:\n  name: Test template\n  severity: high\n"
+	path := filepath.Join(t.TempDir(), "cve-2021-1234.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing template: %v", err)
+	}
+	repo := &fakeTemplateRepository{templates: map[string]*model.Template{
+		"cve-2021-1234": {ID: "cve-2021-1234", Path: path},
+		"stale":         {ID: "stale", Path: filepath.Join(t.TempDir(), "removed.yaml")},
+	}}
+	templates := service.NewTemplateService(repo, &config.Config{}, zap.NewNop())
+
+	tests := []struct {
+		name     string
+		id       string
+		wantCode int
+		wantBody string
+	}{
+		{name: "found", id: "cve-2021-1234", wantCode: http.StatusOK, wantBody: content},
+		{name: "file removed from disk", id: "stale", wantCode: http.StatusNotFound},
+		{name: "unknown template", id: "missing", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/templates/"+tt.id+"/content", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			rec := httptest.NewRecorder()
+			newTestServer().handleGetTemplateContent(templates).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/yaml" {
+				t.Errorf("Content-Type = %q, want application/yaml", got)
+			}
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want the template file %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandleGetScanResultsFilters(t *testing.T) {
+	scans := &fakeScanService{results: []*model.ScanResult{
+		{ID: "r1", TemplateID: "cve-2021-1234", Severity: "critical", Matched: true},
+		{ID: "r2", TemplateID: "exposed-panel", Severity: "medium", Matched: true},
+		{ID: "r3", TemplateID: "cve-2022-5678", Severity: "critical", Matched: true},
+		{ID: "r4", TemplateID: "tech-detect", Severity: "info", Matched: false},
+	}}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantCode   int
+		wantFilter model.ResultFilter
+		wantIDs    []string
+	}{
+		{name: "no filter", query: "", wantCode: http.StatusOK, wantIDs: []string{"r1", "r2", "r3", "r4"}},
+		{name: "critical", query: "severity=critical", wantCode: http.StatusOK,
+			wantFilter: model.ResultFilter{Severity: ptr("critical")}, wantIDs: []string{"r1", "r3"}},
+		{name: "unknown severity", query: "severity=catastrophic", wantCode: http.StatusOK,
+			wantFilter: model.ResultFilter{Severity: ptr("catastrophic")}, wantIDs: []string{}},
+		{name: "template and matched", query: "template_id=tech-detect&matched=false", wantCode: http.StatusOK,
+			wantFilter: model.ResultFilter{TemplateID: ptr("tech-detect"), Matched: ptr(false)}, wantIDs: []string{"r4"}},
+		{name: "invalid matched", query: "matched=maybe", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scans.filter = model.ResultFilter{}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/scans/scan-1/results?"+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "scan-1"})
+			rec := httptest.NewRecorder()
+			newTestServer().handleGetScanResults(scans).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			got, _ := json.Marshal(scans.filter)
+			want, _ := json.Marshal(tt.wantFilter)
+			if string(got) != string(want) {
+				t.Errorf("filter = %s, want %s", got, want)
+			}
+
+			var resp model.ListResponse[*model.ScanResult]
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if resp.Data == nil {
+				t.Fatal("data = null, want a list")
+			}
+			ids := []string{}
+			for _, result := range resp.Data {
+				ids = append(ids, result.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("results = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestHandleCompareScans(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		err        error
+		wantStatus int
+	}{
+		{name: "compared", query: "id1=scan-1&id2=scan-2", wantStatus: http.StatusOK},
+		{name: "scan missing", query: "id1=scan-1&id2=missing", err: repository.ErrNotFound, wantStatus: http.StatusNotFound},
+		{name: "second id omitted", query: "id1=scan-1", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scans := &fakeScanService{err: tt.err}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/scans/compare?"+tt.query, nil)
+			newTestServer().handleCompareScans(scans).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var comparison map[string][]model.ScanResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &comparison); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			for _, key := range []string{"new", "resolved", "common"} {
+				if _, ok := comparison[key]; !ok {
+					t.Errorf("response has no %q list: %s", key, rec.Body.String())
+				}
+			}
+			if len(comparison["common"]) != 1 || comparison["common"][0].ID != "result-1" {
+				t.Errorf("common = %+v, want result-1", comparison["common"])
+			}
+		})
+	}
+}
+
+func TestHandleListScansByTarget(t *testing.T) {
+	scans := &fakeScanService{scans: []model.Scan{
+		{ID: "app-1", Target: "https://a.example.com/app", Targets: []string{"https://a.example.com/app"}},
+		{ID: "app-2", Target: "https://a.example.com/app", Targets: []string{"https://a.example.com/app"}},
+		{ID: "root", Target: "https://a.example.com", Targets: []string{"https://a.example.com"}},
+		{ID: "other", Target: "https://b.example.com", Targets: []string{"https://b.example.com"}},
+	}}
+	router := mux.NewRouter().UseEncodedPath()
+	router.Use(unescapePathVarsMiddleware())
+	router.HandleFunc("/api/v1/targets/{target}/scans", newTestServer().handleListScansByTarget(scans)).Methods(http.MethodGet)
+
+	tests := []struct {
+		name    string
+		target  string
+		wantIDs []string
+	}{
+		{name: "target with a path", target: "https://a.example.com/app", wantIDs: []string{"app-1", "app-2"}},
+		{name: "host only", target: "https://a.example.com", wantIDs: []string{"root"}},
+		{name: "other host", target: "https://b.example.com", wantIDs: []string{"other"}},
+		{name: "no scans", target: "https://c.example.com", wantIDs: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			path := "/api/v1/targets/" + url.PathEscape(tt.target) + "/scans"
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d, want 200 (%s)", path, rec.Code, rec.Body.String())
+			}
+			var resp model.ListResponse[model.Scan]
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			ids := []string{}
+			for _, scan := range resp.Data {
+				ids = append(ids, scan.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("GET %s scans = %v, want %v", path, ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddlewareLevels(t *testing.T) {
+	silentPaths := []string{"/health", "/metrics"}
+
+	tests := []struct {
+		name      string
+		path      string
+		wantLevel zapcore.Level
+	}{
+		{name: "api request", path: "/api/v1/scans", wantLevel: zap.InfoLevel},
+		{name: "health check", path: "/health", wantLevel: zap.DebugLevel},
+		{name: "prefix match", path: "/metrics/prometheus", wantLevel: zap.DebugLevel},
+		{name: "silent path inside another", path: "/api/v1/health", wantLevel: zap.InfoLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.DebugLevel)
+			handler := loggingMiddleware(zap.New(core), silentPaths, false, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			entries := logs.FilterMessage("HTTP request").All()
+			if len(entries) != 1 {
+				t.Fatalf("logged %d requests, want 1", len(entries))
+			}
+			if entries[0].Level != tt.wantLevel {
+				t.Errorf("GET %s logged at %v, want %v", tt.path, entries[0].Level, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddlewareRequestBodies(t *testing.T) {
+	const maxBytes = 1 << 10
+	body := strings.Repeat("a", maxBytes+100)
+
+	tests := []struct {
+		name      string
+		logBodies bool
+		wantBody  string
+	}{
+		{name: "disabled", logBodies: false, wantBody: ""},
+		{name: "enabled and capped", logBodies: true, wantBody: body[:maxBytes]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.DebugLevel)
+			var read string
+			handler := loggingMiddleware(zap.New(core), nil, tt.logBodies, maxBytes)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				read = string(data)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/scans", strings.NewReader(body)))
+
+			if read != body {
+				t.Errorf("handler read %d bytes, want the full %d byte body", len(read), len(body))
+			}
+			entries := logs.FilterMessage("HTTP request").All()
+			if len(entries) != 1 {
+				t.Fatalf("logged %d requests, want 1", len(entries))
+			}
+			logged, _ := entries[0].ContextMap()["body"].(string)
+			if logged != tt.wantBody {
+				t.Errorf("logged a %d byte body, want %d bytes", len(logged), len(tt.wantBody))
+			}
+		})
+	}
+}
+
+func TestDebugRequestBodyMiddleware(t *testing.T) {
+	const maxBytes = 16
+	tests := []struct {
+		name     string
+		body     string
+		wantLog  bool
+		wantBody string
+	}{
+		{name: "short body", body: `{"target": "x"}`, wantLog: true, wantBody: `{"target": "x"}`},
+		{name: "long body is capped", body: `{"target": "http://example.com"}`, wantLog: true, wantBody: `{"target": "http`},
+		{name: "no body", body: "", wantLog: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.DebugLevel)
+			var read string
+			handler := debugRequestBodyMiddleware(zap.New(core), maxBytes)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				read = string(data)
+			}))
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/scans", body))
+
+			if read != tt.body {
+				t.Errorf("handler read %q, want the full body %q", read, tt.body)
+			}
+			entries := logs.FilterMessage("HTTP request body").All()
+			if !tt.wantLog {
+				if len(entries) != 0 {
+					t.Errorf("logged %d bodies, want none", len(entries))
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("logged %d bodies, want 1", len(entries))
+			}
+			if entries[0].Level != zapcore.DebugLevel {
+				t.Errorf("logged at %v, want debug", entries[0].Level)
+			}
+			if logged, _ := entries[0].ContextMap()["body"].(string); logged != tt.wantBody {
+				t.Errorf("logged body %q, want %q", logged, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandleListScansTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "two tags", query: "?tags=prod,web", want: []string{"prod", "web"}},
+		{name: "spaces and empty entries", query: "?tags=prod,%20web%20,,", want: []string{"prod", "web"}},
+		{name: "no tags", query: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scans := &fakeScanService{}
+			rec := httptest.NewRecorder()
+			newTestServer().handleListScans(scans).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scans"+tt.query, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 (%s)", rec.Code, rec.Body.String())
+			}
+			if !slices.Equal(scans.listTags, tt.want) {
+				t.Errorf("tags = %q, want %q", scans.listTags, tt.want)
+			}
+		})
+	}
+}
+
+func TestListEndpointsEnvelope(t *testing.T) {
+	scans := &fakeScanService{
+		scans:   []model.Scan{{ID: "scan-1", Target: "http://a.example.com", Targets: []string{"http://a.example.com"}}},
+		results: []*model.ScanResult{{ID: "result-1", ScanID: "scan-1", TemplateID: "tech-detect"}},
+	}
+	templates := service.NewTemplateService(&fakeTemplateRepository{templates: map[string]*model.Template{
+		"tech-detect": {ID: "tech-detect"},
+	}}, &config.Config{}, zap.NewNop())
+
+	tests := []struct {
+		name    string
+		path    string
+		handler http.HandlerFunc
+	}{
+		{name: "scans", path: "/api/v1/scans?limit=10&offset=0", handler: newTestServer().handleListScans(scans)},
+		{name: "templates", path: "/api/v1/templates?limit=10&offset=0", handler: newTestServer().handleListTemplates(templates)},
+		{name: "results", path: "/api/v1/scans/scan-1/results?limit=10&offset=0", handler: newTestServer().handleGetScanResults(scans)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, tt.path, nil), map[string]string{"id": "scan-1"})
+			tt.handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, http.StatusOK, rec.Body.String())
+			}
+
+			var body map[string]interface{}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if data, ok := body["data"].([]interface{}); !ok || len(data) != 1 {
+				t.Errorf("data = %v, want an array of one item", body["data"])
+			}
+			meta, ok := body["meta"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("meta = %v, want an object", body["meta"])
+			}
+			for key, want := range map[string]float64{"total": 1, "limit": 10, "offset": 0} {
+				if got, ok := meta[key].(float64); !ok || got != want {
+					t.Errorf("meta.%s = %v, want the number %v", key, meta[key], want)
+				}
+			}
+		})
+	}
+}
+
+// importRequest returns a multipart import request with archive in the named
+// file field
+func importRequest(t *testing.T, field string, archive []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile(field, "templates.zip")
+	if err != nil {
+		t.Fatalf("CreateFormFile() error = %v", err)
+	}
+	part.Write(archive)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/templates/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestHandleImportTemplates(t *testing.T) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"http/imported.yaml": "id: imported\ninfo:\n  name: Imported\n  severity: info\n",
+		"http/broken.yaml":   "id: [unclosed\n",
+	} {
+		f, _ := archive.Create(name)
+		f.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("zip Close() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		field     string
+		maxBytes  int64
+		wantCode  int
+		wantStore bool
+	}{
+		{name: "archive", field: "zip", maxBytes: 1 << 20, wantCode: http.StatusOK, wantStore: true},
+		{name: "missing zip field", field: "file", maxBytes: 1 << 20, wantCode: http.StatusBadRequest},
+		{name: "archive too large", field: "zip", maxBytes: 64, wantCode: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Nuclei.UploadDir = t.TempDir()
+			cfg.Nuclei.MaxTemplateSize = 1 << 20
+			cfg.Nuclei.MaxImportBytes = tt.maxBytes
+			repo := &fakeTemplateRepository{}
+			srv := &Server{cfg: cfg, logger: zap.NewNop()}
+
+			rec := httptest.NewRecorder()
+			srv.handleImportTemplates(service.NewTemplateService(repo, cfg, zap.NewNop())).ServeHTTP(rec, importRequest(t, tt.field, buf.Bytes()))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if !tt.wantStore {
+				if len(repo.templates) != 0 {
+					t.Errorf("stored %d templates, want none", len(repo.templates))
+				}
+				return
+			}
+
+			var result model.ImportResult
+			if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if result.Imported != 1 || result.Failed != 1 || len(result.Errors) != 1 {
+				t.Errorf("response = %+v, want 1 imported and 1 failed", result)
+			}
+			if _, ok := repo.templates["imported"]; !ok {
+				t.Error("template imported was not stored")
+			}
+		})
+	}
+}
+
+func TestHandleExportTemplates(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cve-2021-1234": "id: cve-2021-1234\ninfo:\n  name: CVE\n  severity: critical\n",
+		"tech-detect":   "id: tech-detect\ninfo:\n  name: Tech\n  severity: info\n",
+	}
+	repo := &fakeTemplateRepository{templates: map[string]*model.Template{
+		"stale": {ID: "stale", Path: filepath.Join(dir, "removed.yaml")},
+	}}
+	for id, content := range files {
+		path := filepath.Join(dir, id+".yaml")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("writing template: %v", err)
+		}
+		repo.templates[id] = &model.Template{ID: id, Path: path}
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/templates/export?severity=critical&tags=cve", nil)
+	newTestServer().handleExportTemplates(service.NewTemplateService(repo, &config.Config{}, zap.NewNop())).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d (%s)", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="templates.zip"` {
+		t.Errorf("Content-Disposition = %q, want the templates.zip attachment", got)
+	}
+	if repo.filter.Severity == nil || *repo.filter.Severity != "critical" || repo.filter.Tags == nil || *repo.filter.Tags != "cve" {
+		t.Errorf("listed with filter %+v, want severity critical and tags cve", repo.filter)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("response is not a ZIP archive: %v", err)
+	}
+	got := map[string]string{}
+	for _, file := range archive.File {
+		f, err := file.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(f)
+		f.Close()
+		got[file.Name] = string(data)
+	}
+	// The template whose file is gone is skipped
+	want := map[string]string{}
+	for id, content := range files {
+		want[id+".yaml"] = content
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("archive holds %v, want %v", slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(want)))
+	}
+}
+
+func TestHandleWorkerPauseAndResume(t *testing.T) {
+	srv := newTestServer()
+	srv.worker = service.NewScanWorker(nil, nil, &config.Config{}, zap.NewNop())
+
+	steps := []struct {
+		name       string
+		method     string
+		handler    http.HandlerFunc
+		wantPaused bool
+	}{
+		{name: "status", method: http.MethodGet, handler: srv.handleGetWorkerStatus(), wantPaused: false},
+		{name: "pause", method: http.MethodPost, handler: srv.handlePauseWorker(), wantPaused: true},
+		{name: "status while paused", method: http.MethodGet, handler: srv.handleGetWorkerStatus(), wantPaused: true},
+		{name: "resume", method: http.MethodPost, handler: srv.handleResumeWorker(), wantPaused: false},
+	}
+	for _, step := range steps {
+		rec := httptest.NewRecorder()
+		step.handler.ServeHTTP(rec, httptest.NewRequest(step.method, "/api/v1/worker", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d", step.name, rec.Code, http.StatusOK)
+		}
+
+		var status map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+			t.Fatalf("%s: decoding response: %v", step.name, err)
+		}
+		if paused, ok := status["paused"].(bool); !ok || paused != step.wantPaused {
+			t.Errorf("%s: paused = %v, want %v", step.name, status["paused"], step.wantPaused)
+		}
+		if _, ok := status["active_scans"].(float64); !ok {
+			t.Errorf("%s: active_scans = %v, want a number", step.name, status["active_scans"])
+		}
+		if _, ok := status["last_run"]; !ok {
+			t.Errorf("%s: response has no last_run", step.name)
+		}
+	}
+}
+
+func TestHandleValidateTemplate(t *testing.T) {
+	templates := service.NewTemplateService(&fakeTemplateRepository{}, &config.Config{}, zap.NewNop())
+	tests := []struct {
+		name      string
+		body      string
+		maxSize   int64
+		wantCode  int
+		wantValid bool
+	}{
+		{name: "valid", body: "id: valid\ninfo:\n  name: Valid\n  author: tester\n  severity: info\ndns:\n  - name: \"{{FQDN}}\"\n",
+			maxSize: 1 << 20, wantCode: http.StatusOK, wantValid: true},
+		{name: "invalid", body: "id: invalid\n", maxSize: 1 << 20, wantCode: http.StatusOK, wantValid: false},
+		{name: "too large", body: "id: valid\n", maxSize: 4, wantCode: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer()
+			srv.cfg.Nuclei.MaxTemplateSize = tt.maxSize
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/templates/validate", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/yaml")
+			srv.handleValidateTemplate(templates).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var result model.TemplateValidation
+			if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if result.Valid != tt.wantValid || (len(result.Errors) == 0) != tt.wantValid {
+				t.Errorf("response = %+v, want valid %v", result, tt.wantValid)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/service"
+)
+
+func TestServerSelfSignedTLS(t *testing.T) {
+	// Reserve a free port for the server to listen on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	cfg := &config.Config{}
+	cfg.Server.Port = port
+	srv := &Server{
+		cfg:    cfg,
+		logger: zap.NewNop(),
+		http: &http.Server{
+			Addr: fmt.Sprintf("127.0.0.1:%d", port),
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "ok")
+			}),
+		},
+		refreshScheduler: service.NewTemplateRefreshScheduler(nil, cfg, zap.NewNop()),
+	}
+	srv.schedulerCtx, srv.stopScheduler = context.WithCancel(context.Background())
+
+	certFile, keyFile, err := srv.generateSelfSignedCert()
+	if err != nil {
+		t.Fatalf("generateSelfSignedCert() error = %v", err)
+	}
+	srv.tlsCertFile, srv.tlsKeyFile = certFile, keyFile
+	srv.selfSignedDir = filepath.Dir(certFile)
+
+	// Trust only the generated certificate
+	pem, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatalf("reading certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		t.Fatal("generated certificate is not valid PEM")
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	started := make(chan error, 1)
+	go func() { started <- srv.Start() }()
+
+	addr := fmt.Sprintf("https://127.0.0.1:%d/", port)
+	deadline := time.Now().Add(5 * time.Second)
+	var resp *http.Response
+	for {
+		resp, err = client.Get(addr)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not accept TLS connections: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.TLS == nil || string(body) != "ok" {
+		t.Errorf("response over TLS %v = %q, want ok", resp.TLS != nil, body)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	select {
+	case err := <-started:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("Start() error = %v, want %v", err, http.ErrServerClosed)
+		}
+	case <-ctx.Done():
+		t.Fatal("Start() did not return within the shutdown timeout")
+	}
+	if _, err := os.Stat(srv.selfSignedDir); !os.IsNotExist(err) {
+		t.Errorf("certificate directory still exists after Shutdown: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// apiKeyBytes is the number of random bytes in a generated API key
+const apiKeyBytes = 32
+
+// apiKeyService implements the APIKeyService interface
+type apiKeyService struct {
+	repo   repository.APIKeyRepository
+	cfg    *config.Config
+	logger *zap.Logger
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(repo repository.APIKeyRepository, cfg *config.Config, logger *zap.Logger) APIKeyService {
+	return &apiKeyService{
+		repo:   repo,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// ListAPIKeys returns all API keys stored in the database
+func (s *apiKeyService) ListAPIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	s.logger.Info("Listing API keys")
+
+	keys, err := s.repo.List(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list API keys from repository", zap.Error(err))
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// CreateAPIKey validates the input, generates a key and stores its hash
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, input model.CreateAPIKeyInput) (*model.CreatedAPIKey, error) {
+	s.logger.Info("Creating API key",
+		zap.String("name", input.Name),
+		zap.Strings("permissions", input.Permissions))
+
+	// Validate input
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidAPIKey)
+	}
+	if len(input.Permissions) == 0 {
+		return nil, fmt.Errorf("%w: at least one permission is required", ErrInvalidAPIKey)
+	}
+	for _, p := range input.Permissions {
+		switch p {
+		case model.PermissionRead, model.PermissionWrite, model.PermissionAdmin:
+		default:
+			return nil, fmt.Errorf("%w: unknown permission %q", ErrInvalidAPIKey, p)
+		}
+	}
+	now := time.Now()
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKey)
+	}
+
+	// Generate key
+	raw := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(raw); err != nil {
+		s.logger.Error("Failed to generate API key", zap.Error(err))
+		return nil, err
+	}
+	plaintext := hex.EncodeToString(raw)
+
+	// Create key
+	key := &model.APIKey{
+		ID:          uuid.New().String(),
+		Key:         hashAPIKey(plaintext),
+		Name:        name,
+		Permissions: input.Permissions,
+		CreatedAt:   now,
+		ExpiresAt:   input.ExpiresAt,
+	}
+
+	// Save key
+	if err := s.repo.Create(ctx, key); err != nil {
+		s.logger.Error("Failed to create API key in repository", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Created API key in repository", zap.String("id", key.ID))
+	return &model.CreatedAPIKey{APIKey: key, PlaintextKey: plaintext}, nil
+}
+
+// DeleteAPIKey deletes an API key by ID
+func (s *apiKeyService) DeleteAPIKey(ctx context.Context, id string) error {
+	s.logger.Info("Deleting API key", zap.String("id", id))
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		s.logger.Error("Failed to delete API key from repository", zap.Error(err), zap.String("id", id))
+		return err
+	}
+
+	return nil
+}
+
+// Authenticate returns the API key matching the plaintext key. The API_KEY
+// from the configuration is accepted as an admin key
+func (s *apiKeyService) Authenticate(ctx context.Context, plaintext string) (*model.APIKey, error) {
+	if plaintext == "" {
+		return nil, ErrUnauthorized
+	}
+
+	// Configured key
+	if s.cfg.Server.APIKey != "" &&
+		subtle.ConstantTimeCompare([]byte(plaintext), []byte(s.cfg.Server.APIKey)) == 1 {
+		return &model.APIKey{
+			ID:          "config",
+			Name:        "API_KEY",
+			Permissions: []string{model.PermissionAdmin},
+		}, nil
+	}
+
+	// Stored keys
+	key, err := s.repo.GetByHash(ctx, hashAPIKey(plaintext))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrUnauthorized
+		}
+		s.logger.Error("Failed to look up API key", zap.Error(err))
+		return nil, err
+	}
+	if key.Expired(time.Now()) {
+		s.logger.Warn("Rejected expired API key", zap.String("id", key.ID))
+		return nil, ErrUnauthorized
+	}
+
+	return key, nil
+}
+
+// AuthRequired reports whether requests must carry an API key. Stored keys
+// turn authentication on without API_KEY, so a key minted while the API is
+// open locks it from then on
+func (s *apiKeyService) AuthRequired(ctx context.Context) (bool, error) {
+	if s.cfg.Server.APIKey != "" {
+		return true, nil
+	}
+
+	count, err := s.repo.Count(ctx)
+	if err != nil {
+		s.logger.Error("Failed to count API keys", zap.Error(err))
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// hashAPIKey returns the hex-encoded SHA-256 hash of a plaintext key
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
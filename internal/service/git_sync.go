@@ -0,0 +1,118 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"nuclei-service-demo/internal/config"
+)
+
+// gitAskPassScript answers git's credential prompts from the environment so
+// the token never appears in a command line or in .git/config
+const gitAskPassScript = `#!/bin/sh
+case "$1" in
+Username*) echo "x-access-token" ;;
+*) echo "$NUCLEI_GIT_TEMPLATES_TOKEN" ;;
+esac
+`
+
+// gitSync brings the templates directory up to date with the configured git
+// repository. A missing directory is cloned; an existing directory is fetched
+// into (initialising a repository first if needed) and reset to the remote
+// branch, leaving untracked files such as uploaded templates in place.
+func gitSync(ctx context.Context, cfg *config.Config) error {
+	dir := cfg.Nuclei.TemplatesDir
+	branch := cfg.Nuclei.GitTemplatesBranch
+
+	env, cleanup, err := gitEnv(cfg.Nuclei.GitTemplatesToken)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Clone when there is nothing to update yet
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		args := []string{"clone", "--depth", "1"}
+		if branch != "" {
+			args = append(args, "--branch", branch)
+		}
+		args = append(args, cfg.Nuclei.GitTemplatesURL, dir)
+		return runGit(ctx, "", env, args...)
+	} else if err != nil {
+		return fmt.Errorf("failed to check templates directory: %w", err)
+	}
+
+	// Point the directory's repository at the configured URL
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := runGit(ctx, dir, env, "init"); err != nil {
+			return err
+		}
+		if err := runGit(ctx, dir, env, "remote", "add", "origin", cfg.Nuclei.GitTemplatesURL); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to check templates directory: %w", err)
+	} else if err := runGit(ctx, dir, env, "remote", "set-url", "origin", cfg.Nuclei.GitTemplatesURL); err != nil {
+		return err
+	}
+
+	// Update to the remote branch
+	ref := branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if err := runGit(ctx, dir, env, "fetch", "--depth", "1", "origin", ref); err != nil {
+		return err
+	}
+	return runGit(ctx, dir, env, "reset", "--hard", "FETCH_HEAD")
+}
+
+// gitEnv returns the environment for git commands, with an askpass helper
+// when a token is set. cleanup removes the helper.
+func gitEnv(token string) ([]string, func(), error) {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if token == "" {
+		return env, func() {}, nil
+	}
+
+	file, err := os.CreateTemp("", "nuclei-git-askpass-*.sh")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create git askpass helper: %w", err)
+	}
+	cleanup := func() { os.Remove(file.Name()) }
+	if _, err := file.WriteString(gitAskPassScript); err != nil {
+		file.Close()
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to write git askpass helper: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to write git askpass helper: %w", err)
+	}
+	if err := os.Chmod(file.Name(), 0o700); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to make git askpass helper executable: %w", err)
+	}
+
+	env = append(env, "GIT_ASKPASS="+file.Name(), "NUCLEI_GIT_TEMPLATES_TOKEN="+token)
+	return env, cleanup, nil
+}
+
+// runGit runs a git command in dir, including its output in the error
+func runGit(ctx context.Context, dir string, env []string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nuclei-service-demo/internal/config"
+)
+
+// testGitRepo is a bare repository served over file:// and a work tree
+// that pushes to it
+type testGitRepo struct {
+	t    *testing.T
+	url  string
+	work string
+	env  []string
+}
+
+// newTestGitRepo creates a bare repository whose branch holds one commit of
+// files
+func newTestGitRepo(t *testing.T, branch string, files map[string]string) *testGitRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	repo := &testGitRepo{
+		t:    t,
+		url:  "file://" + filepath.Join(root, "templates.git"),
+		work: filepath.Join(root, "work"),
+		env: append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_CONFIG_GLOBAL=/dev/null"),
+	}
+	repo.git("", "init", "--bare", "--initial-branch", branch, filepath.Join(root, "templates.git"))
+	repo.git("", "init", "--initial-branch", branch, repo.work)
+	repo.git(repo.work, "remote", "add", "origin", repo.url)
+	repo.commit(files)
+	return repo
+}
+
+func (r *testGitRepo) git(dir string, args ...string) {
+	r.t.Helper()
+	if err := runGit(context.Background(), dir, r.env, args...); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// commit writes files to the work tree, commits them and pushes the branch
+func (r *testGitRepo) commit(files map[string]string) {
+	r.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(r.work, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			r.t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	r.git(r.work, "add", "-A")
+	r.git(r.work, "commit", "-m", "update templates")
+	r.git(r.work, "push", "origin", "HEAD")
+}
+
+// readTemplate returns the content of name in dir, or "" if it is missing
+func readTemplate(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		t.Fatalf("ReadFile(%s) error = %v", name, err)
+	}
+	return string(data)
+}
+
+func TestGitSyncClonesAndUpdates(t *testing.T) {
+	repo := newTestGitRepo(t, "release", map[string]string{"http/a.yaml": "id: a\n"})
+
+	cfg := &config.Config{}
+	cfg.Nuclei.TemplatesDir = filepath.Join(t.TempDir(), "templates")
+	cfg.Nuclei.GitTemplatesURL = repo.url
+	cfg.Nuclei.GitTemplatesBranch = "release"
+
+	if err := gitSync(context.Background(), cfg); err != nil {
+		t.Fatalf("first gitSync() error = %v", err)
+	}
+	if got := readTemplate(t, cfg.Nuclei.TemplatesDir, "http/a.yaml"); got != "id: a\n" {
+		t.Fatalf("cloned http/a.yaml = %q, want the committed template", got)
+	}
+
+	// An uploaded template is untracked and survives the update
+	uploaded := filepath.Join(cfg.Nuclei.TemplatesDir, "uploaded.yaml")
+	if err := os.WriteFile(uploaded, []byte("id: uploaded\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	repo.commit(map[string]string{"http/a.yaml": "id: a\n# v2\n", "http/b.yaml": "id: b\n"})
+
+	if err := gitSync(context.Background(), cfg); err != nil {
+		t.Fatalf("second gitSync() error = %v", err)
+	}
+	for name, want := range map[string]string{
+		"http/a.yaml":   "id: a\n# v2\n",
+		"http/b.yaml":   "id: b\n",
+		"uploaded.yaml": "id: uploaded\n",
+	} {
+		if got := readTemplate(t, cfg.Nuclei.TemplatesDir, name); got != want {
+			t.Errorf("after update %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGitSyncInitialisesExistingDirectory(t *testing.T) {
+	repo := newTestGitRepo(t, "main", map[string]string{"http/a.yaml": "id: a\n"})
+
+	// Templates baked into an image are a plain directory
+	cfg := &config.Config{}
+	cfg.Nuclei.TemplatesDir = t.TempDir()
+	cfg.Nuclei.GitTemplatesURL = repo.url
+	if err := os.WriteFile(filepath.Join(cfg.Nuclei.TemplatesDir, "http-a.yaml"), []byte("id: old\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := gitSync(context.Background(), cfg); err != nil {
+		t.Fatalf("gitSync() error = %v", err)
+	}
+	if got := readTemplate(t, cfg.Nuclei.TemplatesDir, "http/a.yaml"); got != "id: a\n" {
+		t.Errorf("http/a.yaml = %q, want the remote template", got)
+	}
+	if got := readTemplate(t, cfg.Nuclei.TemplatesDir, "http-a.yaml"); got != "id: old\n" {
+		t.Errorf("http-a.yaml = %q, want the existing file kept", got)
+	}
+}
+
+func TestGitSyncUnknownBranch(t *testing.T) {
+	repo := newTestGitRepo(t, "main", map[string]string{"http/a.yaml": "id: a\n"})
+
+	cfg := &config.Config{}
+	cfg.Nuclei.TemplatesDir = filepath.Join(t.TempDir(), "templates")
+	cfg.Nuclei.GitTemplatesURL = repo.url
+	cfg.Nuclei.GitTemplatesBranch = "missing"
+
+	err := gitSync(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "git clone failed") {
+		t.Errorf("gitSync() error = %v, want git clone failed", err)
+	}
+}
+
+func TestGitEnvAskPass(t *testing.T) {
+	env, cleanup, err := gitEnv("s3cret")
+	if err != nil {
+		t.Fatalf("gitEnv() error = %v", err)
+	}
+
+	var askPass string
+	for _, v := range env {
+		if path, ok := strings.CutPrefix(v, "GIT_ASKPASS="); ok {
+			askPass = path
+		}
+	}
+	if askPass == "" {
+		t.Fatal("gitEnv() set no GIT_ASKPASS helper")
+	}
+
+	for prompt, want := range map[string]string{
+		"Username for 'https://github.com': ": "x-access-token",
+		"Password for 'https://github.com': ": "s3cret",
+	} {
+		cmd := exec.Command(askPass, prompt)
+		cmd.Env = env
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("askpass helper error = %v", err)
+		}
+		if got := strings.TrimSpace(string(out)); got != want {
+			t.Errorf("askpass(%q) = %q, want %q", prompt, got, want)
+		}
+	}
+
+	cleanup()
+	if _, err := os.Stat(askPass); !os.IsNotExist(err) {
+		t.Errorf("askpass helper still exists after cleanup: %v", err)
+	}
+}
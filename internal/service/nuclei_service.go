@@ -2,22 +2,37 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	nucleiLib "github.com/projectdiscovery/nuclei/v3/lib"
 	"github.com/projectdiscovery/nuclei/v3/pkg/output"
+	"github.com/projectdiscovery/nuclei/v3/pkg/templates"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 
 	"nuclei-service-demo/internal/config"
 	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/telemetry"
 )
 
+// tracer starts the spans wrapping nuclei engine calls and scan processing
+var tracer = telemetry.Tracer("nuclei-service-demo/internal/service")
+
 // NucleiServiceInterface defines the interface for nuclei operations
 type NucleiServiceInterface interface {
-	StartScan(ctx context.Context, scan *model.Scan) ([]*model.ScanResult, error)
+	// StartScan runs the scan, passing each result to onResult as it is
+	// found. An error from onResult stops the scan and is returned
+	StartScan(ctx context.Context, scan *model.Scan, onResult func(*model.ScanResult) error) error
 	CancelScan(ctx context.Context, scanID string) error
 }
 
@@ -27,51 +42,109 @@ type nucleiService struct {
 	logger  *zap.Logger
 	mu      sync.Mutex
 	cancels map[string]context.CancelFunc
+
+	// newEngine creates the nuclei engine for a scan
+	newEngine func(ctx context.Context, options ...nucleiLib.NucleiSDKOptions) (nucleiEngine, error)
+}
+
+// nucleiEngine is the part of *nucleiLib.NucleiEngine a scan uses
+type nucleiEngine interface {
+	LoadAllTemplates() error
+	LoadTargets(targets []string, probeNonHttp bool)
+	GetTemplates() []*templates.Template
+	ExecuteCallbackWithCtx(ctx context.Context, callback ...func(event *output.ResultEvent)) error
+	Close()
+}
+
+// newNucleiEngine creates a nuclei engine with the given options
+func newNucleiEngine(ctx context.Context, options ...nucleiLib.NucleiSDKOptions) (nucleiEngine, error) {
+	engine, err := nucleiLib.NewNucleiEngineCtx(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	return engine, nil
 }
 
 // NewNucleiService creates a new nuclei service
 func NewNucleiService(cfg *config.Config, logger *zap.Logger) NucleiServiceInterface {
 	return &nucleiService{
-		cfg:     cfg,
-		logger:  logger,
-		cancels: make(map[string]context.CancelFunc),
+		cfg:       cfg,
+		logger:    logger,
+		cancels:   make(map[string]context.CancelFunc),
+		newEngine: newNucleiEngine,
 	}
 }
 
-// StartScan starts a new nuclei scan using the nuclei library
-func (s *nucleiService) StartScan(ctx context.Context, scan *model.Scan) ([]*model.ScanResult, error) {
-	// Create cancellable context and store cancel function
+// StartScan starts a new nuclei scan using the nuclei library, streaming
+// results to onResult instead of collecting them
+func (s *nucleiService) StartScan(ctx context.Context, scan *model.Scan, onResult func(*model.ScanResult) error) error {
+	logger := telemetry.Logger(ctx, s.logger)
+
+	// Resolve workflows before anything is registered for the scan
+	sources, err := s.templateSources(scan)
+	if err != nil {
+		logger.Error("Failed to resolve scan workflows", zap.Error(err), zap.String("scan_id", scan.ID))
+		return err
+	}
+
+	// Create cancellable context and store cancel function, released on every
+	// return
 	scanCtx, cancel := context.WithCancel(ctx)
 	s.mu.Lock()
 	s.cancels[scan.ID] = cancel
 	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.cancels, scan.ID)
+		s.mu.Unlock()
+		cancel()
+	}()
 
-	s.logger.Info("Starting nuclei scan",
+	logger.Info("Starting nuclei scan",
 		zap.String("scan_id", scan.ID),
-		zap.String("target", scan.Target),
+		zap.Strings("targets", scan.Targets),
 		zap.Strings("template_ids", scan.TemplateIDs),
+		zap.Strings("workflow_ids", scan.WorkflowIDs),
+		zap.Strings("tags", scan.Tags),
 	)
 
 	// Build SDK options
 	opts := []nucleiLib.NucleiSDKOptions{
-		// filter by severity
+		// filter by template IDs, tags and severity
 		nucleiLib.WithTemplateFilters(nucleiLib.TemplateFilters{
 			IDs:      scan.TemplateIDs,
+			Tags:     scan.Tags,
 			Severity: "critical,high,medium,low,info",
 		}),
-		// load templates from directory
-		nucleiLib.WithTemplatesOrWorkflows(nucleiLib.TemplateSources{
-			Templates: []string{s.cfg.Nuclei.TemplatesDir},
-		}),
+		// load templates from directory and any selected workflows
+		nucleiLib.WithTemplatesOrWorkflows(sources),
 		// disable update checks
 		nucleiLib.DisableUpdateCheck(),
+		// concurrency
+		nucleiLib.WithConcurrency(buildConcurrencyOpts(scan.Options, s.cfg.Nuclei.Concurrency)),
+		// redirects
+		withFollowRedirects(followRedirects(scan.Options, s.cfg.Nuclei.FollowRedirects)),
+	}
+
+	// add proxy
+	proxy := s.cfg.Nuclei.Proxy
+	if scan.Options != nil && scan.Options.Proxy != "" {
+		proxy = scan.Options.Proxy
+	}
+	if proxy != "" {
+		opts = append(opts, nucleiLib.WithProxy([]string{proxy}, false))
+	}
+
+	// add DNS resolvers
+	resolvers := s.cfg.Nuclei.DNSResolvers
+	if scan.Options != nil && len(scan.Options.DNSResolvers) > 0 {
+		resolvers = scan.Options.DNSResolvers
+	}
+	if len(resolvers) > 0 {
+		opts = append(opts, withResolvers(resolvers))
 	}
 
-	// add concurrency
 	if scan.Options != nil {
-		// if scan.Options.Concurrency > 0 {
-		// 	opts = append(opts, nucleiLib.WithConcurrency(nucleiLib.Concurrency(scan.Options.Concurrency)))
-		// }
 		// rate limit
 		if scan.Options.RateLimit > 0 {
 			opts = append(opts, nucleiLib.WithGlobalRateLimitCtx(scanCtx, scan.Options.RateLimit, time.Second))
@@ -81,42 +154,102 @@ func (s *nucleiService) StartScan(ctx context.Context, scan *model.Scan) ([]*mod
 			hopts := nucleiLib.HeadlessOpts{}
 			opts = append(opts, nucleiLib.EnableHeadlessWithOpts(&hopts))
 		}
+		// nuclei's HTTP client never verifies certificates and the SDK has no
+		// option to change that, so the flag is only recorded and logged
+		if scan.Options.TLSSkipVerify != nil && *scan.Options.TLSSkipVerify {
+			logger.Warn("Scanning with TLS certificate verification disabled",
+				zap.String("scan_id", scan.ID),
+				zap.Strings("targets", scan.Targets),
+			)
+		}
+		// custom headers and cookies
+		if headers := formatHeaders(scan.Options.CustomHeaders, scan.Options.CustomCookies); len(headers) > 0 {
+			opts = append(opts, nucleiLib.WithHeaders(headers))
+		}
+	}
+
+	// capture verbose output into the scan's log; a dry run sends no
+	// requests, so there is nothing to capture
+	if scan.Options != nil && scan.Options.Verbose && !scan.Options.DryRun {
+		capture := &verboseLog{}
+		defer func() { scan.VerboseLog = capture.String() }()
+		defer verboseLogs.capture(capture)()
+		opts = append(opts, nucleiLib.WithVerbosity(nucleiLib.VerbosityOptions{Verbose: true}))
+	}
+
+	// passive mode matches stored responses instead of the targets
+	inputs := scan.Targets
+	if s.cfg.Nuclei.Passive || (scan.Options != nil && scan.Options.Passive) {
+		if scan.Options == nil || scan.Options.PassiveInput == "" {
+			return fmt.Errorf("%w: passive_input is required in passive mode", model.ErrInvalidScanOptions)
+		}
+		opts = append(opts, nucleiLib.EnablePassiveMode())
+		inputs = []string{filepath.Join(s.cfg.Nuclei.PassiveInputDir, scan.Options.PassiveInput)}
 	}
 
 	// initialize engine
-	engine, err := nucleiLib.NewNucleiEngineCtx(scanCtx, opts...)
+	_, initSpan := tracer.Start(scanCtx, "nuclei.engine.init", trace.WithAttributes(attribute.String("scan.id", scan.ID)))
+	engine, err := s.newEngine(scanCtx, opts...)
 
 	if err != nil {
-		s.logger.Error("Failed to initialize nuclei engine", zap.Error(err))
-		return nil, fmt.Errorf("initializing nuclei engine: %w", err)
+		telemetry.EndSpan(initSpan, err)
+		logger.Error("Failed to initialize nuclei engine", zap.Error(err))
+		return fmt.Errorf("initializing nuclei engine: %w", err)
 	}
 	defer engine.Close()
 
 	engine.LoadAllTemplates()
 
 	// load targets
-	engine.LoadTargets([]string{scan.Target}, false)
+	engine.LoadTargets(inputs, false)
+	telemetry.EndSpan(initSpan, nil)
 
-	// collect results
-	var results []*model.ScanResult
+	// report the selected templates without sending any requests
+	if scan.Options != nil && scan.Options.DryRun {
+		results := dryRunResults(scan, engine.GetTemplates())
+		for _, result := range results {
+			if err := onResult(result); err != nil {
+				return err
+			}
+		}
+		logger.Info("Completed nuclei dry run",
+			zap.String("scan_id", scan.ID),
+			zap.Int("result_count", len(results)),
+		)
+		return nil
+	}
+
+	// stream results; nuclei may call back from several goroutines, so
+	// deliveries are serialised and the first failure stops the scan
+	var (
+		resultMu    sync.Mutex
+		resultCount int
+		resultErr   error
+	)
 	callback := func(event *output.ResultEvent) {
 		if event == nil {
-			s.logger.Warn("Received nil event in callback")
+			logger.Warn("Received nil event in callback")
 			return
 		}
-		s.logger.Info("Received nuclei event", zap.Any("event", event))
-		// map event to ScanResult
-		result := &model.ScanResult{
-			ID:         event.MatcherName + ":" + fmt.Sprint(time.Now().UnixNano()),
-			ScanID:     scan.ID,
-			TemplateID: event.TemplateID,
-			// Severity:   event.Info.Severity,
-			Host:      event.Host,
-			MatchedAt: time.Now(),
-			// add other fields as needed
-		}
-		results = append(results, result)
-		s.logger.Info("Processed scan result",
+		logger.Debug("Received nuclei event",
+			zap.String("template_id", event.TemplateID),
+			zap.String("host", event.Host),
+			zap.String("severity", event.Info.SeverityHolder.Severity.String()),
+		)
+		result := resultFromEvent(scan.ID, event)
+
+		resultMu.Lock()
+		defer resultMu.Unlock()
+		if resultErr != nil {
+			return
+		}
+		if err := onResult(result); err != nil {
+			resultErr = err
+			cancel()
+			return
+		}
+		resultCount++
+		logger.Info("Processed scan result",
 			zap.String("scan_id", scan.ID),
 			zap.String("result_id", result.ID),
 			zap.String("template_id", result.TemplateID),
@@ -124,28 +257,267 @@ func (s *nucleiService) StartScan(ctx context.Context, scan *model.Scan) ([]*mod
 		)
 	}
 
+	// enforce per-scan timeout
+	timeout := s.cfg.Nuclei.Timeout
+	if scan.Options != nil && scan.Options.Timeout > 0 {
+		timeout = scan.Options.Timeout
+	}
+	execCtx := scanCtx
+	if timeout > 0 {
+		var execCancel context.CancelFunc
+		execCtx, execCancel = context.WithTimeout(scanCtx, time.Duration(timeout)*time.Second)
+		defer execCancel()
+	}
+
 	// execute scan
-	s.logger.Info("Executing nuclei scan", zap.String("scan_id", scan.ID))
-	err = engine.ExecuteCallbackWithCtx(scanCtx, callback)
+	logger.Info("Executing nuclei scan", zap.String("scan_id", scan.ID), zap.Int("timeout", timeout))
+	execCtx, execSpan := tracer.Start(execCtx, "nuclei.engine.execute", trace.WithAttributes(attribute.String("scan.id", scan.ID)))
+	err = engine.ExecuteCallbackWithCtx(execCtx, callback)
+	resultMu.Lock()
+	if err == nil && resultErr != nil {
+		err = fmt.Errorf("handling result: %w", resultErr)
+	}
+	resultMu.Unlock()
+	// the engine stops on context cancellation without reporting it
+	if err == nil && errors.Is(execCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("scan timed out after %ds: %w", timeout, execCtx.Err())
+	}
+	execSpan.SetAttributes(attribute.Int("scan.result_count", resultCount))
+	telemetry.EndSpan(execSpan, err)
 	if err != nil {
-		// remove cancel
-		s.mu.Lock()
-		delete(s.cancels, scan.ID)
-		s.mu.Unlock()
-		s.logger.Error("Nuclei execution failed", zap.Error(err))
-		return nil, fmt.Errorf("nuclei execution: %w", err)
+		logger.Error("Nuclei execution failed", zap.Error(err))
+		return fmt.Errorf("nuclei execution: %w", err)
 	}
 
-	// cleanup cancel
-	s.mu.Lock()
-	delete(s.cancels, scan.ID)
-	s.mu.Unlock()
-
-	s.logger.Info("Completed nuclei scan",
+	logger.Info("Completed nuclei scan",
 		zap.String("scan_id", scan.ID),
-		zap.Int("result_count", len(results)),
+		zap.Int("result_count", resultCount),
 	)
-	return results, nil
+	return nil
+}
+
+// templateSources returns the templates directory and the workflow files
+// selected by the scan. Workflows alone skip the directory, so a scan naming
+// only workflows doesn't also run every template.
+func (s *nucleiService) templateSources(scan *model.Scan) (nucleiLib.TemplateSources, error) {
+	sources := nucleiLib.TemplateSources{}
+	if len(scan.WorkflowIDs) == 0 || len(scan.TemplateIDs) > 0 || len(scan.Tags) > 0 {
+		sources.Templates = []string{s.cfg.Nuclei.TemplatesDir}
+	}
+	if len(scan.WorkflowIDs) > 0 {
+		paths, err := resolveWorkflowPaths(s.cfg.Nuclei.TemplatesDir, scan.WorkflowIDs)
+		if err != nil {
+			return sources, err
+		}
+		sources.Workflows = paths
+	}
+	return sources, nil
+}
+
+// resolveWorkflowPaths walks dir for the workflow files with the given IDs. A
+// workflow's ID is its id field or, failing that, its path relative to dir
+// without the extension, as for templates.
+func resolveWorkflowPaths(dir string, ids []string) ([]string, error) {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	found := make(map[string]string, len(ids))
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var workflow struct {
+			ID        string        `yaml:"id"`
+			Workflows []interface{} `yaml:"workflows"`
+		}
+		if err := yaml.Unmarshal(data, &workflow); err != nil || len(workflow.Workflows) == 0 {
+			return nil
+		}
+
+		id := workflow.ID
+		if id == "" {
+			if rel, err := filepath.Rel(dir, path); err == nil {
+				id = strings.TrimSuffix(rel, filepath.Ext(rel))
+			}
+		}
+		if wanted[id] {
+			if _, ok := found[id]; !ok {
+				found[id] = path
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk template directory: %w", err)
+	}
+
+	paths := make([]string, 0, len(ids))
+	for _, id := range ids {
+		path, ok := found[id]
+		if !ok {
+			return nil, fmt.Errorf("workflow not found: %s", id)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// dryRunResults returns an unmatched result for each target and template a
+// scan would run
+func dryRunResults(scan *model.Scan, loaded []*templates.Template) []*model.ScanResult {
+	results := make([]*model.ScanResult, 0, len(scan.Targets)*len(loaded))
+	for _, target := range scan.Targets {
+		for _, template := range loaded {
+			results = append(results, &model.ScanResult{
+				ID:           model.NewUUID(),
+				ScanID:       scan.ID,
+				TemplateID:   template.ID,
+				TemplateName: template.Info.Name,
+				Severity:     template.Info.SeverityHolder.Severity.String(),
+				Matched:      false,
+				Host:         target,
+				MatchedAt:    time.Now(),
+			})
+		}
+	}
+	return results
+}
+
+// resultFromEvent maps a nuclei result event to a scan result
+func resultFromEvent(scanID string, event *output.ResultEvent) *model.ScanResult {
+	return &model.ScanResult{
+		ID:               event.MatcherName + ":" + fmt.Sprint(time.Now().UnixNano()),
+		ScanID:           scanID,
+		TemplateID:       event.TemplateID,
+		TemplateName:     event.Info.Name,
+		Severity:         event.Info.SeverityHolder.Severity.String(),
+		Matched:          true,
+		Host:             event.Host,
+		MatchedAt:        time.Now(),
+		MatcherName:      event.MatcherName,
+		ExtractedResults: event.ExtractedResults,
+		Request:          event.Request,
+		Response:         event.Response,
+		Metadata:         eventMetadata(event),
+	}
+}
+
+// eventMetadata collects event details that have no dedicated result field
+func eventMetadata(event *output.ResultEvent) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"ip":        event.IP,
+		"timestamp": event.Timestamp,
+		"type":      event.Type,
+	}
+	if event.CURLCommand != "" {
+		metadata["curl_command"] = event.CURLCommand
+	}
+	if event.Interaction != nil {
+		metadata["interaction"] = event.Interaction
+	}
+	return metadata
+}
+
+// formatHeaders converts headers to the "Name: value" form nuclei expects,
+// sorted by name so scans are reproducible. Cookies are sent as a single
+// Cookie header, appended to one given in headers
+func formatHeaders(headers, cookies map[string]string) []string {
+	cookie := ""
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		if strings.EqualFold(name, "Cookie") {
+			cookie = headers[name]
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	formatted := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		formatted = append(formatted, name+": "+headers[name])
+	}
+	if cookie = formatCookies(cookie, cookies); cookie != "" {
+		formatted = append(formatted, "Cookie: "+cookie)
+	}
+	return formatted
+}
+
+// formatCookies appends cookies to a Cookie header value as "name=value"
+// pairs, sorted by name
+func formatCookies(header string, cookies map[string]string) string {
+	names := make([]string, 0, len(cookies))
+	for name := range cookies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names)+1)
+	if header != "" {
+		pairs = append(pairs, header)
+	}
+	for _, name := range names {
+		pairs = append(pairs, name+"="+cookies[name])
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// buildConcurrencyOpts builds nuclei concurrency options from the scan options,
+// falling back to the configured concurrency when the scan does not set one
+func buildConcurrencyOpts(opts *model.ScanOptions, cfgConcurrency int) nucleiLib.Concurrency {
+	concurrency := cfgConcurrency
+	if opts != nil && opts.Concurrency > 0 {
+		concurrency = opts.Concurrency
+	}
+	// nuclei requires every concurrency value to be at least 1
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return nucleiLib.Concurrency{
+		TemplateConcurrency:           concurrency,
+		HostConcurrency:               concurrency,
+		HeadlessHostConcurrency:       concurrency,
+		HeadlessTemplateConcurrency:   concurrency,
+		JavascriptTemplateConcurrency: concurrency,
+		TemplatePayloadConcurrency:    concurrency,
+		ProbeConcurrency:              concurrency,
+	}
+}
+
+// withResolvers sets the DNS resolvers used by the engine. The SDK only
+// exposes them through WithNetworkConfig, which would also reset the
+// timeout, retries and host error options to zero
+func withResolvers(resolvers []string) nucleiLib.NucleiSDKOptions {
+	return func(e *nucleiLib.NucleiEngine) error {
+		e.Options().InternalResolversList = resolvers
+		return nil
+	}
+}
+
+// followRedirects returns the scan's follow_redirects option, or
+// defaultFollow when the scan does not set it
+func followRedirects(opts *model.ScanOptions, defaultFollow bool) bool {
+	if opts != nil && opts.FollowRedirects != nil {
+		return *opts.FollowRedirects
+	}
+	return defaultFollow
+}
+
+// withFollowRedirects sets whether the engine follows HTTP redirects; the SDK
+// has no dedicated option for it so the engine options are set directly
+func withFollowRedirects(follow bool) nucleiLib.NucleiSDKOptions {
+	return func(e *nucleiLib.NucleiEngine) error {
+		e.Options().FollowRedirects = follow
+		return nil
+	}
 }
 
 // CancelScan cancels a running scan
@@ -0,0 +1,687 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	nucleiLib "github.com/projectdiscovery/nuclei/v3/lib"
+	nucleiModel "github.com/projectdiscovery/nuclei/v3/pkg/model"
+	"github.com/projectdiscovery/nuclei/v3/pkg/model/types/severity"
+	"github.com/projectdiscovery/nuclei/v3/pkg/output"
+	"github.com/projectdiscovery/nuclei/v3/pkg/templates"
+	"github.com/projectdiscovery/nuclei/v3/pkg/types"
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/model"
+)
+
+// errOptionsCaptured stops engine creation once the options are captured
+var errOptionsCaptured = errors.New("engine options captured")
+
+// newTestNucleiConfig returns a configuration for scans against an empty
+// templates directory
+func newTestNucleiConfig(t *testing.T) *config.Config {
+	cfg := &config.Config{}
+	cfg.Nuclei.TemplatesDir = t.TempDir()
+	cfg.Nuclei.Concurrency = 25
+	cfg.Nuclei.Timeout = 30
+	return cfg
+}
+
+// captureEngineOptions starts scan and returns the options its nuclei engine
+// is created with, stopping before the engine is initialized
+func captureEngineOptions(t *testing.T, cfg *config.Config, scan *model.Scan) *types.Options {
+	t.Helper()
+
+	svc := NewNucleiService(cfg, zap.NewNop()).(*nucleiService)
+	var captured *types.Options
+	svc.newEngine = func(ctx context.Context, options ...nucleiLib.NucleiSDKOptions) (nucleiEngine, error) {
+		capture := func(e *nucleiLib.NucleiEngine) error {
+			captured = e.Options()
+			return errOptionsCaptured
+		}
+		return newNucleiEngine(ctx, append(options, capture)...)
+	}
+
+	err := svc.StartScan(context.Background(), scan, func(*model.ScanResult) error { return nil })
+	if !errors.Is(err, errOptionsCaptured) {
+		t.Fatalf("StartScan() error = %v, want %v", err, errOptionsCaptured)
+	}
+	return captured
+}
+
+// newTestScan returns a scan of one target with the given options
+func newTestScan(options *model.ScanOptions) *model.Scan {
+	return &model.Scan{
+		ID:      "scan-1",
+		Target:  "http://127.0.0.1",
+		Targets: []string{"http://127.0.0.1"},
+		Options: options,
+	}
+}
+
+func TestStartScanSetsConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *model.ScanOptions
+		want    int
+	}{
+		{name: "scan option", options: &model.ScanOptions{Concurrency: 7}, want: 7},
+		{name: "configured default", options: &model.ScanOptions{}, want: 25},
+		{name: "no options", options: nil, want: 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := captureEngineOptions(t, newTestNucleiConfig(t), newTestScan(tt.options))
+
+			if opts.TemplateThreads != tt.want {
+				t.Errorf("TemplateThreads = %d, want %d", opts.TemplateThreads, tt.want)
+			}
+			if opts.BulkSize != tt.want {
+				t.Errorf("BulkSize = %d, want %d", opts.BulkSize, tt.want)
+			}
+			if opts.PayloadConcurrency != tt.want {
+				t.Errorf("PayloadConcurrency = %d, want %d", opts.PayloadConcurrency, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildConcurrencyOptsFloorsAtOne(t *testing.T) {
+	got := buildConcurrencyOpts(nil, 0)
+	if got.TemplateConcurrency != 1 || got.HostConcurrency != 1 || got.ProbeConcurrency != 1 {
+		t.Errorf("buildConcurrencyOpts(nil, 0) = %+v, want every value 1", got)
+	}
+}
+
+func TestStartScanFollowRedirects(t *testing.T) {
+	follow, noFollow := true, false
+	tests := []struct {
+		name          string
+		configDefault bool
+		option        *bool
+		want          bool
+	}{
+		{name: "scan enables", configDefault: false, option: &follow, want: true},
+		{name: "scan disables", configDefault: true, option: &noFollow, want: false},
+		{name: "config enables", configDefault: true, option: nil, want: true},
+		{name: "config disables", configDefault: false, option: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestNucleiConfig(t)
+			cfg.Nuclei.FollowRedirects = tt.configDefault
+
+			opts := captureEngineOptions(t, cfg, newTestScan(&model.ScanOptions{FollowRedirects: tt.option}))
+			if opts.FollowRedirects != tt.want {
+				t.Errorf("FollowRedirects = %v, want %v", opts.FollowRedirects, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartScanFiltersTemplatesByTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+	}{
+		{name: "tags", tags: []string{"cve", "rce"}},
+		{name: "no tags", tags: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan := newTestScan(nil)
+			scan.Tags = tt.tags
+
+			opts := captureEngineOptions(t, newTestNucleiConfig(t), scan)
+			if !slices.Equal([]string(opts.Tags), tt.tags) {
+				t.Errorf("Tags = %v, want %v", opts.Tags, tt.tags)
+			}
+		})
+	}
+}
+
+// fakeEngine is a nuclei engine that loads templates and whose execution
+// is run by execute
+type fakeEngine struct {
+	execute   func(ctx context.Context) error
+	templates []*templates.Template
+	targets   []string
+}
+
+func (e *fakeEngine) LoadAllTemplates() error                         { return nil }
+func (e *fakeEngine) LoadTargets(targets []string, probeNonHttp bool) { e.targets = targets }
+func (e *fakeEngine) GetTemplates() []*templates.Template             { return e.templates }
+func (e *fakeEngine) Close()                                          {}
+
+func (e *fakeEngine) ExecuteCallbackWithCtx(ctx context.Context, callback ...func(event *output.ResultEvent)) error {
+	return e.execute(ctx)
+}
+
+func TestStartScanTimeout(t *testing.T) {
+	// like nuclei, the engine stops on cancellation without reporting it
+	waitForCancel := func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}
+	finish := func(context.Context) error { return nil }
+
+	tests := []struct {
+		name        string
+		timeout     int
+		execute     func(ctx context.Context) error
+		wantTimeout bool
+	}{
+		{name: "exceeded", timeout: 1, execute: waitForCancel, wantTimeout: true},
+		{name: "finished in time", timeout: 1, execute: finish, wantTimeout: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewNucleiService(newTestNucleiConfig(t), zap.NewNop()).(*nucleiService)
+			svc.newEngine = func(context.Context, ...nucleiLib.NucleiSDKOptions) (nucleiEngine, error) {
+				return &fakeEngine{execute: tt.execute}, nil
+			}
+
+			scan := newTestScan(&model.ScanOptions{Timeout: tt.timeout})
+			err := svc.StartScan(context.Background(), scan, func(*model.ScanResult) error { return nil })
+			if tt.wantTimeout {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("StartScan() error = %v, want %v", err, context.DeadlineExceeded)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StartScan() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestStartScanSetsProxy(t *testing.T) {
+	tests := []struct {
+		name          string
+		configDefault string
+		option        string
+		want          []string
+	}{
+		{name: "scan proxy", configDefault: "", option: "http://127.0.0.1:8080", want: []string{"http://127.0.0.1:8080"}},
+		{name: "scan overrides config", configDefault: "http://proxy:3128", option: "socks5://127.0.0.1:1080", want: []string{"socks5://127.0.0.1:1080"}},
+		{name: "config proxy", configDefault: "http://proxy:3128", option: "", want: []string{"http://proxy:3128"}},
+		{name: "no proxy", configDefault: "", option: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestNucleiConfig(t)
+			cfg.Nuclei.Proxy = tt.configDefault
+
+			opts := captureEngineOptions(t, cfg, newTestScan(&model.ScanOptions{Proxy: tt.option}))
+			if !slices.Equal([]string(opts.Proxy), tt.want) {
+				t.Errorf("Proxy = %v, want %v", opts.Proxy, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartScanSetsCustomHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    []string
+	}{
+		{
+			name:    "sorted by name",
+			headers: map[string]string{"X-Tenant": "acme", "Authorization": "Bearer token"},
+			want:    []string{"Authorization: Bearer token", "X-Tenant: acme"},
+		},
+		{name: "none", headers: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := captureEngineOptions(t, newTestNucleiConfig(t), newTestScan(&model.ScanOptions{CustomHeaders: tt.headers}))
+			if !slices.Equal([]string(opts.CustomHeaders), tt.want) {
+				t.Errorf("CustomHeaders = %q, want %q", opts.CustomHeaders, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatHeadersCookies(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		cookies map[string]string
+		want    []string
+	}{
+		{
+			name:    "cookies sorted by name",
+			cookies: map[string]string{"theme": "dark", "session": "abc123"},
+			want:    []string{"Cookie: session=abc123; theme=dark"},
+		},
+		{
+			name:    "appended to a Cookie header",
+			headers: map[string]string{"cookie": "tracking=off", "X-Tenant": "acme"},
+			cookies: map[string]string{"session": "abc123"},
+			want:    []string{"X-Tenant: acme", "Cookie: tracking=off; session=abc123"},
+		},
+		{name: "none", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatHeaders(tt.headers, tt.cookies); !slices.Equal(got, tt.want) {
+				t.Errorf("formatHeaders() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartScanForwardsCookies(t *testing.T) {
+	scan := newTestScan(&model.ScanOptions{CustomCookies: map[string]string{"session": "abc123"}})
+	opts := captureEngineOptions(t, newTestNucleiConfig(t), scan)
+
+	want := []string{"Cookie: session=abc123"}
+	if !slices.Equal([]string(opts.CustomHeaders), want) {
+		t.Errorf("CustomHeaders = %q, want %q", opts.CustomHeaders, want)
+	}
+}
+
+func TestStartScanDryRunSendsNoRequests(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { requests.Add(1) }))
+	defer server.Close()
+
+	svc := NewNucleiService(newTestNucleiConfig(t), zap.NewNop()).(*nucleiService)
+	svc.newEngine = func(context.Context, ...nucleiLib.NucleiSDKOptions) (nucleiEngine, error) {
+		return &fakeEngine{
+			execute: func(context.Context) error {
+				t.Error("dry run executed the scan")
+				return nil
+			},
+			templates: []*templates.Template{
+				{ID: "cve-2021-1234", Info: nucleiModel.Info{Name: "Example CVE", SeverityHolder: severity.Holder{Severity: severity.Critical}}},
+				{ID: "tech-detect", Info: nucleiModel.Info{Name: "Tech detect", SeverityHolder: severity.Holder{Severity: severity.Info}}},
+			},
+		}, nil
+	}
+
+	scan := newTestScan(&model.ScanOptions{DryRun: true})
+	scan.Targets = []string{server.URL, server.URL + "/admin"}
+	var results []*model.ScanResult
+	err := svc.StartScan(context.Background(), scan, func(result *model.ScanResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StartScan() error = %v", err)
+	}
+
+	if n := requests.Load(); n != 0 {
+		t.Errorf("target received %d requests, want none", n)
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want one per target and template", len(results))
+	}
+	for _, result := range results {
+		if result.Matched {
+			t.Errorf("result %s on %s is matched, want unmatched", result.TemplateID, result.Host)
+		}
+	}
+	if first := results[0]; first.TemplateID != "cve-2021-1234" || first.TemplateName != "Example CVE" || first.Severity != "critical" || first.Host != server.URL {
+		t.Errorf("first result = %+v, want the critical template on the first target", first)
+	}
+}
+
+func TestStartScanLoadsEveryTarget(t *testing.T) {
+	engine := &fakeEngine{execute: func(context.Context) error { return nil }}
+	svc := NewNucleiService(newTestNucleiConfig(t), zap.NewNop()).(*nucleiService)
+	svc.newEngine = func(context.Context, ...nucleiLib.NucleiSDKOptions) (nucleiEngine, error) {
+		return engine, nil
+	}
+
+	scan := newTestScan(nil)
+	scan.Targets = []string{"http://a.example.com", "http://b.example.com"}
+	if err := svc.StartScan(context.Background(), scan, noResults); err != nil {
+		t.Fatalf("StartScan() error = %v", err)
+	}
+	if !slices.Equal(engine.targets, scan.Targets) {
+		t.Errorf("loaded targets %v, want %v", engine.targets, scan.Targets)
+	}
+}
+
+func TestStartScanPassiveMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		configPassive bool
+		options       *model.ScanOptions
+		want          bool
+	}{
+		{name: "active by default", want: false},
+		{name: "passive option", options: &model.ScanOptions{Passive: true, PassiveInput: "responses"}, want: true},
+		{name: "passive config default", configPassive: true, options: &model.ScanOptions{PassiveInput: "responses"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestNucleiConfig(t)
+			cfg.Nuclei.Passive = tt.configPassive
+
+			opts := captureEngineOptions(t, cfg, newTestScan(tt.options))
+			if opts.OfflineHTTP != tt.want {
+				t.Errorf("OfflineHTTP = %v, want %v", opts.OfflineHTTP, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartScanPassiveInput(t *testing.T) {
+	cfg := newTestNucleiConfig(t)
+	cfg.Nuclei.PassiveInputDir = "/var/lib/nuclei/passive"
+
+	engine := &fakeEngine{execute: func(context.Context) error { return nil }}
+	svc := NewNucleiService(cfg, zap.NewNop()).(*nucleiService)
+	svc.newEngine = func(context.Context, ...nucleiLib.NucleiSDKOptions) (nucleiEngine, error) {
+		return engine, nil
+	}
+
+	scan := newTestScan(&model.ScanOptions{Passive: true, PassiveInput: "site/responses"})
+	if err := svc.StartScan(context.Background(), scan, noResults); err != nil {
+		t.Fatalf("StartScan() error = %v", err)
+	}
+	// The stored responses replace the targets as input
+	want := []string{"/var/lib/nuclei/passive/site/responses"}
+	if !slices.Equal(engine.targets, want) {
+		t.Errorf("loaded targets %v, want %v", engine.targets, want)
+	}
+
+	// Passive mode from the config still needs an input
+	cfg.Nuclei.Passive = true
+	err := svc.StartScan(context.Background(), newTestScan(nil), noResults)
+	if !errors.Is(err, model.ErrInvalidScanOptions) {
+		t.Errorf("StartScan() without passive_input error = %v, want ErrInvalidScanOptions", err)
+	}
+}
+
+func TestStartScanReleasesCancel(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   *model.ScanOptions
+		engineErr error
+		execErr   error
+	}{
+		{name: "completed"},
+		{name: "dry run", options: &model.ScanOptions{DryRun: true}},
+		{name: "missing passive input", options: &model.ScanOptions{Passive: true}},
+		{name: "engine init failure", engineErr: errors.New("init failed")},
+		{name: "execution failure", execErr: errors.New("execution failed")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var scanCtx context.Context
+			svc := NewNucleiService(newTestNucleiConfig(t), zap.NewNop()).(*nucleiService)
+			svc.newEngine = func(ctx context.Context, _ ...nucleiLib.NucleiSDKOptions) (nucleiEngine, error) {
+				scanCtx = ctx
+				if tt.engineErr != nil {
+					return nil, tt.engineErr
+				}
+				return &fakeEngine{execute: func(context.Context) error { return tt.execErr }}, nil
+			}
+
+			_ = svc.StartScan(context.Background(), newTestScan(tt.options), noResults)
+
+			svc.mu.Lock()
+			registered := len(svc.cancels)
+			svc.mu.Unlock()
+			if registered != 0 {
+				t.Errorf("%d cancels still registered after StartScan returned, want none", registered)
+			}
+			if scanCtx != nil && scanCtx.Err() == nil {
+				t.Error("scan context is not cancelled after StartScan returned")
+			}
+		})
+	}
+}
+
+func TestStartScanForwardsDNSResolvers(t *testing.T) {
+	tests := []struct {
+		name          string
+		configDefault []string
+		options       *model.ScanOptions
+		want          []string
+	}{
+		{name: "none", want: nil},
+		{name: "config default", configDefault: []string{"10.0.0.53:53"}, want: []string{"10.0.0.53:53"}},
+		{
+			name:          "scan option wins",
+			configDefault: []string{"10.0.0.53:53"},
+			options:       &model.ScanOptions{DNSResolvers: []string{"1.1.1.1:53", "dns.internal:5353"}},
+			want:          []string{"1.1.1.1:53", "dns.internal:5353"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestNucleiConfig(t)
+			cfg.Nuclei.DNSResolvers = tt.configDefault
+
+			opts := captureEngineOptions(t, cfg, newTestScan(tt.options))
+			if !slices.Equal(opts.InternalResolversList, tt.want) {
+				t.Errorf("InternalResolversList = %v, want %v", opts.InternalResolversList, tt.want)
+			}
+		})
+	}
+}
+
+// testWorkflow chains a template by path, which is all resolving needs
+const testWorkflow = `id: %s
+info:
+  name: Test workflow
+workflows:
+  - template: http/tech-detect.yaml
+`
+
+func TestStartScanLoadsWorkflows(t *testing.T) {
+	cfg := newTestNucleiConfig(t)
+	dir := filepath.Join(cfg.Nuclei.TemplatesDir, "workflows")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	workflowPath := filepath.Join(dir, "wordpress-workflow.yaml")
+	if err := os.WriteFile(workflowPath, []byte(fmt.Sprintf(testWorkflow, "wordpress-workflow")), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	// A template with the same ID is not a workflow
+	templatePath := filepath.Join(cfg.Nuclei.TemplatesDir, "wordpress.yaml")
+	if err := os.WriteFile(templatePath, []byte("id: wordpress-workflow\ninfo:\n  name: Not a workflow\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		tags          []string
+		wantTemplates []string
+	}{
+		{name: "workflows only", wantTemplates: nil},
+		{name: "workflows and tags", tags: []string{"wordpress"}, wantTemplates: []string{cfg.Nuclei.TemplatesDir}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan := newTestScan(nil)
+			scan.WorkflowIDs = []string{"wordpress-workflow"}
+			scan.Tags = tt.tags
+
+			opts := captureEngineOptions(t, cfg, scan)
+			if !slices.Equal(opts.Workflows, []string{workflowPath}) {
+				t.Errorf("Workflows = %v, want %v", opts.Workflows, []string{workflowPath})
+			}
+			if !slices.Equal(opts.Templates, tt.wantTemplates) {
+				t.Errorf("Templates = %v, want %v", opts.Templates, tt.wantTemplates)
+			}
+		})
+	}
+}
+
+func TestStartScanUnknownWorkflow(t *testing.T) {
+	svc := NewNucleiService(newTestNucleiConfig(t), zap.NewNop()).(*nucleiService)
+	svc.newEngine = func(context.Context, ...nucleiLib.NucleiSDKOptions) (nucleiEngine, error) {
+		t.Fatal("engine created for a scan with an unknown workflow")
+		return nil, nil
+	}
+
+	scan := newTestScan(nil)
+	scan.WorkflowIDs = []string{"missing-workflow"}
+	err := svc.StartScan(context.Background(), scan, noResults)
+	if err == nil || !strings.Contains(err.Error(), "workflow not found: missing-workflow") {
+		t.Errorf("StartScan() error = %v, want workflow not found", err)
+	}
+}
+
+func TestResultFromEvent(t *testing.T) {
+	event := &output.ResultEvent{
+		TemplateID: "cve-2021-1234",
+		Info: nucleiModel.Info{
+			Name:           "Example CVE",
+			SeverityHolder: severity.Holder{Severity: severity.High},
+		},
+		Host:             "http://example.com",
+		MatcherName:      "status-200",
+		ExtractedResults: []string{"v1.2.3"},
+		Request:          "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		Response:         "HTTP/1.1 200 OK\r\n\r\nok",
+	}
+
+	result := resultFromEvent("scan-1", event)
+	want := &model.ScanResult{
+		ScanID:           "scan-1",
+		TemplateID:       "cve-2021-1234",
+		TemplateName:     "Example CVE",
+		Severity:         "high",
+		Matched:          true,
+		Host:             "http://example.com",
+		MatcherName:      "status-200",
+		ExtractedResults: []string{"v1.2.3"},
+		Request:          event.Request,
+		Response:         event.Response,
+	}
+	// ID, match time and metadata are not taken from the event's fields
+	got := *result
+	got.ID, got.MatchedAt, got.Metadata = "", time.Time{}, nil
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("resultFromEvent() = %+v, want %+v", &got, want)
+	}
+	if result.ID == "" || result.MatchedAt.IsZero() {
+		t.Errorf("resultFromEvent() ID %q and MatchedAt %v, want both set", result.ID, result.MatchedAt)
+	}
+}
+
+func TestEventMetadata(t *testing.T) {
+	matchedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	interaction := &server.Interaction{Protocol: "dns", UniqueID: "c59t2cp2l1d3q0f7n8i0"}
+
+	tests := []struct {
+		name  string
+		event *output.ResultEvent
+		want  map[string]interface{}
+	}{
+		{
+			name:  "plain event",
+			event: &output.ResultEvent{IP: "93.184.216.34", Timestamp: matchedAt, Type: "http"},
+			want: map[string]interface{}{
+				"ip":        "93.184.216.34",
+				"timestamp": matchedAt,
+				"type":      "http",
+			},
+		},
+		{
+			name: "rich event",
+			event: &output.ResultEvent{
+				IP:          "93.184.216.34",
+				Timestamp:   matchedAt,
+				Type:        "http",
+				CURLCommand: "curl -X GET http://example.com",
+				Interaction: interaction,
+			},
+			want: map[string]interface{}{
+				"ip":           "93.184.216.34",
+				"timestamp":    matchedAt,
+				"type":         "http",
+				"curl_command": "curl -X GET http://example.com",
+				"interaction":  interaction,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eventMetadata(tt.event); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("eventMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	event := tests[1].event
+	if got := resultFromEvent("scan-1", event).Metadata; !reflect.DeepEqual(got, tests[1].want) {
+		t.Errorf("resultFromEvent() metadata = %v, want %v", got, tests[1].want)
+	}
+}
+
+// tlsTestTemplate matches the body served by the self-signed test server
+const tlsTestTemplate = `id: self-signed-body
+info:
+  name: Self-signed body
+  author: test
+  severity: info
+http:
+  - method: GET
+    path:
+      - "{{BaseURL}}/"
+    matchers:
+      - type: word
+        words:
+          - "served over self-signed TLS"
+`
+
+func TestStartScanSelfSignedTarget(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "served over self-signed TLS")
+	}))
+	defer server.Close()
+
+	skipVerify := true
+	tests := []struct {
+		name    string
+		options *model.ScanOptions
+	}{
+		{name: "tls skip verify", options: &model.ScanOptions{TLSSkipVerify: &skipVerify}},
+		{name: "option omitted", options: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestNucleiConfig(t)
+			if err := os.WriteFile(filepath.Join(cfg.Nuclei.TemplatesDir, "self-signed-body.yaml"), []byte(tlsTestTemplate), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			scan := &model.Scan{ID: "scan-1", Target: server.URL, Targets: []string{server.URL}, Options: tt.options}
+
+			var results []*model.ScanResult
+			err := NewNucleiService(cfg, zap.NewNop()).StartScan(context.Background(), scan, func(result *model.ScanResult) error {
+				results = append(results, result)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("StartScan() error = %v", err)
+			}
+			if len(results) != 1 || results[0].TemplateID != "self-signed-body" {
+				t.Errorf("results = %v, want one match of the untrusted certificate target", results)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// profileService implements the ProfileService interface
+type profileService struct {
+	repo   repository.ProfileRepository
+	cfg    *config.Config
+	logger *zap.Logger
+}
+
+// NewProfileService creates a new scan profile service
+func NewProfileService(repo repository.ProfileRepository, cfg *config.Config, logger *zap.Logger) ProfileService {
+	return &profileService{
+		repo:   repo,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// ListProfiles returns all scan profiles
+func (s *profileService) ListProfiles(ctx context.Context) ([]*model.ScanProfile, error) {
+	s.logger.Info("Listing scan profiles")
+
+	profiles, err := s.repo.List(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list scan profiles from repository", zap.Error(err))
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// GetProfile returns a scan profile by ID
+func (s *profileService) GetProfile(ctx context.Context, id string) (*model.ScanProfile, error) {
+	s.logger.Info("Getting scan profile", zap.String("id", id))
+
+	profile, err := s.repo.Get(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get scan profile from repository", zap.Error(err), zap.String("id", id))
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// CreateProfile validates and stores a new scan profile
+func (s *profileService) CreateProfile(ctx context.Context, input model.ScanProfileInput) (*model.ScanProfile, error) {
+	s.logger.Info("Creating scan profile", zap.String("name", input.Name))
+
+	if err := s.validateProfileInput(&input); err != nil {
+		return nil, err
+	}
+
+	// Create profile
+	now := time.Now()
+	profile := &model.ScanProfile{
+		ID:          uuid.New().String(),
+		Name:        input.Name,
+		Description: input.Description,
+		Options:     input.Options,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	// Save profile
+	if err := s.repo.Create(ctx, profile); err != nil {
+		s.logger.Error("Failed to create scan profile in repository", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Created scan profile in repository", zap.String("id", profile.ID))
+	return profile, nil
+}
+
+// UpdateProfile validates and replaces an existing scan profile
+func (s *profileService) UpdateProfile(ctx context.Context, id string, input model.ScanProfileInput) (*model.ScanProfile, error) {
+	s.logger.Info("Updating scan profile", zap.String("id", id))
+
+	if err := s.validateProfileInput(&input); err != nil {
+		return nil, err
+	}
+
+	profile := &model.ScanProfile{
+		ID:          id,
+		Name:        input.Name,
+		Description: input.Description,
+		Options:     input.Options,
+		UpdatedAt:   time.Now(),
+	}
+	if err := s.repo.Update(ctx, profile); err != nil {
+		s.logger.Error("Failed to update scan profile in repository", zap.Error(err), zap.String("id", id))
+		return nil, err
+	}
+
+	s.logger.Info("Updated scan profile in repository", zap.String("id", id))
+	return profile, nil
+}
+
+// DeleteProfile deletes a scan profile by ID
+func (s *profileService) DeleteProfile(ctx context.Context, id string) error {
+	s.logger.Info("Deleting scan profile", zap.String("id", id))
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		s.logger.Error("Failed to delete scan profile from repository", zap.Error(err), zap.String("id", id))
+		return err
+	}
+
+	return nil
+}
+
+// validateProfileInput trims the profile name and checks the input. Options a
+// profile leaves at zero stay unset, so they are checked with the configured
+// defaults in their place
+func (s *profileService) validateProfileInput(input *model.ScanProfileInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidProfile)
+	}
+	options := input.Options
+	options.ApplyDefaults(s.cfg.Nuclei.Concurrency, s.cfg.Nuclei.RateLimit, s.cfg.Nuclei.Timeout)
+	return options.Validate()
+}
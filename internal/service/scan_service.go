@@ -2,56 +2,78 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"nuclei-service-demo/internal/config"
+	apperrors "nuclei-service-demo/internal/errors"
+	"nuclei-service-demo/internal/export"
 	"nuclei-service-demo/internal/model"
 	"nuclei-service-demo/internal/repository"
 )
 
 // scanService implements the ScanService interface
 type scanService struct {
-	scanRepo     repository.ScanRepository
-	templateRepo repository.TemplateRepository
-	nucleiSvc    NucleiServiceInterface
-	cfg          *config.Config
-	logger       *zap.Logger
+	scanRepo        repository.ScanRepository
+	templateRepo    repository.TemplateRepository
+	targetGroupRepo repository.TargetGroupRepository
+	profileRepo     repository.ProfileRepository
+	nucleiSvc       NucleiServiceInterface
+	cfg             *config.Config
+	logger          *zap.Logger
 }
 
 // NewScanService creates a new scan service
 func NewScanService(
 	scanRepo repository.ScanRepository,
 	templateRepo repository.TemplateRepository,
+	targetGroupRepo repository.TargetGroupRepository,
+	profileRepo repository.ProfileRepository,
 	nucleiSvc NucleiServiceInterface,
 	cfg *config.Config,
 	logger *zap.Logger,
 ) ScanService {
 	return &scanService{
-		scanRepo:     scanRepo,
-		templateRepo: templateRepo,
-		nucleiSvc:    nucleiSvc,
-		cfg:          cfg,
-		logger:       logger,
+		scanRepo:        scanRepo,
+		templateRepo:    templateRepo,
+		targetGroupRepo: targetGroupRepo,
+		profileRepo:     profileRepo,
+		nucleiSvc:       nucleiSvc,
+		cfg:             cfg,
+		logger:          logger,
 	}
 }
 
-// ListScans lists scans
-func (s *scanService) ListScans(ctx context.Context, status, target, templateID *string) ([]model.Scan, error) {
+// ListScans returns a page of scans, newest first
+func (s *scanService) ListScans(ctx context.Context, status, target, templateID *string, tags []string, page model.Page) (*model.ListResponse[model.Scan], error) {
 	s.logger.Info("Listing scans",
 		zap.String("status", safePtr(status)),
 		zap.String("target", safePtr(target)),
-		zap.String("templateID", safePtr(templateID)))
+		zap.String("templateID", safePtr(templateID)),
+		zap.Strings("tags", tags),
+		zap.Int("limit", page.Limit),
+		zap.Int("offset", page.Offset))
 
-	scans, err := s.scanRepo.List(ctx, status, target, templateID)
+	total, err := s.scanRepo.Count(ctx, status, target, templateID, tags)
+	if err != nil {
+		s.logger.Error("Failed to count scans in repository", zap.Error(err))
+		return nil, err
+	}
+
+	scans, err := s.scanRepo.List(ctx, status, target, templateID, tags, model.ScanOrderNewest, page)
 	if err != nil {
 		s.logger.Error("Failed to list scans from repository", zap.Error(err))
 		return nil, err
 	}
 
-	s.logger.Info("Retrieved scans from repository", zap.Int("count", len(scans)))
+	s.logger.Info("Retrieved scans from repository",
+		zap.Int("count", len(scans)),
+		zap.Int("total", total))
 
 	// Convert []*model.Scan to []model.Scan
 	result := make([]model.Scan, len(scans))
@@ -59,7 +81,14 @@ func (s *scanService) ListScans(ctx context.Context, status, target, templateID
 		result[i] = *scan
 	}
 
-	return result, nil
+	return &model.ListResponse[model.Scan]{
+		Data: result,
+		Meta: model.PageMeta{
+			Total:  total,
+			Limit:  page.Limit,
+			Offset: page.Offset,
+		},
+	}, nil
 }
 
 // GetScan gets a scan by ID
@@ -79,16 +108,69 @@ func (s *scanService) GetScan(ctx context.Context, id string) (*model.Scan, erro
 // StartScan starts a new scan
 func (s *scanService) StartScan(ctx context.Context, input model.StartScanInput) (*model.Scan, error) {
 	s.logger.Info("Starting scan",
-		zap.String("target", input.Target),
+		zap.Strings("targets", input.ScanTargets()),
 		zap.Strings("templateIDs", input.TemplateIDs),
+		zap.Strings("workflowIDs", input.WorkflowIDs),
 		zap.Strings("tags", input.Tags))
 
+	// Apply scan profile
+	if err := s.applyProfile(ctx, &input); err != nil {
+		return nil, err
+	}
+
+	// Validate priority
+	priority := model.ScanPriorityNormal
+	if input.Priority != nil {
+		if err := model.ValidatePriority(*input.Priority); err != nil {
+			s.logger.Warn("Invalid scan priority", zap.Error(err))
+			return nil, err
+		}
+		priority = *input.Priority
+	}
+
+	// Validate options, filling omitted ones from the configuration
+	if input.Options != nil {
+		input.Options.ApplyDefaults(s.cfg.Nuclei.Concurrency, s.cfg.Nuclei.RateLimit, s.cfg.Nuclei.Timeout)
+		if err := input.Options.Validate(); err != nil {
+			s.logger.Warn("Invalid scan options", zap.Error(err))
+			return nil, err
+		}
+		// nuclei logs through a process-wide logger, so a verbose scan's log
+		// would also hold the output of scans running alongside it
+		if input.Options.Verbose && !input.Options.DryRun && s.cfg.Worker.MaxConcurrency > 1 {
+			s.logger.Warn("Rejected verbose scan on a concurrent worker", zap.Int("max_concurrency", s.cfg.Worker.MaxConcurrency))
+			return nil, fmt.Errorf("%w: verbose requires WORKER_MAX_CONCURRENCY=1", model.ErrInvalidScanOptions)
+		}
+		if input.Options.DryRun {
+			return s.dryRunScan(ctx, input)
+		}
+	}
+
+	// Enforce queue depth limit
+	if s.cfg.Worker.MaxQueueDepth > 0 {
+		pending, err := s.scanRepo.CountByStatus(ctx, model.ScanStatusPending)
+		if err != nil {
+			s.logger.Error("Failed to count pending scans", zap.Error(err))
+			return nil, err
+		}
+		if pending >= s.cfg.Worker.MaxQueueDepth {
+			s.logger.Warn("Scan queue is full",
+				zap.Int("pending", pending),
+				zap.Int("max_queue_depth", s.cfg.Worker.MaxQueueDepth))
+			return nil, model.ErrQueueFull
+		}
+	}
+
 	// Create scan
+	targets := input.ScanTargets()
 	scan := &model.Scan{
 		ID:          uuid.New().String(),
-		Target:      input.Target,
+		Target:      targets[0],
+		Targets:     targets,
 		TemplateIDs: input.TemplateIDs,
+		WorkflowIDs: input.WorkflowIDs,
 		Tags:        input.Tags,
+		Priority:    priority,
 		Options:     input.Options,
 		Status:      model.ScanStatusPending,
 		CreatedAt:   time.Now(),
@@ -105,6 +187,120 @@ func (s *scanService) StartScan(ctx context.Context, input model.StartScanInput)
 	return scan, nil
 }
 
+// StartGroupScan starts one scan per target of the input's target group,
+// sharing the input's templates, tags and options
+func (s *scanService) StartGroupScan(ctx context.Context, input model.StartScanInput) ([]*model.Scan, error) {
+	if input.TargetGroupID == nil {
+		return nil, fmt.Errorf("%w: target group ID is required", ErrInvalidTargetGroup)
+	}
+	s.logger.Info("Starting target group scan", zap.String("target_group_id", *input.TargetGroupID))
+
+	// Get target group
+	group, err := s.targetGroupRepo.Get(ctx, *input.TargetGroupID)
+	if err != nil {
+		s.logger.Error("Failed to get target group from repository", zap.Error(err), zap.String("id", *input.TargetGroupID))
+		return nil, err
+	}
+
+	// Apply the profile once for the whole group
+	if err := s.applyProfile(ctx, &input); err != nil {
+		return nil, err
+	}
+
+	// Reject the whole group up front rather than queueing part of it
+	dryRun := input.Options != nil && input.Options.DryRun
+	if s.cfg.Worker.MaxQueueDepth > 0 && !dryRun {
+		pending, err := s.scanRepo.CountByStatus(ctx, model.ScanStatusPending)
+		if err != nil {
+			s.logger.Error("Failed to count pending scans", zap.Error(err))
+			return nil, err
+		}
+		if pending+len(group.Targets) > s.cfg.Worker.MaxQueueDepth {
+			s.logger.Warn("Scan queue cannot fit target group",
+				zap.Int("pending", pending),
+				zap.Int("targets", len(group.Targets)),
+				zap.Int("max_queue_depth", s.cfg.Worker.MaxQueueDepth))
+			return nil, model.ErrQueueFull
+		}
+	}
+
+	// Start a scan per target
+	scans := make([]*model.Scan, 0, len(group.Targets))
+	for _, target := range group.Targets {
+		targetInput := input
+		targetInput.Target = target
+		targetInput.Targets = nil
+		targetInput.TargetGroupID = nil
+
+		scan, err := s.StartScan(ctx, targetInput)
+		if err != nil {
+			s.logger.Error("Failed to start target group scan",
+				zap.Error(err),
+				zap.String("target_group_id", group.ID),
+				zap.String("target", target),
+				zap.Int("started", len(scans)))
+			return nil, err
+		}
+		scans = append(scans, scan)
+	}
+
+	s.logger.Info("Started target group scan", zap.String("target_group_id", group.ID), zap.Int("scans", len(scans)))
+	return scans, nil
+}
+
+// applyProfile merges the options of the input's scan profile, if any, under
+// the input's explicit options and clears the profile reference
+func (s *scanService) applyProfile(ctx context.Context, input *model.StartScanInput) error {
+	if input.ProfileID == nil {
+		return nil
+	}
+
+	profile, err := s.profileRepo.Get(ctx, *input.ProfileID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			s.logger.Warn("Scan profile not found", zap.String("profile_id", *input.ProfileID))
+			return ErrProfileNotFound
+		}
+		s.logger.Error("Failed to get scan profile from repository", zap.Error(err), zap.String("profile_id", *input.ProfileID))
+		return err
+	}
+
+	input.Options = model.MergeScanOptions(profile.Options, input.Options)
+	input.ProfileID = nil
+	return nil
+}
+
+// dryRunScan resolves the templates a scan would run without storing the
+// scan or sending any requests to the target
+func (s *scanService) dryRunScan(ctx context.Context, input model.StartScanInput) (*model.Scan, error) {
+	targets := input.ScanTargets()
+	scan := &model.Scan{
+		ID:          uuid.New().String(),
+		Target:      targets[0],
+		Targets:     targets,
+		TemplateIDs: input.TemplateIDs,
+		WorkflowIDs: input.WorkflowIDs,
+		Tags:        input.Tags,
+		Options:     input.Options,
+		Status:      model.ScanStatusDryRun,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	scan.Results = []model.ScanResult{}
+	err := s.nucleiSvc.StartScan(ctx, scan, func(result *model.ScanResult) error {
+		scan.Results = append(scan.Results, *result)
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to dry run scan", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Dry run scan resolved templates", zap.String("id", scan.ID), zap.Int("template_count", len(scan.Results)))
+	return scan, nil
+}
+
 // DeleteScan deletes a scan
 func (s *scanService) DeleteScan(ctx context.Context, id string) (bool, error) {
 	s.logger.Info("Deleting scan", zap.String("id", id))
@@ -116,10 +312,22 @@ func (s *scanService) DeleteScan(ctx context.Context, id string) (bool, error) {
 		return false, err
 	}
 
-	// Cancel scan if running
-	if scan.Status == model.ScanStatusRunning {
-		if err := s.nucleiSvc.CancelScan(ctx, id); err != nil {
-			s.logger.Error("Failed to cancel scan", zap.Error(err))
+	// Cancel scan if it has not finished, so a worker that picked it up stops
+	// storing its outcome. A scan that finished in the meantime is deleted
+	// as it is
+	if model.ValidateTransition(scan.Status, model.ScanStatusCancelled) == nil {
+		previous := scan.Status
+		scan.Status = model.ScanStatusCancelled
+		err := s.scanRepo.UpdateStatus(ctx, scan, previous)
+		if err != nil && !errors.Is(err, repository.ErrStatusChanged) {
+			s.logger.Error("Failed to cancel scan in repository", zap.Error(err), zap.String("id", id))
+			return false, err
+		}
+		// A pending scan that changed status may have been started since
+		if previous == model.ScanStatusRunning || err != nil {
+			if err := s.nucleiSvc.CancelScan(ctx, id); err != nil {
+				s.logger.Error("Failed to cancel scan", zap.Error(err))
+			}
 		}
 	}
 
@@ -133,16 +341,231 @@ func (s *scanService) DeleteScan(ctx context.Context, id string) (bool, error) {
 	return true, nil
 }
 
-// GetScanResults returns scan results for a scan
-func (s *scanService) GetScanResults(ctx context.Context, scanID string) ([]*model.ScanResult, error) {
-	s.logger.Info("Getting scan results", zap.String("scan_id", scanID))
+// GetScanResults returns a page of scan results for a scan matching the filter
+func (s *scanService) GetScanResults(ctx context.Context, scanID string, filter model.ResultFilter, page model.Page) (*model.ListResponse[*model.ScanResult], error) {
+	s.logger.Info("Getting scan results",
+		zap.String("scan_id", scanID),
+		zap.Int("limit", page.Limit),
+		zap.Int("offset", page.Offset))
+
+	total, err := s.scanRepo.CountResults(ctx, scanID, filter)
+	if err != nil {
+		s.logger.Error("Failed to count scan results in repository", zap.Error(err), zap.String("scan_id", scanID))
+		return nil, err
+	}
 
-	results, err := s.scanRepo.GetResults(ctx, scanID)
+	results, err := s.scanRepo.GetResults(ctx, scanID, filter, page)
 	if err != nil {
 		s.logger.Error("Failed to get scan results from repository", zap.Error(err), zap.String("scan_id", scanID))
 		return nil, err
 	}
 
-	s.logger.Info("Retrieved scan results from repository", zap.String("scan_id", scanID), zap.Int("count", len(results)))
+	s.logger.Info("Retrieved scan results from repository",
+		zap.String("scan_id", scanID),
+		zap.Int("count", len(results)),
+		zap.Int("total", total))
+	if results == nil {
+		results = []*model.ScanResult{}
+	}
+	return &model.ListResponse[*model.ScanResult]{
+		Data: results,
+		Meta: model.PageMeta{
+			Total:  total,
+			Limit:  page.Limit,
+			Offset: page.Offset,
+		},
+	}, nil
+}
+
+// ExportResultsToElasticsearch indexes the scan's results, leaving out false
+// positives, and returns how many were indexed
+func (s *scanService) ExportResultsToElasticsearch(ctx context.Context, scanID string, opts export.ESExportOptions) (int, error) {
+	s.logger.Info("Exporting scan results to Elasticsearch",
+		zap.String("scan_id", scanID),
+		zap.String("index", opts.Index))
+
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+
+	scan, err := s.scanRepo.Get(ctx, scanID)
+	if err != nil {
+		return 0, err
+	}
+
+	// A running scan's results are incomplete
+	if scan.Status == model.ScanStatusRunning {
+		return 0, apperrors.ErrScanRunning
+	}
+
+	results, err := s.scanRepo.GetResults(ctx, scanID, model.ResultFilter{}, model.Page{})
+	if err != nil {
+		s.logger.Error("Failed to get scan results from repository", zap.Error(err), zap.String("scan_id", scanID))
+		return 0, err
+	}
+
+	indexed, err := export.ExportToElasticsearch(ctx, results, opts)
+	if err != nil {
+		s.logger.Error("Failed to export scan results to Elasticsearch",
+			zap.Error(err),
+			zap.String("scan_id", scanID),
+			zap.Int("indexed", indexed))
+		return indexed, err
+	}
+
+	s.logger.Info("Exported scan results to Elasticsearch",
+		zap.String("scan_id", scanID),
+		zap.Int("indexed", indexed))
+	return indexed, nil
+}
+
+// GetScanLog returns the nuclei output captured by a verbose scan. It returns
+// ErrNoScanLog if the scan has none
+func (s *scanService) GetScanLog(ctx context.Context, id string) (string, error) {
+	log, err := s.scanRepo.GetVerboseLog(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get scan log from repository", zap.Error(err), zap.String("id", id))
+		return "", err
+	}
+	if log == "" {
+		return "", ErrNoScanLog
+	}
+	return log, nil
+}
+
+// UpdateLabels merges labels into a scan's labels and returns the updated
+// scan. Labels already set are overwritten and labels not in labels are kept
+func (s *scanService) UpdateLabels(ctx context.Context, id string, labels map[string]string) (*model.Scan, error) {
+	s.logger.Info("Updating scan labels", zap.String("id", id), zap.Int("labels", len(labels)))
+
+	if err := model.ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+	if err := s.scanRepo.UpdateLabels(ctx, id, labels); err != nil {
+		s.logger.Error("Failed to update scan labels in repository", zap.Error(err), zap.String("id", id))
+		return nil, err
+	}
+
+	return s.GetScan(ctx, id)
+}
+
+// MarkResultFalsePositive marks or unmarks a scan result as a false positive.
+// Unmarking clears the note
+func (s *scanService) MarkResultFalsePositive(ctx context.Context, scanID, resultID string, fp bool, note string) error {
+	s.logger.Info("Marking scan result false positive",
+		zap.String("scan_id", scanID),
+		zap.String("result_id", resultID),
+		zap.Bool("false_positive", fp))
+
+	if !fp {
+		note = ""
+	}
+	if err := s.scanRepo.UpdateResultFalsePositive(ctx, scanID, resultID, fp, strings.TrimSpace(note)); err != nil {
+		s.logger.Error("Failed to update scan result in repository", zap.Error(err), zap.String("result_id", resultID))
+		return err
+	}
+
+	return nil
+}
+
+// CompareScans returns the findings that are new, resolved or common in scan
+// id2 relative to scan id1, matching findings by template, host and matcher
+func (s *scanService) CompareScans(ctx context.Context, id1, id2 string) (*model.ScanComparison, error) {
+	s.logger.Info("Comparing scans", zap.String("id1", id1), zap.String("id2", id2))
+
+	before, err := s.allResults(ctx, id1)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.allResults(ctx, id2)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index the baseline findings
+	baseline := make(map[resultKey]bool, len(before))
+	for _, result := range before {
+		baseline[keyOf(result)] = true
+	}
+
+	comparison := &model.ScanComparison{
+		New:      []*model.ScanResult{},
+		Resolved: []*model.ScanResult{},
+		Common:   []*model.ScanResult{},
+	}
+	current := make(map[resultKey]bool, len(after))
+	for _, result := range after {
+		key := keyOf(result)
+		current[key] = true
+		if baseline[key] {
+			comparison.Common = append(comparison.Common, result)
+		} else {
+			comparison.New = append(comparison.New, result)
+		}
+	}
+	for _, result := range before {
+		if !current[keyOf(result)] {
+			comparison.Resolved = append(comparison.Resolved, result)
+		}
+	}
+
+	s.logger.Info("Compared scans",
+		zap.String("id1", id1),
+		zap.String("id2", id2),
+		zap.Int("new", len(comparison.New)),
+		zap.Int("resolved", len(comparison.Resolved)),
+		zap.Int("common", len(comparison.Common)))
+	return comparison, nil
+}
+
+// resultKey identifies the same finding across scans
+type resultKey struct {
+	templateID  string
+	host        string
+	matcherName string
+}
+
+// keyOf returns the comparison key of a scan result
+func keyOf(result *model.ScanResult) resultKey {
+	return resultKey{
+		templateID:  result.TemplateID,
+		host:        result.Host,
+		matcherName: result.MatcherName,
+	}
+}
+
+// allResults returns every result of an existing scan
+func (s *scanService) allResults(ctx context.Context, scanID string) ([]*model.ScanResult, error) {
+	if _, err := s.scanRepo.Get(ctx, scanID); err != nil {
+		s.logger.Error("Failed to get scan from repository", zap.Error(err), zap.String("id", scanID))
+		return nil, err
+	}
+
+	// Keep false positives so a finding marked in one scan is not reported as resolved
+	filter := model.ResultFilter{IncludeFalsePositives: true}
+	total, err := s.scanRepo.CountResults(ctx, scanID, filter)
+	if err != nil {
+		s.logger.Error("Failed to count scan results in repository", zap.Error(err), zap.String("scan_id", scanID))
+		return nil, err
+	}
+
+	results, err := s.scanRepo.GetResults(ctx, scanID, filter, model.Page{Limit: total})
+	if err != nil {
+		s.logger.Error("Failed to get scan results from repository", zap.Error(err), zap.String("scan_id", scanID))
+		return nil, err
+	}
 	return results, nil
 }
+
+// GetScanStats returns aggregate scan and result statistics
+func (s *scanService) GetScanStats(ctx context.Context) (*model.ScanStats, error) {
+	s.logger.Info("Getting scan statistics")
+
+	stats, err := s.scanRepo.GetStats(ctx)
+	if err != nil {
+		s.logger.Error("Failed to get scan statistics from repository", zap.Error(err))
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+)
+
+// newTestScanService returns a scan service over repo with the given queue
+// depth limit
+func newTestScanService(t *testing.T, repo *fakeScanRepository, maxQueueDepth int) ScanService {
+	t.Helper()
+
+	cfg := newTestNucleiConfig(t)
+	cfg.Nuclei.RateLimit = 150
+	cfg.Worker.MaxQueueDepth = maxQueueDepth
+	return NewScanService(repo, nil, nil, nil, &fakeNucleiService{}, cfg, zap.NewNop())
+}
+
+// pendingScans returns n pending scans
+func pendingScans(n int) []*model.Scan {
+	scans := make([]*model.Scan, n)
+	for i := range scans {
+		scans[i] = pendingScan(fmt.Sprintf("pending-%d", i), "http://ok.example.com")
+	}
+	return scans
+}
+
+func TestStartScanQueueDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		pending  int
+		limit    int
+		wantFull bool
+	}{
+		{name: "empty queue", pending: 0, limit: 3},
+		{name: "one below the limit", pending: 2, limit: 3},
+		{name: "at the limit", pending: 3, limit: 3, wantFull: true},
+		{name: "over the limit", pending: 4, limit: 3, wantFull: true},
+		{name: "limit disabled", pending: 4, limit: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeScanRepository{scans: pendingScans(tt.pending)}
+			// a running scan does not count towards the queue
+			repo.scans = append(repo.scans, &model.Scan{ID: "running", Status: model.ScanStatusRunning})
+			svc := newTestScanService(t, repo, tt.limit)
+
+			scan, err := svc.StartScan(context.Background(), model.StartScanInput{Target: "http://example.com"})
+			if tt.wantFull {
+				if !errors.Is(err, model.ErrQueueFull) {
+					t.Fatalf("StartScan() error = %v, want ErrQueueFull", err)
+				}
+				if len(repo.scans) != tt.pending+1 {
+					t.Errorf("stored %d scans, want %d as the rejected scan is not stored", len(repo.scans), tt.pending+1)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StartScan() error = %v", err)
+			}
+			if got := repo.status(scan.ID); got != model.ScanStatusPending {
+				t.Errorf("stored scan status = %q, want %q", got, model.ScanStatusPending)
+			}
+		})
+	}
+}
+
+// finding returns a result of scan matching template on host
+func finding(scanID, id, templateID, host, matcher string) *model.ScanResult {
+	return &model.ScanResult{ID: id, ScanID: scanID, TemplateID: templateID, Host: host, MatcherName: matcher}
+}
+
+// resultIDs returns the IDs of results
+func resultIDs(results []*model.ScanResult) []string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+	return ids
+}
+
+func TestCompareScans(t *testing.T) {
+	repo := &fakeScanRepository{
+		scans: []*model.Scan{
+			{ID: "before", Status: model.ScanStatusCompleted},
+			{ID: "after", Status: model.ScanStatusCompleted},
+			{ID: "empty", Status: model.ScanStatusCompleted},
+		},
+		results: map[string][]*model.ScanResult{
+			"before": {
+				finding("before", "b-fixed", "cve-2021-1234", "http://a.example.com", ""),
+				finding("before", "b-kept", "tech-detect", "http://a.example.com", "nginx"),
+				finding("before", "b-other-matcher", "tech-detect", "http://a.example.com", "apache"),
+			},
+			"after": {
+				finding("after", "a-kept", "tech-detect", "http://a.example.com", "nginx"),
+				finding("after", "a-new-host", "cve-2021-1234", "http://b.example.com", ""),
+				finding("after", "a-new-template", "exposed-panel", "http://a.example.com", ""),
+			},
+		},
+	}
+	svc := newTestScanService(t, repo, 0)
+
+	tests := []struct {
+		name         string
+		id1, id2     string
+		wantNew      []string
+		wantResolved []string
+		wantCommon   []string
+	}{
+		{
+			name:         "known overlaps",
+			id1:          "before",
+			id2:          "after",
+			wantNew:      []string{"a-new-host", "a-new-template"},
+			wantResolved: []string{"b-fixed", "b-other-matcher"},
+			wantCommon:   []string{"a-kept"},
+		},
+		{
+			name:       "same scan",
+			id1:        "after",
+			id2:        "after",
+			wantNew:    []string{},
+			wantCommon: []string{"a-kept", "a-new-host", "a-new-template"},
+		},
+		{
+			name:         "everything resolved",
+			id1:          "before",
+			id2:          "empty",
+			wantNew:      []string{},
+			wantResolved: []string{"b-fixed", "b-kept", "b-other-matcher"},
+			wantCommon:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison, err := svc.CompareScans(context.Background(), tt.id1, tt.id2)
+			if err != nil {
+				t.Fatalf("CompareScans() error = %v", err)
+			}
+			for _, list := range []struct {
+				name string
+				got  []*model.ScanResult
+				want []string
+			}{
+				{"new", comparison.New, tt.wantNew},
+				{"resolved", comparison.Resolved, tt.wantResolved},
+				{"common", comparison.Common, tt.wantCommon},
+			} {
+				if list.want == nil {
+					list.want = []string{}
+				}
+				if got := resultIDs(list.got); !slices.Equal(got, list.want) {
+					t.Errorf("%s = %v, want %v", list.name, got, list.want)
+				}
+			}
+		})
+	}
+
+	for _, ids := range [][2]string{{"missing", "after"}, {"before", "missing"}} {
+		if _, err := svc.CompareScans(context.Background(), ids[0], ids[1]); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("CompareScans(%s, %s) error = %v, want ErrNotFound", ids[0], ids[1], err)
+		}
+	}
+}
+
+func TestStartScanDryRunIsNotStored(t *testing.T) {
+	// a full queue does not stop a dry run, which is never queued
+	repo := &fakeScanRepository{scans: pendingScans(1)}
+	svc := newTestScanService(t, repo, 1)
+
+	input := model.StartScanInput{Target: "http://example.com", Options: &model.ScanOptions{DryRun: true}}
+	scan, err := svc.StartScan(context.Background(), input)
+	if err != nil {
+		t.Fatalf("StartScan() error = %v", err)
+	}
+	if scan.Status != model.ScanStatusDryRun {
+		t.Errorf("Status = %q, want %q", scan.Status, model.ScanStatusDryRun)
+	}
+	if len(repo.scans) != 1 {
+		t.Errorf("stored %d scans, want only the pending one", len(repo.scans))
+	}
+}
+
+// fakeProfileRepository serves scan profiles by ID. Methods a test does not
+// use panic through the nil embedded interface
+type fakeProfileRepository struct {
+	repository.ProfileRepository
+
+	profiles map[string]*model.ScanProfile
+}
+
+// Get returns the profile with the given ID
+func (f *fakeProfileRepository) Get(ctx context.Context, id string) (*model.ScanProfile, error) {
+	profile, ok := f.profiles[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return profile, nil
+}
+
+func TestStartScanAppliesProfile(t *testing.T) {
+	profiles := &fakeProfileRepository{profiles: map[string]*model.ScanProfile{
+		"stealth": {ID: "stealth", Name: "Stealth", Options: model.ScanOptions{Concurrency: 2, RateLimit: 5, Timeout: 900, Retries: 3}},
+	}}
+	repo := &fakeScanRepository{}
+	cfg := newTestNucleiConfig(t)
+	svc := NewScanService(repo, nil, nil, profiles, &fakeNucleiService{}, cfg, zap.NewNop())
+
+	profileID := "stealth"
+	scan, err := svc.StartScan(context.Background(), model.StartScanInput{
+		Target:    "http://example.com",
+		ProfileID: &profileID,
+		Options:   &model.ScanOptions{RateLimit: 20},
+	})
+	if err != nil {
+		t.Fatalf("StartScan() error = %v", err)
+	}
+	want := model.ScanOptions{Concurrency: 2, RateLimit: 20, Timeout: 900, Retries: 3}
+	if scan.Options == nil || !reflect.DeepEqual(*scan.Options, want) {
+		t.Errorf("Options = %+v, want the profile with the explicit rate limit %+v", scan.Options, want)
+	}
+
+	missing := "missing"
+	_, err = svc.StartScan(context.Background(), model.StartScanInput{Target: "http://example.com", ProfileID: &missing})
+	if !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("StartScan() with a missing profile error = %v, want ErrProfileNotFound", err)
+	}
+	if len(repo.scans) != 1 {
+		t.Errorf("stored %d scans, want only the one with a profile", len(repo.scans))
+	}
+}
+
+func TestStartScanVerboseRequiresSingleScanWorker(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxConcurrency int
+		dryRun         bool
+		wantErr        error
+	}{
+		{name: "one scan at a time", maxConcurrency: 1},
+		{name: "concurrent worker", maxConcurrency: 4, wantErr: model.ErrInvalidScanOptions},
+		{name: "dry run on a concurrent worker", maxConcurrency: 4, dryRun: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestNucleiConfig(t)
+			cfg.Nuclei.RateLimit = 150
+			cfg.Worker.MaxConcurrency = tt.maxConcurrency
+			scans := NewScanService(&fakeScanRepository{}, nil, nil, nil, &fakeNucleiService{}, cfg, zap.NewNop())
+
+			_, err := scans.StartScan(context.Background(), model.StartScanInput{
+				Target:  "http://ok.example.com",
+				Options: &model.ScanOptions{Verbose: true, DryRun: tt.dryRun},
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("StartScan() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStartScanWithTwoTargets(t *testing.T) {
+	repo := &fakeScanRepository{}
+	svc := newTestScanService(t, repo, 0)
+
+	targets := []string{"http://a.example.com", "http://b.example.com"}
+	scan, err := svc.StartScan(context.Background(), model.StartScanInput{Targets: targets})
+	if err != nil {
+		t.Fatalf("StartScan() error = %v", err)
+	}
+	if scan.Target != targets[0] || !slices.Equal(scan.Targets, targets) {
+		t.Errorf("target %q and targets %v, want %q and %v", scan.Target, scan.Targets, targets[0], targets)
+	}
+	if len(repo.scans) != 1 {
+		t.Errorf("stored %d scans, want one scan for both targets", len(repo.scans))
+	}
+}
+
+func TestListScansPageMeta(t *testing.T) {
+	repo := &fakeScanRepository{scans: pendingScans(3)}
+	repo.scans[0].Status = model.ScanStatusCompleted
+	scans := newTestScanService(t, repo, 0)
+
+	pending := model.ScanStatusPending
+	list, err := scans.ListScans(context.Background(), &pending, nil, nil, nil, model.Page{Limit: 50, Offset: 10})
+	if err != nil {
+		t.Fatalf("ListScans() error = %v", err)
+	}
+	if want := (model.PageMeta{Total: 2, Limit: 50, Offset: 10}); list.Meta != want {
+		t.Errorf("Meta = %+v, want %+v", list.Meta, want)
+	}
+	if len(list.Data) != 2 {
+		t.Errorf("Data has %d scans, want 2", len(list.Data))
+	}
+}
+
+func TestGetScanResultsPageMeta(t *testing.T) {
+	repo := &fakeScanRepository{results: map[string][]*model.ScanResult{
+		"scan-1": {finding("scan-1", "a", "tech-detect", "http://a.example.com", ""), finding("scan-1", "b", "cve-2021-1234", "http://a.example.com", "")},
+	}}
+	scans := newTestScanService(t, repo, 0)
+
+	tests := []struct {
+		name      string
+		scanID    string
+		wantTotal int
+	}{
+		{name: "results", scanID: "scan-1", wantTotal: 2},
+		{name: "no results", scanID: "scan-2", wantTotal: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := model.Page{Limit: 25, Offset: 0}
+			results, err := scans.GetScanResults(context.Background(), tt.scanID, model.ResultFilter{}, page)
+			if err != nil {
+				t.Fatalf("GetScanResults() error = %v", err)
+			}
+			if want := (model.PageMeta{Total: tt.wantTotal, Limit: 25}); results.Meta != want {
+				t.Errorf("Meta = %+v, want %+v", results.Meta, want)
+			}
+			// An empty page is encoded as [] rather than null
+			if results.Data == nil || len(results.Data) != tt.wantTotal {
+				t.Errorf("Data = %v, want %d results", results.Data, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestDeleteScanCancelsWorkerEngine(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	nuclei := newFakeEngineService(t, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return nil
+	})
+
+	// The worker and the scan service share one nuclei service, as in main
+	repo := &fakeScanRepository{scans: []*model.Scan{pendingScan("running", "http://ok.example.com")}}
+	cfg := newTestNucleiConfig(t)
+	cfg.Worker.MaxConcurrency = 1
+	worker := NewScanWorker(repo, nuclei, cfg, zap.NewNop())
+	scans := NewScanService(repo, nil, nil, nil, nuclei, cfg, zap.NewNop())
+
+	processed := make(chan error, 1)
+	go func() { processed <- worker.processPendingScans(context.Background()) }()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the worker did not start the scan")
+	}
+
+	if _, err := scans.DeleteScan(context.Background(), "running"); err != nil {
+		t.Fatalf("DeleteScan() error = %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deleting the running scan did not cancel its engine")
+	}
+	if err := <-processed; err != nil {
+		t.Fatalf("processPendingScans() error = %v", err)
+	}
+}
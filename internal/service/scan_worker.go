@@ -2,29 +2,85 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"nuclei-service-demo/internal/config"
 	"nuclei-service-demo/internal/model"
 	"nuclei-service-demo/internal/repository"
+	"nuclei-service-demo/internal/telemetry"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// defaultHeartbeatInterval is how often a running scan records a heartbeat
+// when none is configured
+const defaultHeartbeatInterval = 30 * time.Second
+
+// resultFlushSize is how many streamed results a scan buffers before storing
+// them with one multi-row insert
+const resultFlushSize = 100
+
 // ScanWorker handles background processing of pending scans
 type ScanWorker struct {
-	scanRepo      repository.ScanRepository
-	nucleiSvc     NucleiServiceInterface
-	logger        *zap.Logger
-	checkInterval time.Duration
+	scanRepo       repository.ScanRepository
+	nucleiSvc      NucleiServiceInterface
+	logger         *zap.Logger
+	checkInterval  time.Duration
+	maxConcurrency int
+	heartbeat      time.Duration
+	// maxHTTPBytes caps the stored request and response of each result
+	maxHTTPBytes int
+
+	// paused stops pending scans from being picked up until resumed
+	paused atomic.Bool
+	// active counts the scans currently running
+	active atomic.Int64
+	// processed counts the scans this worker completed or failed since
+	// start, and failed the ones it failed; scans whose outcome could not be
+	// stored are not counted
+	processed atomic.Int64
+	failed    atomic.Int64
+
+	// mu guards stopping so no scan is started once Stop has begun waiting,
+	// and lastRun
+	mu       sync.Mutex
+	stopping bool
+	lastRun  *time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+	inFlight sync.WaitGroup
 }
 
 // NewScanWorker creates a new scan worker
-func NewScanWorker(scanRepo repository.ScanRepository, nucleiSvc NucleiServiceInterface, logger *zap.Logger) *ScanWorker {
+func NewScanWorker(scanRepo repository.ScanRepository, nucleiSvc NucleiServiceInterface, cfg *config.Config, logger *zap.Logger) *ScanWorker {
+	checkInterval := cfg.Worker.CheckInterval
+	if checkInterval <= 0 {
+		checkInterval = 20 * time.Second
+	}
+	maxConcurrency := cfg.Worker.MaxConcurrency
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+	heartbeat := cfg.Worker.HeartbeatInterval
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeatInterval
+	}
+
 	return &ScanWorker{
-		scanRepo:      scanRepo,
-		nucleiSvc:     nucleiSvc,
-		logger:        logger,
-		checkInterval: 20 * time.Second,
+		scanRepo:       scanRepo,
+		nucleiSvc:      nucleiSvc,
+		logger:         logger,
+		checkInterval:  checkInterval,
+		maxConcurrency: maxConcurrency,
+		heartbeat:      heartbeat,
+		maxHTTPBytes:   cfg.Nuclei.MaxHTTPStorageBytes,
+		stop:           make(chan struct{}),
 	}
 }
 
@@ -35,6 +91,7 @@ func (w *ScanWorker) Start(ctx context.Context) {
 
 	w.logger.Info("Starting scan worker",
 		zap.Duration("interval", w.checkInterval),
+		zap.Int("max_concurrency", w.maxConcurrency),
 	)
 
 	for {
@@ -42,6 +99,9 @@ func (w *ScanWorker) Start(ctx context.Context) {
 		case <-ctx.Done():
 			w.logger.Info("Stopping scan worker")
 			return
+		case <-w.stop:
+			w.logger.Info("Stopping scan worker")
+			return
 		case <-ticker.C:
 			if err := w.processPendingScans(ctx); err != nil {
 				w.logger.Error("Error processing pending scans",
@@ -52,75 +112,357 @@ func (w *ScanWorker) Start(ctx context.Context) {
 	}
 }
 
+// Pause stops the worker from starting pending scans; running scans finish
+func (w *ScanWorker) Pause() {
+	if !w.paused.Swap(true) {
+		w.logger.Info("Scan worker paused")
+	}
+}
+
+// Resume lets the worker start pending scans again
+func (w *ScanWorker) Resume() {
+	if w.paused.Swap(false) {
+		w.logger.Info("Scan worker resumed")
+	}
+}
+
+// Status returns whether the worker is paused, how many scans it is running
+// and when it last checked for pending scans
+func (w *ScanWorker) Status() model.WorkerStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return model.WorkerStatus{
+		Paused:      w.paused.Load(),
+		ActiveScans: int(w.active.Load()),
+		LastRun:     w.lastRun,
+	}
+}
+
+// GetWorkerStats returns the worker's counters since it was created
+func (w *ScanWorker) GetWorkerStats() model.WorkerStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return model.WorkerStats{
+		ActiveScans:    int(w.active.Load()),
+		TotalProcessed: w.processed.Load(),
+		TotalFailed:    w.failed.Load(),
+		LastRunAt:      w.lastRun,
+	}
+}
+
 // processPendingScans processes all pending scans
 func (w *ScanWorker) processPendingScans(ctx context.Context) error {
-	// Get pending scans
+	if w.paused.Load() {
+		return nil
+	}
+
+	now := time.Now()
+	w.mu.Lock()
+	w.lastRun = &now
+	w.mu.Unlock()
+
+	// Get pending scans, highest priority first so they take the first
+	// worker slots
 	status := model.ScanStatusPending
-	scans, err := w.scanRepo.List(ctx, &status, nil, nil)
+	scans, err := w.scanRepo.List(ctx, &status, nil, nil, nil, model.ScanOrderPriority, model.Page{})
 	if err != nil {
 		return err
 	}
 
+	// Run scans concurrently, bounded by the worker pool size
+	sem := make(chan struct{}, w.maxConcurrency)
+	var wg sync.WaitGroup
 	for _, scan := range scans {
-		// Update scan status to running
-		scan.Status = "running"
-		if err := w.scanRepo.Update(ctx, scan); err != nil {
-			w.logger.Error("Failed to update scan status",
-				zap.Error(err),
+		sem <- struct{}{}
+		if w.paused.Load() || !w.acquire() {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(scan *model.Scan) {
+			defer wg.Done()
+			defer w.inFlight.Done()
+			defer func() { <-sem }()
+			w.active.Add(1)
+			defer w.active.Add(-1)
+			w.processScan(ctx, scan)
+		}(scan)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// acquire registers a scan as in flight, failing once the worker is stopping
+func (w *ScanWorker) acquire() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopping {
+		return false
+	}
+	w.inFlight.Add(1)
+	return true
+}
+
+// Stop stops the worker from starting new scans and waits for in-flight scans
+// to finish, returning the context's error if its deadline passes first
+func (w *ScanWorker) Stop(ctx context.Context) error {
+	w.mu.Lock()
+	w.stopping = true
+	w.mu.Unlock()
+	w.stopOnce.Do(func() { close(w.stop) })
+
+	w.logger.Info("Waiting for in-flight scans to finish")
+
+	done := make(chan struct{})
+	go func() {
+		w.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		w.logger.Info("All in-flight scans finished")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// processScan runs a single pending scan and stores its outcome
+func (w *ScanWorker) processScan(ctx context.Context, scan *model.Scan) {
+	ctx, span := tracer.Start(ctx, "ScanWorker.processScan", trace.WithAttributes(attribute.String("scan.id", scan.ID)))
+	defer span.End()
+	logger := telemetry.Logger(ctx, w.logger)
+
+	// Update scan status to running
+	if err := model.ValidateTransition(scan.Status, model.ScanStatusRunning); err != nil {
+		logger.Error("Refusing to start scan",
+			zap.Error(err),
+			zap.String("scan_id", scan.ID),
+		)
+		return
+	}
+	scan.Status = model.ScanStatusRunning
+	if err := w.scanRepo.UpdateStatus(ctx, scan, model.ScanStatusPending); err != nil {
+		if errors.Is(err, repository.ErrStatusChanged) {
+			logger.Info("Skipping scan that is no longer pending",
 				zap.String("scan_id", scan.ID),
 			)
-			continue
+			return
 		}
+		logger.Error("Failed to update scan status",
+			zap.Error(err),
+			zap.String("scan_id", scan.ID),
+		)
+		return
+	}
+
+	// Store the outcome even if the worker context is cancelled while the
+	// scan runs, so found results are kept and a finished scan is never left
+	// running
+	storeCtx := context.WithoutCancel(ctx)
 
-		// Start scan
-		results, err := w.nucleiSvc.StartScan(ctx, scan)
+	// Record heartbeats until the scan finishes so a stalled scan can be
+	// told apart from a long one
+	stopHeartbeat := w.startHeartbeat(storeCtx, scan.ID)
+	defer stopHeartbeat()
+
+	// Run the scan, storing results in batches as they are found; the last
+	// batch is stored with the completed status. Deliveries are serialised,
+	// so the buffer needs no lock
+	var pending []*model.ScanResult
+	stored, skipped := 0, 0
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		inserted, err := w.scanRepo.AddResultsBatch(storeCtx, nil, pending)
 		if err != nil {
-			w.logger.Error("Failed to start scan",
+			return fmt.Errorf("failed to add results: %w", err)
+		}
+		stored += inserted
+		skipped += len(pending) - inserted
+		pending = nil
+		return nil
+	}
+	err := w.nucleiSvc.StartScan(ctx, scan, func(result *model.ScanResult) error {
+		result.TruncateStoredHTTP(w.maxHTTPBytes)
+		pending = append(pending, result)
+		if len(pending) < resultFlushSize {
+			return nil
+		}
+		return flush()
+	})
+	if scan.VerboseLog != "" {
+		if err := w.scanRepo.SaveVerboseLog(storeCtx, scan.ID, scan.VerboseLog); err != nil {
+			logger.Warn("Failed to store scan verbose log",
 				zap.Error(err),
 				zap.String("scan_id", scan.ID),
 			)
-			scan.Status = "failed"
-			scan.Error = err.Error()
-			if err := w.scanRepo.Update(ctx, scan); err != nil {
-				w.logger.Error("Failed to update scan status",
-					zap.Error(err),
-					zap.String("scan_id", scan.ID),
-				)
-			}
-			continue
 		}
+	}
+	if err != nil {
+		// A failed scan keeps the results it found
+		if flushErr := flush(); flushErr != nil {
+			logger.Warn("Failed to store results of failed scan",
+				zap.Error(flushErr),
+				zap.String("scan_id", scan.ID),
+			)
+		}
+		logger.Error("Scan failed",
+			zap.Error(err),
+			zap.String("scan_id", scan.ID),
+			zap.Int("stored_results", stored),
+		)
+		w.failScan(storeCtx, scan, err)
+		return
+	}
 
-		w.logger.Info("Scan completed",
+	// Store the last batch and mark the scan completed
+	inserted, err := w.completeScan(storeCtx, scan, pending)
+	if err != nil {
+		if errors.Is(err, repository.ErrStatusChanged) {
+			logger.Info("Scan was cancelled or failed before it completed",
+				zap.String("scan_id", scan.ID),
+			)
+			return
+		}
+		logger.Error("Failed to complete scan",
+			zap.Error(err),
 			zap.String("scan_id", scan.ID),
-			zap.Int("result_count", len(results)),
 		)
+		if flushErr := flush(); flushErr != nil {
+			logger.Warn("Failed to store results of failed scan",
+				zap.Error(flushErr),
+				zap.String("scan_id", scan.ID),
+			)
+		}
+		w.failScan(storeCtx, scan, err)
+		return
+	}
+	stored += inserted
+	skipped += len(pending) - inserted
+	w.processed.Add(1)
 
-		w.logger.Info("Scan results",
+	logger.Info("Scan completed",
+		zap.String("scan_id", scan.ID),
+		zap.Int("result_count", stored),
+	)
+	if skipped > 0 {
+		logger.Info("Skipped duplicate scan results",
 			zap.String("scan_id", scan.ID),
-			zap.Any("results", results),
+			zap.Int("skipped", skipped),
 		)
+	}
+}
 
-		// Store results
-		for _, result := range results {
-			if err := w.scanRepo.AddResult(ctx, result); err != nil {
-				w.logger.Error("Failed to store scan result",
-					zap.Error(err),
-					zap.String("scan_id", scan.ID),
-					zap.String("result_id", result.ID),
-				)
-				continue
+// startHeartbeat records a heartbeat for the scan now and then every
+// heartbeat interval until the returned function is called
+func (w *ScanWorker) startHeartbeat(ctx context.Context, scanID string) func() {
+	beat := func() {
+		if err := w.scanRepo.Heartbeat(ctx, scanID); err != nil {
+			w.logger.Warn("Failed to record scan heartbeat",
+				zap.Error(err),
+				zap.String("scan_id", scanID),
+			)
+		}
+	}
+	beat()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(w.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				beat()
 			}
 		}
+	}()
 
-		// Update scan status to completed
-		scan.Status = "completed"
-		if err := w.scanRepo.Update(ctx, scan); err != nil {
-			w.logger.Error("Failed to update scan status",
-				zap.Error(err),
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// failScan marks a running scan as failed with the given error
+func (w *ScanWorker) failScan(ctx context.Context, scan *model.Scan, scanErr error) {
+	if err := model.ValidateTransition(scan.Status, model.ScanStatusFailed); err != nil {
+		w.logger.Error("Refusing to fail scan",
+			zap.Error(err),
+			zap.String("scan_id", scan.ID),
+		)
+		return
+	}
+
+	previous := scan.Status
+	scan.Status = model.ScanStatusFailed
+	scan.Error = scanErr.Error()
+	if err := w.scanRepo.UpdateStatus(ctx, scan, previous); err != nil {
+		if errors.Is(err, repository.ErrStatusChanged) {
+			w.logger.Info("Scan was cancelled or failed before it could be failed",
 				zap.String("scan_id", scan.ID),
 			)
+			return
 		}
+		w.logger.Error("Failed to update scan status",
+			zap.Error(err),
+			zap.String("scan_id", scan.ID),
+		)
+		return
 	}
+	w.processed.Add(1)
+	w.failed.Add(1)
+}
 
-	return nil
+// completeScan stores the last results of a running scan, summarizes its
+// results by severity and marks it completed in a single transaction, so a
+// completed scan always has all its results. It returns how many of results
+// were inserted
+func (w *ScanWorker) completeScan(ctx context.Context, scan *model.Scan, results []*model.ScanResult) (int, error) {
+	if err := model.ValidateTransition(scan.Status, model.ScanStatusCompleted); err != nil {
+		return 0, err
+	}
+
+	tx, err := w.scanRepo.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	inserted, err := w.scanRepo.AddResultsBatch(ctx, tx, results)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add results: %w", err)
+	}
+
+	// The summary saves clients from fetching every result
+	summary, err := w.scanRepo.GetResultSummaryWithTx(ctx, tx, scan.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to summarize results: %w", err)
+	}
+
+	previous := scan.Status
+	scan.Status = model.ScanStatusCompleted
+	scan.ResultSummary = summary
+	if err := w.scanRepo.UpdateStatusWithTx(ctx, tx, scan, previous); err != nil {
+		scan.Status, scan.ResultSummary = previous, nil
+		return 0, fmt.Errorf("failed to update scan status: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		scan.Status, scan.ResultSummary = previous, nil
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return inserted, nil
 }
@@ -0,0 +1,662 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+)
+
+// fakeScanRepository keeps scans and their verbose logs in memory for the
+// scan worker, and results seeded by scan ID. statusErrs fails the status
+// update of a scan ID to a status, keyed "id:status". Results and status
+// updates made in a transaction are kept apart until it commits; batches
+// records the size of each batch stored outside one. Methods a test does not
+// use panic through the nil embedded interface
+type fakeScanRepository struct {
+	repository.ScanRepository
+
+	mu          sync.Mutex
+	scans       []*model.Scan
+	results     map[string][]*model.ScanResult
+	statusErrs  map[string]error
+	verboseLogs map[string]string
+	batches     []int
+	txs         map[*sql.Tx]*fakeTx
+}
+
+// fakeTx holds the results and status updates of an open transaction
+type fakeTx struct {
+	results []*model.ScanResult
+	updates []func()
+}
+
+// fakeTxConnector opens connections whose transactions report whether they
+// were committed to done
+type fakeTxConnector struct {
+	done func(committed bool)
+}
+
+func (c fakeTxConnector) Connect(context.Context) (driver.Conn, error) { return fakeTxConn(c), nil }
+func (c fakeTxConnector) Driver() driver.Driver                        { return nil }
+
+type fakeTxConn struct {
+	done func(committed bool)
+}
+
+func (c fakeTxConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeTxConn) Close() error                        { return nil }
+func (c fakeTxConn) Begin() (driver.Tx, error)           { return fakeTxDriverTx(c), nil }
+
+type fakeTxDriverTx struct {
+	done func(committed bool)
+}
+
+func (t fakeTxDriverTx) Commit() error   { t.done(true); return nil }
+func (t fakeTxDriverTx) Rollback() error { t.done(false); return nil }
+
+// BeginTx starts a transaction on its own connection, applied to the
+// repository when it commits
+func (f *fakeScanRepository) BeginTx(ctx context.Context) (*sql.Tx, error) {
+	var tx *sql.Tx
+	db := sql.OpenDB(fakeTxConnector{done: func(committed bool) { f.endTx(tx, committed) }})
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.txs == nil {
+		f.txs = make(map[*sql.Tx]*fakeTx)
+	}
+	f.txs[tx] = &fakeTx{}
+	return tx, nil
+}
+
+// endTx applies a committed transaction and discards a rolled back one
+func (f *fakeScanRepository) endTx(tx *sql.Tx, committed bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state := f.txs[tx]
+	delete(f.txs, tx)
+	if !committed || state == nil {
+		return
+	}
+	f.storeResults(state.results)
+	for _, update := range state.updates {
+		update()
+	}
+}
+
+// storeResults stores results by scan ID; f.mu must be held
+func (f *fakeScanRepository) storeResults(results []*model.ScanResult) {
+	if f.results == nil {
+		f.results = make(map[string][]*model.ScanResult)
+	}
+	for _, result := range results {
+		f.results[result.ScanID] = append(f.results[result.ScanID], result)
+	}
+}
+
+// Get returns a copy of the stored scan
+func (f *fakeScanRepository) Get(ctx context.Context, id string) (*model.Scan, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, scan := range f.scans {
+		if scan.ID == id {
+			c := *scan
+			return &c, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+// CountResults returns the number of seeded results of the scan
+func (f *fakeScanRepository) CountResults(ctx context.Context, scanID string, filter model.ResultFilter) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.results[scanID]), nil
+}
+
+// GetResults returns the seeded results of the scan, ignoring the filter
+func (f *fakeScanRepository) GetResults(ctx context.Context, scanID string, filter model.ResultFilter, page model.Page) ([]*model.ScanResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.results[scanID], nil
+}
+
+// List returns copies of the stored scans with the given status, in the
+// order they were stored
+func (f *fakeScanRepository) List(ctx context.Context, status, target, templateID *string, tags []string, order model.ScanOrder, page model.Page) ([]*model.Scan, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var scans []*model.Scan
+	for _, scan := range f.scans {
+		if status == nil || scan.Status == *status {
+			c := *scan
+			scans = append(scans, &c)
+		}
+	}
+	return scans, nil
+}
+
+// UpdateStatus stores the scan's status if the stored one is still from.
+// Like a database call, it fails once ctx is cancelled
+func (f *fakeScanRepository) UpdateStatus(ctx context.Context, scan *model.Scan, from model.ScanStatus) error {
+	return f.updateStatus(ctx, nil, scan, from)
+}
+
+// UpdateStatusWithTx checks the scan's status like UpdateStatus and stores
+// it when tx commits
+func (f *fakeScanRepository) UpdateStatusWithTx(ctx context.Context, tx *sql.Tx, scan *model.Scan, from model.ScanStatus) error {
+	return f.updateStatus(ctx, tx, scan, from)
+}
+
+func (f *fakeScanRepository) updateStatus(ctx context.Context, tx *sql.Tx, scan *model.Scan, from model.ScanStatus) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.statusErrs[scan.ID+":"+scan.Status]; err != nil {
+		return err
+	}
+	for _, stored := range f.scans {
+		if stored.ID != scan.ID {
+			continue
+		}
+		if stored.Status != from {
+			return repository.ErrStatusChanged
+		}
+		update := func() {
+			stored.Status = scan.Status
+			stored.Error = scan.Error
+		}
+		if tx == nil {
+			update()
+		} else {
+			f.txs[tx].updates = append(f.txs[tx].updates, update)
+		}
+		return nil
+	}
+	return repository.ErrNotFound
+}
+
+// Delete removes the scan
+func (f *fakeScanRepository) Delete(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, scan := range f.scans {
+		if scan.ID == id {
+			f.scans = append(f.scans[:i], f.scans[i+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+// status returns the stored status of the scan
+func (f *fakeScanRepository) status(id string) model.ScanStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, scan := range f.scans {
+		if scan.ID == id {
+			return scan.Status
+		}
+	}
+	return ""
+}
+
+// Heartbeat does nothing
+func (f *fakeScanRepository) Heartbeat(ctx context.Context, id string) error {
+	return nil
+}
+
+// AddResultsBatch stores every result, or keeps them for tx, failing once
+// ctx is cancelled
+func (f *fakeScanRepository) AddResultsBatch(ctx context.Context, tx *sql.Tx, results []*model.ScanResult) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if tx != nil {
+		f.txs[tx].results = append(f.txs[tx].results, results...)
+		return len(results), nil
+	}
+	f.batches = append(f.batches, len(results))
+	f.storeResults(results)
+	return len(results), nil
+}
+
+// SaveVerboseLog stores the scan's verbose log
+func (f *fakeScanRepository) SaveVerboseLog(ctx context.Context, id, log string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.verboseLogs == nil {
+		f.verboseLogs = make(map[string]string)
+	}
+	f.verboseLogs[id] = log
+	return nil
+}
+
+// GetVerboseLog returns the scan's stored verbose log
+func (f *fakeScanRepository) GetVerboseLog(ctx context.Context, id string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.verboseLogs[id], nil
+}
+
+// Create stores the scan
+func (f *fakeScanRepository) Create(ctx context.Context, scan *model.Scan) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.scans = append(f.scans, scan)
+	return nil
+}
+
+// CountByStatus returns the number of stored scans with the given status
+func (f *fakeScanRepository) CountByStatus(ctx context.Context, status string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := 0
+	for _, scan := range f.scans {
+		if scan.Status == status {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Count returns the number of stored scans with the given status
+func (f *fakeScanRepository) Count(ctx context.Context, status, target, templateID *string, tags []string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := 0
+	for _, scan := range f.scans {
+		if status == nil || scan.Status == *status {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetResultSummaryWithTx returns an empty summary
+func (f *fakeScanRepository) GetResultSummaryWithTx(ctx context.Context, tx *sql.Tx, scanID string) (*model.ResultSummary, error) {
+	return &model.ResultSummary{}, nil
+}
+
+// fakeNucleiService finishes scans immediately, failing those whose target
+// has an error in errs and giving verbose scans verboseLog. If ran is set,
+// the ID of each scan is sent on it, and if release is set, scans block
+// until it is closed. Each scan then reports a copy of every result in
+// results. Methods a test does not use panic through the nil embedded
+// interface
+type fakeNucleiService struct {
+	NucleiServiceInterface
+
+	errs       map[string]error
+	verboseLog string
+	ran        chan string
+	release    chan struct{}
+	results    []*model.ScanResult
+}
+
+// StartScan returns the error set for the scan's target
+func (f *fakeNucleiService) StartScan(ctx context.Context, scan *model.Scan, onResult func(*model.ScanResult) error) error {
+	if f.ran != nil {
+		f.ran <- scan.ID
+	}
+	if f.release != nil {
+		<-f.release
+	}
+	for _, result := range f.results {
+		r := *result
+		r.ScanID = scan.ID
+		if err := onResult(&r); err != nil {
+			return err
+		}
+	}
+	if scan.Options != nil && scan.Options.Verbose {
+		scan.VerboseLog = f.verboseLog
+	}
+	return f.errs[scan.Target]
+}
+
+// newTestWorker returns a worker running one scan at a time
+func newTestWorker(repo *fakeScanRepository, nuclei *fakeNucleiService) *ScanWorker {
+	cfg := &config.Config{}
+	cfg.Worker.MaxConcurrency = 1
+	return NewScanWorker(repo, nuclei, cfg, zap.NewNop())
+}
+
+// pendingScan returns a pending scan of target
+func pendingScan(id, target string) *model.Scan {
+	return &model.Scan{ID: id, Target: target, Targets: []string{target}, Status: model.ScanStatusPending}
+}
+
+func TestScanWorkerCounters(t *testing.T) {
+	repo := &fakeScanRepository{
+		scans: []*model.Scan{
+			pendingScan("completes", "http://ok.example.com"),
+			pendingScan("fails", "http://broken.example.com"),
+			pendingScan("taken", "http://ok.example.com"),
+			pendingScan("unstored", "http://broken.example.com"),
+		},
+		statusErrs: map[string]error{
+			// another worker picked the scan up first
+			"taken:" + model.ScanStatusRunning: repository.ErrStatusChanged,
+			// the failure could not be stored
+			"unstored:" + model.ScanStatusFailed: errors.New("database is down"),
+		},
+	}
+	nuclei := &fakeNucleiService{errs: map[string]error{
+		"http://broken.example.com": errors.New("nuclei execution failed"),
+	}}
+	worker := newTestWorker(repo, nuclei)
+
+	if err := worker.processPendingScans(context.Background()); err != nil {
+		t.Fatalf("processPendingScans() error = %v", err)
+	}
+
+	stats := worker.GetWorkerStats()
+	if stats.TotalProcessed != 2 {
+		t.Errorf("TotalProcessed = %d, want 2 (the completed and the failed scan)", stats.TotalProcessed)
+	}
+	if stats.TotalFailed != 1 {
+		t.Errorf("TotalFailed = %d, want 1 (only the failure that was stored)", stats.TotalFailed)
+	}
+	if stats.ActiveScans != 0 {
+		t.Errorf("ActiveScans = %d, want 0", stats.ActiveScans)
+	}
+	if stats.LastRunAt == nil {
+		t.Error("LastRunAt = nil, want the time of the run")
+	}
+
+	want := map[string]model.ScanStatus{
+		"completes": model.ScanStatusCompleted,
+		"fails":     model.ScanStatusFailed,
+		"unstored":  model.ScanStatusRunning,
+	}
+	for id, status := range want {
+		if got := repo.status(id); got != status {
+			t.Errorf("scan %s status = %q, want %q", id, got, status)
+		}
+	}
+
+	// A second run finds no pending scans and leaves the counters alone
+	if err := worker.processPendingScans(context.Background()); err != nil {
+		t.Fatalf("processPendingScans() error = %v", err)
+	}
+	if again := worker.GetWorkerStats(); again.TotalProcessed != 2 || again.TotalFailed != 1 {
+		t.Errorf("after a second run processed = %d and failed = %d, want 2 and 1", again.TotalProcessed, again.TotalFailed)
+	}
+}
+
+func TestScanWorkerStartChecksAtInterval(t *testing.T) {
+	repo := &fakeScanRepository{scans: []*model.Scan{pendingScan("queued", "http://ok.example.com")}}
+	nuclei := &fakeNucleiService{ran: make(chan string, 1)}
+	cfg := &config.Config{}
+	cfg.Worker.CheckInterval = time.Millisecond
+	worker := NewScanWorker(repo, nuclei, cfg, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go worker.Start(ctx)
+
+	select {
+	case id := <-nuclei.ran:
+		if id != "queued" {
+			t.Errorf("ran scan %q, want queued", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the worker did not pick up the pending scan")
+	}
+
+	if err := worker.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if got := repo.status("queued"); got != model.ScanStatusCompleted {
+		t.Errorf("scan status = %q, want %q", got, model.ScanStatusCompleted)
+	}
+}
+
+func TestScanWorkerStopWaitsForInFlightScans(t *testing.T) {
+	repo := &fakeScanRepository{scans: []*model.Scan{pendingScan("slow", "http://ok.example.com")}}
+	nuclei := &fakeNucleiService{ran: make(chan string, 1), release: make(chan struct{})}
+	worker := newTestWorker(repo, nuclei)
+
+	processed := make(chan error, 1)
+	go func() { processed <- worker.processPendingScans(context.Background()) }()
+	select {
+	case <-nuclei.ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the worker did not start the slow scan")
+	}
+
+	// The scan is still running, so Stop gives up at the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := worker.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stop() with the scan running error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := repo.status("slow"); got != model.ScanStatusRunning {
+		t.Errorf("scan status while stopping = %q, want %q", got, model.ScanStatusRunning)
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- worker.Stop(context.Background()) }()
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop() returned %v before the scan finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(nuclei.release)
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("Stop() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop() did not return after the scan finished")
+	}
+	if err := <-processed; err != nil {
+		t.Fatalf("processPendingScans() error = %v", err)
+	}
+	if got := repo.status("slow"); got != model.ScanStatusCompleted {
+		t.Errorf("scan status = %q, want %q", got, model.ScanStatusCompleted)
+	}
+}
+
+func TestScanWorkerStoresOutcomeAfterCancellation(t *testing.T) {
+	repo := &fakeScanRepository{scans: []*model.Scan{pendingScan("cancelled", "http://ok.example.com")}}
+	nuclei := &fakeNucleiService{
+		ran:     make(chan string, 1),
+		release: make(chan struct{}),
+		results: []*model.ScanResult{{ID: "result-1", TemplateID: "tech-detect", Severity: "info", Matched: true}},
+	}
+	worker := newTestWorker(repo, nuclei)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	processed := make(chan error, 1)
+	go func() { processed <- worker.processPendingScans(ctx) }()
+	select {
+	case <-nuclei.ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the worker did not start the scan")
+	}
+
+	// The worker is cancelled while the scan runs, which then finishes
+	cancel()
+	close(nuclei.release)
+	<-processed
+	if err := worker.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	if got := repo.status("cancelled"); got != model.ScanStatusCompleted {
+		t.Errorf("scan status = %q, want %q", got, model.ScanStatusCompleted)
+	}
+	if results := repo.results["cancelled"]; len(results) != 1 {
+		t.Errorf("stored %d results, want the result found before the scan finished", len(results))
+	}
+}
+
+func TestScanWorkerPauseAndResume(t *testing.T) {
+	repo := &fakeScanRepository{}
+	worker := newTestWorker(repo, &fakeNucleiService{})
+	scans := newTestScanService(t, repo, 0)
+
+	worker.Pause()
+	scan, err := scans.StartScan(context.Background(), model.StartScanInput{Target: "http://ok.example.com"})
+	if err != nil {
+		t.Fatalf("StartScan() error = %v", err)
+	}
+	if err := worker.processPendingScans(context.Background()); err != nil {
+		t.Fatalf("processPendingScans() while paused error = %v", err)
+	}
+	if got := repo.status(scan.ID); got != model.ScanStatusPending {
+		t.Errorf("scan status while paused = %q, want %q", got, model.ScanStatusPending)
+	}
+	if status := worker.Status(); !status.Paused || status.LastRun != nil {
+		t.Errorf("Status() while paused = %+v, want paused and never run", status)
+	}
+
+	worker.Resume()
+	if err := worker.processPendingScans(context.Background()); err != nil {
+		t.Fatalf("processPendingScans() after resuming error = %v", err)
+	}
+	if got := repo.status(scan.ID); got != model.ScanStatusCompleted {
+		t.Errorf("scan status after resuming = %q, want %q", got, model.ScanStatusCompleted)
+	}
+	if status := worker.Status(); status.Paused || status.LastRun == nil || status.ActiveScans != 0 {
+		t.Errorf("Status() after resuming = %+v, want running with a last run and no active scans", status)
+	}
+}
+
+func TestScanWorkerTruncatesStoredHTTP(t *testing.T) {
+	repo := &fakeScanRepository{scans: []*model.Scan{pendingScan("large", "http://ok.example.com")}}
+	nuclei := &fakeNucleiService{results: []*model.ScanResult{{
+		TemplateID: "tech-detect",
+		Request:    "GET / HTTP/1.1\r\n\r\n",
+		Response:   strings.Repeat("a", 1<<20),
+	}}}
+	cfg := &config.Config{}
+	cfg.Worker.MaxConcurrency = 1
+	cfg.Nuclei.MaxHTTPStorageBytes = 64 << 10
+	worker := NewScanWorker(repo, nuclei, cfg, zap.NewNop())
+
+	if err := worker.processPendingScans(context.Background()); err != nil {
+		t.Fatalf("processPendingScans() error = %v", err)
+	}
+
+	stored := repo.results["large"]
+	if len(stored) != 1 {
+		t.Fatalf("stored %d results, want 1", len(stored))
+	}
+	if want := strings.Repeat("a", 64<<10) + "[truncated]"; stored[0].Response != want {
+		t.Errorf("stored response has %d bytes, want the %d byte limit and the marker", len(stored[0].Response), 64<<10)
+	}
+	if stored[0].Request != "GET / HTTP/1.1\r\n\r\n" {
+		t.Errorf("stored request = %q, want it unchanged", stored[0].Request)
+	}
+}
+
+// distinctResults returns n results with distinct template IDs
+func distinctResults(n int) []*model.ScanResult {
+	results := make([]*model.ScanResult, n)
+	for i := range results {
+		results[i] = &model.ScanResult{TemplateID: fmt.Sprintf("template-%d", i), Severity: "info", Matched: true}
+	}
+	return results
+}
+
+func TestScanWorkerStoresResultsInBatches(t *testing.T) {
+	repo := &fakeScanRepository{scans: []*model.Scan{pendingScan("many", "http://ok.example.com")}}
+	worker := newTestWorker(repo, &fakeNucleiService{results: distinctResults(2*resultFlushSize + 1)})
+
+	if err := worker.processPendingScans(context.Background()); err != nil {
+		t.Fatalf("processPendingScans() error = %v", err)
+	}
+
+	// The last result is stored with the completed status
+	if want := []int{resultFlushSize, resultFlushSize}; !slices.Equal(repo.batches, want) {
+		t.Errorf("batches stored while scanning = %v, want %v", repo.batches, want)
+	}
+	if got := len(repo.results["many"]); got != 2*resultFlushSize+1 {
+		t.Errorf("stored %d results, want %d", got, 2*resultFlushSize+1)
+	}
+	if got := repo.status("many"); got != model.ScanStatusCompleted {
+		t.Errorf("scan status = %q, want %q", got, model.ScanStatusCompleted)
+	}
+}
+
+func TestScanWorkerCompletesInOneTransaction(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusErr   error
+		wantStatus  model.ScanStatus
+		wantBatches []int
+	}{
+		// The results are only stored by the committed transaction
+		{name: "completed", wantStatus: model.ScanStatusCompleted},
+		// The rolled back results are stored on their own for the failed scan
+		{name: "status update fails", statusErr: errors.New("connection reset"), wantStatus: model.ScanStatusFailed, wantBatches: []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeScanRepository{
+				scans:      []*model.Scan{pendingScan("scan-1", "http://ok.example.com")},
+				statusErrs: map[string]error{"scan-1:" + model.ScanStatusCompleted: tt.statusErr},
+			}
+			worker := newTestWorker(repo, &fakeNucleiService{results: distinctResults(2)})
+
+			if err := worker.processPendingScans(context.Background()); err != nil {
+				t.Fatalf("processPendingScans() error = %v", err)
+			}
+
+			if got := repo.status("scan-1"); got != tt.wantStatus {
+				t.Errorf("scan status = %q, want %q", got, tt.wantStatus)
+			}
+			if !slices.Equal(repo.batches, tt.wantBatches) {
+				t.Errorf("batches stored outside the transaction = %v, want %v", repo.batches, tt.wantBatches)
+			}
+			if got := len(repo.results["scan-1"]); got != 2 {
+				t.Errorf("stored %d results, want 2", got)
+			}
+			if len(repo.txs) != 0 {
+				t.Errorf("%d transactions left open", len(repo.txs))
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// staleScanMessage is recorded on scans failed by the reaper
+const staleScanMessage = "scan stalled"
+
+// StaleScansReaper fails running scans whose worker stopped sending
+// heartbeats, such as scans left behind by a crashed process
+type StaleScansReaper struct {
+	scanRepo          repository.ScanRepository
+	logger            *zap.Logger
+	heartbeatInterval time.Duration
+}
+
+// NewStaleScansReaper creates a new stale scans reaper
+func NewStaleScansReaper(scanRepo repository.ScanRepository, cfg *config.Config, logger *zap.Logger) *StaleScansReaper {
+	heartbeatInterval := cfg.Worker.HeartbeatInterval
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = defaultHeartbeatInterval
+	}
+
+	return &StaleScansReaper{
+		scanRepo:          scanRepo,
+		logger:            logger,
+		heartbeatInterval: heartbeatInterval,
+	}
+}
+
+// Start reaps stalled scans every heartbeat interval until ctx is cancelled,
+// so scans whose heartbeats were still fresh when the process started are
+// reaped once they go stale
+func (r *StaleScansReaper) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.heartbeatInterval)
+	defer ticker.Stop()
+
+	r.logger.Info("Starting stale scans reaper", zap.Duration("interval", r.heartbeatInterval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Stopping stale scans reaper")
+			return
+		case <-ticker.C:
+			if err := r.Run(ctx); err != nil {
+				r.logger.Error("Failed to reap stalled scans", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Run marks running scans that missed two heartbeats as failed
+func (r *StaleScansReaper) Run(ctx context.Context) error {
+	before := time.Now().Add(-2 * r.heartbeatInterval)
+	count, err := r.scanRepo.FailStaleScans(ctx, before, staleScanMessage)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		r.logger.Warn("Failed stalled scans",
+			zap.Int("count", count),
+			zap.Time("last_heartbeat_before", before),
+		)
+	}
+	return nil
+}
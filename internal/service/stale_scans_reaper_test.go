@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+)
+
+// fakeHeartbeatRepository keeps the last heartbeat of running scans and
+// fails those whose heartbeat is older than the cutoff. Methods a test does
+// not use panic through the nil embedded interface
+type fakeHeartbeatRepository struct {
+	repository.ScanRepository
+
+	mu         sync.Mutex
+	heartbeats map[string]time.Time
+	statuses   map[string]model.ScanStatus
+}
+
+// FailStaleScans fails the running scans whose heartbeat is before before
+func (f *fakeHeartbeatRepository) FailStaleScans(ctx context.Context, before time.Time, message string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := 0
+	for id, heartbeat := range f.heartbeats {
+		if f.statuses[id] == model.ScanStatusRunning && heartbeat.Before(before) {
+			f.statuses[id] = model.ScanStatusFailed
+			count++
+		}
+	}
+	return count, nil
+}
+
+// status returns the status of the scan
+func (f *fakeHeartbeatRepository) status(id string) model.ScanStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.statuses[id]
+}
+
+func TestStaleScansReaperReapsScansThatGoStaleAfterStartup(t *testing.T) {
+	// A process restarted right after the previous one died, so the scan it
+	// left running still has a fresh heartbeat
+	repo := &fakeHeartbeatRepository{
+		heartbeats: map[string]time.Time{"orphaned": time.Now()},
+		statuses:   map[string]model.ScanStatus{"orphaned": model.ScanStatusRunning},
+	}
+	cfg := &config.Config{}
+	cfg.Worker.HeartbeatInterval = 20 * time.Millisecond
+	reaper := NewStaleScansReaper(repo, cfg, zap.NewNop())
+
+	if err := reaper.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := repo.status("orphaned"); got != model.ScanStatusRunning {
+		t.Fatalf("scan status after startup = %q, want %q", got, model.ScanStatusRunning)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		reaper.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for repo.status("orphaned") != model.ScanStatusFailed {
+		if time.Now().After(deadline) {
+			t.Fatal("the reaper did not fail the scan once its heartbeat went stale")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
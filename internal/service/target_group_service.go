@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// targetGroupService implements the TargetGroupService interface
+type targetGroupService struct {
+	repo   repository.TargetGroupRepository
+	cfg    *config.Config
+	logger *zap.Logger
+}
+
+// NewTargetGroupService creates a new target group service
+func NewTargetGroupService(repo repository.TargetGroupRepository, cfg *config.Config, logger *zap.Logger) TargetGroupService {
+	return &targetGroupService{
+		repo:   repo,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// ListTargetGroups returns all target groups
+func (s *targetGroupService) ListTargetGroups(ctx context.Context) ([]*model.TargetGroup, error) {
+	s.logger.Info("Listing target groups")
+
+	groups, err := s.repo.List(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list target groups from repository", zap.Error(err))
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// GetTargetGroup returns a target group by ID
+func (s *targetGroupService) GetTargetGroup(ctx context.Context, id string) (*model.TargetGroup, error) {
+	s.logger.Info("Getting target group", zap.String("id", id))
+
+	group, err := s.repo.Get(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get target group from repository", zap.Error(err), zap.String("id", id))
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// CreateTargetGroup validates and stores a new target group
+func (s *targetGroupService) CreateTargetGroup(ctx context.Context, input model.CreateTargetGroupInput) (*model.TargetGroup, error) {
+	s.logger.Info("Creating target group",
+		zap.String("name", input.Name),
+		zap.Int("targets", len(input.Targets)))
+
+	// Validate input
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidTargetGroup)
+	}
+	if len(input.Targets) == 0 {
+		return nil, fmt.Errorf("%w: at least one target is required", ErrInvalidTargetGroup)
+	}
+	for _, target := range input.Targets {
+		if err := model.ValidateTarget(target); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTargetGroup, err)
+		}
+	}
+
+	// Create target group
+	now := time.Now()
+	group := &model.TargetGroup{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Targets:   input.Targets,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// Save target group
+	if err := s.repo.Create(ctx, group); err != nil {
+		s.logger.Error("Failed to create target group in repository", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Created target group in repository", zap.String("id", group.ID))
+	return group, nil
+}
+
+// DeleteTargetGroup deletes a target group by ID
+func (s *targetGroupService) DeleteTargetGroup(ctx context.Context, id string) error {
+	s.logger.Info("Deleting target group", zap.String("id", id))
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		s.logger.Error("Failed to delete target group from repository", zap.Error(err), zap.String("id", id))
+		return err
+	}
+
+	return nil
+}
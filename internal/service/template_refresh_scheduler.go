@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"nuclei-service-demo/internal/config"
+	"nuclei-service-demo/internal/model"
+
+	"go.uber.org/zap"
+)
+
+// TemplateRefreshScheduler periodically reloads templates from disk and
+// serializes manual refreshes with the scheduled ones
+type TemplateRefreshScheduler struct {
+	templateSvc TemplateService
+	logger      *zap.Logger
+	interval    time.Duration
+
+	// mu guards status, including whether a refresh is running
+	mu     sync.Mutex
+	status model.TemplateRefreshStatus
+}
+
+// NewTemplateRefreshScheduler creates a new template refresh scheduler
+func NewTemplateRefreshScheduler(templateSvc TemplateService, cfg *config.Config, logger *zap.Logger) *TemplateRefreshScheduler {
+	return &TemplateRefreshScheduler{
+		templateSvc: templateSvc,
+		logger:      logger,
+		interval:    cfg.Nuclei.TemplateRefreshInterval,
+	}
+}
+
+// Start runs scheduled refreshes until ctx is cancelled; a non-positive
+// interval disables scheduling
+func (s *TemplateRefreshScheduler) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		s.logger.Info("Template auto-refresh disabled")
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.logger.Info("Starting template refresh scheduler", zap.Duration("interval", s.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Stopping template refresh scheduler")
+			return
+		case <-ticker.C:
+			if _, err := s.Refresh(ctx); err != nil {
+				s.logger.Error("Scheduled template refresh failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Refresh reloads templates now, returning ErrRefreshInProgress if another
+// refresh has not finished yet
+func (s *TemplateRefreshScheduler) Refresh(ctx context.Context) (*model.RefreshResult, error) {
+	s.mu.Lock()
+	if s.status.Running {
+		s.mu.Unlock()
+		s.logger.Info("Skipping template refresh, one is already running")
+		return nil, ErrRefreshInProgress
+	}
+	s.status.Running = true
+	s.mu.Unlock()
+
+	start := time.Now()
+	s.logger.Info("Template refresh started")
+
+	result, err := s.templateSvc.Refresh(ctx)
+	duration := time.Since(start)
+
+	// Count what was loaded, even after a partial failure
+	count := 0
+	if stats, statsErr := s.templateSvc.GetTemplateStats(ctx); statsErr != nil {
+		s.logger.Warn("Failed to count templates after refresh", zap.Error(statsErr))
+	} else {
+		count = stats.Total
+	}
+
+	s.mu.Lock()
+	s.status.Running = false
+	s.status.LastRefreshAt = &start
+	s.status.LastDurationMs = duration.Milliseconds()
+	s.status.TemplateCount = count
+	s.status.LastResult = result
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	s.mu.Unlock()
+
+	fields := []zap.Field{
+		zap.Duration("duration", duration),
+		zap.Int("templates", count),
+		zap.Error(err),
+	}
+	if result != nil {
+		fields = append(fields,
+			zap.Int("loaded", result.Loaded),
+			zap.Int("unchanged", result.Unchanged),
+			zap.Int("removed", result.Removed),
+			zap.Int("failed", result.Failed),
+		)
+	}
+	s.logger.Info("Template refresh finished", fields...)
+	return result, err
+}
+
+// Status returns the state of the current or most recent refresh
+func (s *TemplateRefreshScheduler) Status() model.TemplateRefreshStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
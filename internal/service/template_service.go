@@ -1,11 +1,17 @@
 package service
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"nuclei-service-demo/internal/config"
 	"nuclei-service-demo/internal/model"
@@ -31,28 +37,45 @@ func NewTemplateService(repo repository.TemplateRepository, cfg *config.Config,
 	}
 }
 
-// List returns a list of templates
-func (s *templateService) List(ctx context.Context, tags, author, severity, templateType *string) ([]model.Template, error) {
+// List returns a page of templates
+func (s *templateService) List(ctx context.Context, tags, author, severity, templateType *string, page model.Page) (*model.ListResponse[model.Template], error) {
 	s.logger.Info("Listing templates",
 		zap.String("tags", safePtr(tags)),
 		zap.String("author", safePtr(author)),
 		zap.String("severity", safePtr(severity)),
-		zap.String("type", safePtr(templateType)))
+		zap.String("type", safePtr(templateType)),
+		zap.Int("limit", page.Limit),
+		zap.Int("offset", page.Offset))
 
-	templates, err := s.repo.List(ctx, tags, author, severity, templateType)
+	total, err := s.repo.Count(ctx, tags, author, severity, templateType)
+	if err != nil {
+		s.logger.Error("Failed to count templates in repository", zap.Error(err))
+		return nil, err
+	}
+
+	templates, err := s.repo.List(ctx, tags, author, severity, templateType, page)
 	if err != nil {
 		s.logger.Error("Failed to list templates from repository", zap.Error(err))
 		return nil, err
 	}
 
-	s.logger.Info("Retrieved templates from repository", zap.Int("count", len(templates)))
+	s.logger.Info("Retrieved templates from repository",
+		zap.Int("count", len(templates)),
+		zap.Int("total", total))
 
 	// Convert to model.Template
 	result := make([]model.Template, len(templates))
 	for i, template := range templates {
 		result[i] = *template
 	}
-	return result, nil
+	return &model.ListResponse[model.Template]{
+		Data: result,
+		Meta: model.PageMeta{
+			Total:  total,
+			Limit:  page.Limit,
+			Offset: page.Offset,
+		},
+	}, nil
 }
 
 // Get returns a template by ID
@@ -69,39 +92,157 @@ func (s *templateService) Get(ctx context.Context, id string) (*model.Template,
 	return template, nil
 }
 
-// Refresh refreshes the template cache
-func (s *templateService) Refresh(ctx context.Context) error {
-	s.logger.Info("Starting template refresh")
+// minSearchQueryLength is the shortest query that is sent to the repository
+const minSearchQueryLength = 2
+
+// Search returns templates matching a full-text query
+func (s *templateService) Search(ctx context.Context, query string) ([]model.Template, error) {
+	s.logger.Info("Searching templates", zap.String("q", query))
+
+	query = strings.TrimSpace(query)
+	if len(query) < minSearchQueryLength {
+		return []model.Template{}, nil
+	}
+
+	templates, err := s.repo.Search(ctx, query)
+	if err != nil {
+		s.logger.Error("Failed to search templates in repository", zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Found templates in repository", zap.Int("count", len(templates)))
+
+	// Convert to model.Template
+	result := make([]model.Template, len(templates))
+	for i, template := range templates {
+		result[i] = *template
+	}
+	return result, nil
+}
+
+// maxRelatedTemplates caps how many related templates are returned
+const maxRelatedTemplates = 10
+
+// GetRelated returns up to maxRelatedTemplates templates sharing tags with the
+// template, those sharing the most tags first
+func (s *templateService) GetRelated(ctx context.Context, id string) ([]model.Template, error) {
+	s.logger.Info("Getting related templates", zap.String("id", id))
+
+	template, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(template.Tags) == 0 {
+		return []model.Template{}, nil
+	}
+
+	templates, err := s.repo.ListByTags(ctx, template.Tags, template.ID, maxRelatedTemplates)
+	if err != nil {
+		s.logger.Error("Failed to list related templates", zap.Error(err), zap.String("id", id))
+		return nil, err
+	}
+
+	// Convert to model.Template
+	result := make([]model.Template, len(templates))
+	for i, related := range templates {
+		result[i] = *related
+	}
+	return result, nil
+}
+
+// GetContent returns the raw YAML of a template by ID
+func (s *templateService) GetContent(ctx context.Context, id string) ([]byte, error) {
+	s.logger.Info("Getting template content", zap.String("id", id))
+
+	template, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The database record can be stale after the templates directory changes
+	data, err := os.ReadFile(template.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.logger.Warn("Template file not found", zap.String("id", id), zap.String("path", template.Path))
+			return nil, repository.ErrNotFound
+		}
+		s.logger.Error("Failed to read template file", zap.Error(err), zap.String("path", template.Path))
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	return data, nil
+}
+
+// GetTemplateStats returns aggregate template statistics
+func (s *templateService) GetTemplateStats(ctx context.Context) (*model.TemplateStats, error) {
+	s.logger.Info("Getting template statistics")
 
-	// First, clear the existing templates
-	if err := s.repo.Refresh(ctx); err != nil {
-		s.logger.Error("Failed to clear templates", zap.Error(err))
-		return fmt.Errorf("failed to clear templates: %w", err)
+	stats, err := s.repo.GetStats(ctx)
+	if err != nil {
+		s.logger.Error("Failed to get template statistics from repository", zap.Error(err))
+		return nil, err
 	}
-	s.logger.Info("Cleared existing templates")
+
+	return stats, nil
+}
+
+// maxRefreshFailureRatio is the share of template files that may fail to
+// load before Refresh reports an error
+const maxRefreshFailureRatio = 0.1
+
+// maxRefreshErrorMessages caps the error messages kept in a refresh result
+const maxRefreshErrorMessages = 100
+
+// Refresh reloads templates from disk, re-parsing only files modified since
+// the last refresh and removing templates whose files are gone
+func (s *templateService) Refresh(ctx context.Context) (*model.RefreshResult, error) {
+	s.logger.Info("Starting template refresh")
 
 	// Get and validate template directory
 	templatesDir := s.cfg.Nuclei.TemplatesDir
 
+	// Pull templates from git first when a repository is configured
+	if s.cfg.Nuclei.GitTemplatesURL != "" {
+		s.logger.Info("Syncing templates from git",
+			zap.String("url", s.cfg.Nuclei.GitTemplatesURL),
+			zap.String("branch", s.cfg.Nuclei.GitTemplatesBranch))
+		if err := gitSync(ctx, s.cfg); err != nil {
+			s.logger.Error("Failed to sync templates from git", zap.Error(err))
+			return nil, fmt.Errorf("failed to sync templates from git: %w", err)
+		}
+	}
+
 	// Check if directory exists
 	if stat, err := os.Stat(templatesDir); err != nil {
 		s.logger.Error("Templates directory not found", zap.String("dir", templatesDir), zap.Error(err))
-		return fmt.Errorf("templates directory not found: %w", err)
+		return nil, fmt.Errorf("templates directory not found: %w", err)
 	} else if !stat.IsDir() {
 		s.logger.Error("Templates path is not a directory", zap.String("dir", templatesDir))
-		return fmt.Errorf("templates path is not a directory: %s", templatesDir)
+		return nil, fmt.Errorf("templates path is not a directory: %s", templatesDir)
+	}
+
+	// Load stored modification times to skip unchanged files
+	modTimes, err := s.repo.ListModTimes(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list template modification times", zap.Error(err))
+		return nil, fmt.Errorf("failed to list template modification times: %w", err)
 	}
 
 	s.logger.Info("Starting to scan templates directory", zap.String("dir", templatesDir))
 
-	templateCount := 0
-	errorCount := 0
+	result := &model.RefreshResult{Errors: []string{}}
+	var errs []error
+	accessErrors := 0
+	paths := []string{}
+	// seen maps each template ID parsed in this refresh to its file
+	seen := make(map[string]string)
 
-	// Walk through template directory
-	err := filepath.Walk(templatesDir, func(path string, info os.FileInfo, err error) error {
+	// Walk through template directory, collecting per-file errors
+	err = filepath.Walk(templatesDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			s.logger.Error("Error accessing path", zap.String("path", path), zap.Error(err))
-			errorCount++
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			accessErrors++
 			return nil // Continue despite errors
 		}
 
@@ -116,6 +257,14 @@ func (s *templateService) Refresh(ctx context.Context) error {
 			s.logger.Info("Skipping non-yaml file", zap.String("path", path))
 			return nil
 		}
+		paths = append(paths, path)
+
+		// Skip files unchanged since the last refresh; Postgres stores microseconds
+		modTime := info.ModTime().UTC().Truncate(time.Microsecond)
+		if stored, ok := modTimes[path]; ok && stored.Equal(modTime) {
+			result.Unchanged++
+			return nil
+		}
 
 		s.logger.Info("Parsing template file", zap.String("path", path))
 
@@ -123,20 +272,36 @@ func (s *templateService) Refresh(ctx context.Context) error {
 		template, err := s.parseTemplateFile(path)
 		if err != nil {
 			s.logger.Warn("Failed to parse template file", zap.Error(err), zap.String("path", path))
-			errorCount++
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
 			return nil // Skip this file but continue with others
 		}
+		template.LastModifiedAt = modTime
+
+		// Keep the first file for an ID rather than letting files overwrite each other
+		if existing, err := s.findIDCollision(ctx, seen, template); err != nil {
+			s.logger.Error("Failed to check template ID collision", zap.Error(err), zap.String("path", path))
+			errs = append(errs, fmt.Errorf("%s: failed to check template ID: %w", path, err))
+			return nil
+		} else if existing != "" {
+			s.logger.Warn("Template ID collision, skipping file",
+				zap.String("id", template.ID),
+				zap.String("path", path),
+				zap.String("existing_path", existing))
+			errs = append(errs, fmt.Errorf("%s: template ID %q is already used by %s", path, template.ID, existing))
+			return nil
+		}
+		seen[template.ID] = path
 
 		// Save template
-		if err := s.repo.Create(ctx, template); err != nil {
+		if err := s.repo.UpsertTemplate(ctx, template); err != nil {
 			s.logger.Error("Failed to save template", zap.Error(err), zap.String("path", path))
-			errorCount++
+			errs = append(errs, fmt.Errorf("%s: failed to save template: %w", path, err))
 			return nil // Skip this file but continue with others
 		}
 
-		templateCount++
-		if templateCount%100 == 0 {
-			s.logger.Info("Processing templates", zap.Int("processed", templateCount))
+		result.Loaded++
+		if result.Loaded%100 == 0 {
+			s.logger.Info("Processing templates", zap.Int("processed", result.Loaded))
 		}
 
 		return nil
@@ -144,12 +309,280 @@ func (s *templateService) Refresh(ctx context.Context) error {
 
 	if err != nil {
 		s.logger.Error("Failed to walk template directory", zap.Error(err), zap.String("dir", templatesDir))
-		return fmt.Errorf("failed to walk template directory: %w", err)
+		return nil, fmt.Errorf("failed to walk template directory: %w", err)
+	}
+
+	// Remove templates whose files were deleted
+	removed, err := s.repo.DeleteMissing(ctx, paths)
+	if err != nil {
+		s.logger.Error("Failed to remove deleted templates", zap.Error(err))
+		return nil, fmt.Errorf("failed to remove deleted templates: %w", err)
+	}
+	result.Removed = removed
+
+	result.Failed = len(errs)
+	for _, err := range errs {
+		if len(result.Errors) == maxRefreshErrorMessages {
+			break
+		}
+		result.Errors = append(result.Errors, err.Error())
 	}
 
 	s.logger.Info("Template refresh completed",
-		zap.Int("totalProcessed", templateCount),
-		zap.Int("errors", errorCount))
+		zap.Int("loaded", result.Loaded),
+		zap.Int("unchanged", result.Unchanged),
+		zap.Int("removed", result.Removed),
+		zap.Int("failed", result.Failed))
+
+	// Tolerate a few broken templates, but not a mostly unreadable directory
+	if total := len(paths) + accessErrors; total > 0 && float64(result.Failed) > maxRefreshFailureRatio*float64(total) {
+		return result, fmt.Errorf("%d of %d template files failed to load: %w", result.Failed, total, errors.Join(errs...))
+	}
+
+	return result, nil
+}
+
+// findIDCollision returns the path of another file that already provides the
+// template's ID, either earlier in this refresh or in the database. A stored
+// file that no longer exists on disk is not a collision, since the template
+// was moved.
+func (s *templateService) findIDCollision(ctx context.Context, seen map[string]string, template *model.Template) (string, error) {
+	if existing, ok := seen[template.ID]; ok {
+		return existing, nil
+	}
+
+	existing, err := s.repo.CheckIDCollision(ctx, template.ID, template.Path)
+	if err != nil || existing == "" {
+		return "", err
+	}
+	if _, err := os.Stat(existing); err != nil {
+		return "", nil
+	}
+	return existing, nil
+}
+
+// Upload validates and stores an uploaded template
+func (s *templateService) Upload(ctx context.Context, filename string, data []byte) (*model.Template, error) {
+	s.logger.Info("Uploading template", zap.String("filename", filename), zap.Int("size", len(data)))
+
+	// Only accept YAML files
+	name := filepath.Base(filename)
+	if filepath.Ext(name) != ".yaml" {
+		return nil, fmt.Errorf("%w: template file must have a .yaml extension", ErrInvalidTemplate)
+	}
+
+	// Parse and validate template
+	path := filepath.Join(s.cfg.Nuclei.UploadDir, name)
+	template, err := s.parseTemplateData(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	if template.Name == "" {
+		return nil, fmt.Errorf("%w: missing info.name", ErrInvalidTemplate)
+	}
+
+	// Reject duplicates
+	if _, err := s.repo.Get(ctx, template.ID); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateExists, template.ID)
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		s.logger.Error("Failed to check existing template", zap.Error(err), zap.String("id", template.ID))
+		return nil, err
+	}
+
+	// Never overwrite another uploaded template's file
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%w: file %s already exists", ErrTemplateExists, name)
+	}
+
+	// Write template file
+	if err := os.MkdirAll(s.cfg.Nuclei.UploadDir, 0755); err != nil {
+		s.logger.Error("Failed to create upload directory", zap.Error(err), zap.String("dir", s.cfg.Nuclei.UploadDir))
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		s.logger.Error("Failed to write template file", zap.Error(err), zap.String("path", path))
+		return nil, fmt.Errorf("failed to write template file: %w", err)
+	}
+
+	// Save template
+	if err := s.repo.Create(ctx, template); err != nil {
+		s.logger.Error("Failed to save template", zap.Error(err), zap.String("id", template.ID))
+		os.Remove(path)
+		return nil, err
+	}
+
+	s.logger.Info("Uploaded template", zap.String("id", template.ID), zap.String("path", path))
+	return template, nil
+}
+
+// Validate checks template YAML for an id, info.name, info.severity,
+// info.author and at least one request section, reporting every problem
+// found. Nothing is stored
+func (s *templateService) Validate(ctx context.Context, data []byte) *model.TemplateValidation {
+	template, err := s.parseTemplateData("", data)
+	if err != nil {
+		return &model.TemplateValidation{Errors: []string{err.Error()}}
+	}
+
+	// The data parsed above, so it also parses as an untyped document
+	var raw map[string]interface{}
+	_ = yaml.Unmarshal(data, &raw)
+
+	var problems []string
+	if id, _ := raw["id"].(string); id == "" {
+		problems = append(problems, "missing field: id")
+	}
+	if template.Name == "" {
+		problems = append(problems, "missing field: info.name")
+	}
+	if template.Severity == "" {
+		problems = append(problems, "missing field: info.severity")
+	}
+	if template.Author == "" {
+		problems = append(problems, "missing field: info.author")
+	}
+	if !model.HasTemplateRequests(raw) {
+		problems = append(problems, "missing request section (http, dns, network, ...)")
+	}
+
+	return &model.TemplateValidation{Valid: len(problems) == 0, Errors: problems}
+}
+
+// Import extracts a ZIP archive to a temporary directory and uploads each YAML
+// template in it, recording per-file failures in the result
+func (s *templateService) Import(ctx context.Context, data []byte) (*model.ImportResult, error) {
+	s.logger.Info("Importing template archive", zap.Int("size", len(data)))
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	dir, err := os.MkdirTemp("", "template-import-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := s.extractArchive(archive, dir); err != nil {
+		return nil, err
+	}
+
+	result := &model.ImportResult{Errors: []string{}}
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err == nil {
+			_, err = s.Upload(ctx, rel, data)
+		}
+		if err != nil {
+			s.logger.Warn("Failed to import template", zap.Error(err), zap.String("path", rel))
+			result.Failed++
+			if len(result.Errors) < maxRefreshErrorMessages {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", rel, err))
+			}
+			return nil
+		}
+		result.Imported++
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to walk import directory", zap.Error(err))
+		return nil, fmt.Errorf("failed to walk import directory: %w", err)
+	}
+
+	s.logger.Info("Template import completed",
+		zap.Int("imported", result.Imported),
+		zap.Int("failed", result.Failed))
+	return result, nil
+}
+
+// ExportTemplates returns the YAML of every template matching the filter.
+// Templates whose files have disappeared since the last refresh are skipped
+func (s *templateService) ExportTemplates(ctx context.Context, filter model.TemplateFilter) ([]model.TemplateContent, error) {
+	s.logger.Info("Exporting templates",
+		zap.String("tags", safePtr(filter.Tags)),
+		zap.String("author", safePtr(filter.Author)),
+		zap.String("severity", safePtr(filter.Severity)),
+		zap.String("type", safePtr(filter.Type)))
+
+	templates, err := s.repo.List(ctx, filter.Tags, filter.Author, filter.Severity, filter.Type, model.Page{})
+	if err != nil {
+		s.logger.Error("Failed to list templates", zap.Error(err))
+		return nil, err
+	}
+
+	contents := make([]model.TemplateContent, 0, len(templates))
+	for _, template := range templates {
+		data, err := os.ReadFile(template.Path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				s.logger.Warn("Template file not found, skipping", zap.String("id", template.ID), zap.String("path", template.Path))
+				continue
+			}
+			s.logger.Error("Failed to read template file", zap.Error(err), zap.String("path", template.Path))
+			return nil, fmt.Errorf("failed to read template file: %w", err)
+		}
+		contents = append(contents, model.TemplateContent{ID: template.ID, Content: data})
+	}
+
+	s.logger.Info("Exported templates", zap.Int("count", len(contents)))
+	return contents, nil
+}
+
+// extractArchive writes the archive's YAML files under dir. Entries whose
+// names would escape dir (zip slip) or that exceed the template size limit
+// reject the whole archive
+func (s *templateService) extractArchive(archive *zip.Reader, dir string) error {
+	for _, file := range archive.File {
+		if !filepath.IsLocal(file.Name) {
+			return fmt.Errorf("%w: entry %q escapes the archive", ErrInvalidArchive, file.Name)
+		}
+		if !file.Mode().IsRegular() || filepath.Ext(file.Name) != ".yaml" {
+			continue
+		}
+
+		path := filepath.Join(dir, file.Name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create import directory: %w", err)
+		}
+		if err := s.extractFile(file, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractFile copies a single archive entry to path, reading no more than the
+// template size limit whatever size the entry claims
+func (s *templateService) extractFile(file *zip.File, path string) error {
+	src, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, file.Name, err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, s.cfg.Nuclei.MaxTemplateSize+1))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, file.Name, err)
+	}
+	if int64(len(data)) > s.cfg.Nuclei.MaxTemplateSize {
+		return fmt.Errorf("%w: %s exceeds the maximum template size", ErrInvalidArchive, file.Name)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write imported template: %w", err)
+	}
 	return nil
 }
 
@@ -161,15 +594,19 @@ func (s *templateService) parseTemplateFile(path string) (*model.Template, error
 		return nil, fmt.Errorf("failed to read template file: %w", err)
 	}
 
+	return s.parseTemplateData(path, data)
+}
+
+// parseTemplateData parses template YAML and extracts its metadata
+func (s *templateService) parseTemplateData(path string, data []byte) (*model.Template, error) {
 	// Parse YAML
 	var templateData struct {
 		ID   string `yaml:"id"`
 		Info struct {
-			Name        string   `yaml:"name"`
-			Description string   `yaml:"description"`
-			Severity    string   `yaml:"severity"`
-			Author      string   `yaml:"author"`
-			Tags        []string `yaml:"tags"`
+			Name        string `yaml:"name"`
+			Description string `yaml:"description"`
+			Severity    string `yaml:"severity"`
+			Author      string `yaml:"author"`
 		} `yaml:"info"`
 	}
 
@@ -177,6 +614,13 @@ func (s *templateService) parseTemplateFile(path string) (*model.Template, error
 		return nil, fmt.Errorf("failed to parse template YAML: %w", err)
 	}
 
+	// Detect type and tags from the untyped document, since tags may be a
+	// string or a list
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse template YAML: %w", err)
+	}
+
 	// Extract ID from file path if not specified
 	id := templateData.ID
 	if id == "" {
@@ -196,7 +640,8 @@ func (s *templateService) parseTemplateFile(path string) (*model.Template, error
 		Description: templateData.Info.Description,
 		Severity:    templateData.Info.Severity,
 		Author:      templateData.Info.Author,
-		Tags:        templateData.Info.Tags,
+		Tags:        model.TemplateTags(raw),
+		Type:        model.DetectTemplateType(raw),
 		Path:        path,
 	}, nil
 }
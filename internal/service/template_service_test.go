@@ -0,0 +1,371 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/model"
+	"nuclei-service-demo/internal/repository"
+)
+
+// fakeTemplateRepository stores upserted templates in memory for a refresh.
+// storedPaths holds the path of templates stored by an earlier refresh,
+// keyed by ID. Methods a test does not use panic through the nil embedded
+// interface
+type fakeTemplateRepository struct {
+	repository.TemplateRepository
+
+	templates   map[string]*model.Template
+	storedPaths map[string]string
+}
+
+// ListModTimes returns no modification times, so every file is parsed
+func (f *fakeTemplateRepository) ListModTimes(ctx context.Context) (map[string]time.Time, error) {
+	return map[string]time.Time{}, nil
+}
+
+// CheckIDCollision returns the stored path of the ID if it differs from path
+func (f *fakeTemplateRepository) CheckIDCollision(ctx context.Context, id, path string) (string, error) {
+	if stored := f.storedPaths[id]; stored != path {
+		return stored, nil
+	}
+	return "", nil
+}
+
+// UpsertTemplate stores the template by ID
+func (f *fakeTemplateRepository) UpsertTemplate(ctx context.Context, template *model.Template) error {
+	if f.templates == nil {
+		f.templates = make(map[string]*model.Template)
+	}
+	f.templates[template.ID] = template
+	return nil
+}
+
+// DeleteMissing removes nothing
+func (f *fakeTemplateRepository) DeleteMissing(ctx context.Context, paths []string) (int, error) {
+	return 0, nil
+}
+
+// Get returns the stored template
+func (f *fakeTemplateRepository) Get(ctx context.Context, id string) (*model.Template, error) {
+	template, ok := f.templates[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return template, nil
+}
+
+// Create stores the template by ID
+func (f *fakeTemplateRepository) Create(ctx context.Context, template *model.Template) error {
+	return f.UpsertTemplate(ctx, template)
+}
+
+// writeTemplates writes valid and broken template files to a new templates
+// directory and returns it
+func writeTemplates(t *testing.T, valid, broken int) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for i := 0; i < valid; i++ {
+		content := fmt.Sprintf("id: valid-%d\ninfo:\n  name: Valid %d\n  severity: info\n", i, i)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("valid-%d.yaml", i)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < broken; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("broken-%d.yaml", i)), []byte("id: [unclosed\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRefreshPartialFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		valid   int
+		broken  int
+		wantErr bool
+	}{
+		{name: "all valid", valid: 5},
+		{name: "one broken in twenty", valid: 19, broken: 1},
+		{name: "exactly ten percent broken", valid: 9, broken: 1},
+		{name: "over ten percent broken", valid: 8, broken: 2, wantErr: true},
+		{name: "all broken", broken: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestNucleiConfig(t)
+			cfg.Nuclei.TemplatesDir = writeTemplates(t, tt.valid, tt.broken)
+			repo := &fakeTemplateRepository{}
+			svc := NewTemplateService(repo, cfg, zap.NewNop())
+
+			result, err := svc.Refresh(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Refresh() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result == nil {
+				t.Fatal("Refresh() result = nil, want the partial result")
+			}
+			if result.Loaded != tt.valid || len(repo.templates) != tt.valid {
+				t.Errorf("Loaded = %d with %d stored, want %d", result.Loaded, len(repo.templates), tt.valid)
+			}
+			if result.Failed != tt.broken || len(result.Errors) != tt.broken {
+				t.Errorf("Failed = %d with %d errors, want %d", result.Failed, len(result.Errors), tt.broken)
+			}
+			for _, msg := range result.Errors {
+				if !strings.Contains(msg, "broken-") {
+					t.Errorf("error %q does not name the broken file", msg)
+				}
+			}
+			if err != nil && !strings.Contains(err.Error(), fmt.Sprintf("%d of %d", tt.broken, tt.valid+tt.broken)) {
+				t.Errorf("Refresh() error = %v, want it to count the failed files", err)
+			}
+		})
+	}
+}
+
+func TestRefreshDetectsTemplateType(t *testing.T) {
+	cfg := newTestNucleiConfig(t)
+	files := map[string]string{
+		"network.yaml": "id: open-port\ninfo:\n  name: Open port\nnetwork:\n  - host:\n      - \"{{Hostname}}\"\n",
+		"dns.yaml":     "id: dns-record\ninfo:\n  name: DNS record\ndns:\n  - name: \"{{FQDN}}\"\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(cfg.Nuclei.TemplatesDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := &fakeTemplateRepository{}
+	if _, err := NewTemplateService(repo, cfg, zap.NewNop()).Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	for id, want := range map[string]string{"open-port": "tcp", "dns-record": "dns"} {
+		if template := repo.templates[id]; template == nil || template.Type != want {
+			t.Errorf("template %s = %+v, want type %q", id, template, want)
+		}
+	}
+}
+
+// writeTemplateFile writes a template with the given ID to path under dir
+// and returns its full path
+func writeTemplateFile(t *testing.T, dir, path, id string) string {
+	t.Helper()
+
+	full := filepath.Join(dir, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := fmt.Sprintf("id: %s\ninfo:\n  name: %s\nhttp:\n  - method: GET\n", id, path)
+	if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return full
+}
+
+func TestRefreshTemplateIDCollisions(t *testing.T) {
+	cfg := newTestNucleiConfig(t)
+	dir := cfg.Nuclei.TemplatesDir
+	first := writeTemplateFile(t, dir, "a/login.yaml", "login-panel")
+	second := writeTemplateFile(t, dir, "b/login.yaml", "login-panel")
+	writeTemplateFile(t, dir, "c/owned.yaml", "owned-elsewhere")
+	writeTemplateFile(t, dir, "d/moved.yaml", "moved")
+	owner := writeTemplateFile(t, t.TempDir(), "owner.yaml", "owned-elsewhere")
+
+	repo := &fakeTemplateRepository{storedPaths: map[string]string{
+		// stored from a file that still exists, so it keeps the ID
+		"owned-elsewhere": owner,
+		// stored from a file that is gone, so the template moved
+		"moved": filepath.Join(dir, "old/moved.yaml"),
+	}}
+	result, err := NewTemplateService(repo, cfg, zap.NewNop()).Refresh(context.Background())
+	if err == nil {
+		t.Fatal("Refresh() error = nil, want the skipped files to exceed the failure ratio")
+	}
+
+	if template := repo.templates["login-panel"]; template == nil || template.Path != first {
+		t.Errorf("login-panel = %+v, want the first file %s", template, first)
+	}
+	if _, ok := repo.templates["owned-elsewhere"]; ok {
+		t.Error("owned-elsewhere was stored, want it skipped as its stored file still exists")
+	}
+	if template := repo.templates["moved"]; template == nil {
+		t.Error("moved was skipped, want it stored from its new path")
+	}
+
+	if result.Failed != 2 {
+		t.Fatalf("Failed = %d, want the two skipped files (errors %q)", result.Failed, result.Errors)
+	}
+	for _, want := range [][2]string{{second, first}, {filepath.Join(dir, "c/owned.yaml"), owner}} {
+		found := false
+		for _, msg := range result.Errors {
+			found = found || (strings.Contains(msg, want[0]) && strings.Contains(msg, want[1]))
+		}
+		if !found {
+			t.Errorf("errors %q do not name %s and %s", result.Errors, want[0], want[1])
+		}
+	}
+}
+
+// zipArchive returns a ZIP archive holding files, keyed by entry name
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("zip Create(%s) error = %v", name, err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatalf("zip Write(%s) error = %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("zip Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestImportTemplates(t *testing.T) {
+	valid := "id: %s\ninfo:\n  name: Imported\n  severity: info\n"
+	tests := []struct {
+		name         string
+		archive      func(t *testing.T) []byte
+		wantErr      error
+		wantImported int
+		wantFailed   int
+	}{
+		{
+			name: "templates in nested directories",
+			archive: func(t *testing.T) []byte {
+				return zipArchive(t, map[string]string{
+					"cves/a.yaml":    fmt.Sprintf(valid, "imported-a"),
+					"misc/b.yaml":    fmt.Sprintf(valid, "imported-b"),
+					"misc/bad.yaml":  "id: [unclosed\n",
+					"README.md":      "not a template",
+					"misc/notes.txt": "skipped",
+				})
+			},
+			wantImported: 2,
+			wantFailed:   1,
+		},
+		{
+			name: "zip slip",
+			archive: func(t *testing.T) []byte {
+				return zipArchive(t, map[string]string{"../escape.yaml": fmt.Sprintf(valid, "escape")})
+			},
+			wantErr: ErrInvalidArchive,
+		},
+		{
+			name: "template over the size limit",
+			archive: func(t *testing.T) []byte {
+				return zipArchive(t, map[string]string{"big.yaml": fmt.Sprintf(valid, "big") + strings.Repeat("#", 1<<10)})
+			},
+			wantErr: ErrInvalidArchive,
+		},
+		{
+			name:    "not a zip archive",
+			archive: func(t *testing.T) []byte { return []byte("id: not-a-zip\n") },
+			wantErr: ErrInvalidArchive,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestNucleiConfig(t)
+			cfg.Nuclei.UploadDir = t.TempDir()
+			cfg.Nuclei.MaxTemplateSize = 512
+			repo := &fakeTemplateRepository{}
+			svc := NewTemplateService(repo, cfg, zap.NewNop())
+
+			result, err := svc.Import(context.Background(), tt.archive(t))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Import() error = %v, want %v", err, tt.wantErr)
+				}
+				if len(repo.templates) != 0 {
+					t.Errorf("stored %d templates from a rejected archive, want none", len(repo.templates))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			if result.Imported != tt.wantImported || result.Failed != tt.wantFailed || len(result.Errors) != tt.wantFailed {
+				t.Errorf("Import() = %+v, want %d imported and %d failed", result, tt.wantImported, tt.wantFailed)
+			}
+			for id, template := range repo.templates {
+				if filepath.Dir(template.Path) != filepath.Clean(cfg.Nuclei.UploadDir) {
+					t.Errorf("template %s stored at %s, want it in the upload directory", id, template.Path)
+				}
+				if _, err := os.Stat(template.Path); err != nil {
+					t.Errorf("template %s file: %v", id, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name       string
+		yaml       string
+		wantValid  bool
+		wantErrors []string
+	}{
+		{
+			name:      "valid",
+			yaml:      "id: valid\ninfo:\n  name: Valid\n  author: tester\n  severity: info\nhttp:\n  - method: GET\n    path:\n      - \"{{BaseURL}}\"\n",
+			wantValid: true,
+		},
+		{
+			name:       "partially invalid",
+			yaml:       "id: partial\ninfo:\n  name: Partial\n  author: tester\n",
+			wantErrors: []string{"missing field: info.severity", "missing request section (http, dns, network, ...)"},
+		},
+		{
+			name:       "missing id and info",
+			yaml:       "http:\n  - method: GET\n",
+			wantErrors: []string{"missing field: id", "missing field: info.name", "missing field: info.severity", "missing field: info.author"},
+		},
+		{
+			name: "malformed YAML",
+			yaml: "id: [unclosed\ninfo: {\n",
+		},
+	}
+	svc := NewTemplateService(&fakeTemplateRepository{}, newTestNucleiConfig(t), zap.NewNop())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := svc.Validate(context.Background(), []byte(tt.yaml))
+			if result.Valid != tt.wantValid {
+				t.Errorf("Valid = %v, want %v (errors %v)", result.Valid, tt.wantValid, result.Errors)
+			}
+			if tt.wantValid {
+				if len(result.Errors) != 0 {
+					t.Errorf("Errors = %v, want none", result.Errors)
+				}
+				return
+			}
+			if len(result.Errors) == 0 {
+				t.Fatal("Errors is empty, want the problems found")
+			}
+			if tt.wantErrors != nil && !slices.Equal(result.Errors, tt.wantErrors) {
+				t.Errorf("Errors = %q, want %q", result.Errors, tt.wantErrors)
+			}
+		})
+	}
+}
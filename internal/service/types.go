@@ -2,31 +2,132 @@ package service
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	apperrors "nuclei-service-demo/internal/errors"
+	"nuclei-service-demo/internal/export"
 	"nuclei-service-demo/internal/model"
 )
 
+// Common errors. They are typed apperrors values, so handlers report them
+// through their error code
+var (
+	// ErrInvalidTemplate is returned when a template fails validation
+	ErrInvalidTemplate = &apperrors.Error{Code: apperrors.CodeInvalidInput, Message: "invalid template"}
+	// ErrTemplateExists is returned when a template with the same ID already exists
+	ErrTemplateExists = &apperrors.Error{Code: apperrors.CodeConflict, Message: "template already exists"}
+	// ErrInvalidArchive is returned when a template import archive cannot be read
+	ErrInvalidArchive = &apperrors.Error{Code: apperrors.CodeInvalidInput, Message: "invalid template archive"}
+	// ErrRefreshInProgress is returned when a template refresh is already running
+	ErrRefreshInProgress = &apperrors.Error{Code: apperrors.CodeConflict, Message: "template refresh already in progress"}
+	// ErrInvalidTargetGroup is returned when a target group fails validation
+	ErrInvalidTargetGroup = &apperrors.Error{Code: apperrors.CodeInvalidInput, Message: "invalid target group"}
+	// ErrInvalidProfile is returned when a scan profile fails validation
+	ErrInvalidProfile = &apperrors.Error{Code: apperrors.CodeInvalidInput, Message: "invalid scan profile"}
+	// ErrProfileNotFound is returned when a scan references a missing profile
+	ErrProfileNotFound = &apperrors.Error{Code: apperrors.CodeNotFound, Message: "scan profile not found"}
+	// ErrInvalidAPIKey is returned when API key input fails validation
+	ErrInvalidAPIKey = &apperrors.Error{Code: apperrors.CodeInvalidInput, Message: "invalid API key"}
+	// ErrNoScanLog is returned when a scan has no verbose log
+	ErrNoScanLog = &apperrors.Error{Code: apperrors.CodeNotFound, Message: "scan has no verbose log"}
+	// ErrUnauthorized is returned when a request carries a missing, unknown or expired API key
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
 // TemplateService defines the interface for template operations
 type TemplateService interface {
-	// List returns a list of templates
-	List(ctx context.Context, tags, author, severity, templateType *string) ([]model.Template, error)
+	// List returns a page of templates
+	List(ctx context.Context, tags, author, severity, templateType *string, page model.Page) (*model.ListResponse[model.Template], error)
 	// Get returns a template by ID
 	Get(ctx context.Context, id string) (*model.Template, error)
-	// Refresh refreshes the template cache
-	Refresh(ctx context.Context) error
+	// Search returns templates matching a full-text query
+	Search(ctx context.Context, query string) ([]model.Template, error)
+	// GetRelated returns templates sharing tags with the template, most shared first
+	GetRelated(ctx context.Context, id string) ([]model.Template, error)
+	// GetContent returns the raw YAML of a template by ID
+	GetContent(ctx context.Context, id string) ([]byte, error)
+	// Refresh reloads templates that changed on disk since the last refresh
+	Refresh(ctx context.Context) (*model.RefreshResult, error)
+	// Upload validates and stores an uploaded template
+	Upload(ctx context.Context, filename string, data []byte) (*model.Template, error)
+	// Validate checks template YAML for required fields without storing it
+	Validate(ctx context.Context, data []byte) *model.TemplateValidation
+	// Import uploads every template in a ZIP archive
+	Import(ctx context.Context, data []byte) (*model.ImportResult, error)
+	// ExportTemplates returns the YAML of every template matching the filter
+	ExportTemplates(ctx context.Context, filter model.TemplateFilter) ([]model.TemplateContent, error)
+	// GetTemplateStats returns aggregate template statistics
+	GetTemplateStats(ctx context.Context) (*model.TemplateStats, error)
 }
 
 // ScanService defines the interface for scan operations
 type ScanService interface {
-	// List returns a list of scans
-	ListScans(ctx context.Context, status, target, templateID *string) ([]model.Scan, error)
+	// ListScans returns a page of scans, newest first
+	ListScans(ctx context.Context, status, target, templateID *string, tags []string, page model.Page) (*model.ListResponse[model.Scan], error)
 	// Get returns a scan by ID
 	GetScan(ctx context.Context, id string) (*model.Scan, error)
 	// Start starts a new scan
 	StartScan(ctx context.Context, input model.StartScanInput) (*model.Scan, error)
+	// StartGroupScan starts one scan per target of the input's target group
+	StartGroupScan(ctx context.Context, input model.StartScanInput) ([]*model.Scan, error)
 	// Delete deletes a scan by ID
 	DeleteScan(ctx context.Context, id string) (bool, error)
+	// GetScanResults returns a page of scan results for a scan matching the filter
+	GetScanResults(ctx context.Context, scanID string, filter model.ResultFilter, page model.Page) (*model.ListResponse[*model.ScanResult], error)
+	// GetScanStats returns aggregate scan and result statistics
+	GetScanStats(ctx context.Context) (*model.ScanStats, error)
+	// CompareScans returns the findings that are new, resolved or common in scan id2 relative to scan id1
+	CompareScans(ctx context.Context, id1, id2 string) (*model.ScanComparison, error)
+	// GetScanLog returns the verbose log of a scan
+	GetScanLog(ctx context.Context, id string) (string, error)
+	// UpdateLabels merges labels into a scan's labels and returns the updated scan
+	UpdateLabels(ctx context.Context, id string, labels map[string]string) (*model.Scan, error)
+	// MarkResultFalsePositive marks or unmarks a scan result as a false positive
+	MarkResultFalsePositive(ctx context.Context, scanID, resultID string, fp bool, note string) error
+	// ExportResultsToElasticsearch indexes a scan's results, returning how many were indexed
+	ExportResultsToElasticsearch(ctx context.Context, scanID string, opts export.ESExportOptions) (int, error)
+}
+
+// TargetGroupService defines the interface for target group operations
+type TargetGroupService interface {
+	// ListTargetGroups returns all target groups
+	ListTargetGroups(ctx context.Context) ([]*model.TargetGroup, error)
+	// GetTargetGroup returns a target group by ID
+	GetTargetGroup(ctx context.Context, id string) (*model.TargetGroup, error)
+	// CreateTargetGroup validates and stores a new target group
+	CreateTargetGroup(ctx context.Context, input model.CreateTargetGroupInput) (*model.TargetGroup, error)
+	// DeleteTargetGroup deletes a target group by ID
+	DeleteTargetGroup(ctx context.Context, id string) error
+}
+
+// ProfileService defines the interface for scan profile operations
+type ProfileService interface {
+	// ListProfiles returns all scan profiles
+	ListProfiles(ctx context.Context) ([]*model.ScanProfile, error)
+	// GetProfile returns a scan profile by ID
+	GetProfile(ctx context.Context, id string) (*model.ScanProfile, error)
+	// CreateProfile validates and stores a new scan profile
+	CreateProfile(ctx context.Context, input model.ScanProfileInput) (*model.ScanProfile, error)
+	// UpdateProfile validates and replaces an existing scan profile
+	UpdateProfile(ctx context.Context, id string, input model.ScanProfileInput) (*model.ScanProfile, error)
+	// DeleteProfile deletes a scan profile by ID
+	DeleteProfile(ctx context.Context, id string) error
+}
+
+// APIKeyService defines the interface for API key operations
+type APIKeyService interface {
+	// ListAPIKeys returns all API keys stored in the database
+	ListAPIKeys(ctx context.Context) ([]*model.APIKey, error)
+	// CreateAPIKey generates and stores a new API key, returning its plaintext once
+	CreateAPIKey(ctx context.Context, input model.CreateAPIKeyInput) (*model.CreatedAPIKey, error)
+	// DeleteAPIKey deletes an API key by ID
+	DeleteAPIKey(ctx context.Context, id string) error
+	// Authenticate returns the unexpired API key matching the plaintext key
+	Authenticate(ctx context.Context, plaintext string) (*model.APIKey, error)
+	// AuthRequired reports whether requests must carry an API key, which is
+	// when API_KEY is configured or any key is stored
+	AuthRequired(ctx context.Context) (bool, error)
 }
 
 // NucleiService handles running nuclei scans
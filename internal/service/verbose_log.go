@@ -0,0 +1,107 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/gologger/levels"
+	"github.com/projectdiscovery/gologger/writer"
+)
+
+// maxVerboseLogBytes caps the nuclei output captured for a verbose scan
+const maxVerboseLogBytes = 10 << 20
+
+// verboseLogTruncated ends a captured log that reached maxVerboseLogBytes
+const verboseLogTruncated = "\n[log truncated]\n"
+
+// ansiEscape matches the color codes nuclei's log formatter adds
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// verboseLog collects the output of a nuclei engine in memory, keeping at
+// most maxVerboseLogBytes. It implements gologger's writer.Writer
+type verboseLog struct {
+	mu        sync.Mutex
+	buf       strings.Builder
+	truncated bool
+}
+
+// verboseLogs copies nuclei's log output to the logs of running verbose
+// scans. nuclei logs through gologger.DefaultLogger rather than a logger per
+// engine, so a log only holds a scan's own output when no other scan runs
+// alongside it; ScanService accepts verbose scans only when the worker runs
+// one scan at a time
+var verboseLogs = &verboseLogTee{
+	next: writer.NewCLI(),
+	logs: make(map[*verboseLog]struct{}),
+}
+
+// verboseLogTee is a writer.Writer passing output on to next and to every
+// registered verboseLog
+type verboseLogTee struct {
+	install sync.Once
+	next    writer.Writer
+
+	mu   sync.Mutex
+	logs map[*verboseLog]struct{}
+}
+
+// capture starts copying nuclei's log output to log, installing the tee as
+// the default logger's writer on first use. The returned function stops it
+func (t *verboseLogTee) capture(log *verboseLog) func() {
+	t.install.Do(func() { gologger.DefaultLogger.SetWriter(t) })
+
+	t.mu.Lock()
+	t.logs[log] = struct{}{}
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		delete(t.logs, log)
+		t.mu.Unlock()
+	}
+}
+
+// Write passes a log line on to the next writer and the registered logs
+func (t *verboseLogTee) Write(data []byte, level levels.Level) {
+	t.next.Write(data, level)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.logs) == 0 {
+		return
+	}
+	plain := ansiEscape.ReplaceAll(data, nil)
+	for log := range t.logs {
+		log.Write(plain, level)
+	}
+}
+
+// Write appends a log line, dropping output past maxVerboseLogBytes
+func (l *verboseLog) Write(data []byte, level levels.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.truncated {
+		return
+	}
+	// Leave room for the truncation notice within the cap
+	if remaining := maxVerboseLogBytes - len(verboseLogTruncated) - l.buf.Len(); len(data)+1 > remaining {
+		if remaining > 0 {
+			l.buf.Write(data[:min(len(data), remaining)])
+		}
+		l.buf.WriteString(verboseLogTruncated)
+		l.truncated = true
+		return
+	}
+	l.buf.Write(data)
+	l.buf.WriteByte('\n')
+}
+
+// String returns the captured output
+func (l *verboseLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
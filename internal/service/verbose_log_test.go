@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/gologger/levels"
+	nucleiLib "github.com/projectdiscovery/nuclei/v3/lib"
+	"go.uber.org/zap"
+
+	"nuclei-service-demo/internal/model"
+)
+
+// newFakeEngineService returns a nuclei service whose engines run execute
+func newFakeEngineService(t *testing.T, execute func(ctx context.Context) error) *nucleiService {
+	t.Helper()
+
+	svc := NewNucleiService(newTestNucleiConfig(t), zap.NewNop()).(*nucleiService)
+	svc.newEngine = func(context.Context, ...nucleiLib.NucleiSDKOptions) (nucleiEngine, error) {
+		return &fakeEngine{execute: execute}, nil
+	}
+	return svc
+}
+
+// noResults accepts and discards scan results
+func noResults(*model.ScanResult) error { return nil }
+
+func TestVerboseScanCapturesItsOutput(t *testing.T) {
+	svc := newFakeEngineService(t, func(context.Context) error {
+		gologger.Info().Msg("verbose scan probe")
+		gologger.Info().Msg("verbose scan finished")
+		return nil
+	})
+
+	scan := newTestScan(&model.ScanOptions{Verbose: true})
+	if err := svc.StartScan(context.Background(), scan, noResults); err != nil {
+		t.Fatalf("StartScan() error = %v", err)
+	}
+	for _, want := range []string{"verbose scan probe", "verbose scan finished"} {
+		if !strings.Contains(scan.VerboseLog, want) {
+			t.Errorf("verbose log = %q, want it to contain %q", scan.VerboseLog, want)
+		}
+	}
+}
+
+func TestScansRunWhileVerboseScanIsQueued(t *testing.T) {
+	ctx := context.Background()
+
+	// A scan is running when a verbose scan comes in
+	runningStarted, release := make(chan struct{}), make(chan struct{})
+	running := newFakeEngineService(t, func(context.Context) error {
+		close(runningStarted)
+		<-release
+		return nil
+	})
+	runningDone := make(chan error, 1)
+	go func() { runningDone <- running.StartScan(ctx, newTestScan(nil), noResults) }()
+	<-runningStarted
+
+	verbose := newFakeEngineService(t, func(context.Context) error { return nil })
+	verboseDone := make(chan error, 1)
+	go func() {
+		verboseDone <- verbose.StartScan(ctx, newTestScan(&model.ScanOptions{Verbose: true}), noResults)
+	}()
+	// Give the verbose scan time to wait for the running one, as it used to
+	time.Sleep(50 * time.Millisecond)
+
+	// Neither a later scan nor a dry run waits for it
+	later := newFakeEngineService(t, func(context.Context) error { return nil })
+	dryRun := newTestScan(&model.ScanOptions{DryRun: true, Verbose: true})
+	for name, start := range map[string]func() error{
+		"scan":    func() error { return later.StartScan(ctx, newTestScan(nil), noResults) },
+		"dry run": func() error { return later.StartScan(ctx, dryRun, noResults) },
+	} {
+		done := make(chan error, 1)
+		go func() { done <- start() }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("%s StartScan() error = %v", name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s did not run while a verbose scan was queued", name)
+		}
+	}
+	if dryRun.VerboseLog != "" {
+		t.Errorf("dry run VerboseLog = %q, want none", dryRun.VerboseLog)
+	}
+
+	close(release)
+	if err := <-runningDone; err != nil {
+		t.Fatalf("running StartScan() error = %v", err)
+	}
+	if err := <-verboseDone; err != nil {
+		t.Fatalf("verbose StartScan() error = %v", err)
+	}
+}
+
+func TestScanWithoutVerboseHasNoLog(t *testing.T) {
+	svc := newFakeEngineService(t, func(context.Context) error {
+		gologger.Info().Msg("probe")
+		return nil
+	})
+
+	scan := newTestScan(nil)
+	if err := svc.StartScan(context.Background(), scan, noResults); err != nil {
+		t.Fatalf("StartScan() error = %v", err)
+	}
+	if scan.VerboseLog != "" {
+		t.Errorf("VerboseLog = %q, want none without the verbose option", scan.VerboseLog)
+	}
+}
+
+func TestVerboseLogTruncates(t *testing.T) {
+	log := &verboseLog{}
+	line := []byte(strings.Repeat("x", 1<<20))
+	for i := 0; i < 12; i++ {
+		log.Write(line, levels.LevelVerbose)
+	}
+
+	captured := log.String()
+	if len(captured) > maxVerboseLogBytes {
+		t.Errorf("captured %d bytes, want at most %d", len(captured), maxVerboseLogBytes)
+	}
+	if !strings.HasSuffix(captured, verboseLogTruncated) {
+		t.Errorf("captured log ends with %q, want the truncation notice", captured[len(captured)-32:])
+	}
+}
+
+func TestVerboseLogIsStoredAndServed(t *testing.T) {
+	repo := &fakeScanRepository{scans: []*model.Scan{
+		pendingScan("verbose", "http://ok.example.com"),
+		pendingScan("quiet", "http://ok.example.com"),
+	}}
+	repo.scans[0].Options = &model.ScanOptions{Verbose: true}
+	nuclei := &fakeNucleiService{verboseLog: "[INF] probing http://ok.example.com\n"}
+	worker := newTestWorker(repo, nuclei)
+
+	if err := worker.processPendingScans(context.Background()); err != nil {
+		t.Fatalf("processPendingScans() error = %v", err)
+	}
+
+	scans := NewScanService(repo, nil, nil, nil, nuclei, newTestNucleiConfig(t), zap.NewNop())
+	log, err := scans.GetScanLog(context.Background(), "verbose")
+	if err != nil {
+		t.Fatalf("GetScanLog(verbose) error = %v", err)
+	}
+	if log != nuclei.verboseLog {
+		t.Errorf("GetScanLog(verbose) = %q, want %q", log, nuclei.verboseLog)
+	}
+	if _, err := scans.GetScanLog(context.Background(), "quiet"); !errors.Is(err, ErrNoScanLog) {
+		t.Errorf("GetScanLog(quiet) error = %v, want ErrNoScanLog", err)
+	}
+}
@@ -0,0 +1,9 @@
+-- Drop tables
+DROP TABLE IF EXISTS scan_results CASCADE;
+DROP TABLE IF EXISTS scan_templates CASCADE;
+DROP TABLE IF EXISTS scan_tags CASCADE;
+DROP TABLE IF EXISTS templates CASCADE;
+DROP TABLE IF EXISTS scans CASCADE;
+
+-- Drop enum for scan status
+DROP TYPE IF EXISTS scan_status CASCADE;
@@ -1,14 +1,6 @@
 -- Create extension for UUID generation
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
--- Drop existing tables if they exist
-DROP TABLE IF EXISTS scan_results CASCADE;
-DROP TABLE IF EXISTS scan_templates CASCADE;
-DROP TABLE IF EXISTS scan_tags CASCADE;
-DROP TABLE IF EXISTS templates CASCADE;
-DROP TABLE IF EXISTS scans CASCADE;
-DROP TYPE IF EXISTS scan_status CASCADE;
-
 -- Create enum for scan status
 CREATE TYPE scan_status AS ENUM ('pending', 'running', 'completed', 'failed', 'cancelled');
 
@@ -73,7 +65,8 @@ CREATE TABLE IF NOT EXISTS scan_results (
     response TEXT,
     metadata JSONB,
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_scan_results_finding UNIQUE (scan_id, template_id, host, matcher_name)
 );
 
 -- Create indexes
@@ -90,4 +83,4 @@ CREATE INDEX idx_templates_author ON templates (author);
 CREATE INDEX idx_scan_results_scan_id ON scan_results(scan_id);
 CREATE INDEX idx_scan_results_template_id ON scan_results(template_id);
 CREATE INDEX idx_scan_results_severity ON scan_results(severity);
-CREATE INDEX idx_scan_results_matched_at ON scan_results(matched_at); 
\ No newline at end of file
+CREATE INDEX idx_scan_results_matched_at ON scan_results(matched_at);
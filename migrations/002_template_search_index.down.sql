@@ -0,0 +1,2 @@
+-- Drop full-text search index for templates
+DROP INDEX IF EXISTS idx_templates_search;
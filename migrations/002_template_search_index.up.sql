@@ -0,0 +1,2 @@
+-- Create full-text search index for templates
+CREATE INDEX idx_templates_search ON templates USING GIN (to_tsvector('english', name || ' ' || description || ' ' || id));
@@ -0,0 +1,2 @@
+-- Drop template file modification times
+ALTER TABLE templates DROP COLUMN IF EXISTS last_modified_at;
@@ -0,0 +1,2 @@
+-- Track template file modification times for incremental refresh
+ALTER TABLE templates ADD COLUMN IF NOT EXISTS last_modified_at TIMESTAMP WITH TIME ZONE;
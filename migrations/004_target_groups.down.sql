@@ -0,0 +1,2 @@
+-- Drop target_groups table
+DROP TABLE IF EXISTS target_groups;
@@ -0,0 +1,2 @@
+-- Drop scan_profiles table
+DROP TABLE IF EXISTS scan_profiles;
@@ -0,0 +1,2 @@
+-- Drop targets column from scans
+ALTER TABLE scans DROP COLUMN IF EXISTS targets;
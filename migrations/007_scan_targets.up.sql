@@ -0,0 +1,5 @@
+-- Add targets column to scans
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS targets TEXT[] NOT NULL DEFAULT '{}';
+
+-- Backfill existing scans with their single target
+UPDATE scans SET targets = ARRAY[target] WHERE targets = '{}';
@@ -0,0 +1,3 @@
+-- Drop false positive columns from scan_results
+ALTER TABLE scan_results DROP COLUMN IF EXISTS false_positive_note;
+ALTER TABLE scan_results DROP COLUMN IF EXISTS is_false_positive;
@@ -0,0 +1,3 @@
+-- Allow scan results to be marked as false positives
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS is_false_positive BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE scan_results ADD COLUMN IF NOT EXISTS false_positive_note TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Drop workflow_ids column from scans
+ALTER TABLE scans DROP COLUMN IF EXISTS workflow_ids;
@@ -0,0 +1,2 @@
+-- Add workflow_ids column to scans
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS workflow_ids TEXT[] NOT NULL DEFAULT '{}';
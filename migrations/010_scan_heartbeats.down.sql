@@ -0,0 +1,2 @@
+-- Drop last_heartbeat_at column from scans
+ALTER TABLE scans DROP COLUMN IF EXISTS last_heartbeat_at;
@@ -0,0 +1,2 @@
+-- Add last_heartbeat_at column to scans so stalled scans can be detected
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS last_heartbeat_at TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+-- The tags column and its index belong to the initial schema, so there is
+-- nothing to revert here
+SELECT 1;
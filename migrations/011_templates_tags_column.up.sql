@@ -0,0 +1,3 @@
+-- Ensure templates carry a tags array that can be filtered on
+ALTER TABLE templates ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_templates_tags ON templates USING GIN (tags);
@@ -0,0 +1,3 @@
+-- Drop priority column from scans
+DROP INDEX IF EXISTS idx_scans_pending_priority;
+ALTER TABLE scans DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,3 @@
+-- Add priority column to scans so pending scans can be run highest priority first
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 1;
+CREATE INDEX IF NOT EXISTS idx_scans_pending_priority ON scans (priority DESC, created_at) WHERE status = 'pending';
@@ -0,0 +1,2 @@
+-- Drop labels column from scans
+ALTER TABLE scans DROP COLUMN IF EXISTS labels;
@@ -0,0 +1,2 @@
+-- Add labels column to scans for user-defined annotations such as project or ticket
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}'::jsonb;
@@ -0,0 +1,2 @@
+-- Drop result_summary column from scans
+ALTER TABLE scans DROP COLUMN IF EXISTS result_summary;
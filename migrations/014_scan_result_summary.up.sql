@@ -0,0 +1,2 @@
+-- Add result_summary column to scans holding result counts by severity
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS result_summary JSONB;
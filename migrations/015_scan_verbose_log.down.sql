@@ -0,0 +1,2 @@
+-- Drop verbose_log column from scans
+ALTER TABLE scans DROP COLUMN IF EXISTS verbose_log;
@@ -0,0 +1,2 @@
+-- Add verbose_log column to scans holding the nuclei output of verbose scans
+ALTER TABLE scans ADD COLUMN IF NOT EXISTS verbose_log TEXT;
@@ -1,7 +1,7 @@
 #!/usr/bin/env bash
 
 # Download templates
-git clone https://github.com/projectdiscovery/nuclei-templates.git templates
+git clone https://github.com/projectdiscovery/nuclei-templates.git templates/nuclei-templates
 
 # Start docker compose
 cd docker
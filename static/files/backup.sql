@@ -0,0 +1,3 @@
+-- Demo database dump exposed by the demo server's directory listing
+CREATE TABLE users (id SERIAL PRIMARY KEY, username TEXT, password TEXT);
+INSERT INTO users (username, password) VALUES ('admin', 'admin123');